@@ -19,6 +19,14 @@ type Config struct {
 	REFRESH_TOKEN_KEY    string
 	TOKEN_DURATION_SEC   int
 	REFRESH_TOKEN_SEC    int
+	// -- Lifetime of tokens issued to admins acting as another user
+	IMPERSONATION_TOKEN_SEC int
+
+	// -- Token signing
+	JWT_SIGNING_ALG            string
+	JWT_SIGNING_KEY_FILE       string
+	JWT_SIGNING_KEY_ID         string
+	JWT_VERIFICATION_KEY_FILES map[string]string
 
 	LOGGIN_DIR string
 
@@ -26,6 +34,21 @@ type Config struct {
 	VALKEY_ADDRESSES   []string
 	VALKEY_PWD         string
 	CACHE_DURATION_SEC int
+	// -- Stale responses are served this long after expiring while they're refreshed
+	CACHE_STALE_SEC int
+	// -- Responses cached in process, in front of Valkey or alone without it, 0 disables it
+	CACHE_LOCAL_ENTRIES int
+
+	// -- Principal cache, 0 disables it. Its invalidations are shared through
+	// -- Valkey, without Valkey they only reach the local instance and the other
+	// -- instances see them after PRINCIPAL_CACHE_SEC
+	PRINCIPAL_CACHE_SEC int
+
+	// -- Responses of create requests are replayed for their Idempotency-Key this long, 0 disables it
+	IDEMPOTENCY_KEY_SEC int
+
+	// -- Maximum limit of list requests
+	MAX_PAGE_SIZE int
 
 	// -- Trusted Proxies
 	TRUSTED_PROXIES []string
@@ -40,6 +63,19 @@ type Config struct {
 	// -- TLS
 	CERT_FILE string
 	KEY_FILE  string
+
+	// -- OpenID Connect
+	OIDC_ISSUER           string
+	OIDC_CLIENT_ID        string
+	OIDC_CLIENT_SECRET    string
+	OIDC_REDIRECT_URL     string
+	OIDC_SCOPES           []string
+	OIDC_ROLE_CLAIM       string
+	OIDC_ROLE_MAPPING     map[string]uint
+	OIDC_DEFAULT_ROLE_ID  uint
+	OIDC_JIT_PROVISIONING bool
+	// -- Company provisioned users are allowed to access
+	OIDC_DEFAULT_COMPANY_ID uint
 }
 
 var configInstance *Config
@@ -62,6 +98,51 @@ func GetConfigInstance() *Config {
 			fmt.Println("Error parsing CACHE_DURATION_SEC")
 		}
 
+		cacheStaleDuration := 30
+		if cStaleDuration := Env("CACHE_STALE_SEC"); cStaleDuration != "" {
+			cacheStaleDuration, err = strconv.Atoi(cStaleDuration)
+			if err != nil || cacheStaleDuration < 0 {
+				fmt.Println("Error parsing CACHE_STALE_SEC")
+				cacheStaleDuration = 0
+			}
+		}
+
+		cacheLocalEntries := 1000
+		if cLocalEntries := Env("CACHE_LOCAL_ENTRIES"); cLocalEntries != "" {
+			cacheLocalEntries, err = strconv.Atoi(cLocalEntries)
+			if err != nil || cacheLocalEntries < 0 {
+				fmt.Println("Error parsing CACHE_LOCAL_ENTRIES")
+				cacheLocalEntries = 0
+			}
+		}
+
+		idempotencyKeyDuration := 86400
+		if iKeyDuration := Env("IDEMPOTENCY_KEY_SEC"); iKeyDuration != "" {
+			idempotencyKeyDuration, err = strconv.Atoi(iKeyDuration)
+			if err != nil || idempotencyKeyDuration < 0 {
+				fmt.Println("Error parsing IDEMPOTENCY_KEY_SEC")
+				idempotencyKeyDuration = 0
+			}
+		}
+
+		principalCacheDuration := 30
+		if pCacheDuration := Env("PRINCIPAL_CACHE_SEC"); pCacheDuration != "" {
+			principalCacheDuration, err = strconv.Atoi(pCacheDuration)
+			if err != nil || principalCacheDuration < 0 {
+				fmt.Println("Error parsing PRINCIPAL_CACHE_SEC")
+				principalCacheDuration = 0
+			}
+		}
+
+		maxPageSize := 100
+		if mPageSize := Env("MAX_PAGE_SIZE"); mPageSize != "" {
+			maxPageSize, err = strconv.Atoi(mPageSize)
+			if err != nil || maxPageSize <= 0 {
+				fmt.Println("Error parsing MAX_PAGE_SIZE")
+				maxPageSize = 100
+			}
+		}
+
 		// -- Token Duration
 		tokenDuration, err := strconv.Atoi(Env("TOKEN_DURATION_SEC"))
 		if err != nil {
@@ -73,11 +154,24 @@ func GetConfigInstance() *Config {
 			fmt.Println("Error parsing REFRESH_TOKEN_SEC")
 		}
 
+		impersonationDuration := 900
+		if iDuration := Env("IMPERSONATION_TOKEN_SEC"); iDuration != "" {
+			impersonationDuration, err = strconv.Atoi(iDuration)
+			if err != nil || impersonationDuration <= 0 {
+				fmt.Println("Error parsing IMPERSONATION_TOKEN_SEC")
+				impersonationDuration = 900
+			}
+		}
+
 		// -- Trusted Proxies
 		proxies := Env("TRUSTED_PROXIES")
 		trustedProxies := []string{}
 		if proxies != "" {
-			trustedProxies = append(trustedProxies, proxies)
+			for _, proxy := range strings.Split(proxies, ",") {
+				if proxy = strings.TrimSpace(proxy); proxy != "" {
+					trustedProxies = append(trustedProxies, proxy)
+				}
+			}
 		}
 
 		// -- CORS
@@ -122,6 +216,90 @@ func GetConfigInstance() *Config {
 			}
 		}
 
+		// -- OpenID Connect
+		oScopes := Env("OIDC_SCOPES")
+		oidcScopes := []string{}
+		if oScopes == "" {
+			oidcScopes = append(oidcScopes, "openid", "email", "profile")
+		} else {
+			oidcScopes = append(oidcScopes, strings.Split(oScopes, ",")...)
+		}
+
+		oidcRoleClaim := Env("OIDC_ROLE_CLAIM")
+		if oidcRoleClaim == "" {
+			oidcRoleClaim = "groups"
+		}
+
+		// OIDC_ROLE_MAPPING = "claim_value:role_id,claim_value:role_id"
+		oidcRoleMapping := map[string]uint{}
+		if oMapping := Env("OIDC_ROLE_MAPPING"); oMapping != "" {
+			for _, pair := range strings.Split(oMapping, ",") {
+				index := strings.LastIndex(pair, ":")
+				if index == -1 {
+					fmt.Printf("Error parsing OIDC_ROLE_MAPPING entry %q\n", pair)
+					continue
+				}
+				roleId, err := strconv.Atoi(pair[index+1:])
+				if err != nil || roleId <= 0 {
+					fmt.Printf("Error parsing OIDC_ROLE_MAPPING entry %q\n", pair)
+					continue
+				}
+				oidcRoleMapping[pair[:index]] = uint(roleId)
+			}
+		}
+
+		oidcDefaultRoleId := 0
+		if oDefaultRoleId := Env("OIDC_DEFAULT_ROLE_ID"); oDefaultRoleId != "" {
+			oidcDefaultRoleId, err = strconv.Atoi(oDefaultRoleId)
+			if err != nil || oidcDefaultRoleId < 0 {
+				fmt.Println("Error parsing OIDC_DEFAULT_ROLE_ID")
+				oidcDefaultRoleId = 0
+			}
+		}
+
+		oidcJitProvisioning, err := strconv.ParseBool(Env("OIDC_JIT_PROVISIONING"))
+		if err != nil {
+			oidcJitProvisioning = false
+		}
+
+		oidcDefaultCompanyId := 1
+		if oDefaultCompanyId := Env("OIDC_DEFAULT_COMPANY_ID"); oDefaultCompanyId != "" {
+			oidcDefaultCompanyId, err = strconv.Atoi(oDefaultCompanyId)
+			if err != nil || oidcDefaultCompanyId <= 0 {
+				fmt.Println("Error parsing OIDC_DEFAULT_COMPANY_ID")
+				oidcDefaultCompanyId = 1
+			}
+		}
+
+		// -- Token signing
+		jwtSigningAlg := strings.ToUpper(Env("JWT_SIGNING_ALG"))
+		switch jwtSigningAlg {
+		case "":
+			jwtSigningAlg = "HS256"
+		case "EDDSA":
+			jwtSigningAlg = "EdDSA"
+		}
+
+		// -- With an asymmetric algorithm TOKEN_KEY only verifies the tokens issued
+		// -- before the switch
+		tokenKey := Env("TOKEN_KEY")
+		if jwtSigningAlg == "HS256" {
+			tokenKey = validateEnvString("TOKEN_KEY")
+		}
+
+		// JWT_VERIFICATION_KEY_FILES = "kid:path,kid:path"
+		jwtVerificationKeyFiles := map[string]string{}
+		if vFiles := Env("JWT_VERIFICATION_KEY_FILES"); vFiles != "" {
+			for _, pair := range strings.Split(vFiles, ",") {
+				kid, path, ok := strings.Cut(pair, ":")
+				if !ok || kid == "" || path == "" {
+					fmt.Printf("Error parsing JWT_VERIFICATION_KEY_FILES entry %q\n", pair)
+					continue
+				}
+				jwtVerificationKeyFiles[kid] = path
+			}
+		}
+
 		configInstance = &Config{
 			PORT: port,
 
@@ -132,16 +310,35 @@ func GetConfigInstance() *Config {
 			DB_CONNECTION_STRING: validateEnvString("DB_CONNECTION_STRING"),
 
 			// -- Valkey
-			VALKEY_ADDRESSES:   strings.Split(Env("VALKEY_ADDRESSES"), ","),
-			VALKEY_PWD:         Env("VALKEY_PWD"),
-			CACHE_DURATION_SEC: cacheDuration,
+			VALKEY_ADDRESSES:    strings.Split(Env("VALKEY_ADDRESSES"), ","),
+			VALKEY_PWD:          Env("VALKEY_PWD"),
+			CACHE_DURATION_SEC:  cacheDuration,
+			CACHE_STALE_SEC:     cacheStaleDuration,
+			CACHE_LOCAL_ENTRIES: cacheLocalEntries,
+
+			// -- Principal cache
+			PRINCIPAL_CACHE_SEC: principalCacheDuration,
+
+			// -- Idempotency
+			IDEMPOTENCY_KEY_SEC: idempotencyKeyDuration,
+
+			// -- Pagination
+			MAX_PAGE_SIZE: maxPageSize,
 
 			// -- Auth
-			TOKEN_KEY:          validateEnvString("TOKEN_KEY"),
+			TOKEN_KEY:          tokenKey,
 			REFRESH_TOKEN_KEY:  validateEnvString("REFRESH_TOKEN_KEY"),
 			TOKEN_DURATION_SEC: tokenDuration,
 			REFRESH_TOKEN_SEC:  refreshDuration,
 
+			IMPERSONATION_TOKEN_SEC: impersonationDuration,
+
+			// -- Token signing
+			JWT_SIGNING_ALG:            jwtSigningAlg,
+			JWT_SIGNING_KEY_FILE:       Env("JWT_SIGNING_KEY_FILE"),
+			JWT_SIGNING_KEY_ID:         Env("JWT_SIGNING_KEY_ID"),
+			JWT_VERIFICATION_KEY_FILES: jwtVerificationKeyFiles,
+
 			// -- Trusted Proxies
 			TRUSTED_PROXIES: trustedProxies,
 
@@ -155,6 +352,18 @@ func GetConfigInstance() *Config {
 			// -- TLS
 			CERT_FILE: Env("CERT_FILE"),
 			KEY_FILE:  Env("KEY_FILE"),
+
+			// -- OpenID Connect
+			OIDC_ISSUER:             strings.TrimSuffix(Env("OIDC_ISSUER"), "/"),
+			OIDC_CLIENT_ID:          Env("OIDC_CLIENT_ID"),
+			OIDC_CLIENT_SECRET:      Env("OIDC_CLIENT_SECRET"),
+			OIDC_REDIRECT_URL:       Env("OIDC_REDIRECT_URL"),
+			OIDC_SCOPES:             oidcScopes,
+			OIDC_ROLE_CLAIM:         oidcRoleClaim,
+			OIDC_ROLE_MAPPING:       oidcRoleMapping,
+			OIDC_DEFAULT_ROLE_ID:    uint(oidcDefaultRoleId),
+			OIDC_JIT_PROVISIONING:   oidcJitProvisioning,
+			OIDC_DEFAULT_COMPANY_ID: uint(oidcDefaultCompanyId),
 		}
 	}
 
@@ -3,7 +3,6 @@ package controllers
 import (
 	"database/sql"
 	"encoding/json"
-	"fmt"
 	"log"
 	"strings"
 
@@ -20,32 +19,75 @@ type AccountingHandler struct {
 }
 
 func (handler *AccountingHandler) Accounts(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	qp := utils.NewQueryParams().
 		PrepareFilters(c, accounting.AccountingAccountAllowFilterFieldsAndOps, `"accounting.account"`).
-		PrepareSorts(c, accounting.AccountingAccountAllowSortFields, `"accounting.account"`).
+		PrepareSorts(c, accounting.AccountingAccountAllowSortFields, `"accounting.account"`, accounting.AccountingAccountSortFields).
 		PreparePagination(c)
 
-	accounts, total, statusCode, err := handler.ServiceFacade.AccountingAccountService.Accounts(qp)
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	accounts, total, statusCode, err := handler.ServiceFacade.AccountingAccountService.Accounts(&ctx, qp)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
 	}
 
-	c.Header("X-Total-Count", fmt.Sprintf("%d", total))
+	qp.PaginationHeaders(c, total)
 	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
 		"accounts": accounts,
 	}))
 
-	c.Set("total", total)
 	if accountsByte, err := json.Marshal(accounts); err == nil {
 		c.Set("response", accountsByte)
 	}
 }
 
+func (handler *AccountingHandler) AggregateAccounts(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	qp := utils.NewQueryParams().
+		PrepareFilters(c, accounting.AccountingAccountAllowFilterFieldsAndOps, `"accounting.account"`).
+		PrepareAggregate(c, accounting.AccountingAccountAllowGroupFields, accounting.AccountingAccountAllowAggregateFields)
+
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	groups, statusCode, err := handler.ServiceFacade.AggregateService.Aggregate(&ctx, "accounting.account", qp)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
+		"groups": groups,
+	}))
+}
+
 func (handler *AccountingHandler) Account(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	id := c.Param("id")
 
-	account, statusCode, err := handler.ServiceFacade.AccountingAccountService.Account(id)
+	account, statusCode, err := handler.ServiceFacade.AccountingAccountService.Account(&ctx, id)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
@@ -54,6 +96,10 @@ func (handler *AccountingHandler) Account(c *gin.Context) {
 	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
 		"account": account,
 	}))
+
+	if accountByte, err := json.Marshal(account); err == nil {
+		c.Set("response", accountByte)
+	}
 }
 
 func (handler *AccountingHandler) CreateAccount(c *gin.Context) {
@@ -110,9 +156,17 @@ func (handler *AccountingHandler) UpdateAccount(c *gin.Context) {
 		return
 	}
 
+	current := func() (any, int, error) {
+		return handler.ServiceFacade.AccountingAccountService.Account(&ctx, id)
+	}
+	if statusCode, err := utils.IfMatch(c, handler.DB, "accounting.account", id, &account.CommonUpdatePrecondition, current); err != nil {
+		utils.UpdateErrorResponse(c, "account", statusCode, err, current)
+		return
+	}
+
 	statusCode, err := handler.ServiceFacade.AccountingAccountService.UpdateAccount(&ctx, id, &account)
 	if err != nil {
-		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		utils.UpdateErrorResponse(c, "account", statusCode, err, current)
 		return
 	}
 
@@ -120,9 +174,15 @@ func (handler *AccountingHandler) UpdateAccount(c *gin.Context) {
 }
 
 func (handler *AccountingHandler) DeleteAccount(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	id := c.Param("id")
 
-	statusCode, err := handler.ServiceFacade.AccountingAccountService.DeleteAccount(id)
+	statusCode, err := handler.ServiceFacade.AccountingAccountService.DeleteAccount(&ctx, id)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
@@ -132,32 +192,75 @@ func (handler *AccountingHandler) DeleteAccount(c *gin.Context) {
 }
 
 func (handler *AccountingHandler) PaymentTerms(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	qp := utils.NewQueryParams().
 		PrepareFilters(c, accounting.AccountingPaymentTermAllowFilterFieldsAndOps, `"accounting.payment_term"`).
 		PrepareSorts(c, accounting.AccountingPaymentTermAllowSortFields, `"limited_payment_terms"`).
 		PreparePagination(c)
 
-	paymentTerms, total, statusCode, err := handler.ServiceFacade.AccountingPaymentTermService.PaymentTerms(qp)
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	paymentTerms, total, statusCode, err := handler.ServiceFacade.AccountingPaymentTermService.PaymentTerms(&ctx, qp)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
 	}
 
-	c.Header("X-Total-Count", fmt.Sprintf("%d", total))
+	qp.PaginationHeaders(c, total)
 	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
 		"payment_terms": paymentTerms,
 	}))
 
-	c.Set("total", total)
 	if paymentTermsByte, err := json.Marshal(paymentTerms); err == nil {
 		c.Set("response", paymentTermsByte)
 	}
 }
 
+func (handler *AccountingHandler) AggregatePaymentTerms(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	qp := utils.NewQueryParams().
+		PrepareFilters(c, accounting.AccountingPaymentTermAllowFilterFieldsAndOps, `"accounting.payment_term"`).
+		PrepareAggregate(c, accounting.AccountingPaymentTermAllowGroupFields, accounting.AccountingPaymentTermAllowAggregateFields)
+
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	groups, statusCode, err := handler.ServiceFacade.AggregateService.Aggregate(&ctx, "accounting.payment_term", qp)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
+		"groups": groups,
+	}))
+}
+
 func (handler *AccountingHandler) PaymentTerm(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	id := c.Param("id")
 
-	paymentTerm, statusCode, err := handler.ServiceFacade.AccountingPaymentTermService.PaymentTerm(id)
+	paymentTerm, statusCode, err := handler.ServiceFacade.AccountingPaymentTermService.PaymentTerm(&ctx, id)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
@@ -166,6 +269,10 @@ func (handler *AccountingHandler) PaymentTerm(c *gin.Context) {
 	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
 		"payment_term": paymentTerm,
 	}))
+
+	if paymentTermByte, err := json.Marshal(paymentTerm); err == nil {
+		c.Set("response", paymentTermByte)
+	}
 }
 
 func (handler *AccountingHandler) CreatePaymentTerm(c *gin.Context) {
@@ -222,9 +329,17 @@ func (handler *AccountingHandler) UpdatePaymentTerm(c *gin.Context) {
 		return
 	}
 
+	current := func() (any, int, error) {
+		return handler.ServiceFacade.AccountingPaymentTermService.PaymentTerm(&ctx, id)
+	}
+	if statusCode, err := utils.IfMatch(c, handler.DB, "accounting.payment_term", id, &paymentTerm.CommonUpdatePrecondition, current); err != nil {
+		utils.UpdateErrorResponse(c, "payment_term", statusCode, err, current)
+		return
+	}
+
 	statusCode, err := handler.ServiceFacade.AccountingPaymentTermService.UpdatePaymentTerm(&ctx, id, &paymentTerm)
 	if err != nil {
-		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		utils.UpdateErrorResponse(c, "payment_term", statusCode, err, current)
 		return
 	}
 
@@ -232,9 +347,15 @@ func (handler *AccountingHandler) UpdatePaymentTerm(c *gin.Context) {
 }
 
 func (handler *AccountingHandler) DeletePaymentTerm(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	id := c.Param("id")
 
-	statusCode, err := handler.ServiceFacade.AccountingPaymentTermService.DeletePaymentTerm(id)
+	statusCode, err := handler.ServiceFacade.AccountingPaymentTermService.DeletePaymentTerm(&ctx, id)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
@@ -244,32 +365,75 @@ func (handler *AccountingHandler) DeletePaymentTerm(c *gin.Context) {
 }
 
 func (handler *AccountingHandler) Journals(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	qp := utils.NewQueryParams().
 		PrepareFilters(c, accounting.AccountingJournalAllowFilterFieldsAndOps, `"accounting.journal"`).
-		PrepareSorts(c, accounting.AccountingJournalAllowSortFields, `"limited_journals"`).
+		PrepareSorts(c, accounting.AccountingJournalAllowSortFields, `"limited_journals"`, accounting.AccountingJournalSortFields).
 		PreparePagination(c)
 
-	journals, total, statusCode, err := handler.ServiceFacade.AccountingJournalService.Journals(qp)
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	journals, total, statusCode, err := handler.ServiceFacade.AccountingJournalService.Journals(&ctx, qp)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
 	}
 
-	c.Header("X-Total-Count", fmt.Sprintf("%d", total))
+	qp.PaginationHeaders(c, total)
 	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
 		"journals": journals,
 	}))
 
-	c.Set("total", total)
 	if journalsByte, err := json.Marshal(journals); err == nil {
 		c.Set("response", journalsByte)
 	}
 }
 
+func (handler *AccountingHandler) AggregateJournals(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	qp := utils.NewQueryParams().
+		PrepareFilters(c, accounting.AccountingJournalAllowFilterFieldsAndOps, `"accounting.journal"`).
+		PrepareAggregate(c, accounting.AccountingJournalAllowGroupFields, accounting.AccountingJournalAllowAggregateFields)
+
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	groups, statusCode, err := handler.ServiceFacade.AggregateService.Aggregate(&ctx, "accounting.journal", qp)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
+		"groups": groups,
+	}))
+}
+
 func (handler *AccountingHandler) Journal(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	id := c.Param("id")
 
-	journal, statusCode, err := handler.ServiceFacade.AccountingJournalService.Journal(id)
+	journal, statusCode, err := handler.ServiceFacade.AccountingJournalService.Journal(&ctx, id)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
@@ -278,6 +442,10 @@ func (handler *AccountingHandler) Journal(c *gin.Context) {
 	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
 		"journal": journal,
 	}))
+
+	if journalByte, err := json.Marshal(journal); err == nil {
+		c.Set("response", journalByte)
+	}
 }
 
 func (handler *AccountingHandler) CreateJournal(c *gin.Context) {
@@ -334,9 +502,17 @@ func (handler *AccountingHandler) UpdateJournal(c *gin.Context) {
 		return
 	}
 
+	current := func() (any, int, error) {
+		return handler.ServiceFacade.AccountingJournalService.Journal(&ctx, id)
+	}
+	if statusCode, err := utils.IfMatch(c, handler.DB, "accounting.journal", id, &journal.CommonUpdatePrecondition, current); err != nil {
+		utils.UpdateErrorResponse(c, "journal", statusCode, err, current)
+		return
+	}
+
 	statusCode, err := handler.ServiceFacade.AccountingJournalService.UpdateJournal(&ctx, id, &journal)
 	if err != nil {
-		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		utils.UpdateErrorResponse(c, "journal", statusCode, err, current)
 		return
 	}
 
@@ -344,9 +520,15 @@ func (handler *AccountingHandler) UpdateJournal(c *gin.Context) {
 }
 
 func (handler *AccountingHandler) DeleteJournal(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	id := c.Param("id")
 
-	statusCode, err := handler.ServiceFacade.AccountingJournalService.DeleteJournal(id)
+	statusCode, err := handler.ServiceFacade.AccountingJournalService.DeleteJournal(&ctx, id)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
@@ -356,32 +538,75 @@ func (handler *AccountingHandler) DeleteJournal(c *gin.Context) {
 }
 
 func (handler *AccountingHandler) JournalEntries(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	qp := utils.NewQueryParams().
 		PrepareFilters(c, accounting.AccountingJournalEntryAllowFilterFieldsAndOps, `"accounting.journal_entry"`).
-		PrepareSorts(c, accounting.AccountingJournalEntryAllowSortFields, `"limited_journal_entries"`).
+		PrepareSorts(c, accounting.AccountingJournalEntryAllowSortFields, `"limited_journal_entries"`, accounting.AccountingJournalEntrySortFields).
 		PreparePagination(c)
 
-	journalEntries, total, statusCode, err := handler.ServiceFacade.AccountingJournalEntryService.JournalEntries(qp)
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	journalEntries, total, statusCode, err := handler.ServiceFacade.AccountingJournalEntryService.JournalEntries(&ctx, qp)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
 	}
 
-	c.Header("X-Total-Count", fmt.Sprintf("%d", total))
+	qp.PaginationHeaders(c, total)
 	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
 		"journal_entries": journalEntries,
 	}))
 
-	c.Set("total", total)
 	if journalEntriesByte, err := json.Marshal(journalEntries); err == nil {
 		c.Set("response", journalEntriesByte)
 	}
 }
 
+func (handler *AccountingHandler) AggregateJournalEntries(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	qp := utils.NewQueryParams().
+		PrepareFilters(c, accounting.AccountingJournalEntryAllowFilterFieldsAndOps, `"accounting.journal_entry"`).
+		PrepareAggregate(c, accounting.AccountingJournalEntryAllowGroupFields, accounting.AccountingJournalEntryAllowAggregateFields)
+
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	groups, statusCode, err := handler.ServiceFacade.AggregateService.Aggregate(&ctx, "accounting.journal_entry", qp)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
+		"groups": groups,
+	}))
+}
+
 func (handler *AccountingHandler) JournalEntry(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	id := c.Param("id")
 
-	journalEntry, statusCode, err := handler.ServiceFacade.AccountingJournalEntryService.JournalEntry(id)
+	journalEntry, statusCode, err := handler.ServiceFacade.AccountingJournalEntryService.JournalEntry(&ctx, id)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
@@ -390,6 +615,10 @@ func (handler *AccountingHandler) JournalEntry(c *gin.Context) {
 	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
 		"journal_entry": journalEntry,
 	}))
+
+	if journalEntryByte, err := json.Marshal(journalEntry); err == nil {
+		c.Set("response", journalEntryByte)
+	}
 }
 
 func (handler *AccountingHandler) CreateJournalEntry(c *gin.Context) {
@@ -451,9 +680,17 @@ func (handler *AccountingHandler) UpdateJournalEntry(c *gin.Context) {
 		return
 	}
 
+	current := func() (any, int, error) {
+		return handler.ServiceFacade.AccountingJournalEntryService.JournalEntry(&ctx, id)
+	}
+	if statusCode, err := utils.IfMatch(c, handler.DB, "accounting.journal_entry", id, &journalEntry.CommonUpdatePrecondition, current); err != nil {
+		utils.UpdateErrorResponse(c, "journal_entry", statusCode, err, current)
+		return
+	}
+
 	statusCode, err := handler.ServiceFacade.AccountingJournalEntryService.UpdateJournalEntry(&ctx, id, &journalEntry)
 	if err != nil {
-		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		utils.UpdateErrorResponse(c, "journal_entry", statusCode, err, current)
 		return
 	}
 
@@ -461,9 +698,15 @@ func (handler *AccountingHandler) UpdateJournalEntry(c *gin.Context) {
 }
 
 func (handler *AccountingHandler) DeleteJournalEntry(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	id := c.Param("id")
 
-	statusCode, err := handler.ServiceFacade.AccountingJournalEntryService.DeleteJournalEntry(id)
+	statusCode, err := handler.ServiceFacade.AccountingJournalEntryService.DeleteJournalEntry(&ctx, id)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"database/sql"
+
+	"system.buon18.com/m/models/setting"
+	"system.buon18.com/m/services"
+	"system.buon18.com/m/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	DB            *sql.DB
+	ServiceFacade *services.ServiceFacade
+}
+
+func (handler *AuditHandler) AuditLogs(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	qp := utils.NewQueryParams().
+		PrepareFilters(c, setting.SettingAuditLogAllowFilterFieldsAndOps, `"setting.audit_log"`).
+		PrepareSorts(c, setting.SettingAuditLogAllowSortFields, `"setting.audit_log"`, setting.SettingAuditLogSortFields).
+		PreparePagination(c)
+
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	auditLogs, total, statusCode, err := handler.ServiceFacade.SettingAuditLogService.AuditLogs(&ctx, qp)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	qp.PaginationHeaders(c, total)
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
+		"audit_logs": auditLogs,
+	}))
+}
+
+// History lists the audit logs of the record :id of resource.
+func (handler *AuditHandler) History(resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, err := utils.Ctx(c)
+		if err != nil {
+			c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+			return
+		}
+
+		id := c.Param("id")
+		qp := utils.NewQueryParams().
+			PrepareFilters(c, setting.SettingAuditLogAllowFilterFieldsAndOps, `"setting.audit_log"`).
+			PrepareSorts(c, setting.SettingAuditLogAllowSortFields, `"setting.audit_log"`, setting.SettingAuditLogSortFields).
+			PreparePagination(c)
+
+		if response, ok := qp.Validate(c); !ok {
+			c.JSON(400, response)
+			return
+		}
+
+		auditLogs, total, statusCode, err := handler.ServiceFacade.SettingAuditLogService.History(&ctx, resource, id, qp)
+		if err != nil {
+			c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+			return
+		}
+
+		qp.PaginationHeaders(c, total)
+		c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
+			"history": auditLogs,
+		}))
+	}
+}
@@ -2,13 +2,21 @@ package controllers
 
 import (
 	"database/sql"
+	"net/http"
+	"strings"
 
+	"system.buon18.com/m/config"
 	"system.buon18.com/m/services"
 	"system.buon18.com/m/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	OIDC_STATE_COOKIE         = "oidc_state"
+	OIDC_STATE_COOKIE_MAX_AGE = 600
+)
+
 type AuthHandler struct {
 	DB            *sql.DB
 	ServiceFacade *services.ServiceFacade
@@ -40,7 +48,7 @@ func (handler *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	tokenAndRefreshToken, statusCode, err := handler.ServiceFacade.AuthService.Login(&req)
+	tokenAndRefreshToken, statusCode, err := handler.ServiceFacade.AuthService.Login(&req, utils.Client(c))
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
@@ -57,7 +65,7 @@ func (handler *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	tokenAndRefreshToken, statusCode, err := handler.ServiceFacade.AuthService.RefreshToken(&req)
+	tokenAndRefreshToken, statusCode, err := handler.ServiceFacade.AuthService.RefreshToken(&req, utils.Client(c))
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
@@ -89,6 +97,29 @@ func (handler *AuthHandler) UpdatePassword(c *gin.Context) {
 	c.JSON(statusCode, utils.NewResponse(statusCode, message, nil))
 }
 
+func (handler *AuthHandler) Impersonate(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	// -- Parse request
+	var req services.ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, utils.NewErrorResponse(400, "invalid request. request should contain user_id field"))
+		return
+	}
+
+	impersonationToken, statusCode, err := handler.ServiceFacade.AuthService.Impersonate(&ctx, &req)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", impersonationToken))
+}
+
 func (handler *AuthHandler) UpdateProfile(c *gin.Context) {
 	ctx, err := utils.Ctx(c)
 	if err != nil {
@@ -115,3 +146,76 @@ func (handler *AuthHandler) UpdateProfile(c *gin.Context) {
 
 	c.JSON(statusCode, utils.NewResponse(statusCode, message, nil))
 }
+
+func (handler *AuthHandler) OIDCLogin(c *gin.Context) {
+	state, err := utils.RandomString(32)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+	nonce, err := utils.RandomString(32)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	url, statusCode, err := handler.ServiceFacade.AuthService.OIDCAuthCodeURL(state, nonce)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	// -- Bind the login attempt to this browser
+	config := config.GetConfigInstance()
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(OIDC_STATE_COOKIE, state+"."+nonce, OIDC_STATE_COOKIE_MAX_AGE, "/api/auth/oidc", "", config.CERT_FILE != "", true)
+	c.Redirect(statusCode, url)
+}
+
+func (handler *AuthHandler) OIDCCallback(c *gin.Context) {
+	if errorCode, ok := c.GetQuery("error"); ok {
+		c.JSON(401, utils.NewErrorResponse(401, "identity provider returned "+errorCode))
+		return
+	}
+
+	code := c.Query("code")
+	state := c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(400, utils.NewErrorResponse(400, "invalid request. request should contain code and state query parameters"))
+		return
+	}
+
+	cookie, err := c.Cookie(OIDC_STATE_COOKIE)
+	if err != nil {
+		c.JSON(400, utils.NewErrorResponse(400, "login session expired"))
+		return
+	}
+	config := config.GetConfigInstance()
+	c.SetCookie(OIDC_STATE_COOKIE, "", -1, "/api/auth/oidc", "", config.CERT_FILE != "", true)
+
+	cookieState, nonce, ok := strings.Cut(cookie, ".")
+	if !ok || cookieState != state {
+		c.JSON(400, utils.NewErrorResponse(400, "invalid state"))
+		return
+	}
+
+	tokenAndRefreshToken, statusCode, err := handler.ServiceFacade.AuthService.OIDCLogin(code, nonce, utils.Client(c))
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", tokenAndRefreshToken))
+}
+
+func (handler *AuthHandler) JWKS(c *gin.Context) {
+	keySet, err := utils.GetWebTokenKeySet()
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	// -- Served as a plain key set so that other services can consume it directly
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(200, keySet.JWKS())
+}
@@ -3,7 +3,6 @@ package controllers
 import (
 	"database/sql"
 	"encoding/json"
-	"fmt"
 	"log"
 	"strings"
 
@@ -20,40 +19,110 @@ type SalesHandler struct {
 }
 
 func (handler *SalesHandler) Quotations(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	qp := utils.NewQueryParams().
 		PrepareFilters(c, sales.SalesQuotationAllowFilterFieldsAndOps, `"sales.quotation"`).
-		PrepareSorts(c, sales.SalesQuotationAllowSortFields, `"limited_quotations"`).
-		PreparePagination(c)
+		PrepareSorts(c, sales.SalesQuotationAllowSortFields, `"limited_quotations"`, sales.SalesQuotationSortFields).
+		PreparePagination(c).
+		PrepareFieldset(c, sales.SalesQuotationAllowFields, sales.SalesQuotationAllowIncludes)
+
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
 
-	quotations, total, statusCode, err := handler.ServiceFacade.SalesQuotationService.Quotations(qp)
+	quotations, total, statusCode, err := handler.ServiceFacade.SalesQuotationService.Quotations(&ctx, qp)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
 	}
 
-	c.Header("X-Total-Count", fmt.Sprintf("%d", total))
+	sparseQuotations, err := qp.SparseFieldset(quotations)
+	if err != nil {
+		log.Printf("%v", err)
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	qp.PaginationHeaders(c, total)
 	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
-		"quotations": quotations,
+		"quotations": sparseQuotations,
 	}))
 
-	c.Set("total", total)
-	if quotationsByte, err := json.Marshal(quotations); err == nil {
+	if quotationsByte, err := json.Marshal(sparseQuotations); err == nil {
 		c.Set("response", quotationsByte)
 	}
 }
 
+func (handler *SalesHandler) AggregateQuotations(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	qp := utils.NewQueryParams().
+		PrepareFilters(c, sales.SalesQuotationAllowFilterFieldsAndOps, `"sales.quotation"`).
+		PrepareAggregate(c, sales.SalesQuotationAllowGroupFields, sales.SalesQuotationAllowAggregateFields)
+
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	groups, statusCode, err := handler.ServiceFacade.AggregateService.Aggregate(&ctx, "sales.quotation", qp)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
+		"groups": groups,
+	}))
+}
+
 func (handler *SalesHandler) Quotation(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	id := c.Param("id")
 
-	quotation, statusCode, err := handler.ServiceFacade.SalesQuotationService.Quotation(id)
+	qp := utils.NewQueryParams().
+		PrepareFieldset(c, sales.SalesQuotationAllowFields, sales.SalesQuotationAllowIncludes)
+
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	quotation, statusCode, err := handler.ServiceFacade.SalesQuotationService.Quotation(&ctx, id, qp)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
 	}
 
+	sparseQuotation, err := qp.SparseFieldset(quotation)
+	if err != nil {
+		log.Printf("%v", err)
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
-		"quotation": quotation,
+		"quotation": sparseQuotation,
 	}))
+
+	if quotationByte, err := json.Marshal(sparseQuotation); err == nil {
+		c.Set("response", quotationByte)
+	}
 }
 
 func (handler *SalesHandler) CreateQuotation(c *gin.Context) {
@@ -120,9 +189,20 @@ func (handler *SalesHandler) UpdateQuotation(c *gin.Context) {
 		return
 	}
 
+	// -- The representation without fields and include, whatever the query of the update
+	current := func() (any, int, error) {
+		qp := utils.NewQueryParams().
+			DefaultFieldset(sales.SalesQuotationAllowFields, sales.SalesQuotationAllowIncludes)
+		return handler.ServiceFacade.SalesQuotationService.Quotation(&ctx, id, qp)
+	}
+	if statusCode, err := utils.IfMatch(c, handler.DB, "sales.quotation", id, &quotation.CommonUpdatePrecondition, current); err != nil {
+		utils.UpdateErrorResponse(c, "quotation", statusCode, err, current)
+		return
+	}
+
 	statusCode, err := handler.ServiceFacade.SalesQuotationService.UpdateQuotation(&ctx, id, &quotation)
 	if err != nil {
-		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		utils.UpdateErrorResponse(c, "quotation", statusCode, err, current)
 		return
 	}
 
@@ -130,9 +210,15 @@ func (handler *SalesHandler) UpdateQuotation(c *gin.Context) {
 }
 
 func (handler *SalesHandler) DeleteQuotation(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	id := c.Param("id")
 
-	statusCode, err := handler.ServiceFacade.SalesQuotationService.DeleteQuotation(id)
+	statusCode, err := handler.ServiceFacade.SalesQuotationService.DeleteQuotation(&ctx, id)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
@@ -142,40 +228,110 @@ func (handler *SalesHandler) DeleteQuotation(c *gin.Context) {
 }
 
 func (handler *SalesHandler) Orders(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	qp := utils.NewQueryParams().
 		PrepareFilters(c, sales.SalesOrderAllowFilterFieldsAndOps, `"sales.order"`).
-		PrepareSorts(c, sales.SalesOrderAllowSortFields, `"limited_orders"`).
-		PreparePagination(c)
+		PrepareSorts(c, sales.SalesOrderAllowSortFields, `"limited_orders"`, sales.SalesOrderSortFields).
+		PreparePagination(c).
+		PrepareFieldset(c, sales.SalesOrderAllowFields, sales.SalesOrderAllowIncludes)
+
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
 
-	orders, total, statusCode, err := handler.ServiceFacade.SalesOrderService.Orders(qp)
+	orders, total, statusCode, err := handler.ServiceFacade.SalesOrderService.Orders(&ctx, qp)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
 	}
 
-	c.Header("X-Total-Count", fmt.Sprintf("%d", total))
+	sparseOrders, err := qp.SparseFieldset(orders)
+	if err != nil {
+		log.Printf("%v", err)
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	qp.PaginationHeaders(c, total)
 	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
-		"orders": orders,
+		"orders": sparseOrders,
 	}))
 
-	c.Set("total", total)
-	if ordersByte, err := json.Marshal(orders); err == nil {
+	if ordersByte, err := json.Marshal(sparseOrders); err == nil {
 		c.Set("response", ordersByte)
 	}
 }
 
+func (handler *SalesHandler) AggregateOrders(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	qp := utils.NewQueryParams().
+		PrepareFilters(c, sales.SalesOrderAllowFilterFieldsAndOps, `"sales.order"`).
+		PrepareAggregate(c, sales.SalesOrderAllowGroupFields, sales.SalesOrderAllowAggregateFields)
+
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	groups, statusCode, err := handler.ServiceFacade.AggregateService.Aggregate(&ctx, "sales.order", qp)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
+		"groups": groups,
+	}))
+}
+
 func (handler *SalesHandler) Order(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	id := c.Param("id")
 
-	order, statusCode, err := handler.ServiceFacade.SalesOrderService.Order(id)
+	qp := utils.NewQueryParams().
+		PrepareFieldset(c, sales.SalesOrderAllowFields, sales.SalesOrderAllowIncludes)
+
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	order, statusCode, err := handler.ServiceFacade.SalesOrderService.Order(&ctx, id, qp)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
 	}
 
+	sparseOrder, err := qp.SparseFieldset(order)
+	if err != nil {
+		log.Printf("%v", err)
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
-		"order": order,
+		"order": sparseOrder,
 	}))
+
+	if orderByte, err := json.Marshal(sparseOrder); err == nil {
+		c.Set("response", orderByte)
+	}
 }
 
 func (handler *SalesHandler) CreateOrder(c *gin.Context) {
@@ -240,9 +396,20 @@ func (handler *SalesHandler) UpdateOrder(c *gin.Context) {
 		return
 	}
 
+	// -- The representation without fields and include, whatever the query of the update
+	current := func() (any, int, error) {
+		qp := utils.NewQueryParams().
+			DefaultFieldset(sales.SalesOrderAllowFields, sales.SalesOrderAllowIncludes)
+		return handler.ServiceFacade.SalesOrderService.Order(&ctx, id, qp)
+	}
+	if statusCode, err := utils.IfMatch(c, handler.DB, "sales.order", id, &order.CommonUpdatePrecondition, current); err != nil {
+		utils.UpdateErrorResponse(c, "order", statusCode, err, current)
+		return
+	}
+
 	statusCode, err := handler.ServiceFacade.SalesOrderService.UpdateOrder(&ctx, id, &order)
 	if err != nil {
-		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		utils.UpdateErrorResponse(c, "order", statusCode, err, current)
 		return
 	}
 
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"system.buon18.com/m/services"
+	"system.buon18.com/m/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// -- Number of results returned by default
+const SEARCH_DEFAULT_LIMIT = 10
+
+type SearchHandler struct {
+	DB            *sql.DB
+	ServiceFacade *services.ServiceFacade
+}
+
+func (handler *SearchHandler) Search(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	limit := SEARCH_DEFAULT_LIMIT
+	if limitStr, ok := c.GetQuery("limit"); ok {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > utils.MAX_PAGE_SIZE {
+			c.JSON(400, utils.NewErrorResponse(400, fmt.Sprintf("limit must be between 1 and %d", utils.MAX_PAGE_SIZE)))
+			return
+		}
+	}
+
+	results, statusCode, err := handler.ServiceFacade.SearchService.Search(&ctx, c.Query("q"), limit)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
+		"results": results,
+	}))
+}
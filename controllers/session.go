@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"database/sql"
+
+	"system.buon18.com/m/models/setting"
+	"system.buon18.com/m/services"
+	"system.buon18.com/m/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	DB            *sql.DB
+	ServiceFacade *services.ServiceFacade
+}
+
+func (handler *SessionHandler) Sessions(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	qp := utils.NewQueryParams().
+		PrepareFilters(c, setting.SettingSessionAllowFilterFieldsAndOps, `"setting.session"`).
+		PrepareSorts(c, setting.SettingSessionAllowSortFields, `"setting.session"`, setting.SettingSessionSortFields).
+		PreparePagination(c)
+
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	sessions, total, statusCode, err := handler.ServiceFacade.SettingSessionService.Sessions(&ctx, qp)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	qp.PaginationHeaders(c, total)
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
+		"sessions": sessions,
+	}))
+}
+
+func (handler *SessionHandler) RevokeSession(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	id := c.Param("id")
+
+	statusCode, err := handler.ServiceFacade.SettingSessionService.RevokeSession(&ctx, id, 0)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "session revoked successfully", nil))
+}
+
+func (handler *SessionHandler) LoginEvents(c *gin.Context) {
+	qp := utils.NewQueryParams().
+		PrepareFilters(c, setting.SettingLoginEventAllowFilterFieldsAndOps, `"setting.login_event"`).
+		PrepareSorts(c, setting.SettingLoginEventAllowSortFields, `"setting.login_event"`, setting.SettingLoginEventSortFields).
+		PreparePagination(c)
+
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	loginEvents, total, statusCode, err := handler.ServiceFacade.SettingSessionService.LoginEvents(qp)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	qp.PaginationHeaders(c, total)
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
+		"login_events": loginEvents,
+	}))
+}
+
+func (handler *SessionHandler) MySessions(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	qp := utils.NewQueryParams().
+		PrepareSorts(c, setting.SettingSessionAllowSortFields, `"setting.session"`, setting.SettingSessionSortFields).
+		PreparePagination(c)
+
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	sessions, total, statusCode, err := handler.ServiceFacade.SettingSessionService.MySessions(&ctx, qp)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	qp.PaginationHeaders(c, total)
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
+		"sessions": sessions,
+	}))
+}
+
+func (handler *SessionHandler) RevokeMySession(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	id := c.Param("id")
+
+	statusCode, err := handler.ServiceFacade.SettingSessionService.RevokeSession(&ctx, id, ctx.User.Id)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "session revoked successfully", nil))
+}
+
+func (handler *SessionHandler) MyLoginEvents(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	qp := utils.NewQueryParams().
+		PrepareSorts(c, setting.SettingLoginEventAllowSortFields, `"setting.login_event"`, setting.SettingLoginEventSortFields).
+		PreparePagination(c)
+
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	loginEvents, total, statusCode, err := handler.ServiceFacade.SettingSessionService.MyLoginEvents(&ctx, qp)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	qp.PaginationHeaders(c, total)
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
+		"login_events": loginEvents,
+	}))
+}
@@ -4,18 +4,21 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"strings"
 
+	"system.buon18.com/m/models"
 	"system.buon18.com/m/models/setting"
 	"system.buon18.com/m/services"
 	"system.buon18.com/m/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nullism/bqb"
 )
 
 var (
 	ErrUnableToDeleteSystemRole = errors.New("unable to delete the system role")
+	ErrUnableToDeleteSystemUser = errors.New("unable to archive the system user")
+	ErrUnableToDeactivateSelf   = errors.New("unable to deactivate yourself")
 )
 
 type SettingHandler struct {
@@ -26,26 +29,67 @@ type SettingHandler struct {
 func (handler *SettingHandler) Users(c *gin.Context) {
 	qp := utils.NewQueryParams().
 		PrepareFilters(c, setting.SettingUserAllowFilterFieldsAndOps, `"setting.user"`).
-		PrepareSorts(c, setting.SettingUserAllowSortFields, `"limited_users"`).
+		PrepareSorts(c, setting.SettingUserAllowSortFields, `"limited_users"`, setting.SettingUserSortFields).
 		PreparePagination(c)
 
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	// -- Archived users are hidden unless filtered by state
+	if _, ok := c.GetQuery("state:in"); !ok {
+		qp.AddCondition(bqb.New(`"setting.user".state != ?`, models.SettingUserStateArchived))
+	}
+
 	users, total, statusCode, err := handler.ServiceFacade.SettingUserService.Users(qp)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
 	}
 
-	c.Header("X-Total-Count", fmt.Sprintf("%d", total))
+	qp.PaginationHeaders(c, total)
 	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
 		"users": users,
 	}))
 
-	c.Set("total", total)
 	if usersByte, err := json.Marshal(users); err == nil {
 		c.Set("response", usersByte)
 	}
 }
 
+func (handler *SettingHandler) AggregateUsers(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	qp := utils.NewQueryParams().
+		PrepareFilters(c, setting.SettingUserAllowFilterFieldsAndOps, `"setting.user"`).
+		PrepareAggregate(c, setting.SettingUserAllowGroupFields, setting.SettingUserAllowAggregateFields)
+
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	// -- Archived users are left out unless filtered by state
+	if _, ok := c.GetQuery("state:in"); !ok {
+		qp.AddCondition(bqb.New(`"setting.user".state != ?`, models.SettingUserStateArchived))
+	}
+
+	groups, statusCode, err := handler.ServiceFacade.AggregateService.Aggregate(&ctx, "setting.user", qp)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
+		"groups": groups,
+	}))
+}
+
 func (handler *SettingHandler) User(c *gin.Context) {
 	id := c.Param("id")
 
@@ -58,6 +102,10 @@ func (handler *SettingHandler) User(c *gin.Context) {
 	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
 		"user": user,
 	}))
+
+	if userByte, err := json.Marshal(user); err == nil {
+		c.Set("response", userByte)
+	}
 }
 
 func (handler *SettingHandler) CreateUser(c *gin.Context) {
@@ -113,47 +161,130 @@ func (handler *SettingHandler) UpdateUser(c *gin.Context) {
 	}
 
 	// unable to update the system user's role
-	if id == "1" && (user.RoleId != nil || user.Password != nil) {
-		c.JSON(400, utils.NewErrorResponse(400, "unable to update the system user's role or password"))
+	if id == "1" && (user.RoleId != nil || user.AddRoleIds != nil || user.RemoveRoleIds != nil || user.Password != nil || user.State != nil) {
+		c.JSON(400, utils.NewErrorResponse(400, "unable to update the system user's role, password or state"))
+		return
+	}
+
+	if id == utils.IntToStr(int(ctx.User.Id)) && user.State != nil && *user.State != models.SettingUserStateActive {
+		c.JSON(400, utils.NewErrorResponse(400, ErrUnableToDeactivateSelf.Error()))
+		return
+	}
+
+	current := func() (any, int, error) {
+		return handler.ServiceFacade.SettingUserService.User(id)
+	}
+	if statusCode, err := utils.IfMatch(c, handler.DB, "setting.user", id, &user.CommonUpdatePrecondition, current); err != nil {
+		utils.UpdateErrorResponse(c, "user", statusCode, err, current)
 		return
 	}
 
 	statusCode, err := handler.ServiceFacade.SettingUserService.UpdateUser(&ctx, id, &user)
 	if err != nil {
-		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		utils.UpdateErrorResponse(c, "user", statusCode, err, current)
 		return
 	}
 
 	c.JSON(statusCode, utils.NewResponse(statusCode, "user updated successfully", nil))
 }
 
+func (handler *SettingHandler) DeleteUser(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	id := c.Param("id")
+
+	if id == "1" {
+		c.JSON(403, utils.NewErrorResponse(403, ErrUnableToDeleteSystemUser.Error()))
+		return
+	}
+	if id == utils.IntToStr(int(ctx.User.Id)) {
+		c.JSON(400, utils.NewErrorResponse(400, ErrUnableToDeactivateSelf.Error()))
+		return
+	}
+
+	statusCode, err := handler.ServiceFacade.SettingUserService.DeleteUser(&ctx, id)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "user archived successfully", nil))
+}
+
 func (handler *SettingHandler) Customers(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	qp := utils.NewQueryParams().
 		PrepareFilters(c, setting.SettingCustomerAllowFilterFieldsAndOps, `"setting.customer"`).
-		PrepareSorts(c, setting.SettingCustomerAllowSortFields, `"limited_customers"`).
+		PrepareSorts(c, setting.SettingCustomerAllowSortFields, `"limited_customers"`, setting.SettingCustomerSortFields).
 		PreparePagination(c)
 
-	customers, total, statusCode, err := handler.ServiceFacade.SettingCustomerService.Customers(qp)
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	customers, total, statusCode, err := handler.ServiceFacade.SettingCustomerService.Customers(&ctx, qp)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
 	}
 
-	c.Header("X-Total-Count", fmt.Sprintf("%d", total))
+	qp.PaginationHeaders(c, total)
 	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
 		"customers": customers,
 	}))
 
-	c.Set("total", total)
 	if customersByte, err := json.Marshal(customers); err == nil {
 		c.Set("response", customersByte)
 	}
 }
 
+func (handler *SettingHandler) AggregateCustomers(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	qp := utils.NewQueryParams().
+		PrepareFilters(c, setting.SettingCustomerAllowFilterFieldsAndOps, `"setting.customer"`).
+		PrepareAggregate(c, setting.SettingCustomerAllowGroupFields, setting.SettingCustomerAllowAggregateFields)
+
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	groups, statusCode, err := handler.ServiceFacade.AggregateService.Aggregate(&ctx, "setting.customer", qp)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
+		"groups": groups,
+	}))
+}
+
 func (handler *SettingHandler) Customer(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	id := c.Param("id")
 
-	customer, statusCode, err := handler.ServiceFacade.SettingCustomerService.Customer(id)
+	customer, statusCode, err := handler.ServiceFacade.SettingCustomerService.Customer(&ctx, id)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
@@ -162,6 +293,10 @@ func (handler *SettingHandler) Customer(c *gin.Context) {
 	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
 		"customer": customer,
 	}))
+
+	if customerByte, err := json.Marshal(customer); err == nil {
+		c.Set("response", customerByte)
+	}
 }
 
 func (handler *SettingHandler) CreateCustomer(c *gin.Context) {
@@ -216,9 +351,17 @@ func (handler *SettingHandler) UpdateCustomer(c *gin.Context) {
 		return
 	}
 
+	current := func() (any, int, error) {
+		return handler.ServiceFacade.SettingCustomerService.Customer(&ctx, id)
+	}
+	if statusCode, err := utils.IfMatch(c, handler.DB, "setting.customer", id, &customer.CommonUpdatePrecondition, current); err != nil {
+		utils.UpdateErrorResponse(c, "customer", statusCode, err, current)
+		return
+	}
+
 	statusCode, err := handler.ServiceFacade.SettingCustomerService.UpdateCustomer(&ctx, id, &customer)
 	if err != nil {
-		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		utils.UpdateErrorResponse(c, "customer", statusCode, err, current)
 		return
 	}
 
@@ -226,9 +369,15 @@ func (handler *SettingHandler) UpdateCustomer(c *gin.Context) {
 }
 
 func (handler *SettingHandler) DeleteCustomer(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	id := c.Param("id")
 
-	statusCode, err := handler.ServiceFacade.SettingCustomerService.DeleteCustomer(id)
+	statusCode, err := handler.ServiceFacade.SettingCustomerService.DeleteCustomer(&ctx, id)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
@@ -237,29 +386,200 @@ func (handler *SettingHandler) DeleteCustomer(c *gin.Context) {
 	c.JSON(statusCode, utils.NewResponse(statusCode, "customer deleted successfully", nil))
 }
 
+func (handler *SettingHandler) Companies(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	qp := utils.NewQueryParams().
+		PrepareFilters(c, setting.SettingCompanyAllowFilterFieldsAndOps, `"setting.company"`).
+		PrepareSorts(c, setting.SettingCompanyAllowSortFields, `"setting.company"`).
+		PreparePagination(c)
+
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	companies, total, statusCode, err := handler.ServiceFacade.SettingCompanyService.Companies(&ctx, qp)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	qp.PaginationHeaders(c, total)
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
+		"companies": companies,
+	}))
+}
+
+func (handler *SettingHandler) Company(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	id := c.Param("id")
+
+	company, statusCode, err := handler.ServiceFacade.SettingCompanyService.Company(&ctx, id)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
+		"company": company,
+	}))
+
+	if companyByte, err := json.Marshal(company); err == nil {
+		c.Set("response", companyByte)
+	}
+}
+
+func (handler *SettingHandler) CreateCompany(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	var company setting.SettingCompanyCreateRequest
+	if err := c.ShouldBindJSON(&company); err != nil {
+		c.JSON(400, utils.NewErrorResponse(400, err.Error()))
+		return
+	}
+
+	if validationErrors, ok := utils.ValidateStruct(company); !ok {
+		c.JSON(400, utils.NewErrorResponse(400, strings.Join(validationErrors, ", ")))
+		return
+	}
+
+	statusCode, err := handler.ServiceFacade.SettingCompanyService.CreateCompany(&ctx, &company)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "company created successfully", nil))
+}
+
+func (handler *SettingHandler) UpdateCompany(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	id := c.Param("id")
+
+	var company setting.SettingCompanyUpdateRequest
+	if err := c.ShouldBindJSON(&company); err != nil {
+		c.JSON(400, utils.NewErrorResponse(400, err.Error()))
+		return
+	}
+
+	if utils.IsAllFieldsNil(&company) {
+		c.JSON(400, utils.NewErrorResponse(400, "no fields to update"))
+		return
+	}
+
+	if validationErrors, ok := utils.ValidateStruct(company); !ok {
+		c.JSON(400, utils.NewErrorResponse(400, strings.Join(validationErrors, ", ")))
+		return
+	}
+
+	current := func() (any, int, error) {
+		return handler.ServiceFacade.SettingCompanyService.Company(&ctx, id)
+	}
+	if statusCode, err := utils.IfMatch(c, handler.DB, "setting.company", id, &company.CommonUpdatePrecondition, current); err != nil {
+		utils.UpdateErrorResponse(c, "company", statusCode, err, current)
+		return
+	}
+
+	statusCode, err := handler.ServiceFacade.SettingCompanyService.UpdateCompany(&ctx, id, &company)
+	if err != nil {
+		utils.UpdateErrorResponse(c, "company", statusCode, err, current)
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "company updated successfully", nil))
+}
+
+func (handler *SettingHandler) DeleteCompany(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	id := c.Param("id")
+
+	statusCode, err := handler.ServiceFacade.SettingCompanyService.DeleteCompany(&ctx, id)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "company deleted successfully", nil))
+}
+
 func (handler *SettingHandler) Roles(c *gin.Context) {
 	qp := utils.NewQueryParams().
 		PrepareFilters(c, setting.SettingRoleAllowFilterFieldsAndOps, `"setting.role"`).
 		PrepareSorts(c, setting.SettingRoleAllowSortFields, `"limited_roles"`).
 		PreparePagination(c)
 
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
 	roles, total, statusCode, err := handler.ServiceFacade.SettingRoleService.Roles(qp)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
 	}
 
-	c.Header("X-Total-Count", fmt.Sprintf("%d", total))
+	qp.PaginationHeaders(c, total)
 	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
 		"roles": roles,
 	}))
 
-	c.Set("total", total)
 	if rolesByte, err := json.Marshal(roles); err == nil {
 		c.Set("response", rolesByte)
 	}
 }
 
+func (handler *SettingHandler) AggregateRoles(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	qp := utils.NewQueryParams().
+		PrepareFilters(c, setting.SettingRoleAllowFilterFieldsAndOps, `"setting.role"`).
+		PrepareAggregate(c, setting.SettingRoleAllowGroupFields, setting.SettingRoleAllowAggregateFields)
+
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	groups, statusCode, err := handler.ServiceFacade.AggregateService.Aggregate(&ctx, "setting.role", qp)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
+		"groups": groups,
+	}))
+}
+
 func (handler *SettingHandler) Role(c *gin.Context) {
 	id := c.Param("id")
 
@@ -272,6 +592,10 @@ func (handler *SettingHandler) Role(c *gin.Context) {
 	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
 		"role": role,
 	}))
+
+	if roleByte, err := json.Marshal(role); err == nil {
+		c.Set("response", roleByte)
+	}
 }
 
 func (handler *SettingHandler) CreateRole(c *gin.Context) {
@@ -381,9 +705,17 @@ func (handler *SettingHandler) UpdateRole(c *gin.Context) {
 		}
 	}
 
+	current := func() (any, int, error) {
+		return handler.ServiceFacade.SettingRoleService.Role(id)
+	}
+	if statusCode, err := utils.IfMatch(c, handler.DB, "setting.role", id, &role.CommonUpdatePrecondition, current); err != nil {
+		utils.UpdateErrorResponse(c, "role", statusCode, err, current)
+		return
+	}
+
 	statusCode, err := handler.ServiceFacade.SettingRoleService.UpdateRole(&ctx, id, &role)
 	if err != nil {
-		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		utils.UpdateErrorResponse(c, "role", statusCode, err, current)
 		return
 	}
 
@@ -391,6 +723,12 @@ func (handler *SettingHandler) UpdateRole(c *gin.Context) {
 }
 
 func (handler *SettingHandler) DeleteRole(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
 	id := c.Param("id")
 
 	if id == "1" {
@@ -398,7 +736,7 @@ func (handler *SettingHandler) DeleteRole(c *gin.Context) {
 		return
 	}
 
-	statusCode, err := handler.ServiceFacade.SettingRoleService.DeleteRole(id)
+	statusCode, err := handler.ServiceFacade.SettingRoleService.DeleteRole(&ctx, id)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
@@ -413,19 +751,169 @@ func (handler *SettingHandler) Permissions(c *gin.Context) {
 		PrepareSorts(c, setting.SettingPermissionAllowSortFields, `"limited_permissions"`).
 		PreparePagination(c)
 
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
 	permissions, total, statusCode, err := handler.ServiceFacade.SettingPermissionService.Permissions(qp)
 	if err != nil {
 		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
 		return
 	}
 
-	c.Header("X-Total-Count", fmt.Sprintf("%d", total))
+	qp.PaginationHeaders(c, total)
 	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
 		"permissions": permissions,
 	}))
 
-	c.Set("total", total)
 	if permissionsByte, err := json.Marshal(permissions); err == nil {
 		c.Set("response", permissionsByte)
 	}
 }
+
+func (handler *SettingHandler) SavedViews(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	qp := utils.NewQueryParams().
+		PrepareFilters(c, setting.SettingSavedViewAllowFilterFieldsAndOps, `"setting.saved_view"`).
+		PrepareSorts(c, setting.SettingSavedViewAllowSortFields, `"setting.saved_view"`).
+		PreparePagination(c)
+
+	if response, ok := qp.Validate(c); !ok {
+		c.JSON(400, response)
+		return
+	}
+
+	savedViews, total, statusCode, err := handler.ServiceFacade.SettingSavedViewService.SavedViews(&ctx, qp)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	qp.PaginationHeaders(c, total)
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
+		"saved_views": savedViews,
+	}))
+}
+
+func (handler *SettingHandler) SavedView(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	id := c.Param("id")
+
+	savedView, statusCode, err := handler.ServiceFacade.SettingSavedViewService.SavedView(&ctx, id)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "", gin.H{
+		"saved_view": savedView,
+	}))
+
+	if savedViewByte, err := json.Marshal(savedView); err == nil {
+		c.Set("response", savedViewByte)
+	}
+}
+
+func (handler *SettingHandler) CreateSavedView(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	var savedView setting.SettingSavedViewCreateRequest
+	if err := c.ShouldBindJSON(&savedView); err != nil {
+		c.JSON(400, utils.NewErrorResponse(400, err.Error()))
+		return
+	}
+
+	if validationErrors, ok := utils.ValidateStruct(savedView); !ok {
+		c.JSON(400, utils.NewErrorResponse(400, strings.Join(validationErrors, ", ")))
+		return
+	}
+
+	statusCode, err := handler.ServiceFacade.SettingSavedViewService.CreateSavedView(&ctx, &savedView)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "saved view created successfully", nil))
+}
+
+func (handler *SettingHandler) UpdateSavedView(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	id := c.Param("id")
+
+	var savedView setting.SettingSavedViewUpdateRequest
+	if err := c.ShouldBindJSON(&savedView); err != nil {
+		c.JSON(400, utils.NewErrorResponse(400, err.Error()))
+		return
+	}
+
+	if utils.IsAllFieldsNil(&savedView) {
+		c.JSON(400, utils.NewErrorResponse(400, "no fields to update"))
+		return
+	}
+
+	if validationErrors, ok := utils.ValidateStruct(savedView); !ok {
+		c.JSON(400, utils.NewErrorResponse(400, strings.Join(validationErrors, ", ")))
+		return
+	}
+
+	current := func() (any, int, error) {
+		return handler.ServiceFacade.SettingSavedViewService.SavedView(&ctx, id)
+	}
+	if statusCode, err := utils.IfMatch(c, handler.DB, "setting.saved_view", id, &savedView.CommonUpdatePrecondition, current); err != nil {
+		utils.UpdateErrorResponse(c, "saved_view", statusCode, err, current)
+		return
+	}
+
+	statusCode, err := handler.ServiceFacade.SettingSavedViewService.UpdateSavedView(&ctx, id, &savedView)
+	if err != nil {
+		utils.UpdateErrorResponse(c, "saved_view", statusCode, err, current)
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "saved view updated successfully", nil))
+}
+
+func (handler *SettingHandler) DeleteSavedView(c *gin.Context) {
+	ctx, err := utils.Ctx(c)
+	if err != nil {
+		c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		return
+	}
+
+	id := c.Param("id")
+
+	statusCode, err := handler.ServiceFacade.SettingSavedViewService.DeleteSavedView(&ctx, id)
+	if err != nil {
+		c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	c.JSON(statusCode, utils.NewResponse(statusCode, "saved view deleted successfully", nil))
+}
+
+func (handler *SettingHandler) CacheStats(c *gin.Context) {
+	c.JSON(200, utils.NewResponse(200, "", gin.H{
+		"cache_stats": utils.GetResponseCache().Stats(),
+	}))
+}
@@ -36,7 +36,7 @@ func InitValkey(addresses []string, password string) *valkey.Client {
 		Password:    password,
 	})
 	if err != nil {
-		log.Printf("Error connecting to valkey: %v\n", err)
+		log.Printf("Error connecting to valkey, responses are cached in process only: %v\n", err)
 		return nil
 	}
 
@@ -2,7 +2,10 @@ package database
 
 const (
 	KEY_SETTING_USER_EMAIL            = "setting.user_email_key"
+	KEY_SETTING_USER_OIDC_SUB         = "setting.user_oidc_sub_key"
 	KEY_SETTING_CUSTOMER_EMAIL        = "setting.customer_email_key"
+	KEY_SETTING_COMPANY_NAME          = "setting.company_name_key"
+	KEY_SETTING_SAVED_VIEW_NAME       = "setting.saved_view_name_key"
 	KEY_SALES_QUOTATION_NAME          = "sales.quotation_name_key"
 	KEY_SALES_ORDER_NAME              = "sales.order_name_key"
 	KEY_ACCOUNTING_ACCOUNT_CODE       = "accounting.account_code_key"
@@ -12,6 +15,8 @@ const (
 
 	FK_SETTING_CUSTOMER_ID         = "setting.customer_id_fkey"
 	FK_SETTING_ROLE_ID             = "setting.role_id_fkey"
+	FK_SETTING_USER_ID             = "setting.user_id_fkey"
+	FK_SETTING_COMPANY_ID          = "setting.company_id_fkey"
 	FK_SALES_QUOTATION_CUSTOMER_ID = "setting.customer_id_fkey"
 	FK_ACCOUNTING_PAYMENT_TERM_ID  = "accounting.payment_term_id_fkey"
 	FK_ACCOUNTING_ACCOUNT_ID       = "accounting.account_id_fkey"
@@ -0,0 +1,14 @@
+#!/bin/bash
+set -e
+
+psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" <<-EOSQL
+    ALTER TABLE "setting.user"
+    ADD COLUMN IF NOT EXISTS oidc_sub VARCHAR(256);
+
+    DO \$\$ BEGIN
+    ALTER TABLE "setting.user"
+    ADD CONSTRAINT "setting.user_oidc_sub_key" UNIQUE (oidc_sub);
+    EXCEPTION
+    WHEN duplicate_table THEN null;
+    END \$\$;
+EOSQL
\ No newline at end of file
@@ -0,0 +1,31 @@
+#!/bin/bash
+set -e
+
+psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" <<-EOSQL
+    CREATE TABLE IF NOT EXISTS
+        "setting.user_role" (
+            setting_user_id BIGINT NOT NULL,
+            setting_role_id BIGINT NOT NULL,
+            -- Timestamps
+            cid BIGINT NOT NULL,
+            ctime TIMESTAMP WITH TIME ZONE NOT NULL,
+            mid BIGINT NOT NULL,
+            mtime TIMESTAMP WITH TIME ZONE NOT NULL,
+            PRIMARY KEY (setting_user_id, setting_role_id),
+            CONSTRAINT "setting.user_id_fkey" FOREIGN KEY (setting_user_id) REFERENCES "setting.user" (id) ON DELETE RESTRICT,
+            CONSTRAINT "setting.role_id_fkey" FOREIGN KEY (setting_role_id) REFERENCES "setting.role" (id) ON DELETE RESTRICT
+        );
+
+    CREATE INDEX IF NOT EXISTS "setting.user_role_role_id_idx" ON "setting.user_role" (setting_role_id);
+
+    -- The role of setting.user is also one of the roles of the user
+    INSERT INTO
+        "setting.user_role" (setting_user_id, setting_role_id, cid, ctime, mid, mtime)
+    SELECT
+        id, setting_role_id, 1, NOW(), 1, NOW()
+    FROM
+        "setting.user"
+    WHERE
+        setting_role_id IS NOT NULL
+    ON CONFLICT DO NOTHING;
+EOSQL
\ No newline at end of file
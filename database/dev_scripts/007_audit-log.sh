@@ -0,0 +1,31 @@
+#!/bin/bash
+set -e
+
+psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" <<-EOSQL
+    CREATE TABLE IF NOT EXISTS
+        "setting.audit_log" (
+            id BIGINT GENERATED BY DEFAULT AS IDENTITY (
+                START
+                WITH
+                    1000
+            ) PRIMARY KEY,
+            resource VARCHAR(64) NOT NULL,
+            record_id BIGINT NOT NULL,
+            action VARCHAR(16) NOT NULL,
+            changes JSONB NOT NULL,
+            ip VARCHAR(64) NOT NULL DEFAULT '',
+            request_id VARCHAR(64) NOT NULL DEFAULT '',
+            -- Timestamps
+            cid BIGINT NOT NULL,
+            ctime TIMESTAMP WITH TIME ZONE NOT NULL
+        );
+
+    CREATE INDEX IF NOT EXISTS "setting.audit_log_resource_record_id_idx" ON "setting.audit_log" (resource, record_id);
+
+    CREATE INDEX IF NOT EXISTS "setting.audit_log_ctime_idx" ON "setting.audit_log" (ctime);
+
+    INSERT INTO
+        "setting.permission" (id, name, cid, ctime, mid, mtime)
+    VALUES
+        (44, 'VIEW_SETTING_AUDIT_LOGS', 1, NOW(), 1, NOW());
+EOSQL
\ No newline at end of file
@@ -0,0 +1,9 @@
+#!/bin/bash
+set -e
+
+psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" <<-EOSQL
+    ALTER TABLE "setting.audit_log"
+    ADD COLUMN IF NOT EXISTS act_id BIGINT;
+
+    CREATE INDEX IF NOT EXISTS "setting.audit_log_act_id_idx" ON "setting.audit_log" (act_id);
+EOSQL
\ No newline at end of file
@@ -0,0 +1,16 @@
+#!/bin/bash
+set -e
+
+psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" <<-EOSQL
+    DO \$\$ BEGIN
+    CREATE TYPE setting_user_state_typ AS ENUM('active', 'inactive', 'archived');
+    EXCEPTION
+    WHEN duplicate_object THEN null;
+    END \$\$;
+
+    ALTER TABLE "setting.user"
+    ADD COLUMN IF NOT EXISTS state setting_user_state_typ NOT NULL DEFAULT 'active',
+    ADD COLUMN IF NOT EXISTS sessions_revoked_at TIMESTAMP WITH TIME ZONE;
+
+    CREATE INDEX IF NOT EXISTS "setting.user_state_idx" ON "setting.user" (state);
+EOSQL
\ No newline at end of file
@@ -0,0 +1,56 @@
+#!/bin/bash
+set -e
+
+psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" <<-EOSQL
+    CREATE TABLE IF NOT EXISTS
+        "setting.session" (
+            id BIGINT GENERATED BY DEFAULT AS IDENTITY (
+                START
+                WITH
+                    1000
+            ) PRIMARY KEY,
+            sid VARCHAR(64) NOT NULL,
+            setting_user_id BIGINT NOT NULL,
+            ip VARCHAR(64) NOT NULL DEFAULT '',
+            user_agent VARCHAR(512) NOT NULL DEFAULT '',
+            last_used_at TIMESTAMP WITH TIME ZONE NOT NULL,
+            expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+            revoked_at TIMESTAMP WITH TIME ZONE,
+            -- Timestamps
+            ctime TIMESTAMP WITH TIME ZONE NOT NULL,
+            CONSTRAINT "setting.session_sid_key" UNIQUE (sid),
+            CONSTRAINT "setting.user_id_fkey" FOREIGN KEY (setting_user_id) REFERENCES "setting.user" (id) ON DELETE CASCADE
+        );
+
+    CREATE INDEX IF NOT EXISTS "setting.session_user_id_idx" ON "setting.session" (setting_user_id);
+
+    CREATE TABLE IF NOT EXISTS
+        "setting.login_event" (
+            id BIGINT GENERATED BY DEFAULT AS IDENTITY (
+                START
+                WITH
+                    1000
+            ) PRIMARY KEY,
+            setting_user_id BIGINT,
+            email VARCHAR(256) NOT NULL,
+            method VARCHAR(16) NOT NULL,
+            success BOOLEAN NOT NULL,
+            reason VARCHAR(64) NOT NULL DEFAULT '',
+            ip VARCHAR(64) NOT NULL DEFAULT '',
+            user_agent VARCHAR(512) NOT NULL DEFAULT '',
+            -- Timestamps
+            ctime TIMESTAMP WITH TIME ZONE NOT NULL,
+            CONSTRAINT "setting.user_id_fkey" FOREIGN KEY (setting_user_id) REFERENCES "setting.user" (id) ON DELETE SET NULL
+        );
+
+    CREATE INDEX IF NOT EXISTS "setting.login_event_user_id_ctime_idx" ON "setting.login_event" (setting_user_id, ctime);
+
+    CREATE INDEX IF NOT EXISTS "setting.login_event_ctime_idx" ON "setting.login_event" (ctime);
+
+    INSERT INTO
+        "setting.permission" (id, name, cid, ctime, mid, mtime)
+    VALUES
+        (45, 'VIEW_SETTING_SESSIONS', 1, NOW(), 1, NOW()),
+        (46, 'DELETE_SETTING_SESSIONS', 1, NOW(), 1, NOW()),
+        (47, 'VIEW_SETTING_LOGIN_EVENTS', 1, NOW(), 1, NOW());
+EOSQL
\ No newline at end of file
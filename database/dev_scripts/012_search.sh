@@ -0,0 +1,56 @@
+#!/bin/bash
+set -e
+
+psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" <<-EOSQL
+    CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+    -- search_vector is matched with full-text search, search_text with trigrams
+    -- when the words don't match, e.g. typos or partial words
+    ALTER TABLE "setting.customer"
+    ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', fullname), 'A') || setweight(to_tsvector('simple', email || ' ' || phone), 'B')
+    ) STORED,
+    ADD COLUMN IF NOT EXISTS search_text TEXT GENERATED ALWAYS AS (fullname || ' ' || email || ' ' || phone) STORED;
+
+    ALTER TABLE "sales.quotation"
+    ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (setweight(to_tsvector('simple', name), 'A')) STORED,
+    ADD COLUMN IF NOT EXISTS search_text TEXT GENERATED ALWAYS AS (name) STORED;
+
+    ALTER TABLE "sales.order"
+    ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', name), 'A') || setweight(to_tsvector('simple', note), 'C')
+    ) STORED,
+    ADD COLUMN IF NOT EXISTS search_text TEXT GENERATED ALWAYS AS (name || ' ' || note) STORED;
+
+    ALTER TABLE "accounting.account"
+    ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', code), 'A') || setweight(to_tsvector('simple', name), 'A')
+    ) STORED,
+    ADD COLUMN IF NOT EXISTS search_text TEXT GENERATED ALWAYS AS (code || ' ' || name) STORED;
+
+    ALTER TABLE "accounting.journal_entry"
+    ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', name), 'A') || setweight(to_tsvector('simple', note), 'C')
+    ) STORED,
+    ADD COLUMN IF NOT EXISTS search_text TEXT GENERATED ALWAYS AS (name || ' ' || note) STORED;
+
+    CREATE INDEX IF NOT EXISTS "setting.customer_search_vector_idx" ON "setting.customer" USING GIN (search_vector);
+
+    CREATE INDEX IF NOT EXISTS "setting.customer_search_text_idx" ON "setting.customer" USING GIN (search_text gin_trgm_ops);
+
+    CREATE INDEX IF NOT EXISTS "sales.quotation_search_vector_idx" ON "sales.quotation" USING GIN (search_vector);
+
+    CREATE INDEX IF NOT EXISTS "sales.quotation_search_text_idx" ON "sales.quotation" USING GIN (search_text gin_trgm_ops);
+
+    CREATE INDEX IF NOT EXISTS "sales.order_search_vector_idx" ON "sales.order" USING GIN (search_vector);
+
+    CREATE INDEX IF NOT EXISTS "sales.order_search_text_idx" ON "sales.order" USING GIN (search_text gin_trgm_ops);
+
+    CREATE INDEX IF NOT EXISTS "accounting.account_search_vector_idx" ON "accounting.account" USING GIN (search_vector);
+
+    CREATE INDEX IF NOT EXISTS "accounting.account_search_text_idx" ON "accounting.account" USING GIN (search_text gin_trgm_ops);
+
+    CREATE INDEX IF NOT EXISTS "accounting.journal_entry_search_vector_idx" ON "accounting.journal_entry" USING GIN (search_vector);
+
+    CREATE INDEX IF NOT EXISTS "accounting.journal_entry_search_text_idx" ON "accounting.journal_entry" USING GIN (search_text gin_trgm_ops);
+EOSQL
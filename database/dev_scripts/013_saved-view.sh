@@ -0,0 +1,30 @@
+#!/bin/bash
+set -e
+
+psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" <<-EOSQL
+    -- A view is private to its creator unless shared with the users of a role
+    CREATE TABLE IF NOT EXISTS
+        "setting.saved_view" (
+            id BIGINT GENERATED BY DEFAULT AS IDENTITY (
+                START
+                WITH
+                    1000
+            ) PRIMARY KEY,
+            name VARCHAR(64) NOT NULL,
+            endpoint VARCHAR(128) NOT NULL,
+            filters JSONB NOT NULL DEFAULT '{}',
+            sorts JSONB NOT NULL DEFAULT '{}',
+            columns JSONB NOT NULL DEFAULT '[]',
+            setting_role_id BIGINT,
+            -- Timestamps
+            cid BIGINT NOT NULL,
+            ctime TIMESTAMP WITH TIME ZONE NOT NULL,
+            mid BIGINT NOT NULL,
+            mtime TIMESTAMP WITH TIME ZONE NOT NULL,
+            CONSTRAINT "setting.saved_view_name_key" UNIQUE (cid, endpoint, name),
+            CONSTRAINT "setting.user_id_fkey" FOREIGN KEY (cid) REFERENCES "setting.user" (id) ON DELETE CASCADE,
+            CONSTRAINT "setting.role_id_fkey" FOREIGN KEY (setting_role_id) REFERENCES "setting.role" (id) ON DELETE SET NULL
+        );
+
+    CREATE INDEX IF NOT EXISTS "setting.saved_view_endpoint_idx" ON "setting.saved_view" (endpoint);
+EOSQL
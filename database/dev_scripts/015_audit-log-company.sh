@@ -0,0 +1,11 @@
+#!/bin/bash
+set -e
+
+psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" <<-EOSQL
+    -- Existing audit logs belong to the default company
+    ALTER TABLE "setting.audit_log"
+    ADD COLUMN IF NOT EXISTS setting_company_id BIGINT NOT NULL DEFAULT 1,
+    ADD CONSTRAINT "setting.company_id_fkey" FOREIGN KEY (setting_company_id) REFERENCES "setting.company" (id) ON DELETE RESTRICT;
+
+    CREATE INDEX IF NOT EXISTS "setting.audit_log_company_id_idx" ON "setting.audit_log" (setting_company_id);
+EOSQL
@@ -10,6 +10,7 @@ import (
 	"system.buon18.com/m/database"
 	"system.buon18.com/m/middlewares"
 	"system.buon18.com/m/routes"
+	"system.buon18.com/m/utils"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -23,10 +24,15 @@ func main() {
 	DB = database.InitSQL(config.DB_CONNECTION_STRING)
 	defer DB.Close()
 
+	utils.MAX_PAGE_SIZE = config.MAX_PAGE_SIZE
+
 	// -- Initialize Valkey
 	valkeyClient := database.InitValkey(config.VALKEY_ADDRESSES, config.VALKEY_PWD)
 	if valkeyClient != nil {
 		defer (*valkeyClient).Close()
+
+		// -- Invalidations of the principal cache reach every instance
+		utils.GetPrincipalCache().UseSharedVersion(utils.NewValkeyPrincipalCacheVersionStore(*valkeyClient))
 	}
 
 	connection := database.Connection{
@@ -45,17 +51,19 @@ func main() {
 		MaxAge:        config.MAX_AGE,
 	}))
 
+	router.Use(middlewares.RequestId())
 	router.Use(middlewares.Logger())
 
 	// -- Routes
 	// -- Public
-	routes.Auth(router, DB)
+	routes.Auth(router, &connection)
 
 	// -- Private
 	router.Use(middlewares.Authenticate(DB))
 	routes.Setting(router, &connection)
 	routes.Sales(router, &connection)
 	routes.Accounting(router, &connection)
+	routes.Search(router, &connection)
 
 	router.Routes()
 
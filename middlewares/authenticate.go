@@ -10,7 +10,6 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/nullism/bqb"
 )
 
 func Authenticate(DB *sql.DB) gin.HandlerFunc {
@@ -37,61 +36,94 @@ func Authenticate(DB *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		// -- Prepare sql query
-		query, params, err := bqb.New(`
-		SELECT 
-			"setting.user".id, 
-			"setting.user".name, 
-			"setting.user".email, 
-			"setting.user".typ, 
-			COALESCE("setting.role".id, 0), 
-			COALESCE("setting.role".name, ''), 
-			COALESCE("setting.role".description, ''), 
-			COALESCE("setting.permission".id, 0), 
-			COALESCE("setting.permission".name, '')
-		FROM 
-			"setting.user"
-		LEFT JOIN "setting.role" ON "setting.user".setting_role_id = "setting.role".id
-		LEFT JOIN "setting.role_permission" ON "setting.role".id = "setting.role_permission".setting_role_id 
-		LEFT JOIN "setting.permission" ON "setting.role_permission".setting_permission_id = "setting.permission".id
-		WHERE "setting.user".email = ?
-		ORDER BY "setting.user".email, "setting.role".id, "setting.permission".id`, claims.Email).ToPgsql()
+		principal, err := cachedPrincipal(DB, claims.Email)
 		if err != nil {
+			log.Printf("Error loading user: %v\n", err)
 			c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
 			c.Abort()
 			return
 		}
 
-		// -- Validate user
-		rows, err := DB.Query(query, params...)
-		if err != nil {
-			log.Printf("Error querying user: %v\n", err)
-			c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+		// -- Deactivated users and revoked sessions are rejected
+		if principal.User.Id == 0 {
+			c.JSON(401, utils.NewErrorResponse(401, "invalid token"))
+			c.Abort()
+			return
+		}
+		if !principal.User.IsActive() {
+			c.JSON(401, utils.NewErrorResponse(401, "account is not active"))
+			c.Abort()
+			return
+		}
+		if principal.User.IsSessionRevoked(claims.IssuedAt) || utils.ContainsString(principal.RevokedSessionIds, claims.Sid) {
+			c.JSON(401, utils.NewErrorResponse(401, "token revoked"))
 			c.Abort()
 			return
 		}
 
-		var user setting.SettingUser
-		var role setting.SettingRole
-		permissions := make([]setting.SettingPermission, 0)
-		for rows.Next() {
-			var permission setting.SettingPermission
-			err = rows.Scan(&user.Id, &user.Name, &user.Email, &user.Typ, &role.Id, &role.Name, &role.Description, &permission.Id, &permission.Name)
+		// -- The admin impersonating the user must still be active with full access
+		if claims.Act != "" {
+			actor, err := cachedPrincipal(DB, claims.Act)
 			if err != nil {
-				log.Printf("Error scanning user: %v\n", err)
+				log.Printf("Error loading actor: %v\n", err)
 				c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
 				c.Abort()
 				return
 			}
 
-			permissions = append(permissions, permission)
+			if actor.User.Id == 0 || !actor.User.IsActive() || actor.User.IsSessionRevoked(claims.IssuedAt) || !utils.HasAnyPermission(permissionNames(actor.Permissions), []string{utils.PREDEFINED_PERMISSIONS.FULL_ACCESS}) {
+				c.JSON(401, utils.NewErrorResponse(401, "invalid token"))
+				c.Abort()
+				return
+			}
+			c.Set("actor", actor.User)
+		}
+
+		// -- Resolve the active company, defaults to the first allowed company
+		company, err := utils.ResolveCompany(principal.Companies, c.GetHeader(utils.COMPANY_HEADER))
+		if err != nil {
+			c.JSON(403, utils.NewErrorResponse(403, err.Error()))
+			c.Abort()
+			return
 		}
 
 		// -- Set user info
-		c.Set("user", user)
-		c.Set("role", role)
-		c.Set("permissions", permissions)
+		c.Set("user", principal.User)
+		c.Set("role", principal.Role)
+		c.Set("roles", principal.Roles)
+		c.Set("permissions", principal.Permissions)
+		c.Set("role_permissions", principal.RolePermissions)
+		c.Set("record_rules", principal.RecordRules)
+		c.Set("company", company)
+		c.Set("companies", principal.Companies)
+		c.Set("session_id", claims.Sid)
 
 		c.Next()
 	}
 }
+
+// cachedPrincipal returns the cached principal of email, loading and caching
+// it when missing.
+func cachedPrincipal(DB *sql.DB, email string) (utils.Principal, error) {
+	principalCache := utils.GetPrincipalCache()
+	if principal, ok := principalCache.Get(email); ok {
+		return principal, nil
+	}
+	version := principalCache.Version()
+
+	principal, err := utils.LoadPrincipal(DB, email)
+	if err != nil {
+		return utils.Principal{}, err
+	}
+	principalCache.Set(email, principal, version)
+
+	return principal, nil
+}
+
+func permissionNames(permissions []setting.SettingPermission) []string {
+	names := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		names = append(names, permission.Name)
+	}
+	return names
+}
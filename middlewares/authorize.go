@@ -2,7 +2,6 @@ package middlewares
 
 import (
 	"log"
-	"strings"
 
 	"system.buon18.com/m/models/setting"
 	"system.buon18.com/m/utils"
@@ -23,19 +22,12 @@ func Authorize(allowPermissions []string) gin.HandlerFunc {
 			return
 		}
 
-		// -- Add FULL_ACCESS permission
-		allowPermissions = append(allowPermissions, "FULL_ACCESS")
-
-		allow := false
-		for _, permission := range allowPermissions {
-			// -- Check permission
-			for _, ctxPermission := range ctxPermissions {
-				if strings.EqualFold(ctxPermission.Name, permission) {
-					allow = true
-					break
-				}
-			}
+		// -- Permissions implied by the hierarchy are already expanded on authentication
+		names := make([]string, 0, len(ctxPermissions))
+		for _, ctxPermission := range ctxPermissions {
+			names = append(names, ctxPermission.Name)
 		}
+		allow := utils.HasAnyPermission(names, allowPermissions)
 
 		if !allow {
 			c.JSON(403, utils.NewErrorResponse(403, utils.ErrForbidden.Error()))
@@ -0,0 +1,78 @@
+package middlewares
+
+import (
+	"bytes"
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"system.buon18.com/m/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// -- Holds the body back until the validators of the response are known
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (writer *bufferedWriter) Write(data []byte) (int, error) {
+	return writer.body.Write(data)
+}
+
+func (writer *bufferedWriter) WriteString(data string) (int, error) {
+	return writer.body.WriteString(data)
+}
+
+// ConditionalGet emits an ETag for the list and detail responses, a hash of
+// the response stored by the handler, and answers a matching If-None-Match
+// with 304. Detail routes pass the table of the record to also emit its mtime
+// as Last-Modified, If-Modified-Since is only evaluated without If-None-Match
+// as the mtime doesn't cover the records the response embeds.
+func ConditionalGet(DB *sql.DB, table string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writer := &bufferedWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		defer func() {
+			c.Writer = writer.ResponseWriter
+		}()
+
+		c.Next()
+
+		response, ok := c.Get("response")
+		if !ok || writer.Status() != 200 {
+			writer.ResponseWriter.Write(writer.body.Bytes())
+			return
+		}
+
+		etag := utils.ETag(response.([]byte), writer.Header().Get("X-Total-Count"))
+		writer.Header().Set("ETag", etag)
+		notModified := false
+		if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" {
+			notModified = utils.ETagMatches(ifNoneMatch, etag)
+		}
+
+		if table != "" {
+			if mtime, err := utils.RecordMTime(DB, table, c.Param("id")); err == nil {
+				// -- Truncated to the precision of the header
+				mtime = mtime.UTC().Truncate(time.Second)
+				writer.Header().Set("Last-Modified", mtime.Format(http.TimeFormat))
+				if ifModifiedSince, err := http.ParseTime(c.GetHeader("If-Modified-Since")); err == nil && c.GetHeader("If-None-Match") == "" {
+					notModified = !mtime.After(ifModifiedSince)
+				}
+			} else {
+				log.Printf("ConditionalGet: %v\n", err)
+			}
+		}
+
+		if notModified {
+			writer.Header().Del("Content-Type")
+			writer.ResponseWriter.WriteHeader(304)
+			writer.ResponseWriter.WriteHeaderNow()
+			return
+		}
+		writer.ResponseWriter.Write(writer.body.Bytes())
+	}
+}
@@ -0,0 +1,250 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"system.buon18.com/m/config"
+	"system.buon18.com/m/database"
+	"system.buon18.com/m/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nullism/bqb"
+	"github.com/valkey-io/valkey-go"
+)
+
+const (
+	IDEMPOTENCY_KEY_HEADER      = "Idempotency-Key"
+	IDEMPOTENCY_REPLAYED_HEADER = "Idempotent-Replayed"
+	IDEMPOTENCY_KEY_PREFIX      = "idempotency_"
+	// -- A request that didn't complete in time, e.g. the server stopped, releases its key
+	IDEMPOTENCY_PROCESSING_TIMEOUT = time.Minute
+)
+
+type idempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	// -- 0 while the first request is processed
+	StatusCode int    `json:"status_code"`
+	Response   []byte `json:"response"`
+}
+
+// -- Records are kept in Valkey, or in Postgres when Valkey is unavailable
+type idempotencyStore interface {
+	// reserve stores the record of a request being processed, the record of
+	// the key is returned instead if it exists.
+	reserve(key string, fingerprint string) (idempotencyRecord, bool, error)
+	complete(key string, record idempotencyRecord, window time.Duration) error
+	release(key string) error
+}
+
+type valkeyIdempotencyStore struct {
+	valkeyClient valkey.Client
+}
+
+func (store valkeyIdempotencyStore) reserve(key string, fingerprint string) (idempotencyRecord, bool, error) {
+	ctx := context.Background()
+	recordByte, err := json.Marshal(idempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		return idempotencyRecord{}, false, err
+	}
+
+	err = store.valkeyClient.Do(ctx, store.valkeyClient.B().Set().Key(IDEMPOTENCY_KEY_PREFIX+key).Value(string(recordByte)).Nx().Ex(IDEMPOTENCY_PROCESSING_TIMEOUT).Build()).Error()
+	if err == nil {
+		return idempotencyRecord{}, true, nil
+	}
+	if !valkey.IsValkeyNil(err) {
+		return idempotencyRecord{}, false, err
+	}
+
+	recordStr, err := store.valkeyClient.Do(ctx, store.valkeyClient.B().Get().Key(IDEMPOTENCY_KEY_PREFIX+key).Build()).ToString()
+	if valkey.IsValkeyNil(err) {
+		// -- The record expired in between, the request is retried
+		return store.reserve(key, fingerprint)
+	} else if err != nil {
+		return idempotencyRecord{}, false, err
+	}
+
+	var record idempotencyRecord
+	err = json.Unmarshal([]byte(recordStr), &record)
+	return record, false, err
+}
+
+func (store valkeyIdempotencyStore) complete(key string, record idempotencyRecord, window time.Duration) error {
+	recordByte, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return store.valkeyClient.Do(context.Background(), store.valkeyClient.B().Set().Key(IDEMPOTENCY_KEY_PREFIX+key).Value(string(recordByte)).Ex(window).Build()).Error()
+}
+
+func (store valkeyIdempotencyStore) release(key string) error {
+	return store.valkeyClient.Do(context.Background(), store.valkeyClient.B().Del().Key(IDEMPOTENCY_KEY_PREFIX+key).Build()).Error()
+}
+
+type postgresIdempotencyStore struct {
+	DB *sql.DB
+}
+
+func (store postgresIdempotencyStore) reserve(key string, fingerprint string) (idempotencyRecord, bool, error) {
+	// -- An expired record is taken over as if the key was never used
+	now := time.Now()
+	query, params, err := bqb.New(`
+	INSERT INTO "setting.idempotency_key" (key, fingerprint, expires_at) VALUES (?, ?, ?)
+	ON CONFLICT (key) DO UPDATE SET fingerprint = EXCLUDED.fingerprint, status_code = 0, response = NULL, expires_at = EXCLUDED.expires_at
+	WHERE "setting.idempotency_key".expires_at < ?
+	RETURNING key`, key, fingerprint, now.Add(IDEMPOTENCY_PROCESSING_TIMEOUT), now).ToPgsql()
+	if err != nil {
+		return idempotencyRecord{}, false, err
+	}
+
+	var reservedKey string
+	err = store.DB.QueryRow(query, params...).Scan(&reservedKey)
+	if err == nil {
+		return idempotencyRecord{}, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return idempotencyRecord{}, false, err
+	}
+
+	query, params, err = bqb.New(`SELECT fingerprint, status_code, response FROM "setting.idempotency_key" WHERE key = ?`, key).ToPgsql()
+	if err != nil {
+		return idempotencyRecord{}, false, err
+	}
+
+	var record idempotencyRecord
+	err = store.DB.QueryRow(query, params...).Scan(&record.Fingerprint, &record.StatusCode, &record.Response)
+	if err == sql.ErrNoRows {
+		// -- The record was released in between, the request is retried
+		return store.reserve(key, fingerprint)
+	}
+	return record, false, err
+}
+
+func (store postgresIdempotencyStore) complete(key string, record idempotencyRecord, window time.Duration) error {
+	query, params, err := bqb.New(`UPDATE "setting.idempotency_key" SET status_code = ?, response = ?, expires_at = ? WHERE key = ?`, record.StatusCode, record.Response, time.Now().Add(window), key).ToPgsql()
+	if err != nil {
+		return err
+	}
+	_, err = store.DB.Exec(query, params...)
+	return err
+}
+
+func (store postgresIdempotencyStore) release(key string) error {
+	query, params, err := bqb.New(`DELETE FROM "setting.idempotency_key" WHERE key = ?`, key).ToPgsql()
+	if err != nil {
+		return err
+	}
+	_, err = store.DB.Exec(query, params...)
+	return err
+}
+
+// idempotencyFingerprint identifies the body of a request, regardless of its
+// JSON formatting.
+func idempotencyFingerprint(body []byte) string {
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, body); err == nil {
+		body = compacted.Bytes()
+	}
+	hash := sha256.Sum256(body)
+	return hex.EncodeToString(hash[:])
+}
+
+// Idempotency replays the first response of a create request to the retries
+// of its Idempotency-Key, for IDEMPOTENCY_KEY_SEC. Keys are specific to the
+// user, the active company and the endpoint, a key reused with a different
+// body is rejected.
+// Server errors aren't kept so that the request can be retried.
+func Idempotency(connection *database.Connection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idempotencyKey := c.GetHeader(IDEMPOTENCY_KEY_HEADER)
+		window := time.Duration(config.GetConfigInstance().IDEMPOTENCY_KEY_SEC) * time.Second
+		if idempotencyKey == "" || window <= 0 {
+			c.Next()
+			return
+		}
+
+		if len(idempotencyKey) > 255 {
+			c.JSON(400, utils.NewErrorResponse(400, "Idempotency-Key must be at most 255 characters"))
+			c.Abort()
+			return
+		}
+
+		ctx, err := utils.Ctx(c)
+		if err != nil {
+			c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(400, utils.NewErrorResponse(400, "invalid request"))
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var store idempotencyStore = postgresIdempotencyStore{DB: connection.DB}
+		if connection.Valkey != nil {
+			store = valkeyIdempotencyStore{valkeyClient: *connection.Valkey}
+		}
+
+		keyHash := sha256.Sum256([]byte(fmt.Sprintf("%d_%d_%s_%s", ctx.User.Id, ctx.Company.Id, c.FullPath(), idempotencyKey)))
+		key := hex.EncodeToString(keyHash[:])
+		fingerprint := idempotencyFingerprint(body)
+
+		record, reserved, err := store.reserve(key, fingerprint)
+		if err != nil {
+			log.Printf("Idempotency: %v\n", err)
+			c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+			c.Abort()
+			return
+		}
+
+		if !reserved {
+			switch {
+			case record.Fingerprint != fingerprint:
+				c.JSON(422, utils.NewErrorResponse(422, "Idempotency-Key was used with a different request"))
+			case record.StatusCode == 0:
+				c.JSON(409, utils.NewErrorResponse(409, "request with this Idempotency-Key is being processed"))
+			default:
+				c.Header(IDEMPOTENCY_REPLAYED_HEADER, "true")
+				c.Data(record.StatusCode, "application/json; charset=utf-8", record.Response)
+			}
+			c.Abort()
+			return
+		}
+
+		writer := &bufferedWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		defer func() {
+			c.Writer = writer.ResponseWriter
+		}()
+
+		c.Next()
+
+		response := writer.body.Bytes()
+		writer.ResponseWriter.Write(response)
+
+		if writer.Status() >= 500 {
+			err = store.release(key)
+		} else {
+			err = store.complete(key, idempotencyRecord{
+				Fingerprint: fingerprint,
+				StatusCode:  writer.Status(),
+				Response:    response,
+			}, window)
+		}
+		if err != nil {
+			log.Printf("Idempotency: %v\n", err)
+		}
+	}
+}
@@ -40,6 +40,7 @@ func Logger() gin.HandlerFunc {
 		log.SetOutput(file)
 		logLine := RequestLogLine{
 			Id:            strconv.Itoa(int(ctx.User.Id)),
+			RequestId:     c.GetString("request_id"),
 			TimeIn:        timeStart.Format(time.RFC3339),
 			DurationMs:    timeEnd.Sub(timeStart).Milliseconds(),
 			StatusCode:    c.Writer.Status(),
@@ -54,6 +55,7 @@ func Logger() gin.HandlerFunc {
 
 type RequestLogLine struct {
 	Id         string `json:"id"`
+	RequestId  string `json:"request_id"`
 	TimeIn     string `json:"time_in"`
 	DurationMs int64  `json:"duration_ms"`
 
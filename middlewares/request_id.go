@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"log"
+
+	"system.buon18.com/m/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const REQUEST_ID_HEADER = "X-Request-Id"
+
+// RequestId keeps the request id given by a proxy or generates one, so that a
+// request can be traced from the response to the logs and the audit trail.
+func RequestId() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestId := c.GetHeader(REQUEST_ID_HEADER)
+		if requestId == "" || len(requestId) > 64 {
+			var err error
+			requestId, err = utils.RandomString(16)
+			if err != nil {
+				log.Printf("Error generating request id: %v\n", err)
+			}
+		}
+
+		c.Set("request_id", requestId)
+		c.Header(REQUEST_ID_HEADER, requestId)
+
+		c.Next()
+	}
+}
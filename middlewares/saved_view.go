@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"database/sql"
+	"strconv"
+
+	settingServices "system.buon18.com/m/services/setting"
+	"system.buon18.com/m/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SavedView applies the saved view of view_id to the list request, the view
+// must be visible to the user and saved for the endpoint of the request.
+func SavedView(DB *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// -- gin caches the query on the first read, it's read from the URL until rewritten
+		query := c.Request.URL.Query()
+		if !query.Has(utils.VIEW_ID_PARAM) {
+			c.Next()
+			return
+		}
+
+		viewId := query.Get(utils.VIEW_ID_PARAM)
+		if _, err := strconv.Atoi(viewId); err != nil {
+			c.JSON(400, utils.NewErrorResponse(400, "view_id must be an integer"))
+			c.Abort()
+			return
+		}
+
+		ctx, err := utils.Ctx(c)
+		if err != nil {
+			c.JSON(500, utils.NewErrorResponse(500, utils.ErrInternalServer.Error()))
+			c.Abort()
+			return
+		}
+
+		service := settingServices.SettingSavedViewService{DB: DB}
+		savedView, statusCode, err := service.SavedView(&ctx, viewId)
+		if err != nil {
+			c.JSON(statusCode, utils.NewErrorResponse(statusCode, err.Error()))
+			c.Abort()
+			return
+		}
+
+		if savedView.Endpoint != c.FullPath() {
+			c.JSON(400, utils.NewErrorResponse(400, "saved view is not a view of this endpoint"))
+			c.Abort()
+			return
+		}
+
+		// -- Columns only apply to the endpoints with sparse fieldsets
+		var columns []string
+		if _, ok := settingServices.SAVED_VIEW_COLUMNS[savedView.Endpoint]; ok {
+			columns = savedView.Columns
+		}
+
+		c.Request.URL.RawQuery = utils.ApplyView(query, savedView.Filters, savedView.Sorts, columns).Encode()
+		c.Next()
+	}
+}
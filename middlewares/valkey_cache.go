@@ -5,79 +5,246 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"time"
+	"strings"
 
-	"system.buon18.com/m/config"
 	"system.buon18.com/m/database"
 	"system.buon18.com/m/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/valkey-io/valkey-go"
 )
 
-func ValkeyCache[T interface{}](connection *database.Connection, fieldName string) gin.HandlerFunc {
+// -- Version of a cache tag, bumped by every purge of the tag
+const CACHE_TAG_KEY_PREFIX = "cache_tag_"
+
+// cacheTagsVersion returns the versions of tags, e.g. "3.0.1", a tag that was
+// never purged is at version 0.
+func cacheTagsVersion(ctx context.Context, valkeyClient valkey.Client, tags []string) (string, error) {
+	keys := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		keys = append(keys, CACHE_TAG_KEY_PREFIX+tag)
+	}
+
+	messages, err := valkeyClient.Do(ctx, valkeyClient.B().Mget().Key(keys...).Build()).ToArray()
+	if err != nil {
+		return "", err
+	}
+
+	versions := make([]string, 0, len(messages))
+	for _, message := range messages {
+		version, err := message.ToString()
+		if valkey.IsValkeyNil(err) {
+			version = "0"
+		} else if err != nil {
+			return "", err
+		}
+		versions = append(versions, version)
+	}
+	return strings.Join(versions, "."), nil
+}
+
+// cacheKey identifies the response of a GET request, the query parameters are
+// sorted so that their order doesn't matter.
+func cacheKey(c *gin.Context, version string, fingerprint string) string {
+	return fmt.Sprintf("tags_%s_%s_%s?%s", version, fingerprint, c.Request.URL.Path, c.Request.URL.Query().Encode())
+}
+
+// cacheLookup returns the entry of key from the in-process tier, then from
+// Valkey, an entry found in Valkey is kept in process.
+func cacheLookup(ctx context.Context, valkeyClient *valkey.Client, responseCache *utils.ResponseCache, key string) (utils.ResponseCacheEntry, bool) {
+	if entry, ok := responseCache.Get(key); ok {
+		return entry, true
+	}
+	if valkeyClient == nil {
+		return utils.ResponseCacheEntry{}, false
+	}
+
+	entryStr, err := (*valkeyClient).Do(ctx, (*valkeyClient).B().Get().Key(key).Build()).ToString()
+	if err != nil {
+		if !valkey.IsValkeyNil(err) {
+			log.Printf("ValkeyCache: %v\n", err)
+		}
+		return utils.ResponseCacheEntry{}, false
+	}
+
+	var entry utils.ResponseCacheEntry
+	if err := json.Unmarshal([]byte(entryStr), &entry); err != nil {
+		log.Printf("ValkeyCache: %v\n", err)
+		return utils.ResponseCacheEntry{}, false
+	}
+	responseCache.Set(key, entry)
+	return entry, true
+}
+
+func cacheRespond(c *gin.Context, fieldName string, entry utils.ResponseCacheEntry, xCache string) {
+	c.Set("response", []byte(entry.Response))
+	c.Header("X-Cache", xCache)
+	if entry.Total != nil {
+		c.Header("X-Total-Count", utils.IntToStr(*entry.Total))
+	}
+	if entry.NextCursor != "" {
+		c.Header("X-Next-Cursor", entry.NextCursor)
+	}
+	if entry.PrevCursor != "" {
+		c.Header("X-Prev-Cursor", entry.PrevCursor)
+	}
+	c.JSON(200, utils.NewResponse(200, "", gin.H{
+		fieldName: entry.Response,
+	}))
+	c.Abort()
+}
+
+// ValkeyCache caches the list and detail responses of resource under fieldName.
+// The entries are specific to what the user is allowed to see, see
+// utils.CacheFingerprint, and are tagged with the resource and the resources
+// its responses embed, see InvalidateCache.
+//
+// Entries are kept in a bounded in-process tier in front of Valkey, or alone
+// when Valkey is unavailable. Concurrent misses of a key are coalesced into a
+// single request to the database, and an expired entry is still served as
+// stale while one request refreshes it.
+func ValkeyCache(connection *database.Connection, fieldName string, resource string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := context.Background()
 
-		if valkeyClient := connection.Valkey; valkeyClient == nil {
+		responseCache := utils.GetResponseCache()
+		if !responseCache.Enabled() {
 			c.Next()
 			return
-		} else {
-			if c.Request.URL.Path == c.Request.RequestURI {
-				if resourceStr, err := (*valkeyClient).Do(ctx, (*valkeyClient).B().Get().Key(c.Request.RequestURI).Build()).ToString(); err == nil {
-					if totalStr, err := (*valkeyClient).Do(ctx, (*valkeyClient).B().Get().Key(fmt.Sprintf("total_%s", c.Request.RequestURI)).Build()).ToString(); err == nil {
-						var jsonResponse T
-						json.Unmarshal([]byte(resourceStr), &jsonResponse)
-						c.Header("X-Cache", "true")
-						c.Header("X-Total-Count", totalStr)
-						c.JSON(200, utils.NewResponse(200, "", gin.H{
-							fieldName: jsonResponse,
-						}))
-						c.Abort()
-						return
-					}
-				}
+		}
+
+		ctxW, err := utils.Ctx(c)
+		if err != nil {
+			c.Next()
+			return
+		}
+		fingerprint, err := ctxW.CacheFingerprint(resource)
+		if err != nil {
+			log.Printf("ValkeyCache: %v\n", err)
+			c.Next()
+			return
+		}
+
+		// -- Entries are keyed by the versions of their tags, the entries stored
+		// -- before a purge are never read again and expire. The versions are
+		// -- shared through Valkey, they're local to the process without it.
+		tags := utils.CacheTags(resource)
+		valkeyClient := connection.Valkey
+		version := ""
+		if valkeyClient != nil {
+			if valkeyVersion, err := cacheTagsVersion(ctx, *valkeyClient, tags); err == nil {
+				version = "valkey_" + valkeyVersion
+			} else {
+				log.Printf("ValkeyCache: %v\n", err)
+				valkeyClient = nil
 			}
+		}
+		if version == "" {
+			version = "local_" + responseCache.TagsVersion(tags)
+		}
+		key := cacheKey(c, version, fingerprint)
 
+		staleEntry, found := cacheLookup(ctx, valkeyClient, responseCache, key)
+		if found && staleEntry.IsFresh() {
+			responseCache.Hit(false)
+			cacheRespond(c, fieldName, staleEntry, "true")
+			return
+		}
+
+		flight, leader := responseCache.Acquire(key)
+		if !leader {
+			// -- Another request is refreshing the entry, the stale entry is served meanwhile
+			if found {
+				responseCache.Hit(true)
+				cacheRespond(c, fieldName, staleEntry, "stale")
+				return
+			}
+			if entry, ok := flight.Wait(c.Request.Context()); ok {
+				responseCache.Coalesce()
+				cacheRespond(c, fieldName, entry, "true")
+				return
+			}
+
+			// -- The response of the other request couldn't be cached
 			c.Next()
+			return
+		}
+
+		responseCache.Miss()
+		var stored *utils.ResponseCacheEntry
+		// -- The flight is released even if the handler panics, its followers would wait forever
+		defer func() {
+			responseCache.Release(key, flight, stored)
+		}()
+
+		c.Next()
+
+		// -- Only successful responses are cached, the context is read before it's reused
+		value, ok := c.Get("response")
+		if !ok || c.Writer.Status() != 200 {
+			return
+		}
+		entry := responseCache.NewEntry()
+		entry.Response = value.([]byte)
+		entry.NextCursor = c.GetString("next_cursor")
+		entry.PrevCursor = c.GetString("prev_cursor")
+		if total, ok := c.Get("total"); ok {
+			total := total.(int)
+			entry.Total = &total
+		}
+		stored = &entry
+		responseCache.Set(key, entry)
+
+		if valkeyClient == nil {
+			return
+		}
+		go func() {
+			entryByte, err := json.Marshal(entry)
+			if err != nil {
+				log.Printf("ValkeyCache: %v\n", err)
+				return
+			}
 
-			go func() {
-				if c.Request.URL.Path == c.Request.RequestURI {
-					config := config.GetConfigInstance()
-					if value, ok := c.Get("response"); ok {
-						result := value.([]byte)
-						resultStr := string(result)
-						err := (*valkeyClient).Do(
-							ctx,
-							(*valkeyClient).
-								B().
-								Set().
-								Key(c.Request.RequestURI).
-								Value(resultStr).
-								ExatTimestamp(time.Now().Add(time.Duration(config.CACHE_DURATION_SEC)*time.Second).Unix()).
-								Build(),
-						).Error()
-						if err != nil {
-							log.Printf("ValkeyCache: %v\n", err)
-						}
-					}
-					if value, ok := c.Get("total"); ok {
-						result := utils.IntToStr(value.(int))
-						err := (*valkeyClient).Do(
-							ctx,
-							(*valkeyClient).
-								B().
-								Set().
-								Key(fmt.Sprintf("total_%s", c.Request.RequestURI)).
-								Value(result).
-								ExatTimestamp(time.Now().Add(time.Duration(config.CACHE_DURATION_SEC)*time.Second).Unix()).
-								Build(),
-						).Error()
-						if err != nil {
-							log.Printf("ValkeyCache: %v\n", err)
-						}
-					}
-				}
-			}()
+			// -- The entry is kept until it can't be served stale anymore
+			err = (*valkeyClient).Do(
+				ctx,
+				(*valkeyClient).
+					B().
+					Set().
+					Key(key).
+					Value(string(entryByte)).
+					ExatTimestamp(responseCache.ExpiresAt(entry).Unix()).
+					Build(),
+			).Error()
+			if err != nil {
+				log.Printf("ValkeyCache: %v\n", err)
+			}
+		}()
+	}
+}
+
+// InvalidateCache purges the cached responses tagged with resources once the
+// request succeeded, along with the responses embedding them.
+func InvalidateCache(connection *database.Connection, resources ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.Writer.Status() >= 400 {
+			return
+		}
+
+		utils.GetResponseCache().Purge(resources...)
+
+		valkeyClient := connection.Valkey
+		if valkeyClient == nil {
+			return
+		}
+
+		ctx := context.Background()
+		for _, resource := range resources {
+			if err := (*valkeyClient).Do(ctx, (*valkeyClient).B().Incr().Key(CACHE_TAG_KEY_PREFIX+resource).Build()).Error(); err != nil {
+				log.Printf("InvalidateCache: %v\n", err)
+			}
 		}
 	}
 }
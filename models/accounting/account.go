@@ -10,6 +10,9 @@ import (
 
 var AccountingAccountAllowFilterFieldsAndOps = []string{"name:like", "code:like", "typ:eq"}
 var AccountingAccountAllowSortFields = []string{"name", "typ"}
+var AccountingAccountAllowGroupFields = []string{"typ"}
+var AccountingAccountAllowAggregateFields = []string{}
+var AccountingAccountSortFields = models.SortFields{"typ": {Typ: models.SORT_TYPE_ENUM}}
 
 type AccountingAccount struct {
 	*models.CommonModel
@@ -42,6 +45,7 @@ type AccountingAccountCreateRequest struct {
 }
 
 type AccountingAccountUpdateRequest struct {
+	models.CommonUpdatePrecondition
 	Name *string `json:"name" validate:"omitempty"`
 	Code *string `json:"code" validate:"omitempty"`
 	Typ  *string `json:"type" validate:"omitempty,accounting_account_typ"`
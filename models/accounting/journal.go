@@ -8,8 +8,11 @@ import (
 	"github.com/nullism/bqb"
 )
 
-var AccountingJournalAllowFilterFieldsAndOps = []string{"code:like", "name:like", "typ:eq"}
-var AccountingJournalAllowSortFields = []string{"code", "name", "typ"}
+var AccountingJournalAllowFilterFieldsAndOps = []string{"code:like", "name:like", "typ:eq", "account.code:like", "account.name:like"}
+var AccountingJournalAllowSortFields = []string{"code", "name", "typ", "account.code"}
+var AccountingJournalAllowGroupFields = []string{"typ", "account.name"}
+var AccountingJournalAllowAggregateFields = []string{}
+var AccountingJournalSortFields = models.SortFields{"typ": {Typ: models.SORT_TYPE_ENUM}}
 
 type AccountingJournal struct {
 	*models.CommonModel
@@ -50,6 +53,7 @@ type AccountingJournalCreateRequest struct {
 }
 
 type AccountingJournalUpdateRequest struct {
+	models.CommonUpdatePrecondition
 	Code      *string `json:"code"`
 	Name      *string `json:"name"`
 	Typ       *string `json:"type" validate:"omitempty,accounting_journal_typ"`
@@ -9,8 +9,11 @@ import (
 	"github.com/nullism/bqb"
 )
 
-var AccountingJournalEntryAllowFilterFieldsAndOps = []string{"status:in", "date:gte", "date:lte", "date:gt", "date:lt", "date:eq", "name:like"}
-var AccountingJournalEntryAllowSortFields = []string{"name", "date", "status"}
+var AccountingJournalEntryAllowFilterFieldsAndOps = []string{"status:in", "date:gte", "date:lte", "date:gt", "date:lt", "date:eq", "name:like", "journal.code:like", "journal.name:like"}
+var AccountingJournalEntryAllowSortFields = []string{"name", "date", "status", "journal.name"}
+var AccountingJournalEntrySortFields = models.SortFields{"date": {Typ: models.SORT_TYPE_DATE}, "status": {Typ: models.SORT_TYPE_ENUM}}
+var AccountingJournalEntryAllowGroupFields = []string{"status", "date:date", "journal.name"}
+var AccountingJournalEntryAllowAggregateFields = []string{}
 
 type AccountingJournalEntry struct {
 	*models.CommonModel
@@ -68,6 +71,7 @@ type AccountingJournalEntryCreateRequest struct {
 }
 
 type AccountingJournalEntryUpdateRequest struct {
+	models.CommonUpdatePrecondition
 	Name        *string                                    `json:"name"`
 	Date        *time.Time                                 `json:"date"`
 	Note        *string                                    `json:"note"`
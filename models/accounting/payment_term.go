@@ -10,6 +10,8 @@ import (
 
 var AccountingPaymentTermAllowFilterFieldsAndOps = []string{"name:like", "description:like"}
 var AccountingPaymentTermAllowSortFields = []string{"name"}
+var AccountingPaymentTermAllowGroupFields = []string{}
+var AccountingPaymentTermAllowAggregateFields = []string{}
 
 type AccountingPaymentTerm struct {
 	*models.CommonModel
@@ -44,6 +46,7 @@ type AccountingPaymentTermCreateRequest struct {
 }
 
 type AccountingPaymentTermUpdateRequest struct {
+	models.CommonUpdatePrecondition
 	Name          *string                                  `json:"name" validate:"omitempty"`
 	Description   *string                                  `json:"description" validate:"omitempty"`
 	AddLines      []AccountingPaymentTermLineCreateRequest `json:"add_lines" validate:"omitempty,dive"`
@@ -4,3 +4,9 @@ type TokenAndRefreshToken struct {
 	Token        string `json:"token"`
 	RefreshToken string `json:"refresh_token"`
 }
+
+type ImpersonationToken struct {
+	Token string `json:"token"`
+	// -- Seconds until the token expires, it can't be refreshed
+	ExpiresIn int `json:"expires_in"`
+}
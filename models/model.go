@@ -21,6 +21,15 @@ var (
 	SettingUserTypUser = "user"
 	SettingUserTypBot  = "bot"
 
+	// Setting user states
+	SettingUserStateActive   = "active"
+	SettingUserStateInactive = "inactive"
+	SettingUserStateArchived = "archived"
+
+	// Setting login event methods
+	SettingLoginEventMethodPassword = "password"
+	SettingLoginEventMethodOIDC     = "oidc"
+
 	// Sales quotation status
 	SalesQuotationStatusQuotation      = "quotation"
 	SalesQuotationStatusQuotationSent  = "quotation_sent"
@@ -52,11 +61,138 @@ var (
 )
 
 var VALID_GENDER_TYPES = []string{SettingGenderTypMale, SettingGenderTypFemale, SettingGenderTypOther}
+var VALID_SETTING_USER_STATES = []string{SettingUserStateActive, SettingUserStateInactive, SettingUserStateArchived}
 var VALID_SALES_QUOTATION_STATUS = []string{SalesQuotationStatusQuotation, SalesQuotationStatusQuotationSent, SalesQuotationStatusSalesOrder, SalesQuotationStatusSalesCancelled}
 var VALID_ACCOUNTING_ACCOUNT_TYPES = []string{AccountingAccountTypAssetCurrent, AccountingAccountTypAssetNonCurrent, AccountingAccountTypLiabilityCurrent, AccountingAccountTypLiabilityNonCurrent, AccountingAccountTypEquity, AccountingAccountTypIncome, AccountingAccountTypExpense, AccountingAccountTypGain, AccountingAccountTypLoss}
 var VALID_ACCOUNTING_JOURNAL_TYPES = []string{AccountingJournalTypSales, AccountingJournalTypPurchase, AccountingJournalTypCash, AccountingJournalTypBank, AccountingJournalTypGeneral}
 var VALID_ACCOUNTING_JOURNAL_ENTRY_TYPES = []string{AccountingJournalEntryStatusDraft, AccountingJournalEntryStatusPosted, AccountingJournalEntryStatusCancelled}
 
+// -- Columns that record rules are allowed to restrict, per resource
+var VALID_RECORD_RULE_RESOURCE_FIELDS = map[string][]string{
+	"setting.customer":         {"id", "cid", "mid", "gender"},
+	"sales.quotation":          {"id", "cid", "mid", "status", "setting_customer_id"},
+	"sales.order":              {"id", "cid", "mid", "sales_quotation_id", "accounting_payment_term_id"},
+	"accounting.journal_entry": {"id", "cid", "mid", "status", "accounting_journal_id"},
+}
+var VALID_RECORD_RULE_OPERATORS = []string{"eq", "ne", "gt", "lt", "gte", "lte", "in", "nin"}
+
+// -- Resources that belong to a company, records are only accessible within the active company
+var COMPANY_RESOURCES = []string{"setting.audit_log", "setting.customer", "sales.quotation", "sales.order", "accounting.account", "accounting.journal", "accounting.payment_term", "accounting.journal_entry"}
+
+// -- Resources embedded in the responses of a resource, e.g. the customer of a
+// -- quotation, a cached response is purged along with the resources it embeds
+var CACHE_DEPENDENCIES = map[string][]string{
+	"setting.user":             {"setting.role", "setting.company"},
+	"sales.quotation":          {"setting.customer"},
+	"sales.order":              {"sales.quotation", "accounting.payment_term"},
+	"accounting.journal":       {"accounting.account"},
+	"accounting.journal_entry": {"accounting.journal"},
+}
+
+// -- List endpoints that views can be saved for, see middlewares.SavedView
+var VALID_SAVED_VIEW_ENDPOINTS = []string{"/api/setting/users", "/api/setting/customers", "/api/setting/roles", "/api/sales/quotations", "/api/sales/orders", "/api/accounting/accounts", "/api/accounting/payment-terms", "/api/accounting/journals", "/api/accounting/journal-entries"}
+
+// Relation is a record of Resource referenced by Column, e.g. the customer of
+// a quotation.
+type Relation struct {
+	Resource string
+	Column   string
+}
+
+// -- Relations list filters and sorts can reach with a path, e.g. customer.email
+var RELATIONS = map[string]map[string]Relation{
+	"sales.quotation": {
+		"customer": {Resource: "setting.customer", Column: "setting_customer_id"},
+	},
+	"sales.order": {
+		"quotation":    {Resource: "sales.quotation", Column: "sales_quotation_id"},
+		"payment_term": {Resource: "accounting.payment_term", Column: "accounting_payment_term_id"},
+	},
+	"accounting.journal": {
+		"account": {Resource: "accounting.account", Column: "accounting_account_id"},
+	},
+	"accounting.journal_entry": {
+		"journal": {Resource: "accounting.journal", Column: "accounting_journal_id"},
+	},
+	"setting.user": {
+		"role": {Resource: "setting.role", Column: "setting_role_id"},
+	},
+}
+
+// -- Fields computed from the record and its children, %[1]s is the record
+var COMPUTED_FIELDS = map[string]map[string]string{
+	"sales.quotation": {
+		"total_amount": `(COALESCE((SELECT SUM("sales.order_item".price - "sales.order_item".discount) FROM "sales.order_item" WHERE "sales.order_item".sales_quotation_id = %[1]s.id), 0) + %[1]s.amount_delivery - %[1]s.discount)`,
+	},
+}
+
+// -- Sort types, text is sorted case-insensitively, the others as stored
+const (
+	SORT_TYPE_TEXT   = "text"
+	SORT_TYPE_DATE   = "date"
+	SORT_TYPE_NUMBER = "number"
+	// -- Enums are sorted in the order their values are declared
+	SORT_TYPE_ENUM = "enum"
+)
+
+// -- Placement of NULLs, by default last in ascending and first in descending order
+const (
+	SORT_NULLS_FIRST = "first"
+	SORT_NULLS_LAST  = "last"
+)
+
+// SortField describes how a sort field is ordered, fields without a SortField
+// are sorted as text, computed fields as stored.
+type SortField struct {
+	Typ       string
+	Nulls     string
+	Collation string
+}
+
+// SortFields maps the sort fields of a resource to their SortField.
+type SortFields map[string]SortField
+
+type AuditResource struct {
+	Table    string
+	Children []AuditChildResource
+}
+
+// AuditChildResource is a table whose rows belong to the audited record, e.g.
+// quotation items. When Column is set only that column is kept, otherwise the
+// whole row.
+type AuditChildResource struct {
+	Name       string
+	Table      string
+	ForeignKey string
+	Column     string
+}
+
+// -- Resources that are audited, the key is used as the resource of the audit log
+var AUDIT_RESOURCES = map[string]AuditResource{
+	"setting.user": {Table: "setting.user", Children: []AuditChildResource{
+		{Name: "role_ids", Table: "setting.user_role", ForeignKey: "setting_user_id", Column: "setting_role_id"},
+		{Name: "company_ids", Table: "setting.user_company", ForeignKey: "setting_user_id", Column: "setting_company_id"},
+	}},
+	"setting.company":  {Table: "setting.company"},
+	"setting.customer": {Table: "setting.customer"},
+	"setting.role": {Table: "setting.role", Children: []AuditChildResource{
+		{Name: "permission_ids", Table: "setting.role_permission", ForeignKey: "setting_role_id", Column: "setting_permission_id"},
+		{Name: "record_rules", Table: "setting.record_rule", ForeignKey: "setting_role_id"},
+	}},
+	"sales.quotation": {Table: "sales.quotation", Children: []AuditChildResource{
+		{Name: "items", Table: "sales.order_item", ForeignKey: "sales_quotation_id"},
+	}},
+	"sales.order":        {Table: "sales.order"},
+	"accounting.account": {Table: "accounting.account"},
+	"accounting.journal": {Table: "accounting.journal"},
+	"accounting.payment_term": {Table: "accounting.payment_term", Children: []AuditChildResource{
+		{Name: "lines", Table: "accounting.payment_term_line", ForeignKey: "accounting_payment_term_id"},
+	}},
+	"accounting.journal_entry": {Table: "accounting.journal_entry", Children: []AuditChildResource{
+		{Name: "lines", Table: "accounting.journal_entry_line", ForeignKey: "accounting_journal_entry_id"},
+	}},
+}
+
 type CommonModel struct {
 	CId   uint
 	CTime time.Time
@@ -81,3 +217,30 @@ func (cm *CommonModel) PrepareForUpdate(mid uint) (err error) {
 type CommonUpdateRequest interface {
 	MapUpdateFields(bqbQuery *bqb.Query, fieldname string, value interface{}) error
 }
+
+// CommonUpdatePrecondition is embedded in the update requests for optimistic
+// concurrency, the update is rejected when the record was modified since the
+// client read it.
+type CommonUpdatePrecondition struct {
+	// -- The mtime the client read, to the second as in the Last-Modified header
+	MTime *time.Time `json:"mtime"`
+	// -- The exact mtime resolved from an If-Match header
+	matchMTime *time.Time
+}
+
+func (precondition *CommonUpdatePrecondition) MatchMTime(mtime time.Time) {
+	precondition.matchMTime = &mtime
+}
+
+func (precondition CommonUpdatePrecondition) HasPrecondition() bool {
+	return precondition.matchMTime != nil || precondition.MTime != nil
+}
+
+// PreconditionIntoBqb restricts the update to the record as the client read it.
+func (precondition CommonUpdatePrecondition) PreconditionIntoBqb(bqbQuery *bqb.Query) {
+	if precondition.matchMTime != nil {
+		bqbQuery.Space(`AND mtime = ?`, *precondition.matchMTime)
+	} else if precondition.MTime != nil {
+		bqbQuery.Space(`AND date_trunc('second', mtime) = date_trunc('second', ?::timestamptz)`, *precondition.MTime)
+	}
+}
@@ -10,8 +10,13 @@ import (
 	"github.com/nullism/bqb"
 )
 
-var SalesOrderAllowFilterFieldsAndOps = []string{"name:like", "commitment_date:eq", "commitment_date:gt", "commitment_date:gte", "commitment_date:lt", "commitment_date:lte", "sales_quotation_id:eq", "accounting_payment_term_id:eq"}
-var SalesOrderAllowSortFields = []string{"name", "commitment_date"}
+var SalesOrderAllowFilterFieldsAndOps = []string{"name:like", "commitment_date:eq", "commitment_date:gt", "commitment_date:gte", "commitment_date:lt", "commitment_date:lte", "sales_quotation_id:eq", "accounting_payment_term_id:eq", "quotation.name:like", "quotation.status:eq", "quotation.customer.fullname:like", "quotation.customer.email:like"}
+var SalesOrderAllowSortFields = []string{"name", "commitment_date", "quotation.name", "quotation.total_amount"}
+var SalesOrderSortFields = models.SortFields{"commitment_date": {Typ: models.SORT_TYPE_DATE}, "quotation.total_amount": {Typ: models.SORT_TYPE_NUMBER}}
+var SalesOrderAllowGroupFields = []string{"commitment_date:date", "quotation.status", "quotation.customer.fullname"}
+var SalesOrderAllowAggregateFields = []string{"quotation.total_amount"}
+var SalesOrderAllowFields = []string{"name", "commitment_date", "note"}
+var SalesOrderAllowIncludes = []string{"quotation", "quotation.customer", "quotation.items", "payment_term", "payment_term.lines"}
 
 type SalesOrder struct {
 	*models.CommonModel
@@ -57,6 +62,7 @@ type SalesOrderCreateRequest struct {
 }
 
 type SalesOrderUpdateRequest struct {
+	models.CommonUpdatePrecondition
 	Name           *string    `json:"name" validate:"omitempty"`
 	CommitmentDate *time.Time `json:"commitment_date" validate:"omitempty"`
 	Note           *string    `json:"note" validate:"omitempty"`
@@ -10,8 +10,13 @@ import (
 	"github.com/nullism/bqb"
 )
 
-var SalesQuotationAllowFilterFieldsAndOps = []string{"name:like", "status:eq", "creation_date:gte", "creation_date:lte", "validity_date:gte", "validity_date:lte"}
-var SalesQuotationAllowSortFields = []string{"name", "status"}
+var SalesQuotationAllowFilterFieldsAndOps = []string{"name:like", "status:eq", "creation_date:gte", "creation_date:lte", "validity_date:gte", "validity_date:lte", "total_amount:gte", "total_amount:lte", "customer.fullname:like", "customer.email:like", "customer.phone:like"}
+var SalesQuotationAllowSortFields = []string{"name", "status", "total_amount", "customer.fullname"}
+var SalesQuotationSortFields = models.SortFields{"status": {Typ: models.SORT_TYPE_ENUM}, "total_amount": {Typ: models.SORT_TYPE_NUMBER}}
+var SalesQuotationAllowGroupFields = []string{"status", "creation_date:date", "validity_date:date", "customer.fullname"}
+var SalesQuotationAllowAggregateFields = []string{"total_amount", "discount", "amount_delivery"}
+var SalesQuotationAllowFields = []string{"name", "creation_date", "validity_date", "discount", "amount_delivery", "status", "total_amount"}
+var SalesQuotationAllowIncludes = []string{"customer", "items"}
 
 type SalesQuotation struct {
 	*models.CommonModel
@@ -75,6 +80,7 @@ type SalesQuotationCreateRequest struct {
 }
 
 type SalesQuotationUpdateRequest struct {
+	models.CommonUpdatePrecondition
 	Name                    *string                        `json:"name" validate:"omitempty"`
 	CreationDate            *time.Time                     `json:"creation_date" validate:"omitempty"`
 	ValidityDate            *time.Time                     `json:"validity_date" validate:"omitempty"`
@@ -0,0 +1,11 @@
+package models
+
+// SearchResult is a record matching a search, Resource is the resource of the
+// record, e.g. sales.quotation.
+type SearchResult struct {
+	Resource string  `json:"resource"`
+	Id       int     `json:"id"`
+	Title    string  `json:"title"`
+	Subtitle string  `json:"subtitle"`
+	Rank     float64 `json:"rank"`
+}
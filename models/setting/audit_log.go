@@ -0,0 +1,59 @@
+package setting
+
+import (
+	"encoding/json"
+	"time"
+
+	"system.buon18.com/m/models"
+)
+
+var SettingAuditLogAllowFilterFieldsAndOps = []string{"resource:eq", "record_id:eq", "action:in", "cid:eq", "request_id:eq", "act_id:eq", "ctime:gte", "ctime:lte"}
+var SettingAuditLogAllowSortFields = []string{"resource", "action", "ctime"}
+var SettingAuditLogSortFields = models.SortFields{"ctime": {Typ: models.SORT_TYPE_DATE}}
+
+// SettingAuditLog is a create, update or delete of an audited record, Changes
+// holds the old and new value of every changed field.
+type SettingAuditLog struct {
+	*models.CommonModel
+	Id        uint
+	Resource  string
+	RecordId  uint
+	Action    string
+	Changes   string
+	IP        string
+	RequestId string
+	// -- Admin impersonating the actor, 0 unless impersonating
+	ActId uint
+}
+
+type SettingAuditLogResponse struct {
+	Id        uint      `json:"id"`
+	Resource  string    `json:"resource"`
+	RecordId  uint      `json:"record_id"`
+	Action    string    `json:"action"`
+	Changes   any       `json:"changes"`
+	ActorId   uint      `json:"actor_id"`
+	ActId     uint      `json:"act_id,omitempty"`
+	IP        string    `json:"ip"`
+	RequestId string    `json:"request_id"`
+	Time      time.Time `json:"time"`
+}
+
+func SettingAuditLogToResponse(auditLog SettingAuditLog) SettingAuditLogResponse {
+	var changes any
+	if err := json.Unmarshal([]byte(auditLog.Changes), &changes); err != nil {
+		changes = map[string]any{}
+	}
+	return SettingAuditLogResponse{
+		Id:        auditLog.Id,
+		Resource:  auditLog.Resource,
+		RecordId:  auditLog.RecordId,
+		Action:    auditLog.Action,
+		Changes:   changes,
+		ActorId:   auditLog.CId,
+		ActId:     auditLog.ActId,
+		IP:        auditLog.IP,
+		RequestId: auditLog.RequestId,
+		Time:      auditLog.CTime,
+	}
+}
@@ -0,0 +1,49 @@
+package setting
+
+import (
+	"strings"
+
+	"system.buon18.com/m/models"
+
+	"github.com/nullism/bqb"
+)
+
+var SettingCompanyAllowFilterFieldsAndOps = []string{"name:like"}
+var SettingCompanyAllowSortFields = []string{"name"}
+
+type SettingCompany struct {
+	*models.CommonModel
+	Id   uint
+	Name string
+}
+
+type SettingCompanyResponse struct {
+	Id   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+func SettingCompanyToResponse(company SettingCompany) SettingCompanyResponse {
+	return SettingCompanyResponse{
+		Id:   company.Id,
+		Name: company.Name,
+	}
+}
+
+type SettingCompanyCreateRequest struct {
+	Name string `json:"name" validate:"required,max=64"`
+}
+
+type SettingCompanyUpdateRequest struct {
+	models.CommonUpdatePrecondition
+	Name *string `json:"name" validate:"omitempty,max=64"`
+}
+
+func (request SettingCompanyUpdateRequest) MapUpdateFields(bqbQuery *bqb.Query, fieldname string, value interface{}) error {
+	switch strings.ToLower(fieldname) {
+	case "name":
+		bqbQuery.Comma("name = ?", value)
+	default:
+		return models.ErrInvalidUpdateField
+	}
+	return nil
+}
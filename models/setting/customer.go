@@ -11,6 +11,9 @@ import (
 
 var SettingCustomerAllowFilterFieldsAndOps = []string{"fullname:like", "gender:in", "email:like", "phone:like"}
 var SettingCustomerAllowSortFields = []string{"fullname", "gender", "email", "phone"}
+var SettingCustomerSortFields = models.SortFields{"gender": {Typ: models.SORT_TYPE_ENUM}}
+var SettingCustomerAllowGroupFields = []string{"gender"}
+var SettingCustomerAllowAggregateFields = []string{}
 
 type SettingCustomer struct {
 	*models.CommonModel
@@ -55,6 +58,7 @@ type SettingCustomerCreateRequest struct {
 }
 
 type SettingCustomerUpdateRequest struct {
+	models.CommonUpdatePrecondition
 	FullName              *string `json:"full_name"`
 	Gender                *string `json:"gender" validate:"omitempty,gender"`
 	Email                 *string `json:"email" validate:"omitempty,email"`
@@ -0,0 +1,51 @@
+package setting
+
+import (
+	"time"
+
+	"system.buon18.com/m/models"
+)
+
+var SettingLoginEventAllowFilterFieldsAndOps = []string{"setting_user_id:eq", "email:like", "method:eq", "success:eq", "ip:eq", "ctime:gte", "ctime:lte"}
+var SettingLoginEventAllowSortFields = []string{"email", "ctime"}
+var SettingLoginEventSortFields = models.SortFields{"ctime": {Typ: models.SORT_TYPE_DATE}}
+
+// SettingLoginEvent is a sign in attempt, UserId is 0 when the email doesn't
+// belong to any user.
+type SettingLoginEvent struct {
+	Id        uint
+	UserId    uint
+	Email     string
+	Method    string
+	Success   bool
+	Reason    string
+	IP        string
+	UserAgent string
+	CTime     time.Time
+}
+
+type SettingLoginEventResponse struct {
+	Id        uint      `json:"id"`
+	UserId    uint      `json:"user_id"`
+	Email     string    `json:"email"`
+	Method    string    `json:"method"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Time      time.Time `json:"time"`
+}
+
+func SettingLoginEventToResponse(loginEvent SettingLoginEvent) SettingLoginEventResponse {
+	return SettingLoginEventResponse{
+		Id:        loginEvent.Id,
+		UserId:    loginEvent.UserId,
+		Email:     loginEvent.Email,
+		Method:    loginEvent.Method,
+		Success:   loginEvent.Success,
+		Reason:    loginEvent.Reason,
+		IP:        loginEvent.IP,
+		UserAgent: loginEvent.UserAgent,
+		Time:      loginEvent.CTime,
+	}
+}
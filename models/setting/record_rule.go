@@ -0,0 +1,42 @@
+package setting
+
+import (
+	"system.buon18.com/m/models"
+)
+
+// SettingRecordRule restricts the records of a resource that users of a role can
+// access. Value may contain the {user.id} and {role.id} placeholders.
+type SettingRecordRule struct {
+	*models.CommonModel
+	Id            uint
+	SettingRoleId uint
+	Resource      string
+	Field         string
+	Operator      string
+	Value         string
+}
+
+type SettingRecordRuleResponse struct {
+	Id       uint   `json:"id"`
+	Resource string `json:"resource"`
+	Field    string `json:"field"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
+func SettingRecordRuleToResponse(recordRule SettingRecordRule) SettingRecordRuleResponse {
+	return SettingRecordRuleResponse{
+		Id:       recordRule.Id,
+		Resource: recordRule.Resource,
+		Field:    recordRule.Field,
+		Operator: recordRule.Operator,
+		Value:    recordRule.Value,
+	}
+}
+
+type SettingRecordRuleCreateRequest struct {
+	Resource string `json:"resource" validate:"required,record_rule_resource"`
+	Field    string `json:"field" validate:"required,record_rule_field"`
+	Operator string `json:"operator" validate:"required,record_rule_operator"`
+	Value    string `json:"value" validate:"required,max=256"`
+}
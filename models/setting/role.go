@@ -10,6 +10,8 @@ import (
 
 var SettingRoleAllowFilterFieldsAndOps = []string{"name:like", "description:like"}
 var SettingRoleAllowSortFields = []string{"name"}
+var SettingRoleAllowGroupFields = []string{}
+var SettingRoleAllowAggregateFields = []string{}
 
 type SettingRole struct {
 	*models.CommonModel
@@ -23,6 +25,7 @@ type SettingRoleResponse struct {
 	Name        string                      `json:"name"`
 	Description string                      `json:"description"`
 	Permissions []SettingPermissionResponse `json:"permissions"`
+	RecordRules []SettingRecordRuleResponse `json:"record_rules,omitempty"`
 }
 
 func SettingRoleToResponse(role SettingRole, permissions []SettingPermissionResponse) SettingRoleResponse {
@@ -35,16 +38,20 @@ func SettingRoleToResponse(role SettingRole, permissions []SettingPermissionResp
 }
 
 type SettingRoleCreateRequest struct {
-	Name          string `json:"name" validate:"required,max=64"`
-	Description   string `json:"description" validate:"required,max=255"`
-	PermissionIds []uint `json:"permission_ids" validate:"required,gt=0,dive"`
+	Name          string                           `json:"name" validate:"required,max=64"`
+	Description   string                           `json:"description" validate:"required,max=255"`
+	PermissionIds []uint                           `json:"permission_ids" validate:"required,gt=0,dive"`
+	RecordRules   []SettingRecordRuleCreateRequest `json:"record_rules" validate:"omitempty,dive"`
 }
 
 type SettingRoleUpdateRequest struct {
-	Name                *string `json:"name" validate:"omitempty,max=64"`
-	Description         *string `json:"description" validate:"omitempty,max=255"`
-	AddPermissionIds    *[]uint `json:"add_permission_ids" validate:"omitempty,gt=0,dive"`
-	RemovePermissionIds *[]uint `json:"remove_permission_ids" validate:"omitempty,gt=0,dive"`
+	models.CommonUpdatePrecondition
+	Name                *string                           `json:"name" validate:"omitempty,max=64"`
+	Description         *string                           `json:"description" validate:"omitempty,max=255"`
+	AddPermissionIds    *[]uint                           `json:"add_permission_ids" validate:"omitempty,gt=0,dive"`
+	RemovePermissionIds *[]uint                           `json:"remove_permission_ids" validate:"omitempty,gt=0,dive"`
+	AddRecordRules      *[]SettingRecordRuleCreateRequest `json:"add_record_rules" validate:"omitempty,gt=0,dive"`
+	RemoveRecordRuleIds *[]uint                           `json:"remove_record_rule_ids" validate:"omitempty,gt=0,dive"`
 }
 
 func (request SettingRoleUpdateRequest) MapUpdateFields(bqbQuery *bqb.Query, fieldname string, value interface{}) error {
@@ -0,0 +1,105 @@
+package setting
+
+import (
+	"encoding/json"
+	"strings"
+
+	"system.buon18.com/m/models"
+
+	"github.com/nullism/bqb"
+)
+
+var SettingSavedViewAllowFilterFieldsAndOps = []string{"name:like", "endpoint:eq"}
+var SettingSavedViewAllowSortFields = []string{"name", "endpoint"}
+
+// SettingSavedView is a named combination of filters, sorts and columns of a
+// list endpoint. Filters maps the query parameters to their value, e.g.
+// status:eq to draft, and Sorts the fields to their direction.
+type SettingSavedView struct {
+	*models.CommonModel
+	Id       uint
+	Name     string
+	Endpoint string
+	Filters  string
+	Sorts    string
+	Columns  string
+	// -- Role the view is shared with, 0 for a view private to its creator
+	RoleId uint
+}
+
+type SettingSavedViewResponse struct {
+	Id       uint              `json:"id"`
+	Name     string            `json:"name"`
+	Endpoint string            `json:"endpoint"`
+	Filters  map[string]string `json:"filters"`
+	Sorts    map[string]string `json:"sorts"`
+	Columns  []string          `json:"columns"`
+	RoleId   uint              `json:"role_id"`
+	OwnerId  uint              `json:"owner_id"`
+}
+
+func SettingSavedViewToResponse(savedView SettingSavedView) SettingSavedViewResponse {
+	filters := map[string]string{}
+	if err := json.Unmarshal([]byte(savedView.Filters), &filters); err != nil {
+		filters = map[string]string{}
+	}
+	sorts := map[string]string{}
+	if err := json.Unmarshal([]byte(savedView.Sorts), &sorts); err != nil {
+		sorts = map[string]string{}
+	}
+	columns := []string{}
+	if err := json.Unmarshal([]byte(savedView.Columns), &columns); err != nil {
+		columns = []string{}
+	}
+	return SettingSavedViewResponse{
+		Id:       savedView.Id,
+		Name:     savedView.Name,
+		Endpoint: savedView.Endpoint,
+		Filters:  filters,
+		Sorts:    sorts,
+		Columns:  columns,
+		RoleId:   savedView.RoleId,
+		OwnerId:  savedView.CommonModel.CId,
+	}
+}
+
+type SettingSavedViewCreateRequest struct {
+	Name     string            `json:"name" validate:"required,max=64"`
+	Endpoint string            `json:"endpoint" validate:"required,saved_view_endpoint"`
+	Filters  map[string]string `json:"filters" validate:"dive,keys,required,endkeys,required"`
+	Sorts    map[string]string `json:"sorts" validate:"dive,keys,required,endkeys,sort_direction"`
+	Columns  []string          `json:"columns" validate:"dive,required"`
+	RoleId   uint              `json:"role_id"`
+}
+
+type SettingSavedViewUpdateRequest struct {
+	models.CommonUpdatePrecondition
+	Name    *string            `json:"name" validate:"omitempty,max=64"`
+	Filters *map[string]string `json:"filters" validate:"omitempty,dive,keys,required,endkeys,required"`
+	Sorts   *map[string]string `json:"sorts" validate:"omitempty,dive,keys,required,endkeys,sort_direction"`
+	Columns *[]string          `json:"columns" validate:"omitempty,dive,required"`
+	// -- 0 makes the view private again
+	RoleId *uint `json:"role_id"`
+}
+
+func (request SettingSavedViewUpdateRequest) MapUpdateFields(bqbQuery *bqb.Query, fieldname string, value interface{}) error {
+	switch strings.ToLower(fieldname) {
+	case "name":
+		bqbQuery.Comma("name = ?", value)
+	case "filters", "sorts", "columns":
+		valueByte, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		bqbQuery.Comma(strings.ToLower(fieldname)+" = ?", string(valueByte))
+	case "roleid":
+		if roleId, ok := value.(*uint); ok && *roleId == 0 {
+			bqbQuery.Comma("setting_role_id = NULL")
+		} else {
+			bqbQuery.Comma("setting_role_id = ?", value)
+		}
+	default:
+		return models.ErrInvalidUpdateField
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package setting
+
+import (
+	"time"
+
+	"system.buon18.com/m/models"
+)
+
+var SettingSessionAllowFilterFieldsAndOps = []string{"setting_user_id:eq", "ip:eq", "ctime:gte", "ctime:lte"}
+var SettingSessionAllowSortFields = []string{"ip", "last_used_at", "ctime"}
+var SettingSessionSortFields = models.SortFields{"last_used_at": {Typ: models.SORT_TYPE_DATE}, "ctime": {Typ: models.SORT_TYPE_DATE}}
+
+// SettingSession is a sign in of a user, tokens refreshed from it share its
+// Sid until the session expires or is revoked.
+type SettingSession struct {
+	Id         uint
+	Sid        string
+	UserId     uint
+	IP         string
+	UserAgent  string
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+	CTime      time.Time
+}
+
+type SettingSessionResponse struct {
+	Id         uint       `json:"id"`
+	UserId     uint       `json:"user_id"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"user_agent"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	// -- Session of the token of the request
+	Current bool `json:"current"`
+}
+
+func SettingSessionToResponse(session SettingSession, currentSid string) SettingSessionResponse {
+	return SettingSessionResponse{
+		Id:         session.Id,
+		UserId:     session.UserId,
+		IP:         session.IP,
+		UserAgent:  session.UserAgent,
+		LastUsedAt: session.LastUsedAt,
+		ExpiresAt:  session.ExpiresAt,
+		RevokedAt:  session.RevokedAt,
+		CreatedAt:  session.CTime,
+		Current:    currentSid != "" && session.Sid == currentSid,
+	}
+}
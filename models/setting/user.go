@@ -2,14 +2,18 @@ package setting
 
 import (
 	"strings"
+	"time"
 
 	"system.buon18.com/m/models"
 
 	"github.com/nullism/bqb"
 )
 
-var SettingUserAllowFilterFieldsAndOps = []string{"name:like", "email:like", "typ:in", "role_id:eq"}
-var SettingUserAllowSortFields = []string{"name", "email", "type"}
+var SettingUserAllowFilterFieldsAndOps = []string{"name:like", "email:like", "typ:in", "role_id:eq", "state:in", "role.name:like"}
+var SettingUserAllowSortFields = []string{"name", "email", "type", "state", "role.name"}
+var SettingUserAllowGroupFields = []string{"typ", "state", "role.name"}
+var SettingUserAllowAggregateFields = []string{}
+var SettingUserSortFields = models.SortFields{"state": {Typ: models.SORT_TYPE_ENUM}}
 
 type SettingUser struct {
 	*models.CommonModel
@@ -18,6 +22,11 @@ type SettingUser struct {
 	Email string
 	Pwd   string
 	Typ   string
+	State string
+	// -- Tokens issued before are rejected, nil when never revoked
+	SessionsRevokedAt *time.Time
+	// -- Single sign-on subject
+	OIDCSub string
 	// -- Foreign keys
 	SettingRoleId uint
 }
@@ -27,7 +36,17 @@ type SettingUserResponse struct {
 	Name  string              `json:"name"`
 	Email string              `json:"email"`
 	Type  string              `json:"type"`
+	State string              `json:"state"`
 	Role  SettingRoleResponse `json:"role"`
+	// -- Every role of the user, the primary role comes first
+	Roles []SettingRoleResponse `json:"roles,omitempty"`
+	// -- Effective permissions, including the implied ones
+	Permissions []SettingPermissionResponse `json:"permissions,omitempty"`
+	// -- Companies the user is allowed to access and the active one
+	Companies []SettingCompanyResponse `json:"companies,omitempty"`
+	Company   *SettingCompanyResponse  `json:"company,omitempty"`
+	// -- Admin impersonating the user
+	Impersonator *SettingUserResponse `json:"impersonator,omitempty"`
 }
 
 func SettingUserToResponse(user SettingUser, role SettingRoleResponse) SettingUserResponse {
@@ -36,21 +55,45 @@ func SettingUserToResponse(user SettingUser, role SettingRoleResponse) SettingUs
 		Name:  user.Name,
 		Email: user.Email,
 		Type:  user.Typ,
+		State: user.State,
 		Role:  role,
 	}
 }
 
+// IsActive reports whether the user is allowed to sign in.
+func (user SettingUser) IsActive() bool {
+	return user.State == models.SettingUserStateActive
+}
+
+// IsSessionRevoked reports whether a token issued at issuedAt was revoked, iat
+// only has second precision.
+func (user SettingUser) IsSessionRevoked(issuedAt time.Time) bool {
+	return user.SessionsRevokedAt != nil && issuedAt.Before(user.SessionsRevokedAt.Truncate(time.Second))
+}
+
 type SettingUserCreateRequest struct {
 	Name   string `json:"name" validate:"required"`
 	Email  string `json:"email" validate:"required,email"`
 	RoleId uint   `json:"role_id" validate:"required"`
+	// -- Additional roles
+	RoleIds []uint `json:"role_ids"`
+	// -- Allowed companies, defaults to the active company
+	CompanyIds []uint `json:"company_ids"`
 }
 
 type SettingUserUpdateRequest struct {
+	models.CommonUpdatePrecondition
 	Name     *string `json:"name"`
 	Email    *string `json:"email" validate:"omitempty,email"`
 	Password *string `json:"password"`
 	RoleId   *uint   `json:"role_id"`
+	State    *string `json:"state" validate:"omitempty,setting_user_state"`
+	// -- Additional roles
+	AddRoleIds    *[]uint `json:"add_role_ids"`
+	RemoveRoleIds *[]uint `json:"remove_role_ids"`
+	// -- Allowed companies
+	AddCompanyIds    *[]uint `json:"add_company_ids"`
+	RemoveCompanyIds *[]uint `json:"remove_company_ids"`
 }
 
 func (request SettingUserUpdateRequest) MapUpdateFields(bqbQuery *bqb.Query, fieldName string, value interface{}) error {
@@ -61,6 +104,8 @@ func (request SettingUserUpdateRequest) MapUpdateFields(bqbQuery *bqb.Query, fie
 		bqbQuery.Comma("email = ?", value)
 	case "roleid":
 		bqbQuery.Comma("setting_role_id = ?", value)
+	case "state":
+		bqbQuery.Comma("state = ?", value)
 	default:
 		return models.ErrInvalidUpdateField
 	}
@@ -4,9 +4,9 @@ import (
 	"system.buon18.com/m/controllers"
 	"system.buon18.com/m/database"
 	"system.buon18.com/m/middlewares"
-	"system.buon18.com/m/models/accounting"
 	"system.buon18.com/m/services"
 	accountingServices "system.buon18.com/m/services/accounting"
+	settingServices "system.buon18.com/m/services/setting"
 	"system.buon18.com/m/utils"
 
 	"github.com/gin-gonic/gin"
@@ -20,111 +20,191 @@ func Accounting(e *gin.Engine, connection *database.Connection) {
 			AccountingPaymentTermService:  &accountingServices.AccountingPaymentTermService{DB: connection.DB},
 			AccountingJournalService:      &accountingServices.AccountingJournalService{DB: connection.DB},
 			AccountingJournalEntryService: &accountingServices.AccountingJournalEntryService{DB: connection.DB},
+			AggregateService:              &services.AggregateService{DB: connection.DB},
+		},
+	}
+
+	auditHandler := controllers.AuditHandler{
+		DB: connection.DB,
+		ServiceFacade: &services.ServiceFacade{
+			SettingAuditLogService: &settingServices.SettingAuditLogService{DB: connection.DB},
 		},
 	}
 
 	e.GET(
 		"/api/accounting/accounts",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCOUNTING, utils.PREDEFINED_PERMISSIONS.ACCOUNTING_ACCOUNTS.VIEW}),
-		middlewares.ValkeyCache[[]accounting.AccountingAccount](connection, "accounts"),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_ACCOUNTS.VIEW}),
+		middlewares.SavedView(connection.DB),
+		middlewares.ConditionalGet(connection.DB, ""),
+		middlewares.ValkeyCache(connection, "accounts", "accounting.account"),
 		handler.Accounts,
 	)
+	e.GET(
+		"/api/accounting/accounts/aggregate",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_ACCOUNTS.VIEW}),
+		handler.AggregateAccounts,
+	)
 	e.GET(
 		"/api/accounting/accounts/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCOUNTING, utils.PREDEFINED_PERMISSIONS.ACCOUNTING_ACCOUNTS.VIEW}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_ACCOUNTS.VIEW}),
+		middlewares.ConditionalGet(connection.DB, "accounting.account"),
+		middlewares.ValkeyCache(connection, "account", "accounting.account"),
 		handler.Account,
 	)
 	e.POST(
 		"/api/accounting/accounts",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCOUNTING, utils.PREDEFINED_PERMISSIONS.ACCOUNTING_ACCOUNTS.CREATE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_ACCOUNTS.CREATE}),
+		middlewares.Idempotency(connection),
+		middlewares.InvalidateCache(connection, "accounting.account"),
 		handler.CreateAccount,
 	)
 	e.PATCH(
 		"/api/accounting/accounts/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCOUNTING, utils.PREDEFINED_PERMISSIONS.ACCOUNTING_ACCOUNTS.UPDATE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_ACCOUNTS.UPDATE}),
+		middlewares.InvalidateCache(connection, "accounting.account"),
 		handler.UpdateAccount,
 	)
 	e.DELETE(
 		"/api/accounting/accounts/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCOUNTING, utils.PREDEFINED_PERMISSIONS.ACCOUNTING_ACCOUNTS.DELETE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_ACCOUNTS.DELETE}),
+		middlewares.InvalidateCache(connection, "accounting.account"),
 		handler.DeleteAccount,
 	)
 	e.GET(
 		"/api/accounting/payment-terms",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCOUNTING, utils.PREDEFINED_PERMISSIONS.ACCOUNTING_PAYMENT_TERMS.VIEW}),
-		middlewares.ValkeyCache[[]accounting.AccountingPaymentTerm](connection, "payment-terms"),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_PAYMENT_TERMS.VIEW}),
+		middlewares.SavedView(connection.DB),
+		middlewares.ConditionalGet(connection.DB, ""),
+		middlewares.ValkeyCache(connection, "payment-terms", "accounting.payment_term"),
 		handler.PaymentTerms,
 	)
+	e.GET(
+		"/api/accounting/payment-terms/aggregate",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_PAYMENT_TERMS.VIEW}),
+		handler.AggregatePaymentTerms,
+	)
 	e.GET(
 		"/api/accounting/payment-terms/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCOUNTING, utils.PREDEFINED_PERMISSIONS.ACCOUNTING_PAYMENT_TERMS.VIEW}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_PAYMENT_TERMS.VIEW}),
+		middlewares.ConditionalGet(connection.DB, "accounting.payment_term"),
+		middlewares.ValkeyCache(connection, "payment_term", "accounting.payment_term"),
 		handler.PaymentTerm,
 	)
 	e.POST(
 		"/api/accounting/payment-terms",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCOUNTING, utils.PREDEFINED_PERMISSIONS.ACCOUNTING_PAYMENT_TERMS.CREATE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_PAYMENT_TERMS.CREATE}),
+		middlewares.Idempotency(connection),
+		middlewares.InvalidateCache(connection, "accounting.payment_term"),
 		handler.CreatePaymentTerm,
 	)
 	e.PATCH(
 		"/api/accounting/payment-terms/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCOUNTING, utils.PREDEFINED_PERMISSIONS.ACCOUNTING_PAYMENT_TERMS.UPDATE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_PAYMENT_TERMS.UPDATE}),
+		middlewares.InvalidateCache(connection, "accounting.payment_term"),
 		handler.UpdatePaymentTerm,
 	)
 	e.DELETE(
 		"/api/accounting/payment-terms/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCOUNTING, utils.PREDEFINED_PERMISSIONS.ACCOUNTING_PAYMENT_TERMS.DELETE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_PAYMENT_TERMS.DELETE}),
+		middlewares.InvalidateCache(connection, "accounting.payment_term"),
 		handler.DeletePaymentTerm,
 	)
 	e.GET(
 		"/api/accounting/journals",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCOUNTING, utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNALS.VIEW}),
-		middlewares.ValkeyCache[[]accounting.AccountingJournal](connection, "journals"),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNALS.VIEW}),
+		middlewares.SavedView(connection.DB),
+		middlewares.ConditionalGet(connection.DB, ""),
+		middlewares.ValkeyCache(connection, "journals", "accounting.journal"),
 		handler.Journals,
 	)
+	e.GET(
+		"/api/accounting/journals/aggregate",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNALS.VIEW}),
+		handler.AggregateJournals,
+	)
 	e.GET(
 		"/api/accounting/journals/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCOUNTING, utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNALS.VIEW}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNALS.VIEW}),
+		middlewares.ConditionalGet(connection.DB, "accounting.journal"),
+		middlewares.ValkeyCache(connection, "journal", "accounting.journal"),
 		handler.Journal,
 	)
 	e.POST(
 		"/api/accounting/journals",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCOUNTING, utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNALS.CREATE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNALS.CREATE}),
+		middlewares.Idempotency(connection),
+		middlewares.InvalidateCache(connection, "accounting.journal"),
 		handler.CreateJournal,
 	)
 	e.PATCH(
 		"/api/accounting/journals/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCOUNTING, utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNALS.UPDATE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNALS.UPDATE}),
+		middlewares.InvalidateCache(connection, "accounting.journal"),
 		handler.UpdateJournal,
 	)
 	e.DELETE(
 		"/api/accounting/journals/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCOUNTING, utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNALS.DELETE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNALS.DELETE}),
+		middlewares.InvalidateCache(connection, "accounting.journal"),
 		handler.DeleteJournal,
 	)
 	e.GET(
 		"/api/accounting/journal-entries",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCOUNTING, utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNAL_ENTRIES.VIEW}),
-		middlewares.ValkeyCache[[]accounting.AccountingJournalEntry](connection, "journal-entries"),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNAL_ENTRIES.VIEW}),
+		middlewares.SavedView(connection.DB),
+		middlewares.ConditionalGet(connection.DB, ""),
+		middlewares.ValkeyCache(connection, "journal-entries", "accounting.journal_entry"),
 		handler.JournalEntries,
 	)
+	e.GET(
+		"/api/accounting/journal-entries/aggregate",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNAL_ENTRIES.VIEW}),
+		handler.AggregateJournalEntries,
+	)
 	e.GET(
 		"/api/accounting/journal-entries/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCOUNTING, utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNAL_ENTRIES.VIEW}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNAL_ENTRIES.VIEW}),
+		middlewares.ConditionalGet(connection.DB, "accounting.journal_entry"),
+		middlewares.ValkeyCache(connection, "journal_entry", "accounting.journal_entry"),
 		handler.JournalEntry,
 	)
 	e.POST(
 		"/api/accounting/journal-entries",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCOUNTING, utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNAL_ENTRIES.CREATE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNAL_ENTRIES.CREATE}),
+		middlewares.Idempotency(connection),
+		middlewares.InvalidateCache(connection, "accounting.journal_entry"),
 		handler.CreateJournalEntry,
 	)
 	e.PATCH(
 		"/api/accounting/journal-entries/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCOUNTING, utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNAL_ENTRIES.UPDATE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNAL_ENTRIES.UPDATE}),
+		middlewares.InvalidateCache(connection, "accounting.journal_entry"),
 		handler.UpdateJournalEntry,
 	)
 	e.DELETE(
 		"/api/accounting/journal-entries/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCOUNTING, utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNAL_ENTRIES.DELETE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNAL_ENTRIES.DELETE}),
+		middlewares.InvalidateCache(connection, "accounting.journal_entry"),
 		handler.DeleteJournalEntry,
 	)
+	e.GET(
+		"/api/accounting/accounts/:id/history",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_ACCOUNTS.VIEW}),
+		auditHandler.History("accounting.account"),
+	)
+	e.GET(
+		"/api/accounting/payment-terms/:id/history",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_PAYMENT_TERMS.VIEW}),
+		auditHandler.History("accounting.payment_term"),
+	)
+	e.GET(
+		"/api/accounting/journals/:id/history",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNALS.VIEW}),
+		auditHandler.History("accounting.journal"),
+	)
+	e.GET(
+		"/api/accounting/journal-entries/:id/history",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNAL_ENTRIES.VIEW}),
+		auditHandler.History("accounting.journal_entry"),
+	)
 }
@@ -33,6 +33,18 @@ func TestAccountingRoutes(t *testing.T) {
 		postgres.WithInitScripts(
 			filepath.Join("..", "database", "dev_scripts", "001_create-schema.sh"),
 			filepath.Join("..", "database", "dev_scripts", "002_seed.sh"),
+			filepath.Join("..", "database", "dev_scripts", "004_oidc.sh"),
+			filepath.Join("..", "database", "dev_scripts", "005_record-rule.sh"),
+			filepath.Join("..", "database", "dev_scripts", "006_user-role.sh"),
+			filepath.Join("..", "database", "dev_scripts", "007_audit-log.sh"),
+			filepath.Join("..", "database", "dev_scripts", "008_impersonation.sh"),
+			filepath.Join("..", "database", "dev_scripts", "009_user-state.sh"),
+			filepath.Join("..", "database", "dev_scripts", "010_session.sh"),
+			filepath.Join("..", "database", "dev_scripts", "011_company.sh"),
+			filepath.Join("..", "database", "dev_scripts", "012_search.sh"),
+			filepath.Join("..", "database", "dev_scripts", "013_saved-view.sh"),
+			filepath.Join("..", "database", "dev_scripts", "014_idempotency-key.sh"),
+			filepath.Join("..", "database", "dev_scripts", "015_audit-log-company.sh"),
 			filepath.Join("..", "database", "dev_scripts", "100_seed-customer.sh"),
 			filepath.Join("..", "database", "dev_scripts", "102_seed-payment-term.sh"),
 			filepath.Join("..", "database", "dev_scripts", "104_seed-accounting-account.sh"),
@@ -1,21 +1,49 @@
 package routes
 
 import (
-	"database/sql"
+	"log"
 
+	"system.buon18.com/m/config"
 	"system.buon18.com/m/controllers"
+	"system.buon18.com/m/database"
 	"system.buon18.com/m/middlewares"
 	"system.buon18.com/m/services"
+	settingServices "system.buon18.com/m/services/setting"
 	"system.buon18.com/m/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-func Auth(e *gin.Engine, db *sql.DB) {
+func Auth(e *gin.Engine, connection *database.Connection) {
+	db := connection.DB
+	config := config.GetConfigInstance()
+
+	// -- Fail at startup instead of on the first login when the keys are misconfigured
+	if _, err := utils.GetWebTokenKeySet(); err != nil {
+		log.Fatalf("Error loading jwt signing keys: %v\n", err)
+	}
+
+	var oidcProvider *utils.OIDCProvider
+	if config.OIDC_ISSUER != "" {
+		oidcProvider = utils.NewOIDCProvider(utils.OIDCConfig{
+			Issuer:       config.OIDC_ISSUER,
+			ClientId:     config.OIDC_CLIENT_ID,
+			ClientSecret: config.OIDC_CLIENT_SECRET,
+			RedirectURL:  config.OIDC_REDIRECT_URL,
+			Scopes:       config.OIDC_SCOPES,
+		})
+	}
+
 	handler := controllers.AuthHandler{
 		DB: db,
 		ServiceFacade: &services.ServiceFacade{
-			AuthService: &services.AuthService{DB: db},
+			AuthService: &services.AuthService{DB: db, OIDCProvider: oidcProvider},
+		},
+	}
+	sessionHandler := controllers.SessionHandler{
+		DB: db,
+		ServiceFacade: &services.ServiceFacade{
+			SettingSessionService: &settingServices.SettingSessionService{DB: db},
 		},
 	}
 	authPermissions := utils.PREDEFINED_PERMISSIONS.AUTH
@@ -34,16 +62,53 @@ func Auth(e *gin.Engine, db *sql.DB) {
 		"/api/auth/refresh-token",
 		handler.RefreshToken,
 	)
+	e.GET(
+		"/.well-known/jwks.json",
+		handler.JWKS,
+	)
+	e.GET(
+		"/api/auth/oidc/login",
+		handler.OIDCLogin,
+	)
+	e.GET(
+		"/api/auth/oidc/callback",
+		handler.OIDCCallback,
+	)
 	e.POST(
 		"/api/auth/update-password",
 		middlewares.Authenticate(db),
 		middlewares.Authorize([]string{authPermissions.UPDATE}),
 		handler.UpdatePassword,
 	)
+	e.POST(
+		"/api/auth/impersonate",
+		middlewares.Authenticate(db),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCESS}),
+		handler.Impersonate,
+	)
 	e.PATCH(
 		"/api/auth/me",
 		middlewares.Authenticate(db),
 		middlewares.Authorize([]string{authPermissions.UPDATE}),
+		middlewares.InvalidateCache(connection, "setting.user"),
 		handler.UpdateProfile,
 	)
+	e.GET(
+		"/api/auth/sessions",
+		middlewares.Authenticate(db),
+		middlewares.Authorize([]string{authPermissions.VIEW}),
+		sessionHandler.MySessions,
+	)
+	e.DELETE(
+		"/api/auth/sessions/:id",
+		middlewares.Authenticate(db),
+		middlewares.Authorize([]string{authPermissions.UPDATE}),
+		sessionHandler.RevokeMySession,
+	)
+	e.GET(
+		"/api/auth/login-events",
+		middlewares.Authenticate(db),
+		middlewares.Authorize([]string{authPermissions.VIEW}),
+		sessionHandler.MyLoginEvents,
+	)
 }
@@ -3,6 +3,7 @@ package routes_test
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http/httptest"
 	"path/filepath"
 	"strings"
@@ -10,6 +11,8 @@ import (
 
 	"system.buon18.com/m/config"
 	"system.buon18.com/m/database"
+	"system.buon18.com/m/models"
+	"system.buon18.com/m/models/setting"
 	"system.buon18.com/m/routes"
 	"system.buon18.com/m/services"
 	"system.buon18.com/m/utils"
@@ -31,6 +34,18 @@ func TestAuthRoutes(t *testing.T) {
 		postgres.WithInitScripts(
 			filepath.Join("..", "database", "dev_scripts", "001_create-schema.sh"),
 			filepath.Join("..", "database", "dev_scripts", "002_seed.sh"),
+			filepath.Join("..", "database", "dev_scripts", "004_oidc.sh"),
+			filepath.Join("..", "database", "dev_scripts", "005_record-rule.sh"),
+			filepath.Join("..", "database", "dev_scripts", "006_user-role.sh"),
+			filepath.Join("..", "database", "dev_scripts", "007_audit-log.sh"),
+			filepath.Join("..", "database", "dev_scripts", "008_impersonation.sh"),
+			filepath.Join("..", "database", "dev_scripts", "009_user-state.sh"),
+			filepath.Join("..", "database", "dev_scripts", "010_session.sh"),
+			filepath.Join("..", "database", "dev_scripts", "011_company.sh"),
+			filepath.Join("..", "database", "dev_scripts", "012_search.sh"),
+			filepath.Join("..", "database", "dev_scripts", "013_saved-view.sh"),
+			filepath.Join("..", "database", "dev_scripts", "014_idempotency-key.sh"),
+			filepath.Join("..", "database", "dev_scripts", "015_audit-log-company.sh"),
 		),
 		postgres.BasicWaitStrategies(),
 	)
@@ -43,7 +58,10 @@ func TestAuthRoutes(t *testing.T) {
 	DB := database.InitSQL(connectionString)
 
 	router := gin.Default()
-	routes.Auth(router, DB)
+	routes.Auth(router, &database.Connection{
+		DB:     DB,
+		Valkey: nil,
+	})
 
 	token, err := utils.GenerateWebToken(utils.WebTokenClaims{
 		Email:       "admin@buon18.com",
@@ -175,9 +193,80 @@ func TestAuthRoutes(t *testing.T) {
 		req.Header.Add("Authorization", "Bearer "+token)
 		router.ServeHTTP(w, req)
 
-		expectedBodyJSON := `{"code":200,"message":"","data":{"user":{"id":2,"name":"Admin","email":"admin@buon18.com","type":"user","role":{"id":1,"name":"bot","description":"BOT","permissions":[{"id":1,"name":"FULL_ACCESS"}]}}}}`
+		expectedBodyJSON := `{"code":200,"message":"","data":{"user":{"id":2,"name":"Admin","email":"admin@buon18.com","type":"user","state":"active","role":{"id":1,"name":"bot","description":"BOT","permissions":[{"id":1,"name":"FULL_ACCESS"}]}}}}`
 
 		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
 	})
 
+	t.Run("SuccessRevokeSession", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		login := services.LoginRequest{
+			Email:    "admin@buon18.com",
+			Password: "new-password",
+		}
+
+		loginJson, err := json.Marshal(login)
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest("POST", "/api/auth/login", strings.NewReader(string(loginJson)))
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Code)
+
+		var loginResponse struct {
+			Data models.TokenAndRefreshToken `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &loginResponse))
+		sessionToken := loginResponse.Data.Token
+
+		// -- The session of the token is listed as current
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest("GET", "/api/auth/sessions", nil)
+		req.Header.Add("Authorization", "Bearer "+sessionToken)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Code)
+
+		var sessionsResponse struct {
+			Data struct {
+				Sessions []setting.SettingSessionResponse `json:"sessions"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessionsResponse))
+
+		var currentSessionId uint
+		for _, session := range sessionsResponse.Data.Sessions {
+			if session.Current {
+				currentSessionId = session.Id
+			}
+		}
+		assert.NotZero(t, currentSessionId)
+
+		// -- Tokens of a revoked session are rejected
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest("DELETE", fmt.Sprintf("/api/auth/sessions/%d", currentSessionId), nil)
+		req.Header.Add("Authorization", "Bearer "+sessionToken)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, 204, w.Code)
+
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest("GET", "/api/auth/me", nil)
+		req.Header.Add("Authorization", "Bearer "+sessionToken)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, 401, w.Code)
+	})
+
+	t.Run("SuccessGetLoginEvents", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/auth/login-events", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Code)
+		assert.Contains(t, w.Body.String(), `"reason":"invalid email or password"`)
+	})
 }
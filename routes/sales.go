@@ -4,9 +4,9 @@ import (
 	"system.buon18.com/m/controllers"
 	"system.buon18.com/m/database"
 	"system.buon18.com/m/middlewares"
-	"system.buon18.com/m/models/sales"
 	"system.buon18.com/m/services"
 	salesServices "system.buon18.com/m/services/sales"
+	settingServices "system.buon18.com/m/services/setting"
 	"system.buon18.com/m/utils"
 
 	"github.com/gin-gonic/gin"
@@ -18,54 +18,97 @@ func Sales(e *gin.Engine, connection *database.Connection) {
 		ServiceFacade: &services.ServiceFacade{
 			SalesOrderService:     &salesServices.SalesOrderService{DB: connection.DB},
 			SalesQuotationService: &salesServices.SalesQuotationService{DB: connection.DB},
+			AggregateService:      &services.AggregateService{DB: connection.DB},
+		},
+	}
+
+	auditHandler := controllers.AuditHandler{
+		DB: connection.DB,
+		ServiceFacade: &services.ServiceFacade{
+			SettingAuditLogService: &settingServices.SettingAuditLogService{DB: connection.DB},
 		},
 	}
 
 	e.GET(
 		"/api/sales/quotations",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SALES, utils.PREDEFINED_PERMISSIONS.SALES_QUOTATIONS.VIEW}),
-		middlewares.ValkeyCache[[]sales.SalesQuotationResponse](connection, "quotations"),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SALES_QUOTATIONS.VIEW}),
+		middlewares.SavedView(connection.DB),
+		middlewares.ConditionalGet(connection.DB, ""),
+		middlewares.ValkeyCache(connection, "quotations", "sales.quotation"),
 		handler.Quotations,
 	)
+	e.GET(
+		"/api/sales/quotations/aggregate",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SALES_QUOTATIONS.VIEW}),
+		handler.AggregateQuotations,
+	)
 	e.GET(
 		"/api/sales/quotations/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SALES, utils.PREDEFINED_PERMISSIONS.SALES_QUOTATIONS.VIEW}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SALES_QUOTATIONS.VIEW}),
+		middlewares.ConditionalGet(connection.DB, "sales.quotation"),
+		middlewares.ValkeyCache(connection, "quotation", "sales.quotation"),
 		handler.Quotation,
 	)
 	e.POST(
 		"/api/sales/quotations",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SALES, utils.PREDEFINED_PERMISSIONS.SALES_QUOTATIONS.CREATE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SALES_QUOTATIONS.CREATE}),
+		middlewares.Idempotency(connection),
+		middlewares.InvalidateCache(connection, "sales.quotation"),
 		handler.CreateQuotation,
 	)
 	e.PATCH(
 		"/api/sales/quotations/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SALES, utils.PREDEFINED_PERMISSIONS.SALES_QUOTATIONS.UPDATE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SALES_QUOTATIONS.UPDATE}),
+		middlewares.InvalidateCache(connection, "sales.quotation"),
 		handler.UpdateQuotation,
 	)
 	e.DELETE(
 		"/api/sales/quotations/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SALES, utils.PREDEFINED_PERMISSIONS.SALES_QUOTATIONS.DELETE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SALES_QUOTATIONS.DELETE}),
+		middlewares.InvalidateCache(connection, "sales.quotation"),
 		handler.DeleteQuotation,
 	)
 	e.GET(
 		"/api/sales/orders",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SALES, utils.PREDEFINED_PERMISSIONS.SALES_ORDERS.VIEW}),
-		middlewares.ValkeyCache[[]sales.SalesOrderResponse](connection, "orders"),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SALES_ORDERS.VIEW}),
+		middlewares.SavedView(connection.DB),
+		middlewares.ConditionalGet(connection.DB, ""),
+		middlewares.ValkeyCache(connection, "orders", "sales.order"),
 		handler.Orders,
 	)
+	e.GET(
+		"/api/sales/orders/aggregate",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SALES_ORDERS.VIEW}),
+		handler.AggregateOrders,
+	)
 	e.GET(
 		"/api/sales/orders/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SALES, utils.PREDEFINED_PERMISSIONS.SALES_ORDERS.VIEW}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SALES_ORDERS.VIEW}),
+		middlewares.ConditionalGet(connection.DB, "sales.order"),
+		middlewares.ValkeyCache(connection, "order", "sales.order"),
 		handler.Order,
 	)
 	e.POST(
 		"/api/sales/orders",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SALES, utils.PREDEFINED_PERMISSIONS.SALES_ORDERS.CREATE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SALES_ORDERS.CREATE}),
+		middlewares.Idempotency(connection),
+		middlewares.InvalidateCache(connection, "sales.order"),
 		handler.CreateOrder,
 	)
 	e.PATCH(
 		"/api/sales/orders/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SALES, utils.PREDEFINED_PERMISSIONS.SALES_ORDERS.UPDATE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SALES_ORDERS.UPDATE}),
+		middlewares.InvalidateCache(connection, "sales.order"),
 		handler.UpdateOrder,
 	)
+	e.GET(
+		"/api/sales/quotations/:id/history",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SALES_QUOTATIONS.VIEW}),
+		auditHandler.History("sales.quotation"),
+	)
+	e.GET(
+		"/api/sales/orders/:id/history",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SALES_ORDERS.VIEW}),
+		auditHandler.History("sales.order"),
+	)
 }
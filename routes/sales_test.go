@@ -35,6 +35,18 @@ func TestSalesRoutes(t *testing.T) {
 			filepath.Join("..", "database", "dev_scripts", "001_create-schema.sh"),
 			filepath.Join("..", "database", "dev_scripts", "002_seed.sh"),
 			filepath.Join("..", "database", "dev_scripts", "003_store-procedure.sh"),
+			filepath.Join("..", "database", "dev_scripts", "004_oidc.sh"),
+			filepath.Join("..", "database", "dev_scripts", "005_record-rule.sh"),
+			filepath.Join("..", "database", "dev_scripts", "006_user-role.sh"),
+			filepath.Join("..", "database", "dev_scripts", "007_audit-log.sh"),
+			filepath.Join("..", "database", "dev_scripts", "008_impersonation.sh"),
+			filepath.Join("..", "database", "dev_scripts", "009_user-state.sh"),
+			filepath.Join("..", "database", "dev_scripts", "010_session.sh"),
+			filepath.Join("..", "database", "dev_scripts", "011_company.sh"),
+			filepath.Join("..", "database", "dev_scripts", "012_search.sh"),
+			filepath.Join("..", "database", "dev_scripts", "013_saved-view.sh"),
+			filepath.Join("..", "database", "dev_scripts", "014_idempotency-key.sh"),
+			filepath.Join("..", "database", "dev_scripts", "015_audit-log-company.sh"),
 			filepath.Join("..", "database", "dev_scripts", "100_seed-customer.sh"),
 			filepath.Join("..", "database", "dev_scripts", "101_seed-quotation.sh"),
 			filepath.Join("..", "database", "dev_scripts", "102_seed-payment-term.sh"),
@@ -92,6 +104,19 @@ func TestSalesRoutes(t *testing.T) {
 		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
 	})
 
+	t.Run("FilterByCustomerSuccessGetListOfQuotations", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/sales/quotations?customer.fullname:like=Jane&sort:total_amount=desc", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "3", w.Header().Get("X-Total-Count"))
+		assert.Contains(t, w.Body.String(), `"full_name":"Jane Doe"`)
+		assert.NotContains(t, w.Body.String(), `"full_name":"John Doe"`)
+	})
+
 	t.Run("SuccessGetQuotationById", func(t *testing.T) {
 		w := httptest.NewRecorder()
 
@@ -104,6 +129,18 @@ func TestSalesRoutes(t *testing.T) {
 		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
 	})
 
+	t.Run("SparseFieldsetGetQuotationById", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/sales/quotations/1?fields=name,total_amount&include=customer", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		expectedBodyJSON := `{"code":200,"message":"","data":{"quotation":{"id":1,"name":"Quotation 1","total_amount":350,"customer":{"id":500,"full_name":"John Doe","gender":"m","email":"jd@dummy-data.com","phone":"096123456","additional_information":{"note":"This is a dummy data from john doe"}}}}}`
+
+		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
+	})
+
 	t.Run("NotFoundGetQuotationById", func(t *testing.T) {
 		w := httptest.NewRecorder()
 
@@ -115,6 +152,28 @@ func TestSalesRoutes(t *testing.T) {
 		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
 	})
 
+	t.Run("SuccessAggregateQuotations", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/sales/quotations/aggregate?group=status&aggregate=count,sum:discount", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		expectedBodyJSON := `{"code":200,"message":"","data":{"groups":[{"status":"quotation","count":1,"sum_discount":50},{"status":"quotation_sent","count":2,"sum_discount":250},{"status":"sales_order","count":2,"sum_discount":450},{"status":"cancelled","count":1,"sum_discount":300}]}}`
+
+		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
+	})
+
+	t.Run("FailedAggregateQuotations", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/sales/quotations/aggregate?group=name&aggregate=sum", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, 400, w.Code)
+	})
+
 	t.Run("SuccessGetListOfOrders", func(t *testing.T) {
 		w := httptest.NewRecorder()
 
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"system.buon18.com/m/controllers"
+	"system.buon18.com/m/database"
+	"system.buon18.com/m/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Search(e *gin.Engine, connection *database.Connection) {
+	handler := controllers.SearchHandler{
+		DB: connection.DB,
+		ServiceFacade: &services.ServiceFacade{
+			SearchService: &services.SearchService{DB: connection.DB},
+		},
+	}
+
+	// -- Every user may search, results are limited to the resources the user may view
+	e.GET(
+		"/api/search",
+		handler.Search,
+	)
+}
@@ -0,0 +1,109 @@
+package routes_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"system.buon18.com/m/config"
+	"system.buon18.com/m/database"
+	"system.buon18.com/m/middlewares"
+	"system.buon18.com/m/routes"
+	"system.buon18.com/m/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+)
+
+func TestSearchRoutes(t *testing.T) {
+	config.GetConfigInstance()
+	ctx := context.Background()
+
+	postgresContainer, err := postgres.Run(ctx,
+		"postgres:16-alpine",
+		postgres.WithDatabase("postgres"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("postgres"),
+		postgres.WithInitScripts(
+			filepath.Join("..", "database", "dev_scripts", "001_create-schema.sh"),
+			filepath.Join("..", "database", "dev_scripts", "002_seed.sh"),
+			filepath.Join("..", "database", "dev_scripts", "003_store-procedure.sh"),
+			filepath.Join("..", "database", "dev_scripts", "004_oidc.sh"),
+			filepath.Join("..", "database", "dev_scripts", "005_record-rule.sh"),
+			filepath.Join("..", "database", "dev_scripts", "006_user-role.sh"),
+			filepath.Join("..", "database", "dev_scripts", "007_audit-log.sh"),
+			filepath.Join("..", "database", "dev_scripts", "008_impersonation.sh"),
+			filepath.Join("..", "database", "dev_scripts", "009_user-state.sh"),
+			filepath.Join("..", "database", "dev_scripts", "010_session.sh"),
+			filepath.Join("..", "database", "dev_scripts", "011_company.sh"),
+			filepath.Join("..", "database", "dev_scripts", "012_search.sh"),
+			filepath.Join("..", "database", "dev_scripts", "013_saved-view.sh"),
+			filepath.Join("..", "database", "dev_scripts", "014_idempotency-key.sh"),
+			filepath.Join("..", "database", "dev_scripts", "015_audit-log-company.sh"),
+			filepath.Join("..", "database", "dev_scripts", "100_seed-customer.sh"),
+			filepath.Join("..", "database", "dev_scripts", "101_seed-quotation.sh"),
+			filepath.Join("..", "database", "dev_scripts", "102_seed-payment-term.sh"),
+			filepath.Join("..", "database", "dev_scripts", "103_seed-order.sh"),
+			filepath.Join("..", "database", "dev_scripts", "104_seed-accounting-account.sh"),
+			filepath.Join("..", "database", "dev_scripts", "105_seed-journal.sh"),
+			filepath.Join("..", "database", "dev_scripts", "106_seed-journal-entry.sh"),
+		),
+		postgres.BasicWaitStrategies(),
+	)
+	assert.NoError(t, err)
+	defer postgresContainer.Terminate(ctx)
+
+	connectionString, err := postgresContainer.ConnectionString(ctx, "sslmode=disable")
+	assert.NoError(t, err)
+
+	DB := database.InitSQL(connectionString)
+
+	router := gin.Default()
+	router.Use(middlewares.Authenticate(DB))
+	routes.Search(router, &database.Connection{
+		DB:     DB,
+		Valkey: nil,
+	})
+
+	token, err := utils.GenerateWebToken(utils.WebTokenClaims{
+		Email:       "admin@buon18.com",
+		Role:        "bot",
+		Permissions: []string{"FULL_ACCESS"},
+	})
+	assert.NoError(t, err)
+
+	t.Run("SuccessSearch", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/search?q=John", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Code)
+		assert.Contains(t, w.Body.String(), `"resource":"setting.customer","id":500,"title":"John Doe"`)
+	})
+
+	t.Run("PrefixSuccessSearch", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/search?q=Quot&limit=5", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Code)
+		assert.Contains(t, w.Body.String(), `"resource":"sales.quotation"`)
+	})
+
+	t.Run("FailedSearch", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/search?q=%20", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		expectedBodyJSON := `{"code":400,"message":"search query is required","data":null}`
+		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
+	})
+}
@@ -4,7 +4,6 @@ import (
 	"system.buon18.com/m/controllers"
 	"system.buon18.com/m/database"
 	"system.buon18.com/m/middlewares"
-	"system.buon18.com/m/models/setting"
 	"system.buon18.com/m/services"
 	settingServices "system.buon18.com/m/services/setting"
 	"system.buon18.com/m/utils"
@@ -17,87 +16,245 @@ func Setting(e *gin.Engine, connection *database.Connection) {
 		DB: connection.DB,
 		ServiceFacade: &services.ServiceFacade{
 			SettingCustomerService:   &settingServices.SettingCustomerService{DB: connection.DB},
+			SettingCompanyService:    &settingServices.SettingCompanyService{DB: connection.DB},
 			SettingRoleService:       &settingServices.SettingRoleService{DB: connection.DB},
 			SettingUserService:       &settingServices.SettingUserService{DB: connection.DB},
 			SettingPermissionService: &settingServices.SettingPermissionService{DB: connection.DB},
+			AggregateService:         &services.AggregateService{DB: connection.DB},
+			SettingSavedViewService:  &settingServices.SettingSavedViewService{DB: connection.DB},
+		},
+	}
+
+	auditHandler := controllers.AuditHandler{
+		DB: connection.DB,
+		ServiceFacade: &services.ServiceFacade{
+			SettingAuditLogService: &settingServices.SettingAuditLogService{DB: connection.DB},
+		},
+	}
+
+	sessionHandler := controllers.SessionHandler{
+		DB: connection.DB,
+		ServiceFacade: &services.ServiceFacade{
+			SettingSessionService: &settingServices.SettingSessionService{DB: connection.DB},
 		},
 	}
 
 	e.GET(
 		"/api/setting/users",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SETTING, utils.PREDEFINED_PERMISSIONS.SETTING_USERS.VIEW}),
-		middlewares.ValkeyCache[[]setting.SettingUserResponse](connection, "users"),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_USERS.VIEW}),
+		middlewares.SavedView(connection.DB),
+		middlewares.ConditionalGet(connection.DB, ""),
+		middlewares.ValkeyCache(connection, "users", "setting.user"),
 		handler.Users,
 	)
+	e.GET(
+		"/api/setting/users/aggregate",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_USERS.VIEW}),
+		handler.AggregateUsers,
+	)
 	e.GET(
 		"/api/setting/users/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SETTING, utils.PREDEFINED_PERMISSIONS.SETTING_USERS.VIEW}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_USERS.VIEW}),
+		middlewares.ConditionalGet(connection.DB, "setting.user"),
+		middlewares.ValkeyCache(connection, "user", "setting.user"),
 		handler.User,
 	)
 	e.POST(
 		"/api/setting/users",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SETTING, utils.PREDEFINED_PERMISSIONS.SETTING_USERS.CREATE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_USERS.CREATE}),
+		middlewares.Idempotency(connection),
+		middlewares.InvalidateCache(connection, "setting.user"),
 		handler.CreateUser,
 	)
 	e.PATCH(
 		"/api/setting/users/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SETTING, utils.PREDEFINED_PERMISSIONS.SETTING_USERS.UPDATE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_USERS.UPDATE}),
+		middlewares.InvalidateCache(connection, "setting.user"),
 		handler.UpdateUser,
 	)
+	e.DELETE(
+		"/api/setting/users/:id",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_USERS.DELETE}),
+		middlewares.InvalidateCache(connection, "setting.user"),
+		handler.DeleteUser,
+	)
 	e.GET(
 		"/api/setting/customers",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SETTING, utils.PREDEFINED_PERMISSIONS.SETTING_CUSTOMERS.VIEW}),
-		middlewares.ValkeyCache[[]setting.SettingCustomerResponse](connection, "customers"),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_CUSTOMERS.VIEW}),
+		middlewares.SavedView(connection.DB),
+		middlewares.ConditionalGet(connection.DB, ""),
+		middlewares.ValkeyCache(connection, "customers", "setting.customer"),
 		handler.Customers,
 	)
+	e.GET(
+		"/api/setting/customers/aggregate",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_CUSTOMERS.VIEW}),
+		handler.AggregateCustomers,
+	)
 	e.GET(
 		"/api/setting/customers/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SETTING, utils.PREDEFINED_PERMISSIONS.SETTING_CUSTOMERS.VIEW}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_CUSTOMERS.VIEW}),
+		middlewares.ConditionalGet(connection.DB, "setting.customer"),
+		middlewares.ValkeyCache(connection, "customer", "setting.customer"),
 		handler.Customer,
 	)
 	e.POST(
 		"/api/setting/customers",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SETTING, utils.PREDEFINED_PERMISSIONS.SETTING_CUSTOMERS.CREATE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_CUSTOMERS.CREATE}),
+		middlewares.Idempotency(connection),
+		middlewares.InvalidateCache(connection, "setting.customer"),
 		handler.CreateCustomer,
 	)
 	e.PATCH(
 		"/api/setting/customers/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SETTING, utils.PREDEFINED_PERMISSIONS.SETTING_CUSTOMERS.UPDATE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_CUSTOMERS.UPDATE}),
+		middlewares.InvalidateCache(connection, "setting.customer"),
 		handler.UpdateCustomer,
 	)
 	e.DELETE(
 		"/api/setting/customers/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SETTING, utils.PREDEFINED_PERMISSIONS.SETTING_CUSTOMERS.DELETE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_CUSTOMERS.DELETE}),
+		middlewares.InvalidateCache(connection, "setting.customer"),
 		handler.DeleteCustomer,
 	)
+	e.GET(
+		"/api/setting/companies",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_COMPANIES.VIEW}),
+		handler.Companies,
+	)
+	e.GET(
+		"/api/setting/companies/:id",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_COMPANIES.VIEW}),
+		middlewares.ConditionalGet(connection.DB, "setting.company"),
+		handler.Company,
+	)
+	e.POST(
+		"/api/setting/companies",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_COMPANIES.CREATE}),
+		middlewares.Idempotency(connection),
+		middlewares.InvalidateCache(connection, "setting.company"),
+		handler.CreateCompany,
+	)
+	e.PATCH(
+		"/api/setting/companies/:id",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_COMPANIES.UPDATE}),
+		middlewares.InvalidateCache(connection, "setting.company"),
+		handler.UpdateCompany,
+	)
+	e.DELETE(
+		"/api/setting/companies/:id",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_COMPANIES.DELETE}),
+		middlewares.InvalidateCache(connection, "setting.company"),
+		handler.DeleteCompany,
+	)
 	e.GET(
 		"/api/setting/roles",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SETTING, utils.PREDEFINED_PERMISSIONS.SETTING_ROLES.VIEW}),
-		middlewares.ValkeyCache[[]setting.SettingRoleResponse](connection, "roles"),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_ROLES.VIEW}),
+		middlewares.SavedView(connection.DB),
+		middlewares.ConditionalGet(connection.DB, ""),
+		middlewares.ValkeyCache(connection, "roles", "setting.role"),
 		handler.Roles,
 	)
+	e.GET(
+		"/api/setting/roles/aggregate",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_ROLES.VIEW}),
+		handler.AggregateRoles,
+	)
 	e.GET(
 		"/api/setting/roles/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SETTING, utils.PREDEFINED_PERMISSIONS.SETTING_ROLES.VIEW}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_ROLES.VIEW}),
+		middlewares.ConditionalGet(connection.DB, "setting.role"),
+		middlewares.ValkeyCache(connection, "role", "setting.role"),
 		handler.Role,
 	)
 	e.POST(
 		"/api/setting/roles",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SETTING, utils.PREDEFINED_PERMISSIONS.SETTING_ROLES.CREATE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_ROLES.CREATE}),
+		middlewares.Idempotency(connection),
+		middlewares.InvalidateCache(connection, "setting.role"),
 		handler.CreateRole,
 	)
 	e.PATCH(
 		"/api/setting/roles/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SETTING, utils.PREDEFINED_PERMISSIONS.SETTING_ROLES.UPDATE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_ROLES.UPDATE}),
+		middlewares.InvalidateCache(connection, "setting.role"),
 		handler.UpdateRole,
 	)
 	e.DELETE(
 		"/api/setting/roles/:id",
-		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_SETTING, utils.PREDEFINED_PERMISSIONS.SETTING_ROLES.DELETE}),
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_ROLES.DELETE}),
+		middlewares.InvalidateCache(connection, "setting.role"),
 		handler.DeleteRole,
 	)
+	e.GET(
+		"/api/setting/saved-views",
+		handler.SavedViews,
+	)
+	e.GET(
+		"/api/setting/saved-views/:id",
+		middlewares.ConditionalGet(connection.DB, "setting.saved_view"),
+		handler.SavedView,
+	)
+	e.POST(
+		"/api/setting/saved-views",
+		middlewares.Idempotency(connection),
+		handler.CreateSavedView,
+	)
+	e.PATCH(
+		"/api/setting/saved-views/:id",
+		handler.UpdateSavedView,
+	)
+	e.DELETE(
+		"/api/setting/saved-views/:id",
+		handler.DeleteSavedView,
+	)
+	e.GET(
+		"/api/setting/cache-stats",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCESS}),
+		handler.CacheStats,
+	)
 	e.GET(
 		"/api/setting/permissions",
 		handler.Permissions,
 	)
+	e.GET(
+		"/api/setting/audit-logs",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_AUDIT_LOGS.VIEW}),
+		auditHandler.AuditLogs,
+	)
+	e.GET(
+		"/api/setting/users/:id/history",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_USERS.VIEW}),
+		auditHandler.History("setting.user"),
+	)
+	e.GET(
+		"/api/setting/customers/:id/history",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_CUSTOMERS.VIEW}),
+		auditHandler.History("setting.customer"),
+	)
+	e.GET(
+		"/api/setting/companies/:id/history",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_COMPANIES.VIEW}),
+		auditHandler.History("setting.company"),
+	)
+	e.GET(
+		"/api/setting/roles/:id/history",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_ROLES.VIEW}),
+		auditHandler.History("setting.role"),
+	)
+	e.GET(
+		"/api/setting/sessions",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_SESSIONS.VIEW}),
+		sessionHandler.Sessions,
+	)
+	e.DELETE(
+		"/api/setting/sessions/:id",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_SESSIONS.DELETE}),
+		sessionHandler.RevokeSession,
+	)
+	e.GET(
+		"/api/setting/login-events",
+		middlewares.Authorize([]string{utils.PREDEFINED_PERMISSIONS.SETTING_LOGIN_EVENTS.VIEW}),
+		sessionHandler.LoginEvents,
+	)
 }
@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"system.buon18.com/m/config"
 	"system.buon18.com/m/database"
@@ -32,6 +33,18 @@ func TestSettingRoutes(t *testing.T) {
 		postgres.WithInitScripts(
 			filepath.Join("..", "database", "dev_scripts", "001_create-schema.sh"),
 			filepath.Join("..", "database", "dev_scripts", "002_seed.sh"),
+			filepath.Join("..", "database", "dev_scripts", "004_oidc.sh"),
+			filepath.Join("..", "database", "dev_scripts", "005_record-rule.sh"),
+			filepath.Join("..", "database", "dev_scripts", "006_user-role.sh"),
+			filepath.Join("..", "database", "dev_scripts", "007_audit-log.sh"),
+			filepath.Join("..", "database", "dev_scripts", "008_impersonation.sh"),
+			filepath.Join("..", "database", "dev_scripts", "009_user-state.sh"),
+			filepath.Join("..", "database", "dev_scripts", "010_session.sh"),
+			filepath.Join("..", "database", "dev_scripts", "011_company.sh"),
+			filepath.Join("..", "database", "dev_scripts", "012_search.sh"),
+			filepath.Join("..", "database", "dev_scripts", "013_saved-view.sh"),
+			filepath.Join("..", "database", "dev_scripts", "014_idempotency-key.sh"),
+			filepath.Join("..", "database", "dev_scripts", "015_audit-log-company.sh"),
 			filepath.Join("..", "database", "dev_scripts", "100_seed-customer.sh"),
 		),
 		postgres.BasicWaitStrategies(),
@@ -72,7 +85,7 @@ func TestSettingRoutes(t *testing.T) {
 		req.Header.Add("Authorization", "Bearer "+token)
 		router.ServeHTTP(w, req)
 
-		expectedBodyJSON := `{"code":200,"message":"","data":{"users":[{"id":1,"name":"bot","email":"bot@buon18.com","type":"bot","role":{"id":1,"name":"bot","description":"BOT","permissions":[{"id":1,"name":"FULL_ACCESS"}]}},{"id":2,"name":"admin","email":"admin@buon18.com","type":"user","role":{"id":1,"name":"bot","description":"BOT","permissions":[{"id":1,"name":"FULL_ACCESS"}]}},{"id":3,"name":"Setting Admin","email":"setting@buon18.com","type":"user","role":{"id":3,"name":"Setting Administrator","description":"Full access to all settings","permissions":[{"id":3,"name":"FULL_SETTING"}]}},{"id":4,"name":"Sales Admin","email":"sales@buon18.com","type":"user","role":{"id":4,"name":"Sales Administrator","description":"Full access to all sales","permissions":[{"id":4,"name":"FULL_SALES"}]}},{"id":5,"name":"Accounting Admin","email":"accounting@buon18.com","type":"user","role":{"id":5,"name":"Accounting Administrator","description":"Full access to all accounting","permissions":[{"id":5,"name":"FULL_ACCOUNTING"}]}}]}}`
+		expectedBodyJSON := `{"code":200,"message":"","data":{"users":[{"id":1,"name":"bot","email":"bot@buon18.com","type":"bot","state":"active","role":{"id":1,"name":"bot","description":"BOT","permissions":[{"id":1,"name":"FULL_ACCESS"}]}},{"id":2,"name":"admin","email":"admin@buon18.com","type":"user","state":"active","role":{"id":1,"name":"bot","description":"BOT","permissions":[{"id":1,"name":"FULL_ACCESS"}]}},{"id":3,"name":"Setting Admin","email":"setting@buon18.com","type":"user","state":"active","role":{"id":3,"name":"Setting Administrator","description":"Full access to all settings","permissions":[{"id":3,"name":"FULL_SETTING"}]}},{"id":4,"name":"Sales Admin","email":"sales@buon18.com","type":"user","state":"active","role":{"id":4,"name":"Sales Administrator","description":"Full access to all sales","permissions":[{"id":4,"name":"FULL_SALES"}]}},{"id":5,"name":"Accounting Admin","email":"accounting@buon18.com","type":"user","state":"active","role":{"id":5,"name":"Accounting Administrator","description":"Full access to all accounting","permissions":[{"id":5,"name":"FULL_ACCOUNTING"}]}}]}}`
 		expectedXTotalCountHeader := "5"
 
 		assert.Equal(t, expectedXTotalCountHeader, w.Header().Get("X-Total-Count"))
@@ -86,13 +99,35 @@ func TestSettingRoutes(t *testing.T) {
 		req.Header.Add("Authorization", "Bearer "+token)
 		router.ServeHTTP(w, req)
 
-		expectedBodyJSON := `{"code":200,"message":"","data":{"users":[{"id":1,"name":"bot","email":"bot@buon18.com","type":"bot","role":{"id":1,"name":"bot","description":"BOT","permissions":[{"id":1,"name":"FULL_ACCESS"}]}}]}}`
+		expectedBodyJSON := `{"code":200,"message":"","data":{"users":[{"id":1,"name":"bot","email":"bot@buon18.com","type":"bot","state":"active","role":{"id":1,"name":"bot","description":"BOT","permissions":[{"id":1,"name":"FULL_ACCESS"}]}}]}}`
 		expectedXTotalCountHeader := "1"
 
 		assert.Equal(t, expectedXTotalCountHeader, w.Header().Get("X-Total-Count"))
 		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
 	})
 
+	t.Run("SuccessAggregateUsers", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/setting/users/aggregate?group=typ", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		expectedBodyJSON := `{"code":200,"message":"","data":{"groups":[{"typ":"user","count":4},{"typ":"bot","count":1}]}}`
+
+		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
+	})
+
+	t.Run("FailedAggregateUsers", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/setting/users/aggregate?group=email", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, 400, w.Code)
+	})
+
 	t.Run("SuccessGetUserById", func(t *testing.T) {
 		w := httptest.NewRecorder()
 
@@ -100,7 +135,7 @@ func TestSettingRoutes(t *testing.T) {
 		req.Header.Add("Authorization", "Bearer "+token)
 		router.ServeHTTP(w, req)
 
-		expectedBodyJSON := `{"code":200,"message":"","data":{"user":{"id":1,"name":"bot","email":"bot@buon18.com","type":"bot","role":{"id":1,"name":"bot","description":"BOT","permissions":[{"id":1,"name":"FULL_ACCESS"}]}}}}`
+		expectedBodyJSON := `{"code":200,"message":"","data":{"user":{"id":1,"name":"bot","email":"bot@buon18.com","type":"bot","state":"active","role":{"id":1,"name":"bot","description":"BOT","permissions":[{"id":1,"name":"FULL_ACCESS"}]},"roles":[{"id":1,"name":"bot","description":"BOT","permissions":null}],"companies":[{"id":1,"name":"Default"}]}}}`
 
 		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
 	})
@@ -169,6 +204,65 @@ func TestSettingRoutes(t *testing.T) {
 		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
 	})
 
+	t.Run("NotModifiedGetCustomerById", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/setting/customers/500", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		etag := w.Header().Get("ETag")
+		lastModified := w.Header().Get("Last-Modified")
+		assert.NotEmpty(t, etag)
+		assert.NotEmpty(t, lastModified)
+
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest("GET", "/api/setting/customers/500", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		req.Header.Add("If-None-Match", etag)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, 304, w.Code)
+		assert.Empty(t, w.Body.String())
+
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest("GET", "/api/setting/customers/500", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		req.Header.Add("If-Modified-Since", lastModified)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, 304, w.Code)
+
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest("GET", "/api/setting/customers/500", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		req.Header.Add("If-None-Match", `"outdated"`)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+	})
+
+	t.Run("NotModifiedGetListOfCustomers", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/setting/customers", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		etag := w.Header().Get("ETag")
+		assert.NotEmpty(t, etag)
+		assert.Empty(t, w.Header().Get("Last-Modified"))
+
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest("GET", "/api/setting/customers", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		req.Header.Add("If-None-Match", etag)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, 304, w.Code)
+	})
+
 	t.Run("SuccessGetListOfRoles", func(t *testing.T) {
 		w := httptest.NewRecorder()
 
@@ -312,6 +406,55 @@ func TestSettingRoutes(t *testing.T) {
 		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
 	})
 
+	t.Run("IdempotentCreateCustomer", func(t *testing.T) {
+		request := setting.SettingCustomerCreateRequest{
+			FullName:              "idempotent test",
+			Gender:                "m",
+			Email:                 "idempotent-test@buon18.com",
+			Phone:                 "+85512123124",
+			AdditionalInformation: `{"note":"This is a dummy data from test"}`,
+		}
+
+		jsonData, err := json.Marshal(request)
+		assert.NoError(t, err)
+
+		expectedBodyJSON := `{"code":201,"message":"customer created successfully","data":null}`
+		for _, replayed := range []string{"", "true"} {
+			w := httptest.NewRecorder()
+
+			req := httptest.NewRequest("POST", "/api/setting/customers", bytes.NewReader(jsonData))
+			req.Header.Add("Authorization", "Bearer "+token)
+			req.Header.Add("Idempotency-Key", "idempotent-create-customer")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, 201, w.Code)
+			assert.Equal(t, replayed, w.Header().Get("Idempotent-Replayed"))
+			assert.JSONEq(t, expectedBodyJSON, w.Body.String())
+		}
+
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/setting/customers?fullname:like=idempotent", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, "1", w.Header().Get("X-Total-Count"))
+
+		request.FullName = "idempotent test changed"
+		jsonData, err = json.Marshal(request)
+		assert.NoError(t, err)
+
+		w = httptest.NewRecorder()
+
+		req = httptest.NewRequest("POST", "/api/setting/customers", bytes.NewReader(jsonData))
+		req.Header.Add("Authorization", "Bearer "+token)
+		req.Header.Add("Idempotency-Key", "idempotent-create-customer")
+		router.ServeHTTP(w, req)
+
+		expectedBodyJSON = `{"code":422,"message":"Idempotency-Key was used with a different request","data":null}`
+		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
+	})
+
 	t.Run("SuccessCreateRole", func(t *testing.T) {
 		w := httptest.NewRecorder()
 
@@ -398,6 +541,55 @@ func TestSettingRoutes(t *testing.T) {
 		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
 	})
 
+	t.Run("ConflictUpdateCustomer", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/setting/customers/500", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		etag := w.Header().Get("ETag")
+		assert.NotEmpty(t, etag)
+
+		fullName := "success"
+		request := setting.SettingCustomerUpdateRequest{
+			FullName: &fullName,
+		}
+		jsonData, err := json.Marshal(request)
+		assert.NoError(t, err)
+
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest("PATCH", "/api/setting/customers/500", bytes.NewReader(jsonData))
+		req.Header.Add("Authorization", "Bearer "+token)
+		req.Header.Add("If-Match", `"outdated"`)
+		router.ServeHTTP(w, req)
+
+		expectedBodyJSON := `{"code":409,"message":"record was modified since it was read","data":{"customer":{"id":500,"full_name":"success","gender":"m","email":"jd@dummy-data.com","phone":"096123456","additional_information":{"note":"This is a dummy data from john doe"}}}}`
+		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest("PATCH", "/api/setting/customers/500", bytes.NewReader(jsonData))
+		req.Header.Add("Authorization", "Bearer "+token)
+		req.Header.Add("If-Match", etag)
+		router.ServeHTTP(w, req)
+
+		expectedBodyJSON = `{"code":200,"message":"customer updated successfully","data":null}`
+		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
+
+		mtime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+		request.MTime = &mtime
+		jsonData, err = json.Marshal(request)
+		assert.NoError(t, err)
+
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest("PATCH", "/api/setting/customers/500", bytes.NewReader(jsonData))
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, 409, w.Code)
+	})
+
 	t.Run("SuccessUpdateUser", func(t *testing.T) {
 		w := httptest.NewRecorder()
 
@@ -421,7 +613,7 @@ func TestSettingRoutes(t *testing.T) {
 		req.Header.Add("Authorization", "Bearer "+token)
 		router.ServeHTTP(w, req)
 
-		expectedBodyJSON = `{"code":200,"message":"","data":{"user":{"id":1,"name":"success","email":"bot@buon18.com","type":"bot","role":{"id":1,"name":"bot","description":"BOT","permissions":[{"id":1,"name":"FULL_ACCESS"}]}}}}`
+		expectedBodyJSON = `{"code":200,"message":"","data":{"user":{"id":1,"name":"success","email":"bot@buon18.com","type":"bot","state":"active","role":{"id":1,"name":"bot","description":"BOT","permissions":[{"id":1,"name":"FULL_ACCESS"}]}}}}`
 		assert.Contains(t, w.Body.String(), expectedBodyJSON)
 	})
 
@@ -487,4 +679,221 @@ func TestSettingRoutes(t *testing.T) {
 		expectedBodyJSON := `{"code":404,"message":"role not found","data":null}`
 		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
 	})
+	t.Run("SuccessArchiveUser", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("DELETE", "/api/setting/users/5", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, 204, w.Code)
+
+		w = httptest.NewRecorder()
+
+		req = httptest.NewRequest("GET", "/api/setting/users/5", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		assert.Contains(t, w.Body.String(), `"state":"archived"`)
+	})
+
+	t.Run("FailedArchiveUser", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("DELETE", "/api/setting/users/0", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		expectedBodyJSON := `{"code":404,"message":"user not found","data":null}`
+		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
+	})
+
+	t.Run("SuccessCreateCompany", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		request := setting.SettingCompanyCreateRequest{
+			Name: "Branch",
+		}
+
+		jsonData, err := json.Marshal(request)
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest("POST", "/api/setting/companies", bytes.NewReader(jsonData))
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		expectedBodyJSON := `{"code":201,"message":"company created successfully","data":null}`
+
+		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
+	})
+
+	t.Run("IsolatedCompany", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/setting/customers", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		req.Header.Add("X-Company-Id", "1000")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "0", w.Header().Get("X-Total-Count"))
+	})
+
+	t.Run("IsolatedCompanyAuditLogs", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/setting/audit-logs", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		req.Header.Add("X-Company-Id", "1000")
+		router.ServeHTTP(w, req)
+
+		expectedBodyJSON := `{"code":200,"message":"","data":{"audit_logs":[]}}`
+		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
+	})
+
+	t.Run("NotAllowedCompany", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/setting/customers", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		req.Header.Add("X-Company-Id", "1001")
+		router.ServeHTTP(w, req)
+
+		expectedBodyJSON := `{"code":403,"message":"company is not allowed","data":null}`
+		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
+	})
+
+	t.Run("IdempotentCreateCustomerOfAnotherCompany", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		request := setting.SettingCustomerCreateRequest{
+			FullName:              "idempotent test",
+			Gender:                "m",
+			Email:                 "idempotent-test@buon18.com",
+			Phone:                 "+85512123124",
+			AdditionalInformation: `{"note":"This is a dummy data from test"}`,
+		}
+
+		jsonData, err := json.Marshal(request)
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest("POST", "/api/setting/customers", bytes.NewReader(jsonData))
+		req.Header.Add("Authorization", "Bearer "+token)
+		req.Header.Add("X-Company-Id", "1000")
+		req.Header.Add("Idempotency-Key", "idempotent-create-customer")
+		router.ServeHTTP(w, req)
+
+		expectedBodyJSON := `{"code":201,"message":"customer created successfully","data":null}`
+		assert.Equal(t, "", w.Header().Get("Idempotent-Replayed"))
+		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
+	})
+
+	t.Run("InvalidQueryParamsGetListOfRoles", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/setting/roles?name:eq=user&limit=1000", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		expectedBodyJSON := `{"code":400,"message":"invalid query parameters","data":{"errors":[{"param":"limit","message":"limit must not exceed 100"},{"param":"name:eq","message":"filter is not allowed"}],"allowed_filters":["name:like","description:like"],"allowed_sorts":["name"]}}`
+
+		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
+	})
+
+	t.Run("SuccessCreateSavedView", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		request := setting.SettingSavedViewCreateRequest{
+			Name:     "Female customers",
+			Endpoint: "/api/setting/customers",
+			Filters:  map[string]string{"gender:in": "f"},
+			Sorts:    map[string]string{"fullname": "asc"},
+		}
+
+		jsonData, err := json.Marshal(request)
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest("POST", "/api/setting/saved-views", bytes.NewReader(jsonData))
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		expectedBodyJSON := `{"code":201,"message":"saved view created successfully","data":null}`
+
+		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
+	})
+
+	t.Run("FailedCreateSavedView", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		request := setting.SettingSavedViewCreateRequest{
+			Name:     "Invoices",
+			Endpoint: "/api/accounting/invoices",
+			Sorts:    map[string]string{"name": "up"},
+		}
+
+		jsonData, err := json.Marshal(request)
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest("POST", "/api/setting/saved-views", bytes.NewReader(jsonData))
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, 400, w.Code)
+	})
+
+	t.Run("FailedCreateSavedViewColumns", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		request := setting.SettingSavedViewCreateRequest{
+			Name:     "Customer contacts",
+			Endpoint: "/api/setting/customers",
+			Columns:  []string{"full_name", "email"},
+		}
+
+		jsonData, err := json.Marshal(request)
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest("POST", "/api/setting/saved-views", bytes.NewReader(jsonData))
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		expectedBodyJSON := `{"code":400,"message":"columns must be fields of the endpoint","data":null}`
+		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
+	})
+
+	t.Run("SavedViewGetListOfCustomers", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/setting/customers?view_id=1000", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		expectedBodyJSON := `{"code":200,"message":"","data":{"customers":[{"id":501,"full_name":"Jane Doe","gender":"f","email":"jad@dummy-data.com","phone":"064456789","additional_information":{"note":"This is a dummy data from jane doe"}}]}}`
+		expectedXTotalCountHeader := "1"
+
+		assert.Equal(t, expectedXTotalCountHeader, w.Header().Get("X-Total-Count"))
+		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
+	})
+
+	t.Run("SavedViewOfAnotherEndpoint", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/setting/roles?view_id=1000", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		expectedBodyJSON := `{"code":400,"message":"saved view is not a view of this endpoint","data":null}`
+		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
+	})
+
+	t.Run("NotFoundSavedView", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		req := httptest.NewRequest("GET", "/api/setting/customers?view_id=0", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		expectedBodyJSON := `{"code":404,"message":"saved view not found","data":null}`
+		assert.JSONEq(t, expectedBodyJSON, w.Body.String())
+	})
 }
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"log"
+	"slices"
 
 	"system.buon18.com/m/database"
 	"system.buon18.com/m/models"
@@ -24,7 +25,9 @@ type AccountingAccountService struct {
 	DB *sql.DB
 }
 
-func (service *AccountingAccountService) Accounts(qp *utils.QueryParams) ([]accounting.AccountingAccountResponse, int, int, error) {
+func (service *AccountingAccountService) Accounts(ctx *utils.CtxW, qp *utils.QueryParams) ([]accounting.AccountingAccountResponse, int, int, error) {
+	qp.AddCondition(ctx.AccessCondition("accounting.account", `"accounting.account"`))
+
 	bqbQuery := bqb.New(`SELECT
 		"accounting.account".id,
 		"accounting.account".code,
@@ -33,9 +36,7 @@ func (service *AccountingAccountService) Accounts(qp *utils.QueryParams) ([]acco
 	FROM
 		"accounting.account"`)
 
-	qp.FilterIntoBqb(bqbQuery)
-	qp.OrderByIntoBqb(bqbQuery, `"accounting.account".id ASC`)
-	qp.PaginationIntoBqb(bqbQuery)
+	qp.PageIntoBqb(bqbQuery, `"accounting.account"`, "ASC")
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -60,26 +61,38 @@ func (service *AccountingAccountService) Accounts(qp *utils.QueryParams) ([]acco
 		accounts = append(accounts, accounting.AccountingAccountToResponse(account))
 	}
 
-	bqbQuery = bqb.New(`SELECT COUNT(*) FROM "accounting.account"`)
-	qp.FilterIntoBqb(bqbQuery)
-
-	query, params, err = bqbQuery.ToPgsql()
-	if err != nil {
-		log.Printf("%v", err)
-		return nil, 0, 500, utils.ErrInternalServer
+	if qp.IsBefore() {
+		slices.Reverse(accounts)
+	}
+	if len(accounts) > 0 {
+		if err := qp.PrepareCursors(service.DB, `"accounting.account"`, accounts[0].Id, accounts[len(accounts)-1].Id, len(accounts)); err != nil {
+			log.Printf("%v", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
 	}
 
 	var total int
-	err = service.DB.QueryRow(query, params...).Scan(&total)
-	if err != nil {
-		log.Printf("%v", err)
-		return nil, 0, 500, utils.ErrInternalServer
+	if qp.WithTotal {
+		bqbQuery = bqb.New(`SELECT COUNT(*) FROM "accounting.account"`)
+		qp.FilterIntoBqb(bqbQuery)
+
+		query, params, err = bqbQuery.ToPgsql()
+		if err != nil {
+			log.Printf("%v", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+
+		err = service.DB.QueryRow(query, params...).Scan(&total)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
 	}
 
 	return accounts, total, 200, nil
 }
 
-func (service *AccountingAccountService) Account(id string) (accounting.AccountingAccountResponse, int, error) {
+func (service *AccountingAccountService) Account(ctx *utils.CtxW, id string) (accounting.AccountingAccountResponse, int, error) {
 	bqbQuery := bqb.New(`SELECT
 		"accounting.account".id,
 		"accounting.account".code,
@@ -89,6 +102,7 @@ func (service *AccountingAccountService) Account(id string) (accounting.Accounti
 		"accounting.account"
 	WHERE
 		"accounting.account".id = ?`, id)
+	ctx.AccessIntoBqb(bqbQuery, "accounting.account", `"accounting.account"`)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -111,9 +125,9 @@ func (service *AccountingAccountService) CreateAccount(ctx *utils.CtxW, account
 	commonModel.PrepareForCreate(ctx.User.Id, ctx.User.Id)
 
 	bqbQuery := bqb.New(`INSERT INTO "accounting.account" 
-	(name, code, typ, cid, ctime, mid, mtime) 
+	(name, code, typ, setting_company_id, cid, ctime, mid, mtime) 
 	VALUES
-	(?, ?, ?, ?, ?, ?, ?)`, account.Name, account.Code, account.Typ, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime)
+	(?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`, account.Name, account.Code, account.Typ, ctx.Company.Id, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -121,8 +135,16 @@ func (service *AccountingAccountService) CreateAccount(ctx *utils.CtxW, account
 		return 500, utils.ErrInternalServer
 	}
 
-	_, err = service.DB.Exec(query, params...)
+	tx, err := service.DB.Begin()
+	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	var id int
+	err = tx.QueryRow(query, params...).Scan(&id)
 	if err != nil {
+		tx.Rollback()
 		switch err.(*pq.Error).Constraint {
 		case database.KEY_ACCOUNTING_ACCOUNT_CODE:
 			return 409, ErrAccountingAccountCodeExists
@@ -132,6 +154,23 @@ func (service *AccountingAccountService) CreateAccount(ctx *utils.CtxW, account
 		return 500, utils.ErrInternalServer
 	}
 
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "accounting.account", id)
+	if err == nil {
+		err = ctx.Audit(tx, "accounting.account", id, nil, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	return 201, nil
 }
 
@@ -142,6 +181,8 @@ func (service *AccountingAccountService) UpdateAccount(ctx *utils.CtxW, id strin
 	bqbQuery := bqb.New(`UPDATE "accounting.account" SET mid = ?, mtime = ?`, commonModel.MId, commonModel.MTime)
 	utils.PrepareUpdateBqbQuery(bqbQuery, account)
 	bqbQuery.Space(`WHERE id = ?`, id)
+	account.PreconditionIntoBqb(bqbQuery)
+	ctx.AccessIntoBqb(bqbQuery, "accounting.account", `"accounting.account"`)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -149,8 +190,22 @@ func (service *AccountingAccountService) UpdateAccount(ctx *utils.CtxW, id strin
 		return 500, utils.ErrInternalServer
 	}
 
-	result, err := service.DB.Exec(query, params...)
+	tx, err := service.DB.Begin()
 	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	before, err := utils.AuditSnapshot(tx, "accounting.account", id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	result, err := tx.Exec(query, params...)
+	if err != nil {
+		tx.Rollback()
 		switch err.(*pq.Error).Constraint {
 		case database.KEY_ACCOUNTING_ACCOUNT_CODE:
 			return 409, ErrAccountingAccountCodeExists
@@ -161,22 +216,58 @@ func (service *AccountingAccountService) UpdateAccount(ctx *utils.CtxW, id strin
 	}
 
 	if n, _ := result.RowsAffected(); n == 0 {
+		tx.Rollback()
+		if account.HasPrecondition() {
+			return 409, utils.ErrUpdateConflict
+		}
 		return 404, ErrAccountNotFound
 	}
 
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "accounting.account", id)
+	if err == nil {
+		err = ctx.Audit(tx, "accounting.account", id, before, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	return 200, nil
 }
 
-func (service *AccountingAccountService) DeleteAccount(id string) (int, error) {
+func (service *AccountingAccountService) DeleteAccount(ctx *utils.CtxW, id string) (int, error) {
 	bqbQuery := bqb.New(`DELETE FROM "accounting.account" WHERE id = ?`, id)
+	ctx.AccessIntoBqb(bqbQuery, "accounting.account", `"accounting.account"`)
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
 		log.Printf("%v", err)
 		return 500, utils.ErrInternalServer
 	}
 
-	result, err := service.DB.Exec(query, params...)
+	tx, err := service.DB.Begin()
 	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	before, err := utils.AuditSnapshot(tx, "accounting.account", id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	result, err := tx.Exec(query, params...)
+	if err != nil {
+		tx.Rollback()
 		switch err.(*pq.Error).Constraint {
 		case database.FK_ACCOUNTING_ACCOUNT_ID:
 			return 409, ErrUnableToDeleteCurrentlyUsedAccount
@@ -187,8 +278,22 @@ func (service *AccountingAccountService) DeleteAccount(id string) (int, error) {
 	}
 
 	if n, _ := result.RowsAffected(); n == 0 {
+		tx.Rollback()
 		return 404, ErrAccountNotFound
 	}
 
+	// -- Audit
+	if err := ctx.Audit(tx, "accounting.account", id, before, nil); err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	return 200, nil
 }
@@ -24,7 +24,9 @@ type AccountingJournalService struct {
 	DB *sql.DB
 }
 
-func (service *AccountingJournalService) Journals(qp *utils.QueryParams) ([]accounting.AccountingJournalResponse, int, int, error) {
+func (service *AccountingJournalService) Journals(ctx *utils.CtxW, qp *utils.QueryParams) ([]accounting.AccountingJournalResponse, int, int, error) {
+	qp.AddCondition(ctx.AccessCondition("accounting.journal", `"accounting.journal"`))
+
 	bqbQuery := bqb.New(`WITH "limited_journals" AS (
 		SELECT
 			"accounting.journal".id,
@@ -35,8 +37,7 @@ func (service *AccountingJournalService) Journals(qp *utils.QueryParams) ([]acco
 		FROM
 			"accounting.journal"`)
 
-	qp.FilterIntoBqb(bqbQuery)
-	qp.PaginationIntoBqb(bqbQuery)
+	qp.PageIntoBqb(bqbQuery, `"accounting.journal"`, "ASC")
 
 	bqbQuery.Space(`)
 	SELECT
@@ -80,26 +81,35 @@ func (service *AccountingJournalService) Journals(qp *utils.QueryParams) ([]acco
 		journals = append(journals, accounting.AccountingJournalToResponse(journal, &accountResponse))
 	}
 
-	bqbQuery = bqb.New(`SELECT COUNT(*) FROM "accounting.journal"`)
-	qp.FilterIntoBqb(bqbQuery)
-
-	query, params, err = bqbQuery.ToPgsql()
-	if err != nil {
-		log.Printf("%v", err)
-		return nil, 0, 500, utils.ErrInternalServer
+	if len(journals) > 0 {
+		if err := qp.PrepareCursors(service.DB, `"accounting.journal"`, journals[0].Id, journals[len(journals)-1].Id, len(journals)); err != nil {
+			log.Printf("%v", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
 	}
 
 	var total int
-	err = service.DB.QueryRow(query, params...).Scan(&total)
-	if err != nil {
-		log.Printf("%v", err)
-		return nil, 0, 500, utils.ErrInternalServer
+	if qp.WithTotal {
+		bqbQuery = bqb.New(`SELECT COUNT(*) FROM "accounting.journal"`)
+		qp.FilterIntoBqb(bqbQuery)
+
+		query, params, err = bqbQuery.ToPgsql()
+		if err != nil {
+			log.Printf("%v", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+
+		err = service.DB.QueryRow(query, params...).Scan(&total)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
 	}
 
 	return journals, total, 200, nil
 }
 
-func (service *AccountingJournalService) Journal(id string) (accounting.AccountingJournalResponse, int, error) {
+func (service *AccountingJournalService) Journal(ctx *utils.CtxW, id string) (accounting.AccountingJournalResponse, int, error) {
 	bqbQuery := bqb.New(`WITH "limited_journals" AS (
 		SELECT
 			"accounting.journal".id,
@@ -110,8 +120,10 @@ func (service *AccountingJournalService) Journal(id string) (accounting.Accounti
 		FROM
 			"accounting.journal"
 		WHERE
-			"accounting.journal".id = ?
-	)
+			"accounting.journal".id = ?`, id)
+	ctx.AccessIntoBqb(bqbQuery, "accounting.journal", `"accounting.journal"`)
+
+	bqbQuery.Space(`)
 	SELECT
 		"limited_journals".id,
 		"limited_journals".code,
@@ -123,7 +135,7 @@ func (service *AccountingJournalService) Journal(id string) (accounting.Accounti
 		"accounting.account".typ
 	FROM
 		"limited_journals"
-	INNER JOIN "accounting.account" ON "accounting.account".id = "limited_journals".accounting_account_id`, id)
+	INNER JOIN "accounting.account" ON "accounting.account".id = "limited_journals".accounting_account_id`)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -157,9 +169,9 @@ func (service *AccountingJournalService) CreateJournal(ctx *utils.CtxW, journal
 	commonModel.PrepareForCreate(ctx.User.Id, ctx.User.Id)
 
 	bqbQuery := bqb.New(`INSERT INTO "accounting.journal"
-	(code, name, typ, accounting_account_id, cid, ctime, mid, mtime)
+	(code, name, typ, accounting_account_id, setting_company_id, cid, ctime, mid, mtime)
 	VALUES
-	(?, ?, ?, ?, ?, ?, ?, ?)`, journal.Code, journal.Name, journal.Typ, journal.AccountId, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime)
+	(?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`, journal.Code, journal.Name, journal.Typ, journal.AccountId, ctx.Company.Id, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -167,8 +179,16 @@ func (service *AccountingJournalService) CreateJournal(ctx *utils.CtxW, journal
 		return 500, utils.ErrInternalServer
 	}
 
-	_, err = service.DB.Exec(query, params...)
+	tx, err := service.DB.Begin()
+	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	var id int
+	err = tx.QueryRow(query, params...).Scan(&id)
 	if err != nil {
+		tx.Rollback()
 		switch err.(*pq.Error).Constraint {
 		case database.FK_ACCOUNTING_ACCOUNT_ID:
 			return 400, ErrAccountNotFound
@@ -180,6 +200,23 @@ func (service *AccountingJournalService) CreateJournal(ctx *utils.CtxW, journal
 		return 500, utils.ErrInternalServer
 	}
 
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "accounting.journal", id)
+	if err == nil {
+		err = ctx.Audit(tx, "accounting.journal", id, nil, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	return 201, nil
 }
 
@@ -190,6 +227,8 @@ func (service *AccountingJournalService) UpdateJournal(ctx *utils.CtxW, id strin
 	bqbQuery := bqb.New(`UPDATE "accounting.journal" SET mid = ?, mtime = ?`, commonModel.MId, commonModel.MTime)
 	utils.PrepareUpdateBqbQuery(bqbQuery, journal)
 	bqbQuery.Space(`WHERE id = ?`, id)
+	journal.PreconditionIntoBqb(bqbQuery)
+	ctx.AccessIntoBqb(bqbQuery, "accounting.journal", `"accounting.journal"`)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -197,8 +236,22 @@ func (service *AccountingJournalService) UpdateJournal(ctx *utils.CtxW, id strin
 		return 500, utils.ErrInternalServer
 	}
 
-	result, err := service.DB.Exec(query, params...)
+	tx, err := service.DB.Begin()
 	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	before, err := utils.AuditSnapshot(tx, "accounting.journal", id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	result, err := tx.Exec(query, params...)
+	if err != nil {
+		tx.Rollback()
 		switch err.(*pq.Error).Constraint {
 		case database.FK_ACCOUNTING_ACCOUNT_ID:
 			return 400, ErrAccountNotFound
@@ -211,22 +264,58 @@ func (service *AccountingJournalService) UpdateJournal(ctx *utils.CtxW, id strin
 	}
 
 	if n, _ := result.RowsAffected(); n == 0 {
+		tx.Rollback()
+		if journal.HasPrecondition() {
+			return 409, utils.ErrUpdateConflict
+		}
 		return 404, ErrJournalNotFound
 	}
 
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "accounting.journal", id)
+	if err == nil {
+		err = ctx.Audit(tx, "accounting.journal", id, before, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	return 200, nil
 }
 
-func (service *AccountingJournalService) DeleteJournal(id string) (int, error) {
+func (service *AccountingJournalService) DeleteJournal(ctx *utils.CtxW, id string) (int, error) {
 	bqbQuery := bqb.New(`DELETE FROM "accounting.journal" WHERE id = ?`, id)
+	ctx.AccessIntoBqb(bqbQuery, "accounting.journal", `"accounting.journal"`)
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
 		log.Printf("%v", err)
 		return 500, utils.ErrInternalServer
 	}
 
-	result, err := service.DB.Exec(query, params...)
+	tx, err := service.DB.Begin()
+	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	before, err := utils.AuditSnapshot(tx, "accounting.journal", id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	result, err := tx.Exec(query, params...)
 	if err != nil {
+		tx.Rollback()
 		switch err.(*pq.Error).Constraint {
 		case database.FK_ACCOUNTING_JOURNAL_ID:
 			return 400, ErrUnableToDeleteCurrentlyUsedJournal
@@ -237,8 +326,22 @@ func (service *AccountingJournalService) DeleteJournal(id string) (int, error) {
 	}
 
 	if n, _ := result.RowsAffected(); n == 0 {
+		tx.Rollback()
 		return 404, ErrJournalNotFound
 	}
 
+	// -- Audit
+	if err := ctx.Audit(tx, "accounting.journal", id, before, nil); err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	return 200, nil
 }
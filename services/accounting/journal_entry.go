@@ -26,7 +26,9 @@ type AccountingJournalEntryService struct {
 	DB *sql.DB
 }
 
-func (service *AccountingJournalEntryService) JournalEntries(qp *utils.QueryParams) ([]accounting.AccountingJournalEntryResponse, int, int, error) {
+func (service *AccountingJournalEntryService) JournalEntries(ctx *utils.CtxW, qp *utils.QueryParams) ([]accounting.AccountingJournalEntryResponse, int, int, error) {
+	qp.AddCondition(ctx.AccessCondition("accounting.journal_entry", `"accounting.journal_entry"`))
+
 	bqbQuery := bqb.New(`WITH limited_journal_entries AS (
     SELECT
         "accounting.journal_entry".id,
@@ -38,8 +40,7 @@ func (service *AccountingJournalEntryService) JournalEntries(qp *utils.QueryPara
     FROM
         "accounting.journal_entry"`)
 
-	qp.FilterIntoBqb(bqbQuery)
-	qp.PaginationIntoBqb(bqbQuery)
+	qp.PageIntoBqb(bqbQuery, `"accounting.journal_entry"`, "ASC")
 
 	bqbQuery.Space(`)
 	SELECT
@@ -143,26 +144,35 @@ func (service *AccountingJournalEntryService) JournalEntries(qp *utils.QueryPara
 		journalEntriesResponse = append(journalEntriesResponse, accounting.AccountingJournalEntryToResponse(lastJournalEntry, journalEntryLinesResponse, journalResponse))
 	}
 
-	bqbQuery = bqb.New(`SELECT COUNT(*) FROM "accounting.journal_entry"`)
-	qp.FilterIntoBqb(bqbQuery)
-
-	query, params, err = bqbQuery.ToPgsql()
-	if err != nil {
-		log.Printf("%v", err)
-		return nil, 0, 500, utils.ErrInternalServer
+	if len(journalEntriesResponse) > 0 {
+		if err := qp.PrepareCursors(service.DB, `"accounting.journal_entry"`, journalEntriesResponse[0].Id, journalEntriesResponse[len(journalEntriesResponse)-1].Id, len(journalEntriesResponse)); err != nil {
+			log.Printf("%v", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
 	}
 
 	var total int
-	err = service.DB.QueryRow(query, params...).Scan(&total)
-	if err != nil {
-		log.Printf("%v", err)
-		return nil, 0, 500, utils.ErrInternalServer
+	if qp.WithTotal {
+		bqbQuery = bqb.New(`SELECT COUNT(*) FROM "accounting.journal_entry"`)
+		qp.FilterIntoBqb(bqbQuery)
+
+		query, params, err = bqbQuery.ToPgsql()
+		if err != nil {
+			log.Printf("%v", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+
+		err = service.DB.QueryRow(query, params...).Scan(&total)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
 	}
 
 	return journalEntriesResponse, total, 200, nil
 }
 
-func (service *AccountingJournalEntryService) JournalEntry(id string) (accounting.AccountingJournalEntryResponse, int, error) {
+func (service *AccountingJournalEntryService) JournalEntry(ctx *utils.CtxW, id string) (accounting.AccountingJournalEntryResponse, int, error) {
 	bqbQuery := bqb.New(`WITH limited_journal_entries AS (
 		SELECT
 			"accounting.journal_entry".id,
@@ -174,8 +184,9 @@ func (service *AccountingJournalEntryService) JournalEntry(id string) (accountin
 		FROM
 			"accounting.journal_entry"
 		WHERE
-			"accounting.journal_entry".id = ?
-		)
+			"accounting.journal_entry".id = ?`, id)
+	ctx.AccessIntoBqb(bqbQuery, "accounting.journal_entry", `"accounting.journal_entry"`)
+	bqbQuery.Space(`)
 		SELECT
 			"limited_journal_entries".id,
 			"limited_journal_entries".name,
@@ -200,7 +211,7 @@ func (service *AccountingJournalEntryService) JournalEntry(id string) (accountin
 		INNER JOIN "accounting.journal_entry_line" ON "accounting.journal_entry_line".accounting_journal_entry_id = "limited_journal_entries".id
 		INNER JOIN "accounting.account" ON "accounting.account".id = "accounting.journal_entry_line".accounting_account_id
 		INNER JOIN "accounting.journal" ON "accounting.journal".id = "limited_journal_entries".accounting_journal_id
-		ORDER BY "limited_journal_entries".id ASC, "accounting.journal_entry_line".sequence ASC`, id)
+		ORDER BY "limited_journal_entries".id ASC, "accounting.journal_entry_line".sequence ASC`)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -275,11 +286,25 @@ func (service *AccountingJournalEntryService) CreateJournalEntry(ctx *utils.CtxW
 		return 500, utils.ErrInternalServer
 	}
 
+	// -- Lines can only use the accounts of the company
+	accountIds := make([]int, 0)
+	for _, line := range journalEntry.Lines {
+		accountIds = append(accountIds, line.AccountId)
+	}
+	if ok, err := accountsInCompany(tx, ctx, accountIds); err != nil || !ok {
+		tx.Rollback()
+		if err == nil {
+			return 404, ErrAccountNotFound
+		}
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	bqbQuery := bqb.New(`INSERT INTO "accounting.journal_entry"
-	(name, date, note, status, accounting_journal_id, cid, ctime, mid, mtime)
+	(name, date, note, status, accounting_journal_id, setting_company_id, cid, ctime, mid, mtime)
 	VALUES
-	(?, ?, ?, ?, ?, ?, ?, ?, ?)
-	RETURNING id`, journalEntry.Name, journalEntry.Date, journalEntry.Note, journalEntry.Status, journalEntry.JournalId, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime)
+	(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	RETURNING id`, journalEntry.Name, journalEntry.Date, journalEntry.Note, journalEntry.Status, journalEntry.JournalId, ctx.Company.Id, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -334,6 +359,17 @@ func (service *AccountingJournalEntryService) CreateJournalEntry(ctx *utils.CtxW
 		return 500, utils.ErrInternalServer
 	}
 
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "accounting.journal_entry", journalEntryId)
+	if err == nil {
+		err = ctx.Audit(tx, "accounting.journal_entry", journalEntryId, nil, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		log.Printf("%v", err)
@@ -353,7 +389,15 @@ func (service *AccountingJournalEntryService) UpdateJournalEntry(ctx *utils.CtxW
 		return 500, utils.ErrInternalServer
 	}
 
+	before, err := utils.AuditSnapshot(tx, "accounting.journal_entry", id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	bqbQuery := bqb.New(`SELECT status FROM "accounting.journal_entry" WHERE id = ?`, id)
+	ctx.AccessIntoBqb(bqbQuery, "accounting.journal_entry", `"accounting.journal_entry"`)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -366,6 +410,9 @@ func (service *AccountingJournalEntryService) UpdateJournalEntry(ctx *utils.CtxW
 	err = tx.QueryRow(query, params...).Scan(&status)
 	if err != nil {
 		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return 404, ErrJournalEntryNotFound
+		}
 		log.Printf("%v", err)
 		return 500, utils.ErrInternalServer
 	}
@@ -378,6 +425,7 @@ func (service *AccountingJournalEntryService) UpdateJournalEntry(ctx *utils.CtxW
 	bqbQuery = bqb.New(`UPDATE "accounting.journal_entry" SET mid = ?, mtime = ?`, commonModel.MId, commonModel.MTime)
 	utils.PrepareUpdateBqbQuery(bqbQuery, journalEntry)
 	bqbQuery.Space(`WHERE id = ?`, id)
+	journalEntry.PreconditionIntoBqb(bqbQuery)
 
 	query, params, err = bqbQuery.ToPgsql()
 	if err != nil {
@@ -386,7 +434,7 @@ func (service *AccountingJournalEntryService) UpdateJournalEntry(ctx *utils.CtxW
 		return 500, utils.ErrInternalServer
 	}
 
-	_, err = tx.Exec(query, params...)
+	result, err := tx.Exec(query, params...)
 	if err != nil {
 		tx.Rollback()
 
@@ -401,6 +449,35 @@ func (service *AccountingJournalEntryService) UpdateJournalEntry(ctx *utils.CtxW
 		return 500, utils.ErrInternalServer
 	}
 
+	// -- The entry exists, it was modified since the client read it
+	if n, _ := result.RowsAffected(); n == 0 {
+		tx.Rollback()
+		return 409, utils.ErrUpdateConflict
+	}
+
+	// -- Lines can only use the accounts of the company
+	accountIds := make([]int, 0)
+	if journalEntry.AddLines != nil {
+		for _, line := range *journalEntry.AddLines {
+			accountIds = append(accountIds, line.AccountId)
+		}
+	}
+	if journalEntry.UpdateLines != nil {
+		for _, line := range *journalEntry.UpdateLines {
+			if line.AccountId != nil {
+				accountIds = append(accountIds, *line.AccountId)
+			}
+		}
+	}
+	if ok, err := accountsInCompany(tx, ctx, accountIds); err != nil || !ok {
+		tx.Rollback()
+		if err == nil {
+			return 400, ErrAccountNotFound
+		}
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	errorChan := make(chan error)
 	var wg sync.WaitGroup
 
@@ -515,6 +592,17 @@ func (service *AccountingJournalEntryService) UpdateJournalEntry(ctx *utils.CtxW
 		return 500, utils.ErrInternalServer
 	}
 
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "accounting.journal_entry", id)
+	if err == nil {
+		err = ctx.Audit(tx, "accounting.journal_entry", id, before, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		log.Printf("%v", err)
@@ -524,14 +612,22 @@ func (service *AccountingJournalEntryService) UpdateJournalEntry(ctx *utils.CtxW
 	return 200, nil
 }
 
-func (service *AccountingJournalEntryService) DeleteJournalEntry(id string) (int, error) {
+func (service *AccountingJournalEntryService) DeleteJournalEntry(ctx *utils.CtxW, id string) (int, error) {
 	tx, err := service.DB.Begin()
 	if err != nil {
 		log.Printf("%v", err)
 		return 500, utils.ErrInternalServer
 	}
 
+	before, err := utils.AuditSnapshot(tx, "accounting.journal_entry", id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	bqbQuery := bqb.New(`SELECT status FROM "accounting.journal_entry" WHERE id = ?`, id)
+	ctx.AccessIntoBqb(bqbQuery, "accounting.journal_entry", `"accounting.journal_entry"`)
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
 		tx.Rollback()
@@ -587,6 +683,13 @@ func (service *AccountingJournalEntryService) DeleteJournalEntry(id string) (int
 		return 500, utils.ErrInternalServer
 	}
 
+	// -- Audit
+	if err := ctx.Audit(tx, "accounting.journal_entry", id, before, nil); err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		log.Printf("%v", err)
@@ -595,3 +698,29 @@ func (service *AccountingJournalEntryService) DeleteJournalEntry(id string) (int
 
 	return 200, nil
 }
+
+// accountsInCompany reports whether every account belongs to the active company.
+func accountsInCompany(tx *sql.Tx, ctx *utils.CtxW, accountIds []int) (bool, error) {
+	if len(accountIds) == 0 {
+		return true, nil
+	}
+
+	bqbQuery := bqb.New(`SELECT COUNT(DISTINCT id) FROM "accounting.account" WHERE id IN (?)`, accountIds)
+	ctx.AccessIntoBqb(bqbQuery, "accounting.account", `"accounting.account"`)
+
+	query, params, err := bqbQuery.ToPgsql()
+	if err != nil {
+		return false, err
+	}
+
+	var count int
+	if err := tx.QueryRow(query, params...).Scan(&count); err != nil {
+		return false, err
+	}
+
+	distinctIds := make(map[int]bool)
+	for _, accountId := range accountIds {
+		distinctIds[accountId] = true
+	}
+	return count == len(distinctIds), nil
+}
@@ -25,7 +25,9 @@ type AccountingPaymentTermService struct {
 	DB *sql.DB
 }
 
-func (service *AccountingPaymentTermService) PaymentTerms(qp *utils.QueryParams) ([]accounting.AccountingPaymentTermResponse, int, int, error) {
+func (service *AccountingPaymentTermService) PaymentTerms(ctx *utils.CtxW, qp *utils.QueryParams) ([]accounting.AccountingPaymentTermResponse, int, int, error) {
+	qp.AddCondition(ctx.AccessCondition("accounting.payment_term", `"accounting.payment_term"`))
+
 	bqbQuery := bqb.New(`
 	WITH "limited_payment_terms" AS (
 		SELECT
@@ -34,8 +36,7 @@ func (service *AccountingPaymentTermService) PaymentTerms(qp *utils.QueryParams)
 			description
 		FROM
 			"accounting.payment_term"`)
-	qp.FilterIntoBqb(bqbQuery)
-	qp.PaginationIntoBqb(bqbQuery)
+	qp.PageIntoBqb(bqbQuery, `"accounting.payment_term"`, "ASC")
 
 	bqbQuery.Space(`)
 	SELECT 
@@ -111,26 +112,35 @@ func (service *AccountingPaymentTermService) PaymentTerms(qp *utils.QueryParams)
 		paymentTermsResponse = append(paymentTermsResponse, accounting.AccountingPaymentTermToResponse(lastPaymentTerm, paymentTermLinesResponse))
 	}
 
-	bqbQuery = bqb.New(`SELECT COUNT(*) FROM "accounting.payment_term"`)
-	qp.FilterIntoBqb(bqbQuery)
-
-	query, params, err = bqbQuery.ToPgsql()
-	if err != nil {
-		log.Printf("%v", err)
-		return nil, 0, 500, utils.ErrInternalServer
+	if len(paymentTermsResponse) > 0 {
+		if err := qp.PrepareCursors(service.DB, `"accounting.payment_term"`, paymentTermsResponse[0].Id, paymentTermsResponse[len(paymentTermsResponse)-1].Id, len(paymentTermsResponse)); err != nil {
+			log.Printf("%v", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
 	}
 
 	var total int
-	err = service.DB.QueryRow(query, params...).Scan(&total)
-	if err != nil {
-		log.Printf("%v", err)
-		return nil, 0, 500, utils.ErrInternalServer
+	if qp.WithTotal {
+		bqbQuery = bqb.New(`SELECT COUNT(*) FROM "accounting.payment_term"`)
+		qp.FilterIntoBqb(bqbQuery)
+
+		query, params, err = bqbQuery.ToPgsql()
+		if err != nil {
+			log.Printf("%v", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+
+		err = service.DB.QueryRow(query, params...).Scan(&total)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
 	}
 
 	return paymentTermsResponse, total, 200, nil
 }
 
-func (service *AccountingPaymentTermService) PaymentTerm(id string) (accounting.AccountingPaymentTermResponse, int, error) {
+func (service *AccountingPaymentTermService) PaymentTerm(ctx *utils.CtxW, id string) (accounting.AccountingPaymentTermResponse, int, error) {
 	bqbQuery := bqb.New(`
 	WITH "limited_payment_terms" AS (
 		SELECT
@@ -139,7 +149,10 @@ func (service *AccountingPaymentTermService) PaymentTerm(id string) (accounting.
 			description
 		FROM
 			"accounting.payment_term"
-		WHERE id = ?)
+		WHERE id = ?`, id)
+	ctx.AccessIntoBqb(bqbQuery, "accounting.payment_term", `"accounting.payment_term"`)
+
+	bqbQuery.Space(`)
 	SELECT 
 		"limited_payment_terms".id,
 		"limited_payment_terms".name,
@@ -151,7 +164,7 @@ func (service *AccountingPaymentTermService) PaymentTerm(id string) (accounting.
 	FROM
 		"limited_payment_terms"
 	INNER JOIN "accounting.payment_term_line" ON "limited_payment_terms".id = "accounting.payment_term_line".accounting_payment_term_id
-	ORDER BY "limited_payment_terms".id ASC, "accounting.payment_term_line".sequence ASC`, id)
+	ORDER BY "limited_payment_terms".id ASC, "accounting.payment_term_line".sequence ASC`)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -209,9 +222,9 @@ func (service *AccountingPaymentTermService) CreatePaymentTerm(ctx *utils.CtxW,
 	}
 
 	bqbQuery := bqb.New(`INSERT INTO "accounting.payment_term" 
-	(name, description, cid, ctime, mid, mtime) 
+	(name, description, setting_company_id, cid, ctime, mid, mtime) 
 	VALUES 
-	(?, ?, ?, ?, ?, ?) RETURNING id`, paymentTerm.Name, paymentTerm.Description, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime)
+	(?, ?, ?, ?, ?, ?, ?) RETURNING id`, paymentTerm.Name, paymentTerm.Description, ctx.Company.Id, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -257,6 +270,17 @@ func (service *AccountingPaymentTermService) CreatePaymentTerm(ctx *utils.CtxW,
 		return 500, utils.ErrInternalServer
 	}
 
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "accounting.payment_term", paymentTermId)
+	if err == nil {
+		err = ctx.Audit(tx, "accounting.payment_term", paymentTermId, nil, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		log.Printf("%v", err)
@@ -276,9 +300,18 @@ func (service *AccountingPaymentTermService) UpdatePaymentTerm(ctx *utils.CtxW,
 		return 500, utils.ErrInternalServer
 	}
 
+	before, err := utils.AuditSnapshot(tx, "accounting.payment_term", id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	bqbQuery := bqb.New(`UPDATE "accounting.payment_term" SET mid = ?, mtime = ?`, commonModel.MId, commonModel.MTime)
 	utils.PrepareUpdateBqbQuery(bqbQuery, paymentTerm)
 	bqbQuery.Space(`WHERE id = ?`, id)
+	paymentTerm.PreconditionIntoBqb(bqbQuery)
+	ctx.AccessIntoBqb(bqbQuery, "accounting.payment_term", `"accounting.payment_term"`)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -302,6 +335,9 @@ func (service *AccountingPaymentTermService) UpdatePaymentTerm(ctx *utils.CtxW,
 
 	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
 		tx.Rollback()
+		if paymentTerm.HasPrecondition() {
+			return 409, utils.ErrUpdateConflict
+		}
 		return 404, ErrPaymentTermNotFound
 	}
 
@@ -420,6 +456,17 @@ func (service *AccountingPaymentTermService) UpdatePaymentTerm(ctx *utils.CtxW,
 		return 500, utils.ErrInternalServer
 	}
 
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "accounting.payment_term", id)
+	if err == nil {
+		err = ctx.Audit(tx, "accounting.payment_term", id, before, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		log.Printf("%v", err)
@@ -429,13 +476,20 @@ func (service *AccountingPaymentTermService) UpdatePaymentTerm(ctx *utils.CtxW,
 	return 200, nil
 }
 
-func (service *AccountingPaymentTermService) DeletePaymentTerm(id string) (int, error) {
+func (service *AccountingPaymentTermService) DeletePaymentTerm(ctx *utils.CtxW, id string) (int, error) {
 	tx, err := service.DB.Begin()
 	if err != nil {
 		log.Printf("%v", err)
 		return 500, utils.ErrInternalServer
 	}
 
+	before, err := utils.AuditSnapshot(tx, "accounting.payment_term", id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	bqbQuery := bqb.New(`DELETE FROM "accounting.payment_term_line" WHERE accounting_payment_term_id = ?`, id)
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -452,6 +506,7 @@ func (service *AccountingPaymentTermService) DeletePaymentTerm(id string) (int,
 	}
 
 	bqbQuery = bqb.New(`DELETE FROM "accounting.payment_term" WHERE id = ?`, id)
+	ctx.AccessIntoBqb(bqbQuery, "accounting.payment_term", `"accounting.payment_term"`)
 	query, params, err = bqbQuery.ToPgsql()
 	if err != nil {
 		log.Printf("%v", err)
@@ -477,6 +532,13 @@ func (service *AccountingPaymentTermService) DeletePaymentTerm(id string) (int,
 		return 404, ErrPaymentTermNotFound
 	}
 
+	// -- Audit
+	if err := ctx.Audit(tx, "accounting.payment_term", id, before, nil); err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		log.Printf("%v", err)
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+
+	"system.buon18.com/m/utils"
+)
+
+type AggregateService struct {
+	DB *sql.DB
+}
+
+// Aggregate groups the accessible records of resource matching the filters
+// of qp, a group maps the group fields and aggregate names to their values.
+func (service *AggregateService) Aggregate(ctx *utils.CtxW, resource string, qp *utils.QueryParams) ([]map[string]interface{}, int, error) {
+	prefix := fmt.Sprintf(`"%s"`, resource)
+	qp.AddCondition(ctx.AccessCondition(resource, prefix))
+
+	query, params, err := qp.AggregateIntoBqb(prefix).ToPgsql()
+	if err != nil {
+		log.Printf("%v", err)
+		return nil, 500, utils.ErrInternalServer
+	}
+
+	rows, err := service.DB.Query(query, params...)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil, 500, utils.ErrInternalServer
+	}
+	defer rows.Close()
+
+	groups := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		values := make([]interface{}, len(qp.Groups)+len(qp.Aggregates))
+		valuePtrs := make([]interface{}, len(values))
+		for index := range values {
+			valuePtrs[index] = &values[index]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			log.Printf("%v", err)
+			return nil, 500, utils.ErrInternalServer
+		}
+
+		group := make(map[string]interface{}, len(values))
+		for index, groupValue := range qp.Groups {
+			if value, ok := values[index].([]byte); ok {
+				values[index] = string(value)
+			}
+			group[groupValue.Field] = values[index]
+		}
+		for index, aggregate := range qp.Aggregates {
+			value := values[len(qp.Groups)+index]
+			// -- Sums and averages of numeric columns are scanned as text
+			if number, ok := value.([]byte); ok {
+				if value, err = strconv.ParseFloat(string(number), 64); err != nil {
+					log.Printf("%v", err)
+					return nil, 500, utils.ErrInternalServer
+				}
+			}
+			group[aggregate.Name()] = value
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, 200, nil
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"reflect"
 
+	"system.buon18.com/m/config"
 	"system.buon18.com/m/models"
 	"system.buon18.com/m/models/setting"
 	"system.buon18.com/m/utils"
@@ -20,6 +21,15 @@ var (
 	ErrInvalidRefreshToken    = errors.New("invalid refresh token")
 	ErrInvalidOldPassword     = errors.New("invalid old password")
 	ErrUserNotFound           = errors.New("user not found")
+	ErrOIDCMissingEmail       = errors.New("id token does not contain an email claim")
+	ErrOIDCEmailNotVerified   = errors.New("email is not verified by the identity provider")
+	ErrOIDCSubjectMismatch    = errors.New("account is linked to another identity")
+	ErrOIDCNoRoleMapped       = errors.New("no role is mapped to this identity")
+	ErrUseSingleSignOn        = errors.New("this account signs in with single sign-on")
+	ErrAccountInactive        = errors.New("account is not active")
+	ErrAlreadyImpersonating   = errors.New("unable to impersonate while impersonating")
+	ErrImpersonateSelf        = errors.New("unable to impersonate yourself")
+	ErrImpersonating          = errors.New("unable to perform this action while impersonating")
 )
 
 type LoginRequest struct {
@@ -42,8 +52,13 @@ type UpdateProfileRequest struct {
 	RoleId *uint   `json:"role_id"`
 }
 
+type ImpersonateRequest struct {
+	UserId uint `json:"user_id" binding:"required"`
+}
+
 type AuthService struct {
-	DB *sql.DB
+	DB           *sql.DB
+	OIDCProvider *utils.OIDCProvider
 }
 
 func (service *AuthService) Me(ctx *utils.CtxW) (setting.SettingUserResponse, int, error) {
@@ -52,32 +67,44 @@ func (service *AuthService) Me(ctx *utils.CtxW) (setting.SettingUserResponse, in
 		perrmissionsResponse = append(perrmissionsResponse, setting.SettingPermissionToResponse(permission))
 	}
 	roleResponse := setting.SettingRoleToResponse(ctx.Role, perrmissionsResponse)
-	return setting.SettingUserToResponse(ctx.User, roleResponse), 200, nil
+	userResponse := setting.SettingUserToResponse(ctx.User, roleResponse)
+
+	// -- Permissions of the context are the effective ones of every role
+	userResponse.Roles = make([]setting.SettingRoleResponse, 0)
+	for _, role := range ctx.Roles {
+		userResponse.Roles = append(userResponse.Roles, setting.SettingRoleToResponse(role, nil))
+	}
+	userResponse.Permissions = perrmissionsResponse
+
+	userResponse.Companies = make([]setting.SettingCompanyResponse, 0)
+	for _, company := range ctx.Companies {
+		userResponse.Companies = append(userResponse.Companies, setting.SettingCompanyToResponse(company))
+	}
+	company := setting.SettingCompanyToResponse(ctx.Company)
+	userResponse.Company = &company
+
+	if ctx.Actor != nil {
+		impersonator := setting.SettingUserToResponse(*ctx.Actor, setting.SettingRoleResponse{})
+		userResponse.Impersonator = &impersonator
+	}
+
+	return userResponse, 200, nil
 }
 
-func (service *AuthService) Login(loginRequest *LoginRequest) (models.TokenAndRefreshToken, int, error) {
+func (service *AuthService) Login(loginRequest *LoginRequest, client utils.ClientInfo) (models.TokenAndRefreshToken, int, error) {
 	// -- Prepare sql query
 	query, params, err := bqb.New(`
 	SELECT 
+		"setting.user".id, 
 		"setting.user".email, 
 		COALESCE("setting.user".pwd, ''), 
+		COALESCE("setting.user".oidc_sub, ''), 
 		"setting.user".typ, 
-		COALESCE("setting.role".id, 0), 
-		COALESCE("setting.role".name, ''), 
-		COALESCE("setting.role".description, ''), 
-		COALESCE("setting.permission".id, 0), 
-		COALESCE("setting.permission".name, '')
+		"setting.user".state
 	FROM 
 		"setting.user"
-	LEFT JOIN 
-		"setting.role" ON "setting.user".setting_role_id = "setting.role".id
-	LEFT JOIN 
-		"setting.role_permission" ON "setting.role".id = "setting.role_permission".setting_role_id 
-	LEFT JOIN 
-		"setting.permission" ON "setting.role_permission".setting_permission_id = "setting.permission".id
 	WHERE 
-		"setting.user".email = ?
-	ORDER BY "setting.user".email, "setting.role".id, "setting.permission".id`, loginRequest.Email).ToPgsql()
+		"setting.user".email = ?`, loginRequest.Email).ToPgsql()
 	if err != nil {
 		log.Printf("Error preparing query: %v\n", err)
 		return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
@@ -85,61 +112,56 @@ func (service *AuthService) Login(loginRequest *LoginRequest) (models.TokenAndRe
 
 	// -- Validate user
 	var user setting.SettingUser
-	var role setting.SettingRole
-	permissions := make([]setting.SettingPermission, 0)
 	if row := service.DB.QueryRow(query, params...); row.Err() != nil {
 		log.Printf("Error querying user: %v\n", row.Err())
 		return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
 	} else {
-		var tmpPermission setting.SettingPermission
-		if err := row.Scan(&user.Email, &user.Pwd, &user.Typ, &role.Id, &role.Name, &role.Description, &tmpPermission.Id, &tmpPermission.Name); err != nil {
+		if err := row.Scan(&user.Id, &user.Email, &user.Pwd, &user.OIDCSub, &user.Typ, &user.State); err != nil {
 			if err == sql.ErrNoRows {
+				recordLoginEvent(service.DB, 0, loginRequest.Email, models.SettingLoginEventMethodPassword, ErrAccountNotFound, client)
 				return models.TokenAndRefreshToken{}, 401, ErrAccountNotFound
 			}
 
 			log.Printf("Error scanning user: %v\n", err)
 			return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
 		}
+	}
 
-		// -- Append permission
-		permissions = append(permissions, tmpPermission)
+	// -- Accounts provisioned through single sign-on have no password to fall back on
+	if user.Pwd == "" && user.OIDCSub != "" {
+		recordLoginEvent(service.DB, user.Id, loginRequest.Email, models.SettingLoginEventMethodPassword, ErrUseSingleSignOn, client)
+		return models.TokenAndRefreshToken{}, 401, ErrUseSingleSignOn
 	}
 
 	if user.Email != loginRequest.Email || (!utils.ComparePwd(loginRequest.Password, user.Pwd) && user.Pwd != "") {
+		recordLoginEvent(service.DB, user.Id, loginRequest.Email, models.SettingLoginEventMethodPassword, ErrInvalidEmailOrPassword, client)
 		return models.TokenAndRefreshToken{}, 401, ErrInvalidEmailOrPassword
 	}
 
-	// -- Generate token
-	permissionNames := make([]string, 0)
-	for _, value := range permissions {
-		permissionNames = append(permissionNames, value.Name)
+	if !user.IsActive() {
+		recordLoginEvent(service.DB, user.Id, loginRequest.Email, models.SettingLoginEventMethodPassword, ErrAccountInactive, client)
+		return models.TokenAndRefreshToken{}, 401, ErrAccountInactive
 	}
-	token, err := utils.GenerateWebToken(utils.WebTokenClaims{
-		Email:       user.Email,
-		Role:        role.Name,
-		Permissions: permissionNames,
-	})
+
+	// -- Roles and permissions are resolved as for the authentication
+	principal, err := utils.LoadPrincipal(service.DB, user.Email)
 	if err != nil {
-		log.Printf("Error generating web token: %v\n", err)
+		log.Printf("Error loading user: %v\n", err)
 		return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
 	}
 
-	// -- Generate refresh token
-	refreshToken, err := utils.GenerateRefreshToken(utils.RefreshTokenClaims{
-		Email: user.Email,
-	})
+	// -- Start a session
+	sid, err := createSession(service.DB, user.Id, client)
 	if err != nil {
-		log.Printf("Error generating refresh token: %v\n", err)
+		log.Printf("Error creating session: %v\n", err)
 		return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
 	}
+	recordLoginEvent(service.DB, user.Id, loginRequest.Email, models.SettingLoginEventMethodPassword, nil, client)
 
-	return models.TokenAndRefreshToken{
-		Token:        token,
-		RefreshToken: refreshToken,
-	}, 200, nil
+	return generateTokenAndRefreshToken(sid, user, principal)
 }
 
-func (service *AuthService) RefreshToken(refreshTokenRequest *RefreshTokenRequest) (models.TokenAndRefreshToken, int, error) {
+func (service *AuthService) RefreshToken(refreshTokenRequest *RefreshTokenRequest, client utils.ClientInfo) (models.TokenAndRefreshToken, int, error) {
 	refreshClaims, refreshErr := utils.ValidateRefreshToken(refreshTokenRequest.RefreshToken)
 	if refreshErr != nil {
 		return models.TokenAndRefreshToken{}, 401, ErrInvalidRefreshToken
@@ -148,25 +170,16 @@ func (service *AuthService) RefreshToken(refreshTokenRequest *RefreshTokenReques
 	// -- Prepare sql query
 	query, params, err := bqb.New(`
 	SELECT 
+		"setting.user".id, 
 		"setting.user".email, 
 		COALESCE("setting.user".pwd, ''), 
 		"setting.user".typ, 
-		COALESCE("setting.role".id, 0), 
-		COALESCE("setting.role".name, ''), 
-		COALESCE("setting.role".description, ''), 
-		COALESCE("setting.permission".id, 0), 
-		COALESCE("setting.permission".name, '')
+		"setting.user".state, 
+		"setting.user".sessions_revoked_at
 	FROM 
 		"setting.user"
-	LEFT JOIN 
-		"setting.role" ON "setting.user".setting_role_id = "setting.role".id
-	LEFT JOIN 
-		"setting.role_permission" ON "setting.role".id = "setting.role_permission".setting_role_id 
-	LEFT JOIN 
-		"setting.permission" ON "setting.role_permission".setting_permission_id = "setting.permission".id
 	WHERE 
-		"setting.user".email = ?
-	ORDER BY "setting.user".email, "setting.role".id, "setting.permission".id`, refreshClaims.Email).ToPgsql()
+		"setting.user".email = ?`, refreshClaims.Email).ToPgsql()
 	if err != nil {
 		log.Printf("Error preparing query: %v\n", err)
 		return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
@@ -174,14 +187,11 @@ func (service *AuthService) RefreshToken(refreshTokenRequest *RefreshTokenReques
 
 	// -- Validate user
 	var user setting.SettingUser
-	var role setting.SettingRole
-	permissions := make([]setting.SettingPermission, 0)
 	if row := service.DB.QueryRow(query, params...); row.Err() != nil {
 		log.Printf("Error querying user: %v\n", row.Err())
 		return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
 	} else {
-		var tmpPermission setting.SettingPermission
-		if err := row.Scan(&user.Email, &user.Pwd, &user.Typ, &role.Id, &role.Name, &role.Description, &tmpPermission.Id, &tmpPermission.Name); err != nil {
+		if err := row.Scan(&user.Id, &user.Email, &user.Pwd, &user.Typ, &user.State, &user.SessionsRevokedAt); err != nil {
 			if err == sql.ErrNoRows {
 				return models.TokenAndRefreshToken{}, 401, ErrAccountNotFound
 			}
@@ -189,20 +199,56 @@ func (service *AuthService) RefreshToken(refreshTokenRequest *RefreshTokenReques
 			log.Printf("Error scanning user: %v\n", err)
 			return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
 		}
+	}
 
-		// -- Append permission
-		permissions = append(permissions, tmpPermission)
+	if !user.IsActive() {
+		return models.TokenAndRefreshToken{}, 401, ErrAccountInactive
+	}
+	if user.IsSessionRevoked(refreshClaims.IssuedAt) {
+		return models.TokenAndRefreshToken{}, 401, ErrInvalidRefreshToken
 	}
 
-	// -- Generate token
+	// -- Roles and permissions are resolved as for the authentication
+	principal, err := utils.LoadPrincipal(service.DB, user.Email)
+	if err != nil {
+		log.Printf("Error loading user: %v\n", err)
+		return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
+	}
+
+	// -- Extend the session, refresh tokens issued before sessions existed start a new one
+	sid := refreshClaims.Sid
+	if sid == "" {
+		sid, err = createSession(service.DB, user.Id, client)
+	} else {
+		err = refreshSession(service.DB, sid, client)
+	}
+	if err != nil {
+		if errors.Is(err, ErrSessionExpired) {
+			return models.TokenAndRefreshToken{}, 401, ErrInvalidRefreshToken
+		}
+
+		log.Printf("Error refreshing session: %v\n", err)
+		return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
+	}
+
+	return generateTokenAndRefreshToken(sid, user, principal)
+}
+
+func principalPermissionNames(principal utils.Principal) []string {
 	permissionNames := make([]string, 0)
-	for _, value := range permissions {
+	for _, value := range principal.Permissions {
 		permissionNames = append(permissionNames, value.Name)
 	}
+	return permissionNames
+}
+
+func generateTokenAndRefreshToken(sid string, user setting.SettingUser, principal utils.Principal) (models.TokenAndRefreshToken, int, error) {
+	// -- Generate token
 	token, err := utils.GenerateWebToken(utils.WebTokenClaims{
 		Email:       user.Email,
-		Role:        role.Name,
-		Permissions: permissionNames,
+		Role:        principal.Role.Name,
+		Permissions: principalPermissionNames(principal),
+		Sid:         sid,
 	})
 	if err != nil {
 		log.Printf("Error generating web token: %v\n", err)
@@ -212,6 +258,7 @@ func (service *AuthService) RefreshToken(refreshTokenRequest *RefreshTokenReques
 	// -- Generate refresh token
 	refreshToken, err := utils.GenerateRefreshToken(utils.RefreshTokenClaims{
 		Email: user.Email,
+		Sid:   sid,
 	})
 	if err != nil {
 		log.Printf("Error generating refresh token: %v\n", err)
@@ -224,7 +271,79 @@ func (service *AuthService) RefreshToken(refreshTokenRequest *RefreshTokenReques
 	}, 200, nil
 }
 
+func (service *AuthService) Impersonate(ctx *utils.CtxW, impersonateRequest *ImpersonateRequest) (models.ImpersonationToken, int, error) {
+	if ctx.Actor != nil {
+		return models.ImpersonationToken{}, 403, ErrAlreadyImpersonating
+	}
+	if impersonateRequest.UserId == ctx.User.Id {
+		return models.ImpersonationToken{}, 400, ErrImpersonateSelf
+	}
+
+	// -- Prepare sql query
+	query, params, err := bqb.New(`
+	SELECT 
+		"setting.user".email, 
+		"setting.user".state
+	FROM 
+		"setting.user"
+	WHERE 
+		"setting.user".id = ?`, impersonateRequest.UserId).ToPgsql()
+	if err != nil {
+		log.Printf("Error preparing query: %v\n", err)
+		return models.ImpersonationToken{}, 500, utils.ErrInternalServer
+	}
+
+	var user setting.SettingUser
+	if err := service.DB.QueryRow(query, params...).Scan(&user.Email, &user.State); err != nil {
+		if err == sql.ErrNoRows {
+			return models.ImpersonationToken{}, 404, ErrUserNotFound
+		}
+
+		log.Printf("Error scanning user: %v\n", err)
+		return models.ImpersonationToken{}, 500, utils.ErrInternalServer
+	}
+
+	if !user.IsActive() {
+		return models.ImpersonationToken{}, 400, ErrAccountInactive
+	}
+
+	// -- Roles and permissions are resolved as for the authentication
+	principal, err := utils.LoadPrincipal(service.DB, user.Email)
+	if err != nil {
+		log.Printf("Error loading user: %v\n", err)
+		return models.ImpersonationToken{}, 500, utils.ErrInternalServer
+	}
+
+	// -- The token acts as the user on behalf of the admin, without refresh token
+	token, err := utils.GenerateWebToken(utils.WebTokenClaims{
+		Email:       user.Email,
+		Role:        principal.Role.Name,
+		Permissions: principalPermissionNames(principal),
+		Act:         ctx.User.Email,
+	})
+	if err != nil {
+		log.Printf("Error generating web token: %v\n", err)
+		return models.ImpersonationToken{}, 500, utils.ErrInternalServer
+	}
+
+	// -- Audit
+	if err := ctx.AuditEvent(service.DB, "setting.user", impersonateRequest.UserId, utils.AUDIT_ACTION_IMPERSONATE); err != nil {
+		log.Printf("Error writing audit log: %v\n", err)
+		return models.ImpersonationToken{}, 500, utils.ErrInternalServer
+	}
+
+	return models.ImpersonationToken{
+		Token:     token,
+		ExpiresIn: config.GetConfigInstance().IMPERSONATION_TOKEN_SEC,
+	}, 200, nil
+}
+
 func (service *AuthService) UpdatePassword(ctx *utils.CtxW, updatePasswordRequest *UpdatePasswordRequest) (string, int, error) {
+	// -- The password stays with the user, not the admin impersonating them
+	if ctx.Actor != nil {
+		return "", 403, ErrImpersonating
+	}
+
 	// -- Prepare sql query
 	query, params, err := bqb.New(`SELECT COALESCE(pwd, '') FROM "setting.user" WHERE id = ?`, ctx.User.Id).ToPgsql()
 	if err != nil {
@@ -267,6 +386,13 @@ func (service *AuthService) UpdatePassword(ctx *utils.CtxW, updatePasswordReques
 			return "", 500, utils.ErrInternalServer
 		}
 
+		before, err := utils.AuditSnapshot(tx, "setting.user", ctx.User.Id)
+		if err != nil {
+			tx.Rollback()
+			log.Printf("Error taking audit snapshot: %v\n", err)
+			return "", 500, utils.ErrInternalServer
+		}
+
 		// -- Update pwd
 		if _, err := tx.Exec(query, params...); err != nil {
 			tx.Rollback()
@@ -275,6 +401,17 @@ func (service *AuthService) UpdatePassword(ctx *utils.CtxW, updatePasswordReques
 			return "", 500, utils.ErrInternalServer
 		}
 
+		// -- Audit
+		after, err := utils.AuditSnapshot(tx, "setting.user", ctx.User.Id)
+		if err == nil {
+			err = ctx.Audit(tx, "setting.user", ctx.User.Id, before, after)
+		}
+		if err != nil {
+			tx.Rollback()
+			log.Printf("Error writing audit log: %v\n", err)
+			return "", 500, utils.ErrInternalServer
+		}
+
 		// -- Commit transaction
 		if err := tx.Commit(); err != nil {
 			log.Printf("Error committing transaction: %v\n", err)
@@ -376,6 +513,13 @@ func (service *AuthService) UpdateProfile(ctx *utils.CtxW, updateData *UpdatePro
 		return "", 500, utils.ErrInternalServer
 	}
 
+	before, err := utils.AuditSnapshot(tx, "setting.user", ctx.User.Id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Error taking audit snapshot: %v\n", err)
+		return "", 500, utils.ErrInternalServer
+	}
+
 	// -- Update user
 	if _, err := tx.Exec(query, params...); err != nil {
 		tx.Rollback()
@@ -383,11 +527,23 @@ func (service *AuthService) UpdateProfile(ctx *utils.CtxW, updateData *UpdatePro
 		return "", 500, utils.ErrInternalServer
 	}
 
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "setting.user", ctx.User.Id)
+	if err == nil {
+		err = ctx.Audit(tx, "setting.user", ctx.User.Id, before, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Error writing audit log: %v\n", err)
+		return "", 500, utils.ErrInternalServer
+	}
+
 	// -- Commit transaction
 	if err := tx.Commit(); err != nil {
 		log.Printf("Error committing transaction: %v\n", err)
 		return "", 500, utils.ErrInternalServer
 	}
+	utils.GetPrincipalCache().InvalidateUser(ctx.User.Id)
 
 	return "success", 200, nil
 }
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"system.buon18.com/m/config"
+	"system.buon18.com/m/database"
+	"system.buon18.com/m/models"
+	"system.buon18.com/m/models/setting"
+	"system.buon18.com/m/utils"
+
+	"github.com/lib/pq"
+	"github.com/nullism/bqb"
+)
+
+func (service *AuthService) OIDCAuthCodeURL(state, nonce string) (string, int, error) {
+	if service.OIDCProvider == nil {
+		return "", 404, utils.ErrOIDCNotConfigured
+	}
+
+	url, err := service.OIDCProvider.AuthCodeURL(state, nonce)
+	if err != nil {
+		log.Printf("Error building authorization url: %v\n", err)
+		return "", 502, utils.ErrOIDCDiscovery
+	}
+
+	return url, 302, nil
+}
+
+func (service *AuthService) OIDCLogin(code, nonce string, client utils.ClientInfo) (models.TokenAndRefreshToken, int, error) {
+	if service.OIDCProvider == nil {
+		return models.TokenAndRefreshToken{}, 404, utils.ErrOIDCNotConfigured
+	}
+
+	// -- Exchange code and verify id token
+	tokenResponse, err := service.OIDCProvider.Exchange(code)
+	if err != nil {
+		log.Printf("Error exchanging authorization code: %v\n", err)
+		return models.TokenAndRefreshToken{}, 401, utils.ErrOIDCExchange
+	}
+
+	claims, err := service.OIDCProvider.VerifyIdToken(tokenResponse.IdToken, nonce)
+	if err != nil {
+		log.Printf("Error verifying id token: %v\n", err)
+		if errors.Is(err, utils.ErrOIDCInvalidNonce) {
+			return models.TokenAndRefreshToken{}, 401, utils.ErrOIDCInvalidNonce
+		}
+		return models.TokenAndRefreshToken{}, 401, utils.ErrOIDCInvalidIdToken
+	}
+
+	config := config.GetConfigInstance()
+	mappedRoleId := claims.MapClaimToRoleId(config.OIDC_ROLE_CLAIM, config.OIDC_ROLE_MAPPING, 0)
+
+	tx, err := service.DB.Begin()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v\n", err)
+		return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
+	}
+
+	// -- Find user by subject, an account is only linked by email when the
+	// -- identity provider verified it, otherwise anyone registering the email
+	// -- at the provider would take the account over
+	findUser := func(condition string, value string) (setting.SettingUser, error) {
+		query, params, err := bqb.New(fmt.Sprintf(`
+		SELECT
+			id,
+			email,
+			COALESCE(oidc_sub, ''),
+			COALESCE(setting_role_id, 0),
+			state
+		FROM
+			"setting.user"
+		WHERE
+			%s = ?`, condition), value).ToPgsql()
+		if err != nil {
+			return setting.SettingUser{}, err
+		}
+
+		var user setting.SettingUser
+		err = tx.QueryRow(query, params...).Scan(&user.Id, &user.Email, &user.OIDCSub, &user.SettingRoleId, &user.State)
+		return user, err
+	}
+
+	var query string
+	var params []interface{}
+
+	// -- A linked account signs in without email, linking and provisioning need one
+	user, err := findUser("oidc_sub", claims.Subject)
+	if err == sql.ErrNoRows {
+		if claims.Email == "" {
+			tx.Rollback()
+			recordLoginEvent(service.DB, 0, claims.Email, models.SettingLoginEventMethodOIDC, ErrOIDCMissingEmail, client)
+			return models.TokenAndRefreshToken{}, 401, ErrOIDCMissingEmail
+		}
+		if !claims.EmailVerified {
+			tx.Rollback()
+			recordLoginEvent(service.DB, 0, claims.Email, models.SettingLoginEventMethodOIDC, ErrOIDCEmailNotVerified, client)
+			return models.TokenAndRefreshToken{}, 401, ErrOIDCEmailNotVerified
+		}
+		user, err = findUser("email", claims.Email)
+	}
+	switch {
+	case err == sql.ErrNoRows:
+		if !config.OIDC_JIT_PROVISIONING {
+			tx.Rollback()
+			recordLoginEvent(service.DB, user.Id, claims.Email, models.SettingLoginEventMethodOIDC, ErrAccountNotFound, client)
+			return models.TokenAndRefreshToken{}, 401, ErrAccountNotFound
+		}
+
+		roleId := mappedRoleId
+		if roleId == 0 {
+			roleId = config.OIDC_DEFAULT_ROLE_ID
+		}
+		if roleId == 0 {
+			tx.Rollback()
+			recordLoginEvent(service.DB, user.Id, claims.Email, models.SettingLoginEventMethodOIDC, ErrOIDCNoRoleMapped, client)
+			return models.TokenAndRefreshToken{}, 403, ErrOIDCNoRoleMapped
+		}
+
+		name := claims.Name
+		if name == "" {
+			name = claims.Email
+		}
+
+		// -- Provisioned users are created on behalf of the system bot
+		commonModel := models.CommonModel{}
+		commonModel.PrepareForCreate(1, 1)
+
+		query, params, err = bqb.New(`
+		INSERT INTO
+			"setting.user"
+			(name, email, typ, oidc_sub, setting_role_id, cid, ctime, mid, mtime)
+		VALUES
+			(?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`, name, claims.Email, models.SettingUserTypUser, claims.Subject, roleId, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime).ToPgsql()
+		if err != nil {
+			tx.Rollback()
+			log.Printf("Error preparing query: %v\n", err)
+			return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
+		}
+
+		var userId uint
+		if err := tx.QueryRow(query, params...).Scan(&userId); err != nil {
+			tx.Rollback()
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Constraint == database.FK_SETTING_ROLE_ID {
+				recordLoginEvent(service.DB, user.Id, claims.Email, models.SettingLoginEventMethodOIDC, ErrOIDCNoRoleMapped, client)
+				return models.TokenAndRefreshToken{}, 403, ErrOIDCNoRoleMapped
+			}
+
+			log.Printf("Error provisioning user: %v\n", err)
+			return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
+		}
+
+		query, params, err = bqb.New(`
+		INSERT INTO
+			"setting.user_company"
+			(setting_user_id, setting_company_id, cid, ctime, mid, mtime)
+		VALUES
+			(?, ?, ?, ?, ?, ?)`, userId, config.OIDC_DEFAULT_COMPANY_ID, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime).ToPgsql()
+		if err != nil {
+			tx.Rollback()
+			log.Printf("Error preparing query: %v\n", err)
+			return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
+		}
+
+		if _, err := tx.Exec(query, params...); err != nil {
+			tx.Rollback()
+			log.Printf("Error provisioning user: %v\n", err)
+			return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
+		}
+	case err != nil:
+		tx.Rollback()
+		log.Printf("Error querying user: %v\n", err)
+		return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
+	default:
+		if user.OIDCSub != "" && user.OIDCSub != claims.Subject {
+			tx.Rollback()
+			recordLoginEvent(service.DB, user.Id, user.Email, models.SettingLoginEventMethodOIDC, ErrOIDCSubjectMismatch, client)
+			return models.TokenAndRefreshToken{}, 401, ErrOIDCSubjectMismatch
+		}
+
+		if !user.IsActive() {
+			tx.Rollback()
+			recordLoginEvent(service.DB, user.Id, user.Email, models.SettingLoginEventMethodOIDC, ErrAccountInactive, client)
+			return models.TokenAndRefreshToken{}, 401, ErrAccountInactive
+		}
+
+		// -- Link the identity, the role is only kept in sync with the identity
+		// -- provider once linked, linking doesn't change the role of an account
+		bqbQuery := bqb.New(`UPDATE "setting.user" SET oidc_sub = ?`, claims.Subject)
+		if user.OIDCSub == claims.Subject && mappedRoleId != 0 && mappedRoleId != user.SettingRoleId {
+			commonModel := models.CommonModel{}
+			commonModel.PrepareForUpdate(1)
+			bqbQuery.Comma("setting_role_id = ?", mappedRoleId)
+			bqbQuery.Comma("mid = ?", commonModel.MId)
+			bqbQuery.Comma("mtime = ?", commonModel.MTime)
+		}
+		bqbQuery.Space("WHERE id = ?", user.Id)
+
+		query, params, err = bqbQuery.ToPgsql()
+		if err != nil {
+			tx.Rollback()
+			log.Printf("Error preparing query: %v\n", err)
+			return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
+		}
+
+		if _, err := tx.Exec(query, params...); err != nil {
+			tx.Rollback()
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Constraint == database.FK_SETTING_ROLE_ID {
+				recordLoginEvent(service.DB, user.Id, user.Email, models.SettingLoginEventMethodOIDC, ErrOIDCNoRoleMapped, client)
+				return models.TokenAndRefreshToken{}, 403, ErrOIDCNoRoleMapped
+			}
+
+			log.Printf("Error linking user: %v\n", err)
+			return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
+		}
+		defer utils.GetPrincipalCache().InvalidateUser(user.Id)
+	}
+
+	// -- Roles and permissions are resolved as for the authentication, within
+	// -- the transaction that may have provisioned the user
+	query, params, err = bqb.New(`SELECT email FROM "setting.user" WHERE oidc_sub = ?`, claims.Subject).ToPgsql()
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Error preparing query: %v\n", err)
+		return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
+	}
+	if err := tx.QueryRow(query, params...).Scan(&user.Email); err != nil {
+		tx.Rollback()
+		log.Printf("Error querying user: %v\n", err)
+		return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
+	}
+
+	principal, err := utils.LoadPrincipal(tx, user.Email)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Error loading user: %v\n", err)
+		return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
+	}
+	user = principal.User
+
+	// -- Start a session
+	sid, err := createSession(tx, user.Id, client)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Error creating session: %v\n", err)
+		return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v\n", err)
+		return models.TokenAndRefreshToken{}, 500, utils.ErrInternalServer
+	}
+	recordLoginEvent(service.DB, user.Id, user.Email, models.SettingLoginEventMethodOIDC, nil, client)
+
+	return generateTokenAndRefreshToken(sid, user, principal)
+}
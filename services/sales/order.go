@@ -3,14 +3,15 @@ package sales
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
+	"slices"
 	"strings"
 
 	"system.buon18.com/m/database"
 	"system.buon18.com/m/models"
 	"system.buon18.com/m/models/accounting"
 	"system.buon18.com/m/models/sales"
-	"system.buon18.com/m/models/setting"
 	"system.buon18.com/m/utils"
 
 	"github.com/lib/pq"
@@ -27,7 +28,106 @@ type SalesOrderService struct {
 	DB *sql.DB
 }
 
-func (service *SalesOrderService) Orders(qp *utils.QueryParams) ([]sales.SalesOrderResponse, int, int, error) {
+// orderRow is an order joined with its included relations.
+type orderRow struct {
+	Order           sales.SalesOrder
+	Quotation       quotationRow
+	PaymentTerm     accounting.AccountingPaymentTerm
+	PaymentTermLine accounting.AccountingPaymentTermLine
+}
+
+// orderColumns returns the columns, scan targets and joins of the orders of
+// "limited_orders" along with the relations included by qp.
+func orderColumns(qp *utils.QueryParams, row *orderRow) ([]string, []interface{}, []string) {
+	columns := []string{
+		`"limited_orders".id`,
+		`"limited_orders".name`,
+		`"limited_orders".commitment_date`,
+		`"limited_orders".note`,
+	}
+	dest := []interface{}{&row.Order.Id, &row.Order.Name, &row.Order.CommitmentDate, &row.Order.Note}
+	joins := []string{}
+
+	if qp.IsIncluded("quotation") {
+		quotationColumns, quotationDest, quotationJoins := quotationColumns(qp, "quotation.", `"sales.quotation"`, &row.Quotation)
+		columns = append(columns, quotationColumns...)
+		dest = append(dest, quotationDest...)
+		joins = append(joins, `INNER JOIN "sales.quotation" ON "sales.quotation".id = "limited_orders".sales_quotation_id`)
+		joins = append(joins, quotationJoins...)
+	}
+
+	if qp.IsIncluded("payment_term") {
+		columns = append(columns,
+			`"accounting.payment_term".id`,
+			`"accounting.payment_term".name`,
+			`"accounting.payment_term".description`,
+		)
+		dest = append(dest, &row.PaymentTerm.Id, &row.PaymentTerm.Name, &row.PaymentTerm.Description)
+		joins = append(joins, `INNER JOIN "accounting.payment_term" ON "accounting.payment_term".id = "limited_orders".accounting_payment_term_id`)
+	}
+
+	if qp.IsIncluded("payment_term.lines") {
+		columns = append(columns,
+			`"accounting.payment_term_line".id`,
+			`"accounting.payment_term_line".sequence`,
+			`"accounting.payment_term_line".value_amount_percent`,
+			`"accounting.payment_term_line".number_of_days`,
+		)
+		dest = append(dest, &row.PaymentTermLine.Id, &row.PaymentTermLine.Sequence, &row.PaymentTermLine.ValueAmountPercent, &row.PaymentTermLine.NumberOfDays)
+		joins = append(joins, `INNER JOIN "accounting.payment_term_line" ON "accounting.payment_term_line".accounting_payment_term_id = "accounting.payment_term".id`)
+	}
+	return columns, dest, joins
+}
+
+// orderOrderBy orders the rows of an order by its included children.
+func orderOrderBy(qp *utils.QueryParams) string {
+	orderBy := []string{`"limited_orders".id ASC`}
+	if qp.IsIncluded("quotation.items") {
+		orderBy = append(orderBy, `"sales.order_item".id ASC`)
+	}
+	if qp.IsIncluded("payment_term.lines") {
+		orderBy = append(orderBy, `"accounting.payment_term_line".sequence ASC`)
+	}
+	return strings.Join(orderBy, ", ")
+}
+
+// orderBuilder collects the rows of an order, the rows are the product of the
+// items and the payment term lines.
+type orderBuilder struct {
+	row              orderRow
+	orderItems       []sales.SalesOrderItem
+	paymentTermLines []accounting.AccountingPaymentTermLine
+}
+
+func (builder *orderBuilder) add(row orderRow) {
+	if builder.row.Order.Id != row.Order.Id {
+		builder.row = row
+		builder.orderItems = make([]sales.SalesOrderItem, 0)
+		builder.paymentTermLines = make([]accounting.AccountingPaymentTermLine, 0)
+	}
+
+	if row.Quotation.OrderItem.Id != 0 && !slices.ContainsFunc(builder.orderItems, func(item sales.SalesOrderItem) bool { return item.Id == row.Quotation.OrderItem.Id }) {
+		builder.orderItems = append(builder.orderItems, row.Quotation.OrderItem)
+	}
+	if row.PaymentTermLine.Id != 0 && !slices.ContainsFunc(builder.paymentTermLines, func(line accounting.AccountingPaymentTermLine) bool { return line.Id == row.PaymentTermLine.Id }) {
+		builder.paymentTermLines = append(builder.paymentTermLines, row.PaymentTermLine)
+	}
+}
+
+func (builder *orderBuilder) response(qp *utils.QueryParams) sales.SalesOrderResponse {
+	quotationResponse := builder.row.Quotation.response(qp, "quotation.", builder.orderItems)
+	paymentTermLinesResponse := make([]accounting.AccountingPaymentTermLineResponse, 0)
+	for _, line := range builder.paymentTermLines {
+		paymentTermLinesResponse = append(paymentTermLinesResponse, accounting.AccountingPaymentTermLineToResponse(line))
+	}
+	paymentTermResponse := accounting.AccountingPaymentTermToResponse(builder.row.PaymentTerm, paymentTermLinesResponse)
+
+	return sales.SalesOrderToResponse(builder.row.Order, quotationResponse, paymentTermResponse)
+}
+
+func (service *SalesOrderService) Orders(ctx *utils.CtxW, qp *utils.QueryParams) ([]sales.SalesOrderResponse, int, int, error) {
+	qp.AddCondition(ctx.AccessCondition("sales.order", `"sales.order"`))
+
 	bqbQuery := bqb.New(`WITH "limited_orders" AS (
 		SELECT
 			id,
@@ -39,49 +139,18 @@ func (service *SalesOrderService) Orders(qp *utils.QueryParams) ([]sales.SalesOr
 		FROM
 			"sales.order"`)
 
-	qp.FilterIntoBqb(bqbQuery)
-	qp.PaginationIntoBqb(bqbQuery)
+	qp.PageIntoBqb(bqbQuery, `"sales.order"`, "ASC")
 
-	bqbQuery.Space(`)
+	row := orderRow{}
+	columns, dest, joins := orderColumns(qp, &row)
+	bqbQuery.Space(fmt.Sprintf(`)
 	SELECT
-		"limited_orders".id,
-		"limited_orders".name,
-		"limited_orders".commitment_date,
-		"limited_orders".note,
-		"sales.quotation".id,
-		"sales.quotation".name,
-		"sales.quotation".creation_date,
-		"sales.quotation".validity_date,
-		"sales.quotation".discount,
-		"sales.quotation".amount_delivery,
-		"sales.quotation".status,
-		"setting.customer".id,
-		"setting.customer".fullname,
-		"setting.customer".gender,
-		"setting.customer".email,
-		"setting.customer".phone,
-		"setting.customer".additional_information,
-		"sales.order_item".id,
-		"sales.order_item".name,
-		"sales.order_item".description,
-		"sales.order_item".price,
-		"sales.order_item".discount,
-		"accounting.payment_term".id,
-		"accounting.payment_term".name,
-		"accounting.payment_term".description,
-		"accounting.payment_term_line".id,
-		"accounting.payment_term_line".sequence,
-		"accounting.payment_term_line".value_amount_percent,
-		"accounting.payment_term_line".number_of_days
+		%s
 	FROM 
 		"limited_orders"
-	INNER JOIN "sales.quotation" ON "sales.quotation".id = "limited_orders".sales_quotation_id
-	INNER JOIN "setting.customer" ON "setting.customer".id = "sales.quotation".setting_customer_id
-	INNER JOIN "sales.order_item" ON "sales.order_item".sales_quotation_id = "sales.quotation".id
-	INNER JOIN "accounting.payment_term" ON "accounting.payment_term".id = "limited_orders".accounting_payment_term_id
-	INNER JOIN "accounting.payment_term_line" ON "accounting.payment_term_line".accounting_payment_term_id = "accounting.payment_term".id`)
+	%s`, strings.Join(columns, ",\n\t\t"), strings.Join(joins, "\n\t")))
 
-	qp.OrderByIntoBqb(bqbQuery, `"limited_orders".id ASC, "sales.quotation".id ASC, "sales.order_item".id ASC, "accounting.payment_term_line".sequence ASC`)
+	qp.OrderByIntoBqb(bqbQuery, orderOrderBy(qp))
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -96,143 +165,53 @@ func (service *SalesOrderService) Orders(qp *utils.QueryParams) ([]sales.SalesOr
 	}
 
 	ordersResponse := make([]sales.SalesOrderResponse, 0)
-	lastOrder := sales.SalesOrder{}
-	lastCustomer := setting.SettingCustomer{}
-	lastQuotation := sales.SalesQuotation{}
-	orderItems := make([]sales.SalesOrderItem, 0)
-	lastPaymentTerm := accounting.AccountingPaymentTerm{}
-	paymentTermLines := make([]accounting.AccountingPaymentTermLine, 0)
+	builder := orderBuilder{}
 	for rows.Next() {
-		tmpOrder := sales.SalesOrder{}
-		tmpCustomer := setting.SettingCustomer{}
-		tmpQuotation := sales.SalesQuotation{}
-		tmpOrderItem := sales.SalesOrderItem{}
-		tmpPaymentTerm := accounting.AccountingPaymentTerm{}
-		tmpPaymentTermLine := accounting.AccountingPaymentTermLine{}
-		err := rows.Scan(
-			&tmpOrder.Id,
-			&tmpOrder.Name,
-			&tmpOrder.CommitmentDate,
-			&tmpOrder.Note,
-			&tmpQuotation.Id,
-			&tmpQuotation.Name,
-			&tmpQuotation.CreationDate,
-			&tmpQuotation.ValidityDate,
-			&tmpQuotation.Discount,
-			&tmpQuotation.AmountDelivery,
-			&tmpQuotation.Status,
-			&tmpCustomer.Id,
-			&tmpCustomer.FullName,
-			&tmpCustomer.Gender,
-			&tmpCustomer.Email,
-			&tmpCustomer.Phone,
-			&tmpCustomer.AdditionalInformation,
-			&tmpOrderItem.Id,
-			&tmpOrderItem.Name,
-			&tmpOrderItem.Description,
-			&tmpOrderItem.Price,
-			&tmpOrderItem.Discount,
-			&tmpPaymentTerm.Id,
-			&tmpPaymentTerm.Name,
-			&tmpPaymentTerm.Description,
-			&tmpPaymentTermLine.Id,
-			&tmpPaymentTermLine.Sequence,
-			&tmpPaymentTermLine.ValueAmountPercent,
-			&tmpPaymentTermLine.NumberOfDays,
-		)
+		row = orderRow{}
+		err := rows.Scan(dest...)
 		if err != nil {
 			log.Printf("%v", err)
 			return nil, 0, 500, utils.ErrInternalServer
 		}
 
-		if (lastQuotation.Id != tmpQuotation.Id && lastQuotation.Id != 0) && (lastPaymentTerm.Id != tmpPaymentTerm.Id && lastPaymentTerm.Id != 0) {
-			orderItemsResponse := make([]sales.SalesOrderItemResponse, 0)
-			for _, item := range orderItems {
-				orderItemsResponse = append(orderItemsResponse, sales.SalesOrderItemToResponse(item))
-			}
-			customerResponse := setting.SettingCustomerToResponse(lastCustomer)
-			quotationResponse := sales.SalesQuotationToResponse(lastQuotation, customerResponse, orderItemsResponse)
-			paymentTermLinesResponse := make([]accounting.AccountingPaymentTermLineResponse, 0)
-			for _, line := range paymentTermLines {
-				paymentTermLinesResponse = append(paymentTermLinesResponse, accounting.AccountingPaymentTermLineToResponse(line))
-			}
-			paymentTermResponse := accounting.AccountingPaymentTermToResponse(lastPaymentTerm, paymentTermLinesResponse)
-			ordersResponse = append(ordersResponse, sales.SalesOrderToResponse(lastOrder, quotationResponse, paymentTermResponse))
-
-			// Reset and append new data
-			lastOrder = tmpOrder
-			lastQuotation = tmpQuotation
-			lastCustomer = tmpCustomer
-			orderItems = make([]sales.SalesOrderItem, 0)
-			orderItems = append(orderItems, tmpOrderItem)
-			lastPaymentTerm = tmpPaymentTerm
-			paymentTermLines = make([]accounting.AccountingPaymentTermLine, 0)
-			paymentTermLines = append(paymentTermLines, tmpPaymentTermLine)
-			continue
-		}
-
-		if lastQuotation.Id == 0 || lastPaymentTerm.Id == 0 || lastOrder.Id == 0 {
-			lastOrder = tmpOrder
-			lastQuotation = tmpQuotation
-			lastCustomer = tmpCustomer
-			lastPaymentTerm = tmpPaymentTerm
-		}
-
-		if tmpOrderItem.Id != 0 {
-			if len(orderItems) != 0 {
-				if orderItems[len(orderItems)-1].Id != tmpOrderItem.Id {
-					orderItems = append(orderItems, tmpOrderItem)
-				}
-			} else {
-				orderItems = append(orderItems, tmpOrderItem)
-			}
-		}
-
-		if tmpPaymentTermLine.Id != 0 {
-			if len(paymentTermLines) != 0 {
-				if paymentTermLines[len(paymentTermLines)-1].Id != tmpPaymentTermLine.Id {
-					paymentTermLines = append(paymentTermLines, tmpPaymentTermLine)
-				}
-			} else {
-				paymentTermLines = append(paymentTermLines, tmpPaymentTermLine)
-			}
+		if builder.row.Order.Id != row.Order.Id && builder.row.Order.Id != 0 {
+			ordersResponse = append(ordersResponse, builder.response(qp))
 		}
+		builder.add(row)
 	}
-	if lastOrder.Id != 0 {
-		orderItemsResponse := make([]sales.SalesOrderItemResponse, 0)
-		for _, item := range orderItems {
-			orderItemsResponse = append(orderItemsResponse, sales.SalesOrderItemToResponse(item))
-		}
-		customerResponse := setting.SettingCustomerToResponse(lastCustomer)
-		quotationResponse := sales.SalesQuotationToResponse(lastQuotation, customerResponse, orderItemsResponse)
-		paymentTermLinesResponse := make([]accounting.AccountingPaymentTermLineResponse, 0)
-		for _, line := range paymentTermLines {
-			paymentTermLinesResponse = append(paymentTermLinesResponse, accounting.AccountingPaymentTermLineToResponse(line))
-		}
-		paymentTermResponse := accounting.AccountingPaymentTermToResponse(lastPaymentTerm, paymentTermLinesResponse)
-		ordersResponse = append(ordersResponse, sales.SalesOrderToResponse(lastOrder, quotationResponse, paymentTermResponse))
+	if builder.row.Order.Id != 0 {
+		ordersResponse = append(ordersResponse, builder.response(qp))
 	}
 
-	bqbQuery = bqb.New(`SELECT COUNT(*) FROM "sales.order"`)
-	qp.FilterIntoBqb(bqbQuery)
-
-	query, params, err = bqbQuery.ToPgsql()
-	if err != nil {
-		log.Printf("%v", err)
-		return nil, 0, 500, utils.ErrInternalServer
+	if len(ordersResponse) > 0 {
+		if err := qp.PrepareCursors(service.DB, `"sales.order"`, ordersResponse[0].Id, ordersResponse[len(ordersResponse)-1].Id, len(ordersResponse)); err != nil {
+			log.Printf("%v", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
 	}
 
 	var total int
-	err = service.DB.QueryRow(query, params...).Scan(&total)
-	if err != nil {
-		log.Printf("%v", err)
-		return nil, 0, 500, utils.ErrInternalServer
+	if qp.WithTotal {
+		bqbQuery = bqb.New(`SELECT COUNT(*) FROM "sales.order"`)
+		qp.FilterIntoBqb(bqbQuery)
+
+		query, params, err = bqbQuery.ToPgsql()
+		if err != nil {
+			log.Printf("%v", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+
+		err = service.DB.QueryRow(query, params...).Scan(&total)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
 	}
 
 	return ordersResponse, total, 200, nil
 }
 
-func (service *SalesOrderService) Order(id string) (sales.SalesOrderResponse, int, error) {
+func (service *SalesOrderService) Order(ctx *utils.CtxW, id string, qp *utils.QueryParams) (sales.SalesOrderResponse, int, error) {
 	bqbQuery := bqb.New(`WITH "limited_orders" AS (
 		SELECT
 			id,
@@ -244,46 +223,18 @@ func (service *SalesOrderService) Order(id string) (sales.SalesOrderResponse, in
 		FROM
 			"sales.order"
 		WHERE
-			id = ?
-	)
+			id = ?`, id)
+	ctx.AccessIntoBqb(bqbQuery, "sales.order", `"sales.order"`)
+
+	row := orderRow{}
+	columns, dest, joins := orderColumns(qp, &row)
+	bqbQuery.Space(fmt.Sprintf(`)
 	SELECT
-		"limited_orders".id,
-		"limited_orders".name,
-		"limited_orders".commitment_date,
-		"limited_orders".note,
-		"sales.quotation".id,
-		"sales.quotation".name,
-		"sales.quotation".creation_date,
-		"sales.quotation".validity_date,
-		"sales.quotation".discount,
-		"sales.quotation".amount_delivery,
-		"sales.quotation".status,
-		"setting.customer".id,
-		"setting.customer".fullname,
-		"setting.customer".gender,
-		"setting.customer".email,
-		"setting.customer".phone,
-		"setting.customer".additional_information,
-		"sales.order_item".id,
-		"sales.order_item".name,
-		"sales.order_item".description,
-		"sales.order_item".price,
-		"sales.order_item".discount,
-		"accounting.payment_term".id,
-		"accounting.payment_term".name,
-		"accounting.payment_term".description,
-		"accounting.payment_term_line".id,
-		"accounting.payment_term_line".sequence,
-		"accounting.payment_term_line".value_amount_percent,
-		"accounting.payment_term_line".number_of_days
+		%s
 	FROM 
 		"limited_orders"
-	INNER JOIN "sales.quotation" ON "sales.quotation".id = "limited_orders".sales_quotation_id
-	INNER JOIN "setting.customer" ON "setting.customer".id = "sales.quotation".setting_customer_id
-	INNER JOIN "sales.order_item" ON "sales.order_item".sales_quotation_id = "sales.quotation".id
-	INNER JOIN "accounting.payment_term" ON "accounting.payment_term".id = "limited_orders".accounting_payment_term_id
-	INNER JOIN "accounting.payment_term_line" ON "accounting.payment_term_line".accounting_payment_term_id = "accounting.payment_term".id
-	ORDER BY "limited_orders".id ASC, "sales.quotation".id ASC, "sales.order_item".id ASC, "accounting.payment_term_line".sequence ASC`, id)
+	%s
+	ORDER BY %s`, strings.Join(columns, ",\n\t\t"), strings.Join(joins, "\n\t"), orderOrderBy(qp)))
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -297,95 +248,29 @@ func (service *SalesOrderService) Order(id string) (sales.SalesOrderResponse, in
 		return sales.SalesOrderResponse{}, 500, utils.ErrInternalServer
 	}
 
-	order := sales.SalesOrder{}
-	customer := setting.SettingCustomer{}
-	quotation := sales.SalesQuotation{}
-	orderItems := make([]sales.SalesOrderItem, 0)
-	paymentTerm := accounting.AccountingPaymentTerm{}
-	paymentTermLines := make([]accounting.AccountingPaymentTermLine, 0)
+	builder := orderBuilder{}
 	for rows.Next() {
-		tmpOrderItem := sales.SalesOrderItem{}
-		tmpPaymentTermLine := accounting.AccountingPaymentTermLine{}
-		err := rows.Scan(
-			&order.Id,
-			&order.Name,
-			&order.CommitmentDate,
-			&order.Note,
-			&quotation.Id,
-			&quotation.Name,
-			&quotation.CreationDate,
-			&quotation.ValidityDate,
-			&quotation.Discount,
-			&quotation.AmountDelivery,
-			&quotation.Status,
-			&customer.Id,
-			&customer.FullName,
-			&customer.Gender,
-			&customer.Email,
-			&customer.Phone,
-			&customer.AdditionalInformation,
-			&tmpOrderItem.Id,
-			&tmpOrderItem.Name,
-			&tmpOrderItem.Description,
-			&tmpOrderItem.Price,
-			&tmpOrderItem.Discount,
-			&paymentTerm.Id,
-			&paymentTerm.Name,
-			&paymentTerm.Description,
-			&tmpPaymentTermLine.Id,
-			&tmpPaymentTermLine.Sequence,
-			&tmpPaymentTermLine.ValueAmountPercent,
-			&tmpPaymentTermLine.NumberOfDays,
-		)
+		row = orderRow{}
+		err := rows.Scan(dest...)
 		if err != nil {
 			log.Printf("%v", err)
 			return sales.SalesOrderResponse{}, 500, utils.ErrInternalServer
 		}
 
-		if tmpOrderItem.Id != 0 {
-			if len(orderItems) != 0 {
-				if orderItems[len(orderItems)-1].Id != tmpOrderItem.Id {
-					orderItems = append(orderItems, tmpOrderItem)
-				}
-			} else {
-				orderItems = append(orderItems, tmpOrderItem)
-			}
-		}
-
-		if tmpPaymentTermLine.Id != 0 {
-			if len(paymentTermLines) != 0 {
-				if paymentTermLines[len(paymentTermLines)-1].Id != tmpPaymentTermLine.Id {
-					paymentTermLines = append(paymentTermLines, tmpPaymentTermLine)
-				}
-			} else {
-				paymentTermLines = append(paymentTermLines, tmpPaymentTermLine)
-			}
-		}
+		builder.add(row)
 	}
-	if order.Id == 0 {
+	if builder.row.Order.Id == 0 {
 		return sales.SalesOrderResponse{}, 404, ErrOrderNotFound
 	}
 
-	orderItemsResponse := make([]sales.SalesOrderItemResponse, 0)
-	for _, item := range orderItems {
-		orderItemsResponse = append(orderItemsResponse, sales.SalesOrderItemToResponse(item))
-	}
-	customerResponse := setting.SettingCustomerToResponse(customer)
-	quotationResponse := sales.SalesQuotationToResponse(quotation, customerResponse, orderItemsResponse)
-	paymentTermLinesResponse := make([]accounting.AccountingPaymentTermLineResponse, 0)
-	for _, line := range paymentTermLines {
-		paymentTermLinesResponse = append(paymentTermLinesResponse, accounting.AccountingPaymentTermLineToResponse(line))
-	}
-	paymentTermResponse := accounting.AccountingPaymentTermToResponse(paymentTerm, paymentTermLinesResponse)
-
-	return sales.SalesOrderToResponse(order, quotationResponse, paymentTermResponse), 200, nil
+	return builder.response(qp), 200, nil
 }
 
 func (service *SalesOrderService) CreateOrder(ctx *utils.CtxW, order *sales.SalesOrderCreateRequest) (int, error) {
 	commonModel := models.CommonModel{}
 	commonModel.PrepareForCreate(ctx.User.Id, ctx.User.Id)
 
-	bqbQuery := bqb.New(`CALL create_sales_order(?, ?, ?, ?, ?, ?, ?, ?, ?)`, order.Name, order.CommitmentDate, order.Note, order.QuotationId, order.PaymentTermId, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime)
+	bqbQuery := bqb.New(`CALL create_sales_order(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, order.Name, order.CommitmentDate, order.Note, order.QuotationId, order.PaymentTermId, ctx.Company.Id, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -393,8 +278,15 @@ func (service *SalesOrderService) CreateOrder(ctx *utils.CtxW, order *sales.Sale
 		return 500, utils.ErrInternalServer
 	}
 
-	_, err = service.DB.Exec(query, params...)
+	tx, err := service.DB.Begin()
 	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	_, err = tx.Exec(query, params...)
+	if err != nil {
+		tx.Rollback()
 		if message := err.(*pq.Error).Message; strings.HasPrefix(message, "custom_error:") {
 			return 400, errors.New(strings.TrimPrefix(message, "custom_error:"))
 		}
@@ -410,6 +302,39 @@ func (service *SalesOrderService) CreateOrder(ctx *utils.CtxW, order *sales.Sale
 		return 500, utils.ErrInternalServer
 	}
 
+	// -- The procedure doesn't return the id, the name is unique within the company
+	query, params, err = bqb.New(`SELECT id FROM "sales.order" WHERE name = ? AND setting_company_id = ?`, order.Name, ctx.Company.Id).ToPgsql()
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	var id int
+	err = tx.QueryRow(query, params...).Scan(&id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "sales.order", id)
+	if err == nil {
+		err = ctx.Audit(tx, "sales.order", id, nil, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	return 201, nil
 }
 
@@ -423,9 +348,18 @@ func (service *SalesOrderService) UpdateOrder(ctx *utils.CtxW, id string, order
 		return 500, utils.ErrInternalServer
 	}
 
+	before, err := utils.AuditSnapshot(tx, "sales.order", id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	bqbQuery := bqb.New(`UPDATE "sales.order" SET mid = ?, mtime = ?`, commonModel.MId, commonModel.MTime)
 	utils.PrepareUpdateBqbQuery(bqbQuery, order)
 	bqbQuery.Space(`WHERE id = ?`, id)
+	order.PreconditionIntoBqb(bqbQuery)
+	ctx.AccessIntoBqb(bqbQuery, "sales.order", `"sales.order"`)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -450,9 +384,23 @@ func (service *SalesOrderService) UpdateOrder(ctx *utils.CtxW, id string, order
 
 	if n, _ := result.RowsAffected(); n == 0 {
 		tx.Rollback()
+		if order.HasPrecondition() {
+			return 409, utils.ErrUpdateConflict
+		}
 		return 404, ErrOrderNotFound
 	}
 
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "sales.order", id)
+	if err == nil {
+		err = ctx.Audit(tx, "sales.order", id, before, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		log.Printf("%v", err)
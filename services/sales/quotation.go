@@ -3,7 +3,9 @@ package sales
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"sync"
 
 	"system.buon18.com/m/database"
@@ -27,7 +29,78 @@ type SalesQuotationService struct {
 	DB *sql.DB
 }
 
-func (service *SalesQuotationService) Quotations(qp *utils.QueryParams) ([]sales.SalesQuotationResponse, int, int, error) {
+// quotationRow is a quotation joined with its included relations.
+type quotationRow struct {
+	Quotation   sales.SalesQuotation
+	TotalAmount float64
+	Customer    setting.SettingCustomer
+	OrderItem   sales.SalesOrderItem
+}
+
+// quotationColumns returns the columns, scan targets and joins of the
+// quotations of table along with the relations of path included by qp, e.g.
+// path is "quotation." for the quotation of an order.
+func quotationColumns(qp *utils.QueryParams, path string, table string, row *quotationRow) ([]string, []interface{}, []string) {
+	columns := []string{
+		table + ".id",
+		table + ".name",
+		table + ".creation_date",
+		table + ".validity_date",
+		table + ".discount",
+		table + ".amount_delivery",
+		table + ".status",
+	}
+	dest := []interface{}{&row.Quotation.Id, &row.Quotation.Name, &row.Quotation.CreationDate, &row.Quotation.ValidityDate, &row.Quotation.Discount, &row.Quotation.AmountDelivery, &row.Quotation.Status}
+	joins := []string{}
+
+	if qp.IsIncluded(path + "customer") {
+		columns = append(columns,
+			`"setting.customer".id`,
+			`"setting.customer".fullname`,
+			`"setting.customer".gender`,
+			`"setting.customer".email`,
+			`"setting.customer".phone`,
+			`"setting.customer".additional_information`,
+		)
+		dest = append(dest, &row.Customer.Id, &row.Customer.FullName, &row.Customer.Gender, &row.Customer.Email, &row.Customer.Phone, &row.Customer.AdditionalInformation)
+		joins = append(joins, fmt.Sprintf(`INNER JOIN "setting.customer" ON %s.setting_customer_id = "setting.customer".id`, table))
+	}
+
+	if qp.IsIncluded(path + "items") {
+		columns = append(columns,
+			`"sales.order_item".id`,
+			`"sales.order_item".name`,
+			`"sales.order_item".description`,
+			`"sales.order_item".price`,
+			`"sales.order_item".discount`,
+		)
+		dest = append(dest, &row.OrderItem.Id, &row.OrderItem.Name, &row.OrderItem.Description, &row.OrderItem.Price, &row.OrderItem.Discount)
+		joins = append(joins, fmt.Sprintf(`LEFT JOIN "sales.order_item" ON %s.id = "sales.order_item".sales_quotation_id`, table))
+	} else {
+		// -- Without the items the total amount is computed by the database
+		totalAmount, _ := utils.FieldExpression("sales.quotation", table, "total_amount")
+		columns = append(columns, totalAmount)
+		dest = append(dest, &row.TotalAmount)
+	}
+	return columns, dest, joins
+}
+
+func (row quotationRow) response(qp *utils.QueryParams, path string, orderItems []sales.SalesOrderItem) sales.SalesQuotationResponse {
+	orderItemsResponse := make([]sales.SalesOrderItemResponse, 0)
+	for _, item := range orderItems {
+		orderItemsResponse = append(orderItemsResponse, sales.SalesOrderItemToResponse(item))
+	}
+
+	response := sales.SalesQuotationToResponse(row.Quotation, setting.SettingCustomerToResponse(row.Customer), orderItemsResponse)
+	if !qp.IsIncluded(path + "items") {
+		response.TotalAmount = row.TotalAmount
+	}
+	return response
+}
+
+func (service *SalesQuotationService) Quotations(ctx *utils.CtxW, qp *utils.QueryParams) ([]sales.SalesQuotationResponse, int, int, error) {
+	qp.AddCondition(ctx.AccessCondition("sales.quotation", `"sales.quotation"`))
+
 	bqbQuery := bqb.New(`
 	WITH "limited_quotations" AS (
 		SELECT
@@ -42,35 +115,22 @@ func (service *SalesQuotationService) Quotations(qp *utils.QueryParams) ([]sales
 		FROM
 			"sales.quotation"`)
 
-	qp.FilterIntoBqb(bqbQuery)
-	qp.PaginationIntoBqb(bqbQuery)
+	qp.PageIntoBqb(bqbQuery, `"sales.quotation"`, "ASC")
 
-	bqbQuery.Space(`)
+	row := quotationRow{}
+	columns, dest, joins := quotationColumns(qp, "", `"limited_quotations"`, &row)
+	bqbQuery.Space(fmt.Sprintf(`)
 	SELECT
-		"limited_quotations".id,
-		"limited_quotations".name,
-		"limited_quotations".creation_date,
-		"limited_quotations".validity_date,
-		"limited_quotations".discount,
-		"limited_quotations".amount_delivery,
-		"limited_quotations".status,
-		"setting.customer".id,
-		"setting.customer".fullname,
-		"setting.customer".gender,
-		"setting.customer".email,
-		"setting.customer".phone,
-		"setting.customer".additional_information,
-		"sales.order_item".id,
-		"sales.order_item".name,
-		"sales.order_item".description,
-		"sales.order_item".price,
-		"sales.order_item".discount
+		%s
 	FROM
 		"limited_quotations"
-	INNER JOIN "setting.customer" ON "limited_quotations".setting_customer_id = "setting.customer".id
-	LEFT JOIN "sales.order_item" ON "limited_quotations"."id" = "sales.order_item".sales_quotation_id`)
+	%s`, strings.Join(columns, ",\n\t\t"), strings.Join(joins, "\n\t")))
 
-	qp.OrderByIntoBqb(bqbQuery, `"limited_quotations".id ASC, "sales.order_item".id ASC`)
+	if qp.IsIncluded("items") {
+		qp.OrderByIntoBqb(bqbQuery, `"limited_quotations".id ASC, "sales.order_item".id ASC`)
+	} else {
+		qp.OrderByIntoBqb(bqbQuery, `"limited_quotations".id ASC`)
+	}
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -85,74 +145,63 @@ func (service *SalesQuotationService) Quotations(qp *utils.QueryParams) ([]sales
 	}
 
 	quotationsResponse := make([]sales.SalesQuotationResponse, 0)
-	lastQuotation := sales.SalesQuotation{}
-	lastCustomer := setting.SettingCustomer{}
+	lastRow := quotationRow{}
 	orderItems := make([]sales.SalesOrderItem, 0)
 	for rows.Next() {
-		var tmpQuotation sales.SalesQuotation
-		var tmpCustomer setting.SettingCustomer
-		var tmpOrderItem sales.SalesOrderItem
-
-		err = rows.Scan(&tmpQuotation.Id, &tmpQuotation.Name, &tmpQuotation.CreationDate, &tmpQuotation.ValidityDate, &tmpQuotation.Discount, &tmpQuotation.AmountDelivery, &tmpQuotation.Status, &tmpCustomer.Id, &tmpCustomer.FullName, &tmpCustomer.Gender, &tmpCustomer.Email, &tmpCustomer.Phone, &tmpCustomer.AdditionalInformation, &tmpOrderItem.Id, &tmpOrderItem.Name, &tmpOrderItem.Description, &tmpOrderItem.Price, &tmpOrderItem.Discount)
+		row = quotationRow{}
+		err = rows.Scan(dest...)
 		if err != nil {
 			log.Printf("%v", err)
 			return []sales.SalesQuotationResponse{}, 0, 500, utils.ErrInternalServer
 		}
 
-		if lastQuotation.Id != tmpQuotation.Id && lastQuotation.Id != 0 {
-			customerResponse := setting.SettingCustomerToResponse(lastCustomer)
-			orderItemsResponse := make([]sales.SalesOrderItemResponse, 0)
-			for _, item := range orderItems {
-				orderItemsResponse = append(orderItemsResponse, sales.SalesOrderItemToResponse(item))
+		if lastRow.Quotation.Id != row.Quotation.Id {
+			if lastRow.Quotation.Id != 0 {
+				quotationsResponse = append(quotationsResponse, lastRow.response(qp, "", orderItems))
 			}
-			quotationsResponse = append(quotationsResponse, sales.SalesQuotationToResponse(lastQuotation, customerResponse, orderItemsResponse))
 
 			// Reset and append new data
-			lastQuotation = tmpQuotation
-			lastCustomer = tmpCustomer
+			lastRow = row
 			orderItems = make([]sales.SalesOrderItem, 0)
-			orderItems = append(orderItems, tmpOrderItem)
-			continue
 		}
 
-		if lastQuotation.Id == 0 {
-			lastQuotation = tmpQuotation
-			lastCustomer = tmpCustomer
-		}
-
-		if tmpOrderItem.Id != 0 {
-			orderItems = append(orderItems, tmpOrderItem)
+		if row.OrderItem.Id != 0 {
+			orderItems = append(orderItems, row.OrderItem)
 		}
 	}
-	if lastQuotation.Id != 0 {
-		customerResponse := setting.SettingCustomerToResponse(lastCustomer)
-		orderItemsResponse := make([]sales.SalesOrderItemResponse, 0)
-		for _, item := range orderItems {
-			orderItemsResponse = append(orderItemsResponse, sales.SalesOrderItemToResponse(item))
-		}
-		quotationsResponse = append(quotationsResponse, sales.SalesQuotationToResponse(lastQuotation, customerResponse, orderItemsResponse))
+	if lastRow.Quotation.Id != 0 {
+		quotationsResponse = append(quotationsResponse, lastRow.response(qp, "", orderItems))
 	}
 
-	bqbQuery = bqb.New(`SELECT COUNT(*) FROM "sales.quotation"`)
-	qp.FilterIntoBqb(bqbQuery)
-
-	query, params, err = bqbQuery.ToPgsql()
-	if err != nil {
-		log.Printf("%v", err)
-		return []sales.SalesQuotationResponse{}, 0, 500, utils.ErrInternalServer
+	if len(quotationsResponse) > 0 {
+		if err := qp.PrepareCursors(service.DB, `"sales.quotation"`, quotationsResponse[0].Id, quotationsResponse[len(quotationsResponse)-1].Id, len(quotationsResponse)); err != nil {
+			log.Printf("%v", err)
+			return []sales.SalesQuotationResponse{}, 0, 500, utils.ErrInternalServer
+		}
 	}
 
 	var total int
-	err = service.DB.QueryRow(query, params...).Scan(&total)
-	if err != nil {
-		log.Printf("%v", err)
-		return []sales.SalesQuotationResponse{}, 0, 500, utils.ErrInternalServer
+	if qp.WithTotal {
+		bqbQuery = bqb.New(`SELECT COUNT(*) FROM "sales.quotation"`)
+		qp.FilterIntoBqb(bqbQuery)
+
+		query, params, err = bqbQuery.ToPgsql()
+		if err != nil {
+			log.Printf("%v", err)
+			return []sales.SalesQuotationResponse{}, 0, 500, utils.ErrInternalServer
+		}
+
+		err = service.DB.QueryRow(query, params...).Scan(&total)
+		if err != nil {
+			log.Printf("%v", err)
+			return []sales.SalesQuotationResponse{}, 0, 500, utils.ErrInternalServer
+		}
 	}
 
 	return quotationsResponse, total, 200, nil
 }
 
-func (service *SalesQuotationService) Quotation(id string) (sales.SalesQuotationResponse, int, error) {
+func (service *SalesQuotationService) Quotation(ctx *utils.CtxW, id string, qp *utils.QueryParams) (sales.SalesQuotationResponse, int, error) {
 	bqbQuery := bqb.New(`
 	WITH "limited_quotations" AS (
 		SELECT
@@ -166,31 +215,21 @@ func (service *SalesQuotationService) Quotation(id string) (sales.SalesQuotation
 			setting_customer_id
 		FROM
 			"sales.quotation"
-		WHERE id = ?)
+		WHERE id = ?`, id)
+	ctx.AccessIntoBqb(bqbQuery, "sales.quotation", `"sales.quotation"`)
+
+	row := quotationRow{}
+	columns, dest, joins := quotationColumns(qp, "", `"limited_quotations"`, &row)
+	bqbQuery.Space(fmt.Sprintf(`)
 	SELECT
-		"limited_quotations".id,
-		"limited_quotations".name,
-		"limited_quotations".creation_date,
-		"limited_quotations".validity_date,
-		"limited_quotations".discount,
-		"limited_quotations".amount_delivery,
-		"limited_quotations".status,
-		"setting.customer".id,
-		"setting.customer".fullname,
-		"setting.customer".gender,
-		"setting.customer".email,
-		"setting.customer".phone,
-		"setting.customer".additional_information,
-		"sales.order_item".id,
-		"sales.order_item".name,
-		"sales.order_item".description,
-		"sales.order_item".price,
-		"sales.order_item".discount
+		%s
 	FROM
 		"limited_quotations"
-	INNER JOIN "setting.customer" ON "limited_quotations".setting_customer_id = "setting.customer".id
-	LEFT JOIN "sales.order_item" ON "limited_quotations"."id" = "sales.order_item".sales_quotation_id
-	ORDER BY "limited_quotations".id ASC, "sales.order_item".id ASC`, id)
+	%s`, strings.Join(columns, ",\n\t\t"), strings.Join(joins, "\n\t")))
+
+	if qp.IsIncluded("items") {
+		bqbQuery.Space(`ORDER BY "limited_quotations".id ASC, "sales.order_item".id ASC`)
+	}
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -204,31 +243,27 @@ func (service *SalesQuotationService) Quotation(id string) (sales.SalesQuotation
 		return sales.SalesQuotationResponse{}, 500, utils.ErrInternalServer
 	}
 
-	quotation := sales.SalesQuotation{}
-	customer := setting.SettingCustomer{}
+	quotation := quotationRow{}
 	orderItems := make([]sales.SalesOrderItem, 0)
 	for rows.Next() {
-		var tmpOrderItem sales.SalesOrderItem
-		err = rows.Scan(&quotation.Id, &quotation.Name, &quotation.CreationDate, &quotation.ValidityDate, &quotation.Discount, &quotation.AmountDelivery, &quotation.Status, &customer.Id, &customer.FullName, &customer.Gender, &customer.Email, &customer.Phone, &customer.AdditionalInformation, &tmpOrderItem.Id, &tmpOrderItem.Name, &tmpOrderItem.Description, &tmpOrderItem.Price, &tmpOrderItem.Discount)
+		row = quotationRow{}
+		err = rows.Scan(dest...)
 		if err != nil {
 			log.Printf("%v", err)
 			return sales.SalesQuotationResponse{}, 500, utils.ErrInternalServer
 		}
 
-		orderItems = append(orderItems, tmpOrderItem)
+		quotation = row
+		if row.OrderItem.Id != 0 {
+			orderItems = append(orderItems, row.OrderItem)
+		}
 	}
 
-	if quotation.Id == 0 {
+	if quotation.Quotation.Id == 0 {
 		return sales.SalesQuotationResponse{}, 404, ErrQuotationNotFound
 	}
 
-	customerResponse := setting.SettingCustomerToResponse(customer)
-	orderItemsResponse := make([]sales.SalesOrderItemResponse, 0)
-	for _, item := range orderItems {
-		orderItemsResponse = append(orderItemsResponse, sales.SalesOrderItemToResponse(item))
-	}
-
-	return sales.SalesQuotationToResponse(quotation, customerResponse, orderItemsResponse), 200, nil
+	return quotation.response(qp, "", orderItems), 200, nil
 }
 
 func (service *SalesQuotationService) CreateQuotation(ctx *utils.CtxW, quotation *sales.SalesQuotationCreateRequest) (int, error) {
@@ -242,9 +277,9 @@ func (service *SalesQuotationService) CreateQuotation(ctx *utils.CtxW, quotation
 	}
 
 	bqbQuery := bqb.New(`INSERT INTO "sales.quotation" 
-	(name, creation_date, validity_date, discount, status, setting_customer_id, cid, ctime, mid, mtime)
+	(name, creation_date, validity_date, discount, status, setting_customer_id, setting_company_id, cid, ctime, mid, mtime)
 	VALUES
-	(?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`, quotation.Name, quotation.CreationDate, quotation.ValidityDate, quotation.Discount, quotation.Status, quotation.CustomerId, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime)
+	(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`, quotation.Name, quotation.CreationDate, quotation.ValidityDate, quotation.Discount, quotation.Status, quotation.CustomerId, ctx.Company.Id, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -291,6 +326,17 @@ func (service *SalesQuotationService) CreateQuotation(ctx *utils.CtxW, quotation
 		return 500, utils.ErrInternalServer
 	}
 
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "sales.quotation", id)
+	if err == nil {
+		err = ctx.Audit(tx, "sales.quotation", id, nil, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		log.Printf("%v", err)
@@ -310,7 +356,15 @@ func (service *SalesQuotationService) UpdateQuotation(ctx *utils.CtxW, id string
 		return 500, utils.ErrInternalServer
 	}
 
+	before, err := utils.AuditSnapshot(tx, "sales.quotation", id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	bqbQuery := bqb.New(`SELECT status FROM "sales.quotation" WHERE id = ?`, id)
+	ctx.AccessIntoBqb(bqbQuery, "sales.quotation", `"sales.quotation"`)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -340,6 +394,7 @@ func (service *SalesQuotationService) UpdateQuotation(ctx *utils.CtxW, id string
 	bqbQuery = bqb.New(`UPDATE "sales.quotation" SET mid = ?, mtime = ?`, commonModel.MId, commonModel.MTime)
 	utils.PrepareUpdateBqbQuery(bqbQuery, quotation)
 	bqbQuery.Space(`WHERE id = ?`, id)
+	quotation.PreconditionIntoBqb(bqbQuery)
 
 	query, params, err = bqbQuery.ToPgsql()
 	if err != nil {
@@ -364,6 +419,9 @@ func (service *SalesQuotationService) UpdateQuotation(ctx *utils.CtxW, id string
 
 	if n, _ := result.RowsAffected(); n == 0 {
 		tx.Rollback()
+		if quotation.HasPrecondition() {
+			return 409, utils.ErrUpdateConflict
+		}
 		return 404, ErrQuotationNotFound
 	}
 
@@ -479,6 +537,17 @@ func (service *SalesQuotationService) UpdateQuotation(ctx *utils.CtxW, id string
 		return 500, errorMessage
 	}
 
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "sales.quotation", id)
+	if err == nil {
+		err = ctx.Audit(tx, "sales.quotation", id, before, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		log.Printf("%v", err)
@@ -488,14 +557,22 @@ func (service *SalesQuotationService) UpdateQuotation(ctx *utils.CtxW, id string
 	return 200, nil
 }
 
-func (service *SalesQuotationService) DeleteQuotation(id string) (int, error) {
+func (service *SalesQuotationService) DeleteQuotation(ctx *utils.CtxW, id string) (int, error) {
 	tx, err := service.DB.Begin()
 	if err != nil {
 		log.Printf("%v", err)
 		return 500, utils.ErrInternalServer
 	}
 
+	before, err := utils.AuditSnapshot(tx, "sales.quotation", id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	bqbQuery := bqb.New(`SELECT status FROM "sales.quotation" WHERE id = ?`, id)
+	ctx.AccessIntoBqb(bqbQuery, "sales.quotation", `"sales.quotation"`)
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
 		log.Printf("%v", err)
@@ -556,6 +633,13 @@ func (service *SalesQuotationService) DeleteQuotation(id string) (int, error) {
 		return 404, ErrQuotationNotFound
 	}
 
+	// -- Audit
+	if err := ctx.Audit(tx, "sales.quotation", id, before, nil); err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		log.Printf("%v", err)
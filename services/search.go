@@ -0,0 +1,110 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"system.buon18.com/m/models"
+	"system.buon18.com/m/utils"
+
+	"github.com/nullism/bqb"
+)
+
+var (
+	ErrSearchQueryRequired = errors.New("search query is required")
+)
+
+// searchResource is a resource searched by its search_vector and search_text
+// columns, Title and Subtitle are fields of the resource, see FieldExpression.
+type searchResource struct {
+	Resource   string
+	Title      string
+	Subtitle   string
+	Permission string
+}
+
+// -- Resources that are searched, a resource is skipped without its permission
+var SEARCH_RESOURCES = []searchResource{
+	{Resource: "setting.customer", Title: "fullname", Subtitle: "email", Permission: utils.PREDEFINED_PERMISSIONS.SETTING_CUSTOMERS.VIEW},
+	{Resource: "sales.quotation", Title: "name", Subtitle: "customer.fullname", Permission: utils.PREDEFINED_PERMISSIONS.SALES_QUOTATIONS.VIEW},
+	{Resource: "sales.order", Title: "name", Subtitle: "quotation.customer.fullname", Permission: utils.PREDEFINED_PERMISSIONS.SALES_ORDERS.VIEW},
+	{Resource: "accounting.account", Title: "name", Subtitle: "code", Permission: utils.PREDEFINED_PERMISSIONS.ACCOUNTING_ACCOUNTS.VIEW},
+	{Resource: "accounting.journal_entry", Title: "name", Subtitle: "journal.name", Permission: utils.PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNAL_ENTRIES.VIEW},
+}
+
+type SearchService struct {
+	DB *sql.DB
+}
+
+// Search ranks the records matching text across the resources the user may
+// view, words match as prefixes and typos fall back to trigram similarity.
+func (service *SearchService) Search(ctx *utils.CtxW, text string, limit int) ([]models.SearchResult, int, error) {
+	tsQuery := utils.PrefixTsQuery(text)
+	if tsQuery == "" {
+		return nil, 400, ErrSearchQueryRequired
+	}
+
+	placeholders := make([]string, 0)
+	parts := make([]interface{}, 0)
+	for _, resource := range SEARCH_RESOURCES {
+		if !ctx.HasPermission(resource.Permission) {
+			continue
+		}
+
+		table := fmt.Sprintf(`"%s"`, resource.Resource)
+		title, _ := utils.FieldExpression(resource.Resource, table, resource.Title)
+		subtitle, _ := utils.FieldExpression(resource.Resource, table, resource.Subtitle)
+
+		part := bqb.New(fmt.Sprintf(`(
+		SELECT
+			'%[1]s' AS resource,
+			%[2]s.id,
+			%[3]s AS title,
+			%[4]s AS subtitle,
+			GREATEST(ts_rank(%[2]s.search_vector, to_tsquery('simple', ?)), word_similarity(?, %[2]s.search_text)) AS rank
+		FROM
+			%[2]s
+		WHERE
+			(%[2]s.search_vector @@ to_tsquery('simple', ?) OR ? <%% %[2]s.search_text)`, resource.Resource, table, title, subtitle), tsQuery, text, tsQuery, text)
+		ctx.AccessIntoBqb(part, resource.Resource, table)
+		part.Space(`ORDER BY rank DESC LIMIT ?)`, limit)
+
+		placeholders = append(placeholders, "?")
+		parts = append(parts, part)
+	}
+
+	results := make([]models.SearchResult, 0)
+	if len(parts) == 0 {
+		return results, 200, nil
+	}
+
+	bqbQuery := bqb.New(strings.Join(placeholders, " UNION ALL "), parts...)
+	bqbQuery.Space(`ORDER BY rank DESC, resource ASC, id ASC LIMIT ?`, limit)
+
+	query, params, err := bqbQuery.ToPgsql()
+	if err != nil {
+		log.Printf("%v", err)
+		return nil, 500, utils.ErrInternalServer
+	}
+
+	rows, err := service.DB.Query(query, params...)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil, 500, utils.ErrInternalServer
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var result models.SearchResult
+		if err := rows.Scan(&result.Resource, &result.Id, &result.Title, &result.Subtitle, &result.Rank); err != nil {
+			log.Printf("%v", err)
+			return nil, 500, utils.ErrInternalServer
+		}
+		results = append(results, result)
+	}
+
+	return results, 200, nil
+}
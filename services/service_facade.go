@@ -9,13 +9,19 @@ import (
 type ServiceFacade struct {
 	AuthService                   *AuthService
 	SettingCustomerService        *setting.SettingCustomerService
+	SettingCompanyService         *setting.SettingCompanyService
 	SettingRoleService            *setting.SettingRoleService
 	SettingUserService            *setting.SettingUserService
 	SettingPermissionService      *setting.SettingPermissionService
+	SettingAuditLogService        *setting.SettingAuditLogService
+	SettingSessionService         *setting.SettingSessionService
+	SettingSavedViewService       *setting.SettingSavedViewService
 	SalesOrderService             *sales.SalesOrderService
 	SalesQuotationService         *sales.SalesQuotationService
 	AccountingAccountService      *accounting.AccountingAccountService
 	AccountingJournalEntryService *accounting.AccountingJournalEntryService
 	AccountingJournalService      *accounting.AccountingJournalService
 	AccountingPaymentTermService  *accounting.AccountingPaymentTermService
+	SearchService                 *SearchService
+	AggregateService              *AggregateService
 }
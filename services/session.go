@@ -0,0 +1,103 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"system.buon18.com/m/config"
+	"system.buon18.com/m/utils"
+
+	"github.com/nullism/bqb"
+)
+
+var (
+	ErrSessionExpired = errors.New("session expired")
+)
+
+// createSession starts a session of the user, the returned sid is put in the
+// token and refresh token.
+func createSession(q utils.Querier, userId uint, client utils.ClientInfo) (string, error) {
+	sid, err := utils.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(config.GetConfigInstance().REFRESH_TOKEN_SEC) * time.Second)
+	query, params, err := bqb.New(`
+	INSERT INTO "setting.session"
+		(sid, setting_user_id, ip, user_agent, last_used_at, expires_at, ctime)
+	VALUES
+		(?, ?, ?, ?, ?, ?, ?)`, sid, userId, client.IP, truncate(client.UserAgent, 512), now, expiresAt, now).ToPgsql()
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := q.Exec(query, params...); err != nil {
+		return "", err
+	}
+	return sid, nil
+}
+
+// refreshSession extends the session on refresh, ErrSessionExpired is
+// returned when it's revoked or expired.
+func refreshSession(q utils.Querier, sid string, client utils.ClientInfo) error {
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(config.GetConfigInstance().REFRESH_TOKEN_SEC) * time.Second)
+	query, params, err := bqb.New(`
+	UPDATE "setting.session" SET
+		ip = ?,
+		user_agent = ?,
+		last_used_at = ?,
+		expires_at = ?
+	WHERE sid = ? AND revoked_at IS NULL AND expires_at > ?`, client.IP, truncate(client.UserAgent, 512), now, expiresAt, sid, now).ToPgsql()
+	if err != nil {
+		return err
+	}
+
+	result, err := q.Exec(query, params...)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrSessionExpired
+	}
+	return nil
+}
+
+// recordLoginEvent records a sign in attempt, failing to record it doesn't
+// fail the sign in.
+func recordLoginEvent(q utils.Querier, userId uint, email string, method string, reason error, client utils.ClientInfo) {
+	var settingUserId interface{}
+	if userId != 0 {
+		settingUserId = userId
+	}
+	reasonStr := ""
+	if reason != nil {
+		reasonStr = reason.Error()
+	}
+
+	query, params, err := bqb.New(`
+	INSERT INTO "setting.login_event"
+		(setting_user_id, email, method, success, reason, ip, user_agent, ctime)
+	VALUES
+		(?, ?, ?, ?, ?, ?, ?, ?)`, settingUserId, truncate(email, 256), method, reason == nil, truncate(reasonStr, 64), client.IP, truncate(client.UserAgent, 512), time.Now()).ToPgsql()
+	if err != nil {
+		log.Printf("Error preparing query: %v\n", err)
+		return
+	}
+
+	if _, err := q.Exec(query, params...); err != nil {
+		log.Printf("Error recording login event: %v\n", err)
+	}
+}
+
+func truncate(value string, length int) string {
+	if len(value) > length {
+		return value[:length]
+	}
+	return value
+}
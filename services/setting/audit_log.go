@@ -0,0 +1,125 @@
+package setting
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"system.buon18.com/m/models"
+	"system.buon18.com/m/models/setting"
+	"system.buon18.com/m/utils"
+
+	"github.com/nullism/bqb"
+)
+
+var (
+	ErrAuditRecordNotFound = errors.New("record not found")
+)
+
+type SettingAuditLogService struct {
+	DB *sql.DB
+}
+
+// AuditLogs lists the audit logs of the active company.
+func (service *SettingAuditLogService) AuditLogs(ctx *utils.CtxW, qp *utils.QueryParams) ([]setting.SettingAuditLogResponse, int, int, error) {
+	bqbQuery := bqb.New(`
+	SELECT
+		"setting.audit_log".id,
+		"setting.audit_log".resource,
+		"setting.audit_log".record_id,
+		"setting.audit_log".action,
+		"setting.audit_log".changes,
+		"setting.audit_log".ip,
+		"setting.audit_log".request_id,
+		COALESCE("setting.audit_log".act_id, 0),
+		"setting.audit_log".cid,
+		"setting.audit_log".ctime
+	FROM "setting.audit_log"`)
+	qp.AddCondition(ctx.AccessCondition("setting.audit_log", `"setting.audit_log"`))
+	qp.PageIntoBqb(bqbQuery, `"setting.audit_log"`, "DESC")
+
+	query, params, err := bqbQuery.ToPgsql()
+	if err != nil {
+		log.Printf("%s", err)
+		return nil, 0, 500, utils.ErrInternalServer
+	}
+
+	rows, err := service.DB.Query(query, params...)
+	if err != nil {
+		log.Printf("%s", err)
+		return nil, 0, 500, utils.ErrInternalServer
+	}
+
+	auditLogs := make([]setting.SettingAuditLogResponse, 0)
+	for rows.Next() {
+		auditLog := setting.SettingAuditLog{CommonModel: &models.CommonModel{}}
+		err := rows.Scan(&auditLog.Id, &auditLog.Resource, &auditLog.RecordId, &auditLog.Action, &auditLog.Changes, &auditLog.IP, &auditLog.RequestId, &auditLog.ActId, &auditLog.CId, &auditLog.CTime)
+		if err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+		auditLogs = append(auditLogs, setting.SettingAuditLogToResponse(auditLog))
+	}
+
+	if qp.IsBefore() {
+		slices.Reverse(auditLogs)
+	}
+	if len(auditLogs) > 0 {
+		if err := qp.PrepareCursors(service.DB, `"setting.audit_log"`, auditLogs[0].Id, auditLogs[len(auditLogs)-1].Id, len(auditLogs)); err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+	}
+
+	var total int
+	if qp.WithTotal {
+		bqbQuery = bqb.New(`SELECT COUNT(*) FROM "setting.audit_log"`)
+		qp.FilterIntoBqb(bqbQuery)
+
+		query, params, err = bqbQuery.ToPgsql()
+		if err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+
+		err = service.DB.QueryRow(query, params...).Scan(&total)
+		if err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+	}
+
+	return auditLogs, total, 200, nil
+}
+
+// History returns the audit logs of a single record, the record must be
+// accessible through the record rules of the user.
+func (service *SettingAuditLogService) History(ctx *utils.CtxW, resource string, id string, qp *utils.QueryParams) ([]setting.SettingAuditLogResponse, int, int, error) {
+	auditResource, ok := models.AUDIT_RESOURCES[resource]
+	if !ok {
+		log.Printf("%s: %s", utils.ErrUnknownAuditResource, resource)
+		return nil, 0, 500, utils.ErrInternalServer
+	}
+
+	if condition := ctx.AccessCondition(resource, fmt.Sprintf(`"%s"`, auditResource.Table)); condition != nil {
+		query, params, err := bqb.New(fmt.Sprintf(`SELECT COUNT(*) FROM "%s" WHERE id = ? AND ?`, auditResource.Table), id, condition).ToPgsql()
+		if err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+
+		var count int
+		if err := service.DB.QueryRow(query, params...).Scan(&count); err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+		if count == 0 {
+			return nil, 0, 404, ErrAuditRecordNotFound
+		}
+	}
+
+	qp.AddCondition(bqb.New(`"setting.audit_log".resource = ? AND "setting.audit_log".record_id = ?`, resource, id))
+	return service.AuditLogs(ctx, qp)
+}
@@ -0,0 +1,333 @@
+package setting
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+
+	"system.buon18.com/m/database"
+	"system.buon18.com/m/models"
+	"system.buon18.com/m/models/setting"
+	"system.buon18.com/m/utils"
+
+	"github.com/lib/pq"
+	"github.com/nullism/bqb"
+)
+
+var (
+	ErrCompanyNotFound                    = errors.New("company not found")
+	ErrCompanyNameExists                  = errors.New("company name already exists")
+	ErrUnableToDeleteCurrentlyUsedCompany = errors.New("unable to delete currently used company")
+)
+
+type SettingCompanyService struct {
+	DB *sql.DB
+}
+
+func (service *SettingCompanyService) Companies(ctx *utils.CtxW, qp *utils.QueryParams) ([]setting.SettingCompanyResponse, int, int, error) {
+	qp.AddCondition(ctx.AllowedCompanyCondition(`"setting.company"`))
+
+	bqbQuery := bqb.New(`
+	SELECT
+		"setting.company".id,
+		"setting.company".name
+	FROM "setting.company"`)
+
+	qp.PageIntoBqb(bqbQuery, `"setting.company"`, "ASC")
+
+	query, params, err := bqbQuery.ToPgsql()
+	if err != nil {
+		log.Printf("%s", err)
+		return nil, 0, 500, utils.ErrInternalServer
+	}
+
+	rows, err := service.DB.Query(query, params...)
+	if err != nil {
+		log.Printf("%s", err)
+		return nil, 0, 500, utils.ErrInternalServer
+	}
+
+	companiesResponse := make([]setting.SettingCompanyResponse, 0)
+	for rows.Next() {
+		tmpCompany := setting.SettingCompany{}
+		if err := rows.Scan(&tmpCompany.Id, &tmpCompany.Name); err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+
+		companiesResponse = append(companiesResponse, setting.SettingCompanyToResponse(tmpCompany))
+	}
+
+	if qp.IsBefore() {
+		slices.Reverse(companiesResponse)
+	}
+	if len(companiesResponse) > 0 {
+		if err := qp.PrepareCursors(service.DB, `"setting.company"`, companiesResponse[0].Id, companiesResponse[len(companiesResponse)-1].Id, len(companiesResponse)); err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+	}
+
+	var total int
+	if qp.WithTotal {
+		bqbQuery = bqb.New(`SELECT COUNT(*) FROM "setting.company"`)
+		qp.FilterIntoBqb(bqbQuery)
+
+		query, params, err = bqbQuery.ToPgsql()
+		if err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+
+		err = service.DB.QueryRow(query, params...).Scan(&total)
+		if err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+	}
+
+	return companiesResponse, total, 200, nil
+}
+
+func (service *SettingCompanyService) Company(ctx *utils.CtxW, id string) (setting.SettingCompanyResponse, int, error) {
+	bqbQuery := bqb.New(`
+	SELECT
+		"setting.company".id,
+		"setting.company".name
+	FROM "setting.company" WHERE "setting.company".id = ?`, id)
+	bqbQuery.Space("AND ?", ctx.AllowedCompanyCondition(`"setting.company"`))
+
+	query, params, err := bqbQuery.ToPgsql()
+	if err != nil {
+		log.Printf("%s", err)
+		return setting.SettingCompanyResponse{}, 500, utils.ErrInternalServer
+	}
+
+	var company setting.SettingCompany
+	err = service.DB.QueryRow(query, params...).Scan(&company.Id, &company.Name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return setting.SettingCompanyResponse{}, 404, ErrCompanyNotFound
+		}
+
+		log.Printf("%s", err)
+		return setting.SettingCompanyResponse{}, 500, utils.ErrInternalServer
+	}
+
+	return setting.SettingCompanyToResponse(company), 200, nil
+}
+
+// CreateCompany creates the company, the creator is allowed to access it.
+func (service *SettingCompanyService) CreateCompany(ctx *utils.CtxW, company *setting.SettingCompanyCreateRequest) (int, error) {
+	commonModel := models.CommonModel{}
+	commonModel.PrepareForCreate(ctx.User.Id, ctx.User.Id)
+
+	query, params, err := bqb.New(`INSERT INTO "setting.company"
+	(name, cid, ctime, mid, mtime)
+	VALUES
+	(?, ?, ?, ?, ?) RETURNING id`, company.Name, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime).ToPgsql()
+	if err != nil {
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	tx, err := service.DB.Begin()
+	if err != nil {
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	var companyId int
+	err = tx.QueryRow(query, params...).Scan(&companyId)
+	if err != nil {
+		tx.Rollback()
+		switch err.(*pq.Error).Constraint {
+		case database.KEY_SETTING_COMPANY_NAME:
+			return 409, ErrCompanyNameExists
+		}
+
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	query, params, err = bqb.New(`INSERT INTO "setting.user_company"
+	(setting_user_id, setting_company_id, cid, ctime, mid, mtime)
+	VALUES
+	(?, ?, ?, ?, ?, ?)`, ctx.User.Id, companyId, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime).ToPgsql()
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	if _, err := tx.Exec(query, params...); err != nil {
+		tx.Rollback()
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "setting.company", companyId)
+	if err == nil {
+		err = ctx.Audit(tx, "setting.company", companyId, nil, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+	utils.GetPrincipalCache().InvalidateUser(ctx.User.Id)
+
+	return 201, nil
+}
+
+func (service *SettingCompanyService) UpdateCompany(ctx *utils.CtxW, id string, company *setting.SettingCompanyUpdateRequest) (int, error) {
+	commonModel := models.CommonModel{}
+	commonModel.PrepareForUpdate(ctx.User.Id)
+
+	bqbQuery := bqb.New(`UPDATE "setting.company" SET mid = ?, mtime = ?`, commonModel.MId, commonModel.MTime)
+	utils.PrepareUpdateBqbQuery(bqbQuery, company)
+	bqbQuery.Space(`WHERE id = ?`, id)
+	company.PreconditionIntoBqb(bqbQuery)
+	bqbQuery.Space("AND ?", ctx.AllowedCompanyCondition(`"setting.company"`))
+
+	query, params, err := bqbQuery.ToPgsql()
+	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	tx, err := service.DB.Begin()
+	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	before, err := utils.AuditSnapshot(tx, "setting.company", id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	result, err := tx.Exec(query, params...)
+	if err != nil {
+		tx.Rollback()
+		switch err.(*pq.Error).Constraint {
+		case database.KEY_SETTING_COMPANY_NAME:
+			return 409, ErrCompanyNameExists
+		}
+
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		tx.Rollback()
+		if company.HasPrecondition() {
+			return 409, utils.ErrUpdateConflict
+		}
+		return 404, ErrCompanyNotFound
+	}
+
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "setting.company", id)
+	if err == nil {
+		err = ctx.Audit(tx, "setting.company", id, before, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+	utils.GetPrincipalCache().InvalidateAll()
+
+	return 200, nil
+}
+
+// DeleteCompany deletes a company without records, the access of the users to
+// the company is removed along with it. Only a company the user is allowed to
+// access can be deleted.
+func (service *SettingCompanyService) DeleteCompany(ctx *utils.CtxW, id string) (int, error) {
+	companyId, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || !ctx.AllowsCompanies([]uint{uint(companyId)}) {
+		return 404, ErrCompanyNotFound
+	}
+
+	tx, err := service.DB.Begin()
+	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	before, err := utils.AuditSnapshot(tx, "setting.company", id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	query, params, err := bqb.New(`DELETE FROM "setting.user_company" WHERE setting_company_id = ?`, id).ToPgsql()
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	if _, err := tx.Exec(query, params...); err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	query, params, err = bqb.New(`DELETE FROM "setting.company" WHERE id = ?`, id).ToPgsql()
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	result, err := tx.Exec(query, params...)
+	if err != nil {
+		tx.Rollback()
+		switch err.(*pq.Error).Constraint {
+		case database.FK_SETTING_COMPANY_ID:
+			return 409, ErrUnableToDeleteCurrentlyUsedCompany
+		}
+
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		tx.Rollback()
+		return 404, ErrCompanyNotFound
+	}
+
+	// -- Audit
+	if err := ctx.Audit(tx, "setting.company", id, before, nil); err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+	utils.GetPrincipalCache().InvalidateAll()
+
+	return 204, nil
+}
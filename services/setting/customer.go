@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"log"
+	"slices"
 
 	"system.buon18.com/m/database"
 	"system.buon18.com/m/models"
@@ -24,7 +25,9 @@ type SettingCustomerService struct {
 	DB *sql.DB
 }
 
-func (service *SettingCustomerService) Customers(qp *utils.QueryParams) ([]setting.SettingCustomerResponse, int, int, error) {
+func (service *SettingCustomerService) Customers(ctx *utils.CtxW, qp *utils.QueryParams) ([]setting.SettingCustomerResponse, int, int, error) {
+	qp.AddCondition(ctx.AccessCondition("setting.customer", `"setting.customer"`))
+
 	bqbQuery := bqb.New(`
 	SELECT
 		"setting.customer".id,
@@ -35,9 +38,7 @@ func (service *SettingCustomerService) Customers(qp *utils.QueryParams) ([]setti
 		"setting.customer".additional_information
 	FROM "setting.customer"`)
 
-	qp.FilterIntoBqb(bqbQuery)
-	qp.OrderByIntoBqb(bqbQuery, `"setting.customer".id ASC`)
-	qp.PaginationIntoBqb(bqbQuery)
+	qp.PageIntoBqb(bqbQuery, `"setting.customer"`, "ASC")
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -63,26 +64,38 @@ func (service *SettingCustomerService) Customers(qp *utils.QueryParams) ([]setti
 		customersResponse = append(customersResponse, setting.SettingCustomerToResponse(tmpCustomer))
 	}
 
-	bqbQuery = bqb.New(`SELECT COUNT(*) FROM "setting.customer"`)
-	qp.FilterIntoBqb(bqbQuery)
-
-	query, params, err = bqbQuery.ToPgsql()
-	if err != nil {
-		log.Printf("%s", err)
-		return nil, 0, 500, utils.ErrInternalServer
+	if qp.IsBefore() {
+		slices.Reverse(customersResponse)
+	}
+	if len(customersResponse) > 0 {
+		if err := qp.PrepareCursors(service.DB, `"setting.customer"`, customersResponse[0].Id, customersResponse[len(customersResponse)-1].Id, len(customersResponse)); err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
 	}
 
 	var total int
-	err = service.DB.QueryRow(query, params...).Scan(&total)
-	if err != nil {
-		log.Printf("%s", err)
-		return nil, 0, 500, utils.ErrInternalServer
+	if qp.WithTotal {
+		bqbQuery = bqb.New(`SELECT COUNT(*) FROM "setting.customer"`)
+		qp.FilterIntoBqb(bqbQuery)
+
+		query, params, err = bqbQuery.ToPgsql()
+		if err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+
+		err = service.DB.QueryRow(query, params...).Scan(&total)
+		if err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
 	}
 
 	return customersResponse, total, 200, nil
 }
 
-func (service *SettingCustomerService) Customer(id string) (setting.SettingCustomerResponse, int, error) {
+func (service *SettingCustomerService) Customer(ctx *utils.CtxW, id string) (setting.SettingCustomerResponse, int, error) {
 	bqbQuery := bqb.New(`
 	SELECT
 		"setting.customer".id,
@@ -92,6 +105,7 @@ func (service *SettingCustomerService) Customer(id string) (setting.SettingCusto
 		"setting.customer".phone,
 		"setting.customer".additional_information
 	FROM "setting.customer" WHERE "setting.customer".id = ?`, id)
+	ctx.AccessIntoBqb(bqbQuery, "setting.customer", `"setting.customer"`)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -131,11 +145,12 @@ func (service *SettingCustomerService) CreateCustomer(ctx *utils.CtxW, customer
 		email,
 		phone,
 		additional_information,
+		setting_company_id,
 		cid,
 		ctime,
 		mid,
 		mtime
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, customer.FullName, customer.Gender, customer.Email, customer.Phone, customer.AdditionalInformation, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime)
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`, customer.FullName, customer.Gender, customer.Email, customer.Phone, customer.AdditionalInformation, ctx.Company.Id, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -143,8 +158,16 @@ func (service *SettingCustomerService) CreateCustomer(ctx *utils.CtxW, customer
 		return 500, utils.ErrInternalServer
 	}
 
-	_, err = service.DB.Exec(query, params...)
+	tx, err := service.DB.Begin()
+	if err != nil {
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	var customerId int
+	err = tx.QueryRow(query, params...).Scan(&customerId)
 	if err != nil {
+		tx.Rollback()
 		switch err.(*pq.Error).Constraint {
 		case database.KEY_SETTING_CUSTOMER_EMAIL:
 			return 409, ErrCustomerEmailExists
@@ -154,6 +177,22 @@ func (service *SettingCustomerService) CreateCustomer(ctx *utils.CtxW, customer
 		return 500, utils.ErrInternalServer
 	}
 
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "setting.customer", customerId)
+	if err == nil {
+		err = ctx.Audit(tx, "setting.customer", customerId, nil, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	return 201, nil
 }
 
@@ -164,6 +203,8 @@ func (service *SettingCustomerService) UpdateCustomer(ctx *utils.CtxW, id string
 	bqbQuery := bqb.New(`UPDATE "setting.customer" SET mid = ?, mtime = ?`, commonModel.MId, commonModel.MTime)
 	utils.PrepareUpdateBqbQuery(bqbQuery, customer)
 	bqbQuery.Space(` WHERE id = ?`, id)
+	customer.PreconditionIntoBqb(bqbQuery)
+	ctx.AccessIntoBqb(bqbQuery, "setting.customer", `"setting.customer"`)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -171,25 +212,60 @@ func (service *SettingCustomerService) UpdateCustomer(ctx *utils.CtxW, id string
 		return 500, utils.ErrInternalServer
 	}
 
-	result, err := service.DB.Exec(query, params...)
+	tx, err := service.DB.Begin()
 	if err != nil {
 		log.Printf("%v", err)
 		return 500, utils.ErrInternalServer
 	}
 
+	before, err := utils.AuditSnapshot(tx, "setting.customer", id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	result, err := tx.Exec(query, params...)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		tx.Rollback()
 		if n == 0 {
+			if customer.HasPrecondition() {
+				return 409, utils.ErrUpdateConflict
+			}
 			return 404, ErrCustomerNotFound
 		}
 		log.Printf("%v", err)
 		return 500, utils.ErrInternalServer
 	}
 
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "setting.customer", id)
+	if err == nil {
+		err = ctx.Audit(tx, "setting.customer", id, before, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	return 200, nil
 }
 
-func (service *SettingCustomerService) DeleteCustomer(id string) (int, error) {
+func (service *SettingCustomerService) DeleteCustomer(ctx *utils.CtxW, id string) (int, error) {
 	bqbQuery := bqb.New(`DELETE FROM "setting.customer" WHERE id = ?`, id)
+	ctx.AccessIntoBqb(bqbQuery, "setting.customer", `"setting.customer"`)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -197,8 +273,22 @@ func (service *SettingCustomerService) DeleteCustomer(id string) (int, error) {
 		return 500, utils.ErrInternalServer
 	}
 
-	result, err := service.DB.Exec(query, params...)
+	tx, err := service.DB.Begin()
 	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	before, err := utils.AuditSnapshot(tx, "setting.customer", id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	result, err := tx.Exec(query, params...)
+	if err != nil {
+		tx.Rollback()
 		switch err.(*pq.Error).Constraint {
 		case database.FK_SETTING_CUSTOMER_ID:
 			return 409, ErrUnableToDeleteCustomer
@@ -207,6 +297,7 @@ func (service *SettingCustomerService) DeleteCustomer(id string) (int, error) {
 	}
 
 	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		tx.Rollback()
 		if n == 0 {
 			return 404, ErrCustomerNotFound
 		}
@@ -214,5 +305,17 @@ func (service *SettingCustomerService) DeleteCustomer(id string) (int, error) {
 		return 500, utils.ErrInternalServer
 	}
 
+	// -- Audit
+	if err := ctx.Audit(tx, "setting.customer", id, before, nil); err != nil {
+		tx.Rollback()
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	return 204, nil
 }
@@ -3,6 +3,7 @@ package setting
 import (
 	"database/sql"
 	"log"
+	"slices"
 
 	"system.buon18.com/m/models/setting"
 	"system.buon18.com/m/utils"
@@ -16,9 +17,7 @@ type SettingPermissionService struct {
 
 func (service *SettingPermissionService) Permissions(qp *utils.QueryParams) ([]setting.SettingPermissionResponse, int, int, error) {
 	bqbQuery := bqb.New(`SELECT "setting.permission".id, "setting.permission".name FROM "setting.permission"`)
-	qp.FilterIntoBqb(bqbQuery)
-	qp.OrderByIntoBqb(bqbQuery, `"setting.permission".id ASC`)
-	qp.PaginationIntoBqb(bqbQuery)
+	qp.PageIntoBqb(bqbQuery, `"setting.permission"`, "ASC")
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -43,20 +42,32 @@ func (service *SettingPermissionService) Permissions(qp *utils.QueryParams) ([]s
 		permissions = append(permissions, setting.SettingPermissionToResponse(permission))
 	}
 
-	bqbQuery = bqb.New(`SELECT COUNT(*) FROM "setting.permission"`)
-	qp.FilterIntoBqb(bqbQuery)
-
-	query, params, err = bqbQuery.ToPgsql()
-	if err != nil {
-		log.Printf("%s", err)
-		return nil, 0, 500, utils.ErrInternalServer
+	if qp.IsBefore() {
+		slices.Reverse(permissions)
+	}
+	if len(permissions) > 0 {
+		if err := qp.PrepareCursors(service.DB, `"setting.permission"`, permissions[0].Id, permissions[len(permissions)-1].Id, len(permissions)); err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
 	}
 
 	var total int
-	err = service.DB.QueryRow(query, params...).Scan(&total)
-	if err != nil {
-		log.Printf("%s", err)
-		return nil, 0, 500, utils.ErrInternalServer
+	if qp.WithTotal {
+		bqbQuery = bqb.New(`SELECT COUNT(*) FROM "setting.permission"`)
+		qp.FilterIntoBqb(bqbQuery)
+
+		query, params, err = bqbQuery.ToPgsql()
+		if err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+
+		err = service.DB.QueryRow(query, params...).Scan(&total)
+		if err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
 	}
 
 	return permissions, total, 200, nil
@@ -31,8 +31,7 @@ func (service *SettingRoleService) Roles(qp *utils.QueryParams) ([]setting.Setti
 			id, name, description
 		FROM "setting.role"`)
 
-	qp.FilterIntoBqb(bqbQuery)
-	qp.PaginationIntoBqb(bqbQuery)
+	qp.PageIntoBqb(bqbQuery, `"setting.role"`, "ASC")
 
 	bqbQuery.Space(`)
 	SELECT 
@@ -92,20 +91,29 @@ func (service *SettingRoleService) Roles(qp *utils.QueryParams) ([]setting.Setti
 		roles = append(roles, setting.SettingRoleToResponse(lastRole, permissionsResponse))
 	}
 
-	bqbQuery = bqb.New(`SELECT COUNT(*) FROM "setting.role"`)
-	qp.FilterIntoBqb(bqbQuery)
-
-	query, params, err = bqbQuery.ToPgsql()
-	if err != nil {
-		log.Printf("%s\n", err)
-		return nil, 0, 500, utils.ErrInternalServer
+	if len(roles) > 0 {
+		if err := qp.PrepareCursors(service.DB, `"setting.role"`, roles[0].Id, roles[len(roles)-1].Id, len(roles)); err != nil {
+			log.Printf("%s\n", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
 	}
 
 	var total int
-	err = service.DB.QueryRow(query, params...).Scan(&total)
-	if err != nil {
-		log.Printf("%s\n", err)
-		return nil, 0, 500, utils.ErrInternalServer
+	if qp.WithTotal {
+		bqbQuery = bqb.New(`SELECT COUNT(*) FROM "setting.role"`)
+		qp.FilterIntoBqb(bqbQuery)
+
+		query, params, err = bqbQuery.ToPgsql()
+		if err != nil {
+			log.Printf("%s\n", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+
+		err = service.DB.QueryRow(query, params...).Scan(&total)
+		if err != nil {
+			log.Printf("%s\n", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
 	}
 
 	return roles, total, 200, nil
@@ -164,7 +172,61 @@ func (service *SettingRoleService) Role(id string) (setting.SettingRoleResponse,
 		permissionsResponse = append(permissionsResponse, setting.SettingPermissionToResponse(permission))
 	}
 
-	return setting.SettingRoleToResponse(role, permissionsResponse), 200, nil
+	query, params, err = bqb.New(`
+	SELECT
+		id,
+		resource,
+		field,
+		operator,
+		value
+	FROM "setting.record_rule"
+	WHERE setting_role_id = ?
+	ORDER BY id ASC`, role.Id).ToPgsql()
+	if err != nil {
+		log.Printf("%s\n", err)
+		return setting.SettingRoleResponse{}, 500, utils.ErrInternalServer
+	}
+
+	rows, err = service.DB.Query(query, params...)
+	if err != nil {
+		log.Printf("%s\n", err)
+		return setting.SettingRoleResponse{}, 500, utils.ErrInternalServer
+	}
+
+	recordRulesResponse := make([]setting.SettingRecordRuleResponse, 0)
+	for rows.Next() {
+		var recordRule setting.SettingRecordRule
+		err := rows.Scan(&recordRule.Id, &recordRule.Resource, &recordRule.Field, &recordRule.Operator, &recordRule.Value)
+		if err != nil {
+			log.Printf("%s\n", err)
+			return setting.SettingRoleResponse{}, 500, utils.ErrInternalServer
+		}
+
+		recordRulesResponse = append(recordRulesResponse, setting.SettingRecordRuleToResponse(recordRule))
+	}
+
+	roleResponse := setting.SettingRoleToResponse(role, permissionsResponse)
+	roleResponse.RecordRules = recordRulesResponse
+
+	return roleResponse, 200, nil
+}
+
+func insertRecordRules(tx *sql.Tx, roleId interface{}, recordRules []setting.SettingRecordRuleCreateRequest, commonModel models.CommonModel) error {
+	bqbQuery := bqb.New(`INSERT INTO "setting.record_rule" (setting_role_id, resource, field, operator, value, cid, ctime, mid, mtime) VALUES`)
+	for index, recordRule := range recordRules {
+		bqbQuery.Space(`(?, ?, ?, ?, ?, ?, ?, ?, ?)`, roleId, recordRule.Resource, recordRule.Field, recordRule.Operator, recordRule.Value, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime)
+		if index != len(recordRules)-1 {
+			bqbQuery.Space(`,`)
+		}
+	}
+
+	query, params, err := bqbQuery.ToPgsql()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(query, params...)
+	return err
 }
 
 func (service *SettingRoleService) CreateRole(ctx *utils.CtxW, role *setting.SettingRoleCreateRequest) (int, error) {
@@ -180,12 +242,8 @@ func (service *SettingRoleService) CreateRole(ctx *utils.CtxW, role *setting.Set
 	bqbQuery := bqb.New(`SELECT COUNT(*) FROM "setting.permission" WHERE id in (`)
 	hasPermission := true
 	for index, permissionId := range role.PermissionIds {
-		if utils.ContainsString(utils.FULL_PERMISSION_IDS, utils.IntToStr(int(permissionId))) {
-			for _, permission := range ctx.Permissions {
-				if !utils.ContainsString([]string{utils.PREDEFINED_PERMISSIONS.FULL_ACCESS}, permission.Name) {
-					hasPermission = false
-				}
-			}
+		if utils.ContainsString(utils.FULL_PERMISSION_IDS, utils.IntToStr(int(permissionId))) && !ctx.HasPermission(utils.PREDEFINED_PERMISSIONS.FULL_ACCESS) {
+			hasPermission = false
 		}
 		bqbQuery.Space(`?`, permissionId)
 		if index != len(role.PermissionIds)-1 {
@@ -257,6 +315,25 @@ func (service *SettingRoleService) CreateRole(ctx *utils.CtxW, role *setting.Set
 		return 500, utils.ErrInternalServer
 	}
 
+	if len(role.RecordRules) > 0 {
+		if err := insertRecordRules(tx, roleId, role.RecordRules, commonModel); err != nil {
+			tx.Rollback()
+			log.Printf("%s\n", err)
+			return 500, utils.ErrInternalServer
+		}
+	}
+
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "setting.role", roleId)
+	if err == nil {
+		err = ctx.Audit(tx, "setting.role", roleId, nil, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%s\n", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		tx.Rollback()
@@ -277,9 +354,17 @@ func (service *SettingRoleService) UpdateRole(ctx *utils.CtxW, id string, role *
 		return 500, utils.ErrInternalServer
 	}
 
+	before, err := utils.AuditSnapshot(tx, "setting.role", id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%s\n", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	bqbQuery := bqb.New(`UPDATE "setting.role" SET mid = ?, mtime = ?`, commonModel.MId, commonModel.MTime)
 	utils.PrepareUpdateBqbQuery(bqbQuery, role)
 	bqbQuery.Space(`WHERE id = ?`, id)
+	role.PreconditionIntoBqb(bqbQuery)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -297,6 +382,9 @@ func (service *SettingRoleService) UpdateRole(ctx *utils.CtxW, id string, role *
 
 	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
 		tx.Rollback()
+		if role.HasPrecondition() {
+			return 409, utils.ErrUpdateConflict
+		}
 		return 404, ErrRoleNotFound
 	}
 
@@ -348,23 +436,69 @@ func (service *SettingRoleService) UpdateRole(ctx *utils.CtxW, id string, role *
 		}
 	}
 
+	if role.AddRecordRules != nil {
+		createModel := models.CommonModel{}
+		createModel.PrepareForCreate(ctx.User.Id, ctx.User.Id)
+		if err := insertRecordRules(tx, id, *role.AddRecordRules, createModel); err != nil {
+			tx.Rollback()
+			log.Printf("%s\n", err)
+			return 500, utils.ErrInternalServer
+		}
+	}
+
+	if role.RemoveRecordRuleIds != nil {
+		query, params, err := bqb.New(`DELETE FROM "setting.record_rule" WHERE setting_role_id = ? AND id IN (?)`, id, *role.RemoveRecordRuleIds).ToPgsql()
+		if err != nil {
+			tx.Rollback()
+			log.Printf("%s\n", err)
+			return 500, utils.ErrInternalServer
+		}
+
+		_, err = tx.Exec(query, params...)
+		if err != nil {
+			tx.Rollback()
+			log.Printf("%s\n", err)
+			return 500, utils.ErrInternalServer
+		}
+	}
+
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "setting.role", id)
+	if err == nil {
+		err = ctx.Audit(tx, "setting.role", id, before, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%s\n", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		tx.Rollback()
 		log.Printf("%s\n", err)
 		return 500, utils.ErrInternalServer
 	}
+	// -- Every user of the role is affected
+	utils.GetPrincipalCache().InvalidateAll()
 
 	return 200, nil
 }
 
-func (service *SettingRoleService) DeleteRole(id string) (int, error) {
+func (service *SettingRoleService) DeleteRole(ctx *utils.CtxW, id string) (int, error) {
 	tx, err := service.DB.Begin()
 	if err != nil {
 		log.Printf("%v\n", err)
 		return 500, utils.ErrInternalServer
 	}
 
+	before, err := utils.AuditSnapshot(tx, "setting.role", id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v\n", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	bqbQuery := bqb.New(`DELETE FROM "setting.role_permission" WHERE setting_role_id = ?`, id)
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -380,6 +514,21 @@ func (service *SettingRoleService) DeleteRole(id string) (int, error) {
 		return 500, utils.ErrInternalServer
 	}
 
+	bqbQuery = bqb.New(`DELETE FROM "setting.record_rule" WHERE setting_role_id = ?`, id)
+	query, params, err = bqbQuery.ToPgsql()
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v\n", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	_, err = tx.Exec(query, params...)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%v\n", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	bqbQuery = bqb.New(`DELETE FROM "setting.role" WHERE id = ?`, id)
 	query, params, err = bqbQuery.ToPgsql()
 	if err != nil {
@@ -406,11 +555,19 @@ func (service *SettingRoleService) DeleteRole(id string) (int, error) {
 		return 404, ErrRoleNotFound
 	}
 
+	// -- Audit
+	if err := ctx.Audit(tx, "setting.role", id, before, nil); err != nil {
+		tx.Rollback()
+		log.Printf("%v\n", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		log.Printf("%v\n", err)
 		return 500, utils.ErrInternalServer
 	}
+	utils.GetPrincipalCache().InvalidateAll()
 
 	return 200, nil
 }
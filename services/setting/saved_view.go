@@ -0,0 +1,314 @@
+package setting
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+
+	"system.buon18.com/m/database"
+	"system.buon18.com/m/models"
+	"system.buon18.com/m/models/sales"
+	"system.buon18.com/m/models/setting"
+	"system.buon18.com/m/utils"
+
+	"github.com/lib/pq"
+	"github.com/nullism/bqb"
+)
+
+var (
+	ErrSavedViewNotFound         = errors.New("saved view not found")
+	ErrSavedViewNameExists       = errors.New("saved view name already exists")
+	ErrSavedViewRoleNotAllowed   = errors.New("saved view can only be shared with a role of the user")
+	ErrSavedViewColumnNotAllowed = errors.New("columns must be fields of the endpoint")
+)
+
+// -- Fields the columns of a view are applied as, see utils.ApplyView. Views
+// -- of endpoints without sparse fieldsets can't have columns
+var SAVED_VIEW_COLUMNS = map[string][]string{
+	"/api/sales/quotations": sales.SalesQuotationAllowFields,
+	"/api/sales/orders":     sales.SalesOrderAllowFields,
+}
+
+type SettingSavedViewService struct {
+	DB *sql.DB
+}
+
+// savedViewVisibleCondition matches the views of the user and the views shared
+// with one of the roles of the user.
+func savedViewVisibleCondition(ctx *utils.CtxW) *bqb.Query {
+	condition := bqb.New(`("setting.saved_view".cid = ?`, ctx.User.Id)
+	for _, role := range ctx.Roles {
+		condition.Space(`OR "setting.saved_view".setting_role_id = ?`, role.Id)
+	}
+	condition.Space(")")
+	return condition
+}
+
+func columnsAllowed(endpoint string, columns []string) bool {
+	for _, column := range columns {
+		if !slices.Contains(SAVED_VIEW_COLUMNS[endpoint], column) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasRole(ctx *utils.CtxW, roleId uint) bool {
+	return slices.ContainsFunc(ctx.Roles, func(role setting.SettingRole) bool {
+		return role.Id == roleId
+	})
+}
+
+func (service *SettingSavedViewService) SavedViews(ctx *utils.CtxW, qp *utils.QueryParams) ([]setting.SettingSavedViewResponse, int, int, error) {
+	qp.AddCondition(savedViewVisibleCondition(ctx))
+
+	bqbQuery := bqb.New(`
+	SELECT
+		"setting.saved_view".id,
+		"setting.saved_view".name,
+		"setting.saved_view".endpoint,
+		"setting.saved_view".filters,
+		"setting.saved_view".sorts,
+		"setting.saved_view".columns,
+		COALESCE("setting.saved_view".setting_role_id, 0),
+		"setting.saved_view".cid
+	FROM "setting.saved_view"`)
+
+	qp.PageIntoBqb(bqbQuery, `"setting.saved_view"`, "ASC")
+
+	query, params, err := bqbQuery.ToPgsql()
+	if err != nil {
+		log.Printf("%s", err)
+		return nil, 0, 500, utils.ErrInternalServer
+	}
+
+	rows, err := service.DB.Query(query, params...)
+	if err != nil {
+		log.Printf("%s", err)
+		return nil, 0, 500, utils.ErrInternalServer
+	}
+
+	savedViewsResponse := make([]setting.SettingSavedViewResponse, 0)
+	for rows.Next() {
+		tmpSavedView := setting.SettingSavedView{CommonModel: &models.CommonModel{}}
+		if err := rows.Scan(&tmpSavedView.Id, &tmpSavedView.Name, &tmpSavedView.Endpoint, &tmpSavedView.Filters, &tmpSavedView.Sorts, &tmpSavedView.Columns, &tmpSavedView.RoleId, &tmpSavedView.CId); err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+
+		savedViewsResponse = append(savedViewsResponse, setting.SettingSavedViewToResponse(tmpSavedView))
+	}
+
+	if qp.IsBefore() {
+		slices.Reverse(savedViewsResponse)
+	}
+	if len(savedViewsResponse) > 0 {
+		if err := qp.PrepareCursors(service.DB, `"setting.saved_view"`, savedViewsResponse[0].Id, savedViewsResponse[len(savedViewsResponse)-1].Id, len(savedViewsResponse)); err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+	}
+
+	var total int
+	if qp.WithTotal {
+		bqbQuery = bqb.New(`SELECT COUNT(*) FROM "setting.saved_view"`)
+		qp.FilterIntoBqb(bqbQuery)
+
+		query, params, err = bqbQuery.ToPgsql()
+		if err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+
+		err = service.DB.QueryRow(query, params...).Scan(&total)
+		if err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+	}
+
+	return savedViewsResponse, total, 200, nil
+}
+
+// SavedView returns a view of the user or shared with one of the roles of the
+// user, the views of the other users are not found.
+func (service *SettingSavedViewService) SavedView(ctx *utils.CtxW, id string) (setting.SettingSavedViewResponse, int, error) {
+	query, params, err := bqb.New(`
+	SELECT
+		"setting.saved_view".id,
+		"setting.saved_view".name,
+		"setting.saved_view".endpoint,
+		"setting.saved_view".filters,
+		"setting.saved_view".sorts,
+		"setting.saved_view".columns,
+		COALESCE("setting.saved_view".setting_role_id, 0),
+		"setting.saved_view".cid
+	FROM "setting.saved_view" WHERE "setting.saved_view".id = ? AND ?`, id, savedViewVisibleCondition(ctx)).ToPgsql()
+	if err != nil {
+		log.Printf("%s", err)
+		return setting.SettingSavedViewResponse{}, 500, utils.ErrInternalServer
+	}
+
+	savedView := setting.SettingSavedView{CommonModel: &models.CommonModel{}}
+	err = service.DB.QueryRow(query, params...).Scan(&savedView.Id, &savedView.Name, &savedView.Endpoint, &savedView.Filters, &savedView.Sorts, &savedView.Columns, &savedView.RoleId, &savedView.CId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return setting.SettingSavedViewResponse{}, 404, ErrSavedViewNotFound
+		}
+
+		log.Printf("%s", err)
+		return setting.SettingSavedViewResponse{}, 500, utils.ErrInternalServer
+	}
+
+	return setting.SettingSavedViewToResponse(savedView), 200, nil
+}
+
+// CreateSavedView creates a view of the user, it's shared with the users of
+// RoleId unless it's 0.
+func (service *SettingSavedViewService) CreateSavedView(ctx *utils.CtxW, savedView *setting.SettingSavedViewCreateRequest) (int, error) {
+	if savedView.RoleId != 0 && !hasRole(ctx, savedView.RoleId) {
+		return 403, ErrSavedViewRoleNotAllowed
+	}
+	if !columnsAllowed(savedView.Endpoint, savedView.Columns) {
+		return 400, ErrSavedViewColumnNotAllowed
+	}
+
+	// -- A private view has no role
+	var roleId interface{}
+	if savedView.RoleId != 0 {
+		roleId = savedView.RoleId
+	}
+
+	if savedView.Filters == nil {
+		savedView.Filters = map[string]string{}
+	}
+	if savedView.Sorts == nil {
+		savedView.Sorts = map[string]string{}
+	}
+	if savedView.Columns == nil {
+		savedView.Columns = []string{}
+	}
+	filters, err := json.Marshal(savedView.Filters)
+	if err != nil {
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+	sorts, err := json.Marshal(savedView.Sorts)
+	if err != nil {
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+	columns, err := json.Marshal(savedView.Columns)
+	if err != nil {
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	commonModel := models.CommonModel{}
+	commonModel.PrepareForCreate(ctx.User.Id, ctx.User.Id)
+
+	query, params, err := bqb.New(`INSERT INTO "setting.saved_view"
+	(name, endpoint, filters, sorts, columns, setting_role_id, cid, ctime, mid, mtime)
+	VALUES
+	(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, savedView.Name, savedView.Endpoint, string(filters), string(sorts), string(columns), roleId, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime).ToPgsql()
+	if err != nil {
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	if _, err := service.DB.Exec(query, params...); err != nil {
+		switch err.(*pq.Error).Constraint {
+		case database.KEY_SETTING_SAVED_VIEW_NAME:
+			return 409, ErrSavedViewNameExists
+		}
+
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	return 201, nil
+}
+
+// UpdateSavedView updates a view of the user, the views shared with the user
+// by others can't be updated.
+func (service *SettingSavedViewService) UpdateSavedView(ctx *utils.CtxW, id string, savedView *setting.SettingSavedViewUpdateRequest) (int, error) {
+	if savedView.RoleId != nil && *savedView.RoleId != 0 && !hasRole(ctx, *savedView.RoleId) {
+		return 403, ErrSavedViewRoleNotAllowed
+	}
+	if savedView.Columns != nil {
+		query, params, err := bqb.New(`SELECT endpoint FROM "setting.saved_view" WHERE id = ? AND cid = ?`, id, ctx.User.Id).ToPgsql()
+		if err != nil {
+			log.Printf("%v", err)
+			return 500, utils.ErrInternalServer
+		}
+
+		var endpoint string
+		if err := service.DB.QueryRow(query, params...).Scan(&endpoint); err != nil {
+			if err == sql.ErrNoRows {
+				return 404, ErrSavedViewNotFound
+			}
+			log.Printf("%v", err)
+			return 500, utils.ErrInternalServer
+		}
+		if !columnsAllowed(endpoint, *savedView.Columns) {
+			return 400, ErrSavedViewColumnNotAllowed
+		}
+	}
+
+	commonModel := models.CommonModel{}
+	commonModel.PrepareForUpdate(ctx.User.Id)
+
+	bqbQuery := bqb.New(`UPDATE "setting.saved_view" SET mid = ?, mtime = ?`, commonModel.MId, commonModel.MTime)
+	utils.PrepareUpdateBqbQuery(bqbQuery, savedView)
+	bqbQuery.Space(`WHERE id = ? AND cid = ?`, id, ctx.User.Id)
+	savedView.PreconditionIntoBqb(bqbQuery)
+
+	query, params, err := bqbQuery.ToPgsql()
+	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	result, err := service.DB.Exec(query, params...)
+	if err != nil {
+		switch err.(*pq.Error).Constraint {
+		case database.KEY_SETTING_SAVED_VIEW_NAME:
+			return 409, ErrSavedViewNameExists
+		}
+
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		if savedView.HasPrecondition() {
+			return 409, utils.ErrUpdateConflict
+		}
+		return 404, ErrSavedViewNotFound
+	}
+
+	return 200, nil
+}
+
+// DeleteSavedView deletes a view of the user.
+func (service *SettingSavedViewService) DeleteSavedView(ctx *utils.CtxW, id string) (int, error) {
+	query, params, err := bqb.New(`DELETE FROM "setting.saved_view" WHERE id = ? AND cid = ?`, id, ctx.User.Id).ToPgsql()
+	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	result, err := service.DB.Exec(query, params...)
+	if err != nil {
+		log.Printf("%v", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return 404, ErrSavedViewNotFound
+	}
+
+	return 204, nil
+}
@@ -0,0 +1,200 @@
+package setting
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"slices"
+
+	"system.buon18.com/m/models/setting"
+	"system.buon18.com/m/utils"
+
+	"github.com/nullism/bqb"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+type SettingSessionService struct {
+	DB *sql.DB
+}
+
+func (service *SettingSessionService) Sessions(ctx *utils.CtxW, qp *utils.QueryParams) ([]setting.SettingSessionResponse, int, int, error) {
+	bqbQuery := bqb.New(`
+	SELECT
+		"setting.session".id,
+		"setting.session".sid,
+		"setting.session".setting_user_id,
+		"setting.session".ip,
+		"setting.session".user_agent,
+		"setting.session".last_used_at,
+		"setting.session".expires_at,
+		"setting.session".revoked_at,
+		"setting.session".ctime
+	FROM "setting.session"`)
+	qp.PageIntoBqb(bqbQuery, `"setting.session"`, "DESC")
+
+	query, params, err := bqbQuery.ToPgsql()
+	if err != nil {
+		log.Printf("%s", err)
+		return nil, 0, 500, utils.ErrInternalServer
+	}
+
+	rows, err := service.DB.Query(query, params...)
+	if err != nil {
+		log.Printf("%s", err)
+		return nil, 0, 500, utils.ErrInternalServer
+	}
+
+	sessions := make([]setting.SettingSessionResponse, 0)
+	for rows.Next() {
+		var session setting.SettingSession
+		err := rows.Scan(&session.Id, &session.Sid, &session.UserId, &session.IP, &session.UserAgent, &session.LastUsedAt, &session.ExpiresAt, &session.RevokedAt, &session.CTime)
+		if err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+		sessions = append(sessions, setting.SettingSessionToResponse(session, ctx.SessionId))
+	}
+
+	if qp.IsBefore() {
+		slices.Reverse(sessions)
+	}
+	if len(sessions) > 0 {
+		if err := qp.PrepareCursors(service.DB, `"setting.session"`, sessions[0].Id, sessions[len(sessions)-1].Id, len(sessions)); err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+	}
+
+	var total int
+	if qp.WithTotal {
+		bqbQuery = bqb.New(`SELECT COUNT(*) FROM "setting.session"`)
+		qp.FilterIntoBqb(bqbQuery)
+
+		query, params, err = bqbQuery.ToPgsql()
+		if err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+
+		err = service.DB.QueryRow(query, params...).Scan(&total)
+		if err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+	}
+
+	return sessions, total, 200, nil
+}
+
+// MySessions returns the sessions of the user that are still usable.
+func (service *SettingSessionService) MySessions(ctx *utils.CtxW, qp *utils.QueryParams) ([]setting.SettingSessionResponse, int, int, error) {
+	qp.AddCondition(bqb.New(`"setting.session".setting_user_id = ? AND "setting.session".revoked_at IS NULL AND "setting.session".expires_at > NOW()`, ctx.User.Id))
+	return service.Sessions(ctx, qp)
+}
+
+// RevokeSession revokes the session id, restricted to the sessions of userId
+// unless it's 0. Tokens of the session are rejected from now on.
+func (service *SettingSessionService) RevokeSession(ctx *utils.CtxW, id string, userId uint) (int, error) {
+	bqbQuery := bqb.New(`UPDATE "setting.session" SET revoked_at = NOW() WHERE id = ? AND revoked_at IS NULL`, id)
+	if userId != 0 {
+		bqbQuery.Space(`AND setting_user_id = ?`, userId)
+	}
+	bqbQuery.Space(`RETURNING setting_user_id`)
+
+	query, params, err := bqbQuery.ToPgsql()
+	if err != nil {
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	var sessionUserId uint
+	if err := service.DB.QueryRow(query, params...).Scan(&sessionUserId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 404, ErrSessionNotFound
+		}
+
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+	utils.GetPrincipalCache().InvalidateUser(sessionUserId)
+
+	return 204, nil
+}
+
+func (service *SettingSessionService) LoginEvents(qp *utils.QueryParams) ([]setting.SettingLoginEventResponse, int, int, error) {
+	bqbQuery := bqb.New(`
+	SELECT
+		"setting.login_event".id,
+		COALESCE("setting.login_event".setting_user_id, 0),
+		"setting.login_event".email,
+		"setting.login_event".method,
+		"setting.login_event".success,
+		"setting.login_event".reason,
+		"setting.login_event".ip,
+		"setting.login_event".user_agent,
+		"setting.login_event".ctime
+	FROM "setting.login_event"`)
+	qp.PageIntoBqb(bqbQuery, `"setting.login_event"`, "DESC")
+
+	query, params, err := bqbQuery.ToPgsql()
+	if err != nil {
+		log.Printf("%s", err)
+		return nil, 0, 500, utils.ErrInternalServer
+	}
+
+	rows, err := service.DB.Query(query, params...)
+	if err != nil {
+		log.Printf("%s", err)
+		return nil, 0, 500, utils.ErrInternalServer
+	}
+
+	loginEvents := make([]setting.SettingLoginEventResponse, 0)
+	for rows.Next() {
+		var loginEvent setting.SettingLoginEvent
+		err := rows.Scan(&loginEvent.Id, &loginEvent.UserId, &loginEvent.Email, &loginEvent.Method, &loginEvent.Success, &loginEvent.Reason, &loginEvent.IP, &loginEvent.UserAgent, &loginEvent.CTime)
+		if err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+		loginEvents = append(loginEvents, setting.SettingLoginEventToResponse(loginEvent))
+	}
+
+	if qp.IsBefore() {
+		slices.Reverse(loginEvents)
+	}
+	if len(loginEvents) > 0 {
+		if err := qp.PrepareCursors(service.DB, `"setting.login_event"`, loginEvents[0].Id, loginEvents[len(loginEvents)-1].Id, len(loginEvents)); err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+	}
+
+	var total int
+	if qp.WithTotal {
+		bqbQuery = bqb.New(`SELECT COUNT(*) FROM "setting.login_event"`)
+		qp.FilterIntoBqb(bqbQuery)
+
+		query, params, err = bqbQuery.ToPgsql()
+		if err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+
+		err = service.DB.QueryRow(query, params...).Scan(&total)
+		if err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+	}
+
+	return loginEvents, total, 200, nil
+}
+
+// MyLoginEvents returns the sign in attempts of the user.
+func (service *SettingSessionService) MyLoginEvents(ctx *utils.CtxW, qp *utils.QueryParams) ([]setting.SettingLoginEventResponse, int, int, error) {
+	qp.AddCondition(bqb.New(`"setting.login_event".setting_user_id = ?`, ctx.User.Id))
+	return service.LoginEvents(qp)
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"log"
+	"time"
 
 	"system.buon18.com/m/database"
 	"system.buon18.com/m/models"
@@ -28,11 +29,10 @@ func (service *SettingUserService) Users(qp *utils.QueryParams) ([]setting.Setti
 	bqbQuery := bqb.New(`
 	WITH "limited_users" AS (
 		SELECT 
-			id, name, email, typ, setting_role_id
+			id, name, email, typ, state, setting_role_id
 		FROM "setting.user"`)
 
-	qp.FilterIntoBqb(bqbQuery)
-	qp.PaginationIntoBqb(bqbQuery)
+	qp.PageIntoBqb(bqbQuery, `"setting.user"`, "ASC")
 
 	bqbQuery.Space(`)
 	SELECT 
@@ -40,6 +40,7 @@ func (service *SettingUserService) Users(qp *utils.QueryParams) ([]setting.Setti
 		"limited_users".name, 
 		"limited_users".email, 
 		"limited_users".typ,
+		"limited_users".state,
 		COALESCE("setting.role".id, 0), 
 		COALESCE("setting.role".name, ''), 
 		COALESCE("setting.role".description, ''), 
@@ -72,7 +73,7 @@ func (service *SettingUserService) Users(qp *utils.QueryParams) ([]setting.Setti
 		var tmpUser setting.SettingUser
 		var tmpRole setting.SettingRole
 		var tmpPermission setting.SettingPermission
-		err := rows.Scan(&tmpUser.Id, &tmpUser.Name, &tmpUser.Email, &tmpUser.Typ, &tmpRole.Id, &tmpRole.Name, &tmpRole.Description, &tmpPermission.Id, &tmpPermission.Name)
+		err := rows.Scan(&tmpUser.Id, &tmpUser.Name, &tmpUser.Email, &tmpUser.Typ, &tmpUser.State, &tmpRole.Id, &tmpRole.Name, &tmpRole.Description, &tmpPermission.Id, &tmpPermission.Name)
 		if err != nil {
 			log.Printf("%s", err)
 			return nil, 0, 500, utils.ErrInternalServer
@@ -110,20 +111,29 @@ func (service *SettingUserService) Users(qp *utils.QueryParams) ([]setting.Setti
 		usersResponse = append(usersResponse, setting.SettingUserToResponse(lastUser, roleResponse))
 	}
 
-	bqbQuery = bqb.New(`SELECT COUNT(*) FROM "setting.user"`)
-	qp.FilterIntoBqb(bqbQuery)
-
-	query, params, err = bqbQuery.ToPgsql()
-	if err != nil {
-		log.Printf("%s", err)
-		return nil, 0, 500, utils.ErrInternalServer
+	if len(usersResponse) > 0 {
+		if err := qp.PrepareCursors(service.DB, `"setting.user"`, usersResponse[0].Id, usersResponse[len(usersResponse)-1].Id, len(usersResponse)); err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
 	}
 
 	var total int
-	err = service.DB.QueryRow(query, params...).Scan(&total)
-	if err != nil {
-		log.Printf("%s", err)
-		return nil, 0, 500, utils.ErrInternalServer
+	if qp.WithTotal {
+		bqbQuery = bqb.New(`SELECT COUNT(*) FROM "setting.user"`)
+		qp.FilterIntoBqb(bqbQuery)
+
+		query, params, err = bqbQuery.ToPgsql()
+		if err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
+
+		err = service.DB.QueryRow(query, params...).Scan(&total)
+		if err != nil {
+			log.Printf("%s", err)
+			return nil, 0, 500, utils.ErrInternalServer
+		}
 	}
 
 	return usersResponse, total, 200, nil
@@ -133,7 +143,7 @@ func (service *SettingUserService) User(id string) (setting.SettingUserResponse,
 	query := `
 	WITH "limited_users" AS (
 		SELECT 
-			id, name, email, typ, setting_role_id
+			id, name, email, typ, state, setting_role_id
 		FROM "setting.user"
 		WHERE id = ?
 	)
@@ -142,6 +152,7 @@ func (service *SettingUserService) User(id string) (setting.SettingUserResponse,
 		"limited_users".name, 
 		"limited_users".email, 
 		"limited_users".typ,
+		"limited_users".state,
 		COALESCE("setting.role".id, 0), 
 		COALESCE("setting.role".name, ''), 
 		COALESCE("setting.role".description, ''), 
@@ -169,7 +180,7 @@ func (service *SettingUserService) User(id string) (setting.SettingUserResponse,
 	permissions := make([]setting.SettingPermission, 0)
 	for rows.Next() {
 		var tmpPermission setting.SettingPermission
-		err := rows.Scan(&user.Id, &user.Name, &user.Email, &user.Typ, &role.Id, &role.Name, &role.Description, &tmpPermission.Id, &tmpPermission.Name)
+		err := rows.Scan(&user.Id, &user.Name, &user.Email, &user.Typ, &user.State, &role.Id, &role.Name, &role.Description, &tmpPermission.Id, &tmpPermission.Name)
 		if err != nil {
 			log.Printf("%s", err)
 			return setting.SettingUserResponse{}, 500, utils.ErrInternalServer
@@ -187,29 +198,154 @@ func (service *SettingUserService) User(id string) (setting.SettingUserResponse,
 		permissionsResponse = append(permissionsResponse, setting.SettingPermissionToResponse(permission))
 	}
 	roleResponse := setting.SettingRoleToResponse(role, permissionsResponse)
+	userResponse := setting.SettingUserToResponse(user, roleResponse)
+
+	// -- Get every role of the user
+	query, params, err = bqb.New(`
+	SELECT 
+		"setting.role".id, 
+		"setting.role".name, 
+		"setting.role".description
+	FROM "setting.role"
+	WHERE "setting.role".id = ? 
+		OR "setting.role".id IN (SELECT setting_role_id FROM "setting.user_role" WHERE setting_user_id = ?)
+	ORDER BY "setting.role".id = ? DESC, "setting.role".id`, role.Id, user.Id, role.Id).ToPgsql()
+	if err != nil {
+		log.Printf("%s", err)
+		return setting.SettingUserResponse{}, 500, utils.ErrInternalServer
+	}
+
+	rows, err = service.DB.Query(query, params...)
+	if err != nil {
+		log.Printf("%s", err)
+		return setting.SettingUserResponse{}, 500, utils.ErrInternalServer
+	}
+
+	userResponse.Roles = make([]setting.SettingRoleResponse, 0)
+	for rows.Next() {
+		var tmpRole setting.SettingRole
+		if err := rows.Scan(&tmpRole.Id, &tmpRole.Name, &tmpRole.Description); err != nil {
+			log.Printf("%s", err)
+			return setting.SettingUserResponse{}, 500, utils.ErrInternalServer
+		}
+		userResponse.Roles = append(userResponse.Roles, setting.SettingRoleToResponse(tmpRole, nil))
+	}
+
+	// -- Get allowed companies of the user
+	query, params, err = bqb.New(`
+	SELECT
+		"setting.company".id,
+		"setting.company".name
+	FROM "setting.company"
+	WHERE "setting.company".id IN (SELECT setting_company_id FROM "setting.user_company" WHERE setting_user_id = ?)
+	ORDER BY "setting.company".id`, user.Id).ToPgsql()
+	if err != nil {
+		log.Printf("%s", err)
+		return setting.SettingUserResponse{}, 500, utils.ErrInternalServer
+	}
+
+	rows, err = service.DB.Query(query, params...)
+	if err != nil {
+		log.Printf("%s", err)
+		return setting.SettingUserResponse{}, 500, utils.ErrInternalServer
+	}
+
+	userResponse.Companies = make([]setting.SettingCompanyResponse, 0)
+	for rows.Next() {
+		var tmpCompany setting.SettingCompany
+		if err := rows.Scan(&tmpCompany.Id, &tmpCompany.Name); err != nil {
+			log.Printf("%s", err)
+			return setting.SettingUserResponse{}, 500, utils.ErrInternalServer
+		}
+		userResponse.Companies = append(userResponse.Companies, setting.SettingCompanyToResponse(tmpCompany))
+	}
 
-	return setting.SettingUserToResponse(user, roleResponse), 200, nil
+	return userResponse, 200, nil
+}
+
+func insertUserRoles(tx *sql.Tx, userId interface{}, roleIds []uint, commonModel models.CommonModel) error {
+	bqbQuery := bqb.New(`INSERT INTO "setting.user_role" (setting_user_id, setting_role_id, cid, ctime, mid, mtime) VALUES`)
+	for index, roleId := range roleIds {
+		bqbQuery.Space(`(?, ?, ?, ?, ?, ?)`, userId, roleId, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime)
+		if index != len(roleIds)-1 {
+			bqbQuery.Space(`,`)
+		}
+	}
+	bqbQuery.Space(`ON CONFLICT DO NOTHING`)
+
+	query, params, err := bqbQuery.ToPgsql()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(query, params...)
+	return err
+}
+
+func insertUserCompanies(tx *sql.Tx, userId interface{}, companyIds []uint, commonModel models.CommonModel) error {
+	bqbQuery := bqb.New(`INSERT INTO "setting.user_company" (setting_user_id, setting_company_id, cid, ctime, mid, mtime) VALUES`)
+	for index, companyId := range companyIds {
+		bqbQuery.Space(`(?, ?, ?, ?, ?, ?)`, userId, companyId, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime)
+		if index != len(companyIds)-1 {
+			bqbQuery.Space(`,`)
+		}
+	}
+	bqbQuery.Space(`ON CONFLICT DO NOTHING`)
+
+	query, params, err := bqbQuery.ToPgsql()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(query, params...)
+	return err
+}
+
+// revokeUserSessions revokes every session of the user that isn't revoked yet.
+func revokeUserSessions(tx *sql.Tx, userId interface{}, revokedAt time.Time) error {
+	query, params, err := bqb.New(`UPDATE "setting.session" SET revoked_at = ? WHERE setting_user_id = ? AND revoked_at IS NULL`, revokedAt, userId).ToPgsql()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(query, params...)
+	return err
 }
 
 func (service *SettingUserService) CreateUser(ctx *utils.CtxW, user *setting.SettingUserCreateRequest) (int, error) {
+	// -- Access is only given to the companies the user is allowed to access
+	if !ctx.AllowsCompanies(user.CompanyIds) {
+		return 403, utils.ErrCompanyNotAllowed
+	}
+
 	commonModel := models.CommonModel{}
 	commonModel.PrepareForCreate(ctx.User.Id, ctx.User.Id)
 
+	tx, err := service.DB.Begin()
+	if err != nil {
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	bqbQuery := bqb.New(`
 	INSERT INTO
 		"setting.user"
 		(name, email, setting_role_id, cid, ctime, mid, mtime)
 	VALUES
 		(?, ?, ?, ?, ?, ?, ?)
+	RETURNING id
 	`, user.Name, user.Email, user.RoleId, commonModel.CId, commonModel.CTime, commonModel.MId, commonModel.MTime)
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
+		tx.Rollback()
 		log.Printf("%s", err)
 		return 500, utils.ErrInternalServer
 	}
 
-	_, err = service.DB.Exec(query, params...)
+	var userId uint
+	err = tx.QueryRow(query, params...).Scan(&userId)
 	if err != nil {
+		tx.Rollback()
 		switch err.(*pq.Error).Constraint {
 		case database.FK_SETTING_ROLE_ID:
 			return 404, ErrRoleNotFound
@@ -221,10 +357,60 @@ func (service *SettingUserService) CreateUser(ctx *utils.CtxW, user *setting.Set
 		return 500, utils.ErrInternalServer
 	}
 
+	if len(user.RoleIds) > 0 {
+		if err := insertUserRoles(tx, userId, user.RoleIds, commonModel); err != nil {
+			tx.Rollback()
+			if err, ok := err.(*pq.Error); ok && err.Constraint == database.FK_SETTING_ROLE_ID {
+				return 404, ErrRoleNotFound
+			}
+
+			log.Printf("%s", err)
+			return 500, utils.ErrInternalServer
+		}
+	}
+
+	// -- Users are allowed to access the active company unless told otherwise
+	companyIds := user.CompanyIds
+	if len(companyIds) == 0 {
+		companyIds = []uint{ctx.Company.Id}
+	}
+	if err := insertUserCompanies(tx, userId, companyIds, commonModel); err != nil {
+		tx.Rollback()
+		if err, ok := err.(*pq.Error); ok && err.Constraint == database.FK_SETTING_COMPANY_ID {
+			return 404, ErrCompanyNotFound
+		}
+
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "setting.user", userId)
+	if err == nil {
+		err = ctx.Audit(tx, "setting.user", userId, nil, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
 	return 201, nil
 }
 
 func (service *SettingUserService) UpdateUser(ctx *utils.CtxW, id string, user *setting.SettingUserUpdateRequest) (int, error) {
+	// -- Access is only given to or taken from the companies the user is allowed to access
+	if (user.AddCompanyIds != nil && !ctx.AllowsCompanies(*user.AddCompanyIds)) || (user.RemoveCompanyIds != nil && !ctx.AllowsCompanies(*user.RemoveCompanyIds)) {
+		return 403, utils.ErrCompanyNotAllowed
+	}
+
 	commonModel := models.CommonModel{}
 	commonModel.PrepareForUpdate(ctx.User.Id)
 
@@ -249,7 +435,13 @@ func (service *SettingUserService) UpdateUser(ctx *utils.CtxW, id string, user *
 		}
 	}
 
+	// -- Leaving the active state signs the user out everywhere
+	if user.State != nil && *user.State != models.SettingUserStateActive {
+		bqbQuery.Comma("sessions_revoked_at = ?", commonModel.MTime)
+	}
+
 	bqbQuery.Space(`WHERE id = ?`, id)
+	user.PreconditionIntoBqb(bqbQuery)
 
 	query, params, err := bqbQuery.ToPgsql()
 	if err != nil {
@@ -257,8 +449,22 @@ func (service *SettingUserService) UpdateUser(ctx *utils.CtxW, id string, user *
 		return 500, utils.ErrInternalServer
 	}
 
-	result, err := service.DB.Exec(query, params...)
+	tx, err := service.DB.Begin()
+	if err != nil {
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	before, err := utils.AuditSnapshot(tx, "setting.user", id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	result, err := tx.Exec(query, params...)
 	if err != nil {
+		tx.Rollback()
 		switch err.(*pq.Error).Constraint {
 		case database.KEY_SETTING_USER_EMAIL:
 			return 409, ErrUserEmailExists
@@ -271,11 +477,176 @@ func (service *SettingUserService) UpdateUser(ctx *utils.CtxW, id string, user *
 	}
 
 	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		tx.Rollback()
 		if n == 0 {
+			if user.HasPrecondition() {
+				return 409, utils.ErrUpdateConflict
+			}
 			return 404, ErrUserNotFound
 		}
 		return 500, utils.ErrInternalServer
 	}
 
+	if user.AddRoleIds != nil && len(*user.AddRoleIds) > 0 {
+		createModel := models.CommonModel{}
+		createModel.PrepareForCreate(ctx.User.Id, ctx.User.Id)
+		if err := insertUserRoles(tx, id, *user.AddRoleIds, createModel); err != nil {
+			tx.Rollback()
+			if err, ok := err.(*pq.Error); ok && err.Constraint == database.FK_SETTING_ROLE_ID {
+				return 404, ErrRoleNotFound
+			}
+
+			log.Printf("%s", err)
+			return 500, utils.ErrInternalServer
+		}
+	}
+
+	if user.State != nil && *user.State != models.SettingUserStateActive {
+		if err := revokeUserSessions(tx, id, commonModel.MTime); err != nil {
+			tx.Rollback()
+			log.Printf("%s", err)
+			return 500, utils.ErrInternalServer
+		}
+	}
+
+	if user.RemoveRoleIds != nil && len(*user.RemoveRoleIds) > 0 {
+		query, params, err := bqb.New(`DELETE FROM "setting.user_role" WHERE setting_user_id = ? AND setting_role_id IN (?)`, id, *user.RemoveRoleIds).ToPgsql()
+		if err != nil {
+			tx.Rollback()
+			log.Printf("%s", err)
+			return 500, utils.ErrInternalServer
+		}
+
+		_, err = tx.Exec(query, params...)
+		if err != nil {
+			tx.Rollback()
+			log.Printf("%s", err)
+			return 500, utils.ErrInternalServer
+		}
+	}
+
+	if user.AddCompanyIds != nil && len(*user.AddCompanyIds) > 0 {
+		createModel := models.CommonModel{}
+		createModel.PrepareForCreate(ctx.User.Id, ctx.User.Id)
+		if err := insertUserCompanies(tx, id, *user.AddCompanyIds, createModel); err != nil {
+			tx.Rollback()
+			if err, ok := err.(*pq.Error); ok && err.Constraint == database.FK_SETTING_COMPANY_ID {
+				return 404, ErrCompanyNotFound
+			}
+
+			log.Printf("%s", err)
+			return 500, utils.ErrInternalServer
+		}
+	}
+
+	if user.RemoveCompanyIds != nil && len(*user.RemoveCompanyIds) > 0 {
+		query, params, err := bqb.New(`DELETE FROM "setting.user_company" WHERE setting_user_id = ? AND setting_company_id IN (?)`, id, *user.RemoveCompanyIds).ToPgsql()
+		if err != nil {
+			tx.Rollback()
+			log.Printf("%s", err)
+			return 500, utils.ErrInternalServer
+		}
+
+		_, err = tx.Exec(query, params...)
+		if err != nil {
+			tx.Rollback()
+			log.Printf("%s", err)
+			return 500, utils.ErrInternalServer
+		}
+	}
+
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "setting.user", id)
+	if err == nil {
+		err = ctx.Audit(tx, "setting.user", id, before, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+	utils.GetPrincipalCache().InvalidateUser(uint(utils.StrToInt(id, 0)))
+
 	return 200, nil
 }
+
+// DeleteUser archives the user instead of deleting it, so that the user stays
+// resolvable as the author of records.
+func (service *SettingUserService) DeleteUser(ctx *utils.CtxW, id string) (int, error) {
+	commonModel := models.CommonModel{}
+	commonModel.PrepareForUpdate(ctx.User.Id)
+
+	query, params, err := bqb.New(`
+	UPDATE "setting.user" SET
+		state = ?,
+		sessions_revoked_at = ?,
+		mid = ?,
+		mtime = ?
+	WHERE id = ? AND state != ?`, models.SettingUserStateArchived, commonModel.MTime, commonModel.MId, commonModel.MTime, id, models.SettingUserStateArchived).ToPgsql()
+	if err != nil {
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	tx, err := service.DB.Begin()
+	if err != nil {
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	before, err := utils.AuditSnapshot(tx, "setting.user", id)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	result, err := tx.Exec(query, params...)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		tx.Rollback()
+		if n == 0 {
+			return 404, ErrUserNotFound
+		}
+		return 500, utils.ErrInternalServer
+	}
+
+	if err := revokeUserSessions(tx, id, commonModel.MTime); err != nil {
+		tx.Rollback()
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	// -- Audit
+	after, err := utils.AuditSnapshot(tx, "setting.user", id)
+	if err == nil {
+		err = ctx.Audit(tx, "setting.user", id, before, after)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+		log.Printf("%s", err)
+		return 500, utils.ErrInternalServer
+	}
+	utils.GetPrincipalCache().InvalidateUser(uint(utils.StrToInt(id, 0)))
+
+	return 204, nil
+}
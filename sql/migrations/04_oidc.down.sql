@@ -0,0 +1,5 @@
+ALTER TABLE "setting.user"
+DROP CONSTRAINT IF EXISTS "setting.user_oidc_sub_key";
+
+ALTER TABLE "setting.user"
+DROP COLUMN IF EXISTS oidc_sub;
\ No newline at end of file
@@ -0,0 +1,9 @@
+ALTER TABLE "setting.user"
+ADD COLUMN IF NOT EXISTS oidc_sub VARCHAR(256);
+
+DO $$ BEGIN
+ALTER TABLE "setting.user"
+ADD CONSTRAINT "setting.user_oidc_sub_key" UNIQUE (oidc_sub);
+EXCEPTION
+WHEN duplicate_table THEN null;
+END $$;
\ No newline at end of file
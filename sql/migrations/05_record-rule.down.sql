@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "setting.record_rule";
\ No newline at end of file
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS
+    "setting.record_rule" (
+        id BIGINT GENERATED BY DEFAULT AS IDENTITY (
+            START
+            WITH
+                1000
+        ) PRIMARY KEY,
+        setting_role_id BIGINT NOT NULL,
+        resource VARCHAR(64) NOT NULL,
+        field VARCHAR(64) NOT NULL,
+        operator VARCHAR(8) NOT NULL,
+        value VARCHAR(256) NOT NULL,
+        -- Timestamps
+        cid BIGINT NOT NULL,
+        ctime TIMESTAMP WITH TIME ZONE NOT NULL,
+        mid BIGINT NOT NULL,
+        mtime TIMESTAMP WITH TIME ZONE NOT NULL,
+        CONSTRAINT "setting.record_rule_role_id_fkey" FOREIGN KEY (setting_role_id) REFERENCES "setting.role" (id) ON DELETE RESTRICT
+    );
+
+CREATE INDEX IF NOT EXISTS "setting.record_rule_role_id_idx" ON "setting.record_rule" (setting_role_id);
\ No newline at end of file
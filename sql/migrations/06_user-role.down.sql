@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "setting.user_role";
\ No newline at end of file
@@ -0,0 +1,9 @@
+DELETE FROM "setting.role_permission"
+WHERE
+    setting_permission_id = 44;
+
+DELETE FROM "setting.permission"
+WHERE
+    id = 44;
+
+DROP TABLE IF EXISTS "setting.audit_log";
\ No newline at end of file
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "setting.audit_log_act_id_idx";
+
+ALTER TABLE "setting.audit_log"
+DROP COLUMN IF EXISTS act_id;
\ No newline at end of file
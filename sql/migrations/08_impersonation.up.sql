@@ -0,0 +1,4 @@
+ALTER TABLE "setting.audit_log"
+ADD COLUMN IF NOT EXISTS act_id BIGINT;
+
+CREATE INDEX IF NOT EXISTS "setting.audit_log_act_id_idx" ON "setting.audit_log" (act_id);
\ No newline at end of file
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS "setting.user_state_idx";
+
+ALTER TABLE "setting.user"
+DROP COLUMN IF EXISTS sessions_revoked_at,
+DROP COLUMN IF EXISTS state;
+
+DROP TYPE IF EXISTS setting_user_state_typ;
\ No newline at end of file
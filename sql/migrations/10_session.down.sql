@@ -0,0 +1,11 @@
+DELETE FROM "setting.role_permission"
+WHERE
+    setting_permission_id IN (45, 46, 47);
+
+DELETE FROM "setting.permission"
+WHERE
+    id IN (45, 46, 47);
+
+DROP TABLE IF EXISTS "setting.login_event";
+
+DROP TABLE IF EXISTS "setting.session";
\ No newline at end of file
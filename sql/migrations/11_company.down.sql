@@ -0,0 +1,103 @@
+DELETE FROM "setting.role_permission"
+WHERE
+    setting_permission_id IN (48, 49, 50, 51);
+
+DELETE FROM "setting.permission"
+WHERE
+    id IN (48, 49, 50, 51);
+
+DROP PROCEDURE IF EXISTS create_sales_order (VARCHAR, TIMESTAMP WITH TIME ZONE, TEXT, BIGINT, BIGINT, BIGINT, BIGINT, TIMESTAMP WITH TIME ZONE, BIGINT, TIMESTAMP WITH TIME ZONE);
+
+CREATE
+OR REPLACE PROCEDURE create_sales_order (
+    name VARCHAR(64),
+    commitment_date TIMESTAMP WITH TIME ZONE,
+    note TEXT,
+    sales_quotation_id BIGINT,
+    accounting_payment_term_id BIGINT,
+    cid BIGINT,
+    ctime TIMESTAMP WITH TIME ZONE,
+    mid BIGINT,
+    mtime TIMESTAMP WITH TIME ZONE
+) LANGUAGE plpgsql AS $$
+DECLARE
+    s_quotation_status sales_quotation_status_typ := 'quotation';
+
+BEGIN
+
+    -- Get sales quotation status
+    SELECT
+        "sales.quotation".status INTO s_quotation_status
+    FROM
+        "sales.quotation"
+    WHERE
+        "sales.quotation".id = sales_quotation_id;
+
+    -- Create sales order if sales quotation is in sales order status
+    IF s_quotation_status = 'sales_order' THEN
+        INSERT INTO
+            "sales.order"
+            (name, commitment_date, note, sales_quotation_id, accounting_payment_term_id, cid, ctime, mid, mtime)
+        VALUES
+            (name, commitment_date, note, sales_quotation_id, accounting_payment_term_id, cid, ctime, mid, mtime);
+    ELSE
+        RAISE EXCEPTION 'custom_error:sales quotation is not in sales_order status';
+    END IF;
+
+END;
+
+$$;
+
+ALTER TABLE "accounting.journal_entry"
+DROP CONSTRAINT "accounting.journal_id_fkey",
+ADD CONSTRAINT "accounting.journal_id_fkey" FOREIGN KEY (accounting_journal_id) REFERENCES "accounting.journal" (id) ON DELETE RESTRICT,
+DROP CONSTRAINT "accounting.journal_entry_name_key",
+ADD CONSTRAINT "accounting.journal_entry_name_key" UNIQUE (name),
+DROP COLUMN IF EXISTS setting_company_id;
+
+ALTER TABLE "accounting.journal"
+DROP CONSTRAINT "accounting.account_id_fkey",
+ADD CONSTRAINT "accounting.account_id_fkey" FOREIGN KEY (accounting_account_id) REFERENCES "accounting.account" (id) ON DELETE RESTRICT,
+DROP CONSTRAINT "accounting.journal_id_company_id_key",
+DROP CONSTRAINT "accounting.journal_code_key",
+ADD CONSTRAINT "accounting.journal_code_key" UNIQUE (code),
+DROP COLUMN IF EXISTS setting_company_id;
+
+ALTER TABLE "sales.order"
+DROP CONSTRAINT "accounting.payment_term_id_fkey",
+ADD CONSTRAINT "accounting.payment_term_id_fkey" FOREIGN KEY (accounting_payment_term_id) REFERENCES "accounting.payment_term" (id) ON DELETE RESTRICT,
+DROP CONSTRAINT "sales.quotation_id_fkey",
+ADD CONSTRAINT "sales.quotation_id_fkey" FOREIGN KEY (sales_quotation_id) REFERENCES "sales.quotation" (id) ON DELETE RESTRICT,
+DROP CONSTRAINT "sales.order_name_key",
+ADD CONSTRAINT "sales.order_name_key" UNIQUE (name),
+DROP COLUMN IF EXISTS setting_company_id;
+
+ALTER TABLE "sales.quotation"
+DROP CONSTRAINT "setting.customer_id_fkey",
+ADD CONSTRAINT "setting.customer_id_fkey" FOREIGN KEY (setting_customer_id) REFERENCES "setting.customer" (id) ON DELETE RESTRICT,
+DROP CONSTRAINT "sales.quotation_id_company_id_key",
+DROP CONSTRAINT "sales.quotation_name_key",
+ADD CONSTRAINT "sales.quotation_name_key" UNIQUE (name),
+DROP COLUMN IF EXISTS setting_company_id;
+
+ALTER TABLE "accounting.account"
+DROP CONSTRAINT "accounting.account_id_company_id_key",
+DROP CONSTRAINT "accounting.account_code_key",
+ADD CONSTRAINT "accounting.account_code_key" UNIQUE (code),
+DROP COLUMN IF EXISTS setting_company_id;
+
+ALTER TABLE "accounting.payment_term"
+DROP CONSTRAINT "accounting.payment_term_id_company_id_key",
+DROP CONSTRAINT "accounting.payment_term_name_key",
+ADD CONSTRAINT "accounting.payment_term_name_key" UNIQUE (name),
+DROP COLUMN IF EXISTS setting_company_id;
+
+ALTER TABLE "setting.customer"
+DROP CONSTRAINT "setting.customer_id_company_id_key",
+DROP CONSTRAINT "setting.customer_email_key",
+ADD CONSTRAINT "setting.customer_email_key" UNIQUE (email),
+DROP COLUMN IF EXISTS setting_company_id;
+
+DROP TABLE IF EXISTS "setting.user_company";
+
+DROP TABLE IF EXISTS "setting.company";
\ No newline at end of file
@@ -0,0 +1,151 @@
+CREATE TABLE IF NOT EXISTS
+    "setting.company" (
+        id BIGINT GENERATED BY DEFAULT AS IDENTITY (
+            START
+            WITH
+                1000
+        ) PRIMARY KEY,
+        name VARCHAR(64) NOT NULL UNIQUE,
+        -- Timestamps
+        cid BIGINT NOT NULL,
+        ctime TIMESTAMP WITH TIME ZONE NOT NULL,
+        mid BIGINT NOT NULL,
+        mtime TIMESTAMP WITH TIME ZONE NOT NULL
+    );
+
+INSERT INTO
+    "setting.company" (id, name, cid, ctime, mid, mtime)
+VALUES
+    (1, 'Default', 1, NOW(), 1, NOW());
+
+CREATE TABLE IF NOT EXISTS
+    "setting.user_company" (
+        setting_user_id BIGINT NOT NULL,
+        setting_company_id BIGINT NOT NULL,
+        -- Timestamps
+        cid BIGINT NOT NULL,
+        ctime TIMESTAMP WITH TIME ZONE NOT NULL,
+        mid BIGINT NOT NULL,
+        mtime TIMESTAMP WITH TIME ZONE NOT NULL,
+        PRIMARY KEY (setting_user_id, setting_company_id),
+        CONSTRAINT "setting.user_id_fkey" FOREIGN KEY (setting_user_id) REFERENCES "setting.user" (id) ON DELETE CASCADE,
+        CONSTRAINT "setting.company_id_fkey" FOREIGN KEY (setting_company_id) REFERENCES "setting.company" (id) ON DELETE RESTRICT
+    );
+
+INSERT INTO
+    "setting.user_company" (setting_user_id, setting_company_id, cid, ctime, mid, mtime)
+SELECT
+    id, 1, 1, NOW(), 1, NOW()
+FROM
+    "setting.user";
+
+-- Existing records belong to the default company
+ALTER TABLE "setting.customer"
+ADD COLUMN IF NOT EXISTS setting_company_id BIGINT NOT NULL DEFAULT 1,
+ADD CONSTRAINT "setting.company_id_fkey" FOREIGN KEY (setting_company_id) REFERENCES "setting.company" (id) ON DELETE RESTRICT,
+DROP CONSTRAINT "setting.customer_email_key",
+ADD CONSTRAINT "setting.customer_email_key" UNIQUE (setting_company_id, email),
+ADD CONSTRAINT "setting.customer_id_company_id_key" UNIQUE (id, setting_company_id);
+
+ALTER TABLE "accounting.payment_term"
+ADD COLUMN IF NOT EXISTS setting_company_id BIGINT NOT NULL DEFAULT 1,
+ADD CONSTRAINT "setting.company_id_fkey" FOREIGN KEY (setting_company_id) REFERENCES "setting.company" (id) ON DELETE RESTRICT,
+DROP CONSTRAINT "accounting.payment_term_name_key",
+ADD CONSTRAINT "accounting.payment_term_name_key" UNIQUE (setting_company_id, name),
+ADD CONSTRAINT "accounting.payment_term_id_company_id_key" UNIQUE (id, setting_company_id);
+
+ALTER TABLE "accounting.account"
+ADD COLUMN IF NOT EXISTS setting_company_id BIGINT NOT NULL DEFAULT 1,
+ADD CONSTRAINT "setting.company_id_fkey" FOREIGN KEY (setting_company_id) REFERENCES "setting.company" (id) ON DELETE RESTRICT,
+DROP CONSTRAINT "accounting.account_code_key",
+ADD CONSTRAINT "accounting.account_code_key" UNIQUE (setting_company_id, code),
+ADD CONSTRAINT "accounting.account_id_company_id_key" UNIQUE (id, setting_company_id);
+
+-- References must stay within the company of the record
+ALTER TABLE "sales.quotation"
+ADD COLUMN IF NOT EXISTS setting_company_id BIGINT NOT NULL DEFAULT 1,
+ADD CONSTRAINT "setting.company_id_fkey" FOREIGN KEY (setting_company_id) REFERENCES "setting.company" (id) ON DELETE RESTRICT,
+DROP CONSTRAINT "sales.quotation_name_key",
+ADD CONSTRAINT "sales.quotation_name_key" UNIQUE (setting_company_id, name),
+ADD CONSTRAINT "sales.quotation_id_company_id_key" UNIQUE (id, setting_company_id),
+DROP CONSTRAINT "setting.customer_id_fkey",
+ADD CONSTRAINT "setting.customer_id_fkey" FOREIGN KEY (setting_customer_id, setting_company_id) REFERENCES "setting.customer" (id, setting_company_id) ON DELETE RESTRICT;
+
+ALTER TABLE "sales.order"
+ADD COLUMN IF NOT EXISTS setting_company_id BIGINT NOT NULL DEFAULT 1,
+ADD CONSTRAINT "setting.company_id_fkey" FOREIGN KEY (setting_company_id) REFERENCES "setting.company" (id) ON DELETE RESTRICT,
+DROP CONSTRAINT "sales.order_name_key",
+ADD CONSTRAINT "sales.order_name_key" UNIQUE (setting_company_id, name),
+DROP CONSTRAINT "sales.quotation_id_fkey",
+ADD CONSTRAINT "sales.quotation_id_fkey" FOREIGN KEY (sales_quotation_id, setting_company_id) REFERENCES "sales.quotation" (id, setting_company_id) ON DELETE RESTRICT,
+DROP CONSTRAINT "accounting.payment_term_id_fkey",
+ADD CONSTRAINT "accounting.payment_term_id_fkey" FOREIGN KEY (accounting_payment_term_id, setting_company_id) REFERENCES "accounting.payment_term" (id, setting_company_id) ON DELETE RESTRICT;
+
+ALTER TABLE "accounting.journal"
+ADD COLUMN IF NOT EXISTS setting_company_id BIGINT NOT NULL DEFAULT 1,
+ADD CONSTRAINT "setting.company_id_fkey" FOREIGN KEY (setting_company_id) REFERENCES "setting.company" (id) ON DELETE RESTRICT,
+DROP CONSTRAINT "accounting.journal_code_key",
+ADD CONSTRAINT "accounting.journal_code_key" UNIQUE (setting_company_id, code),
+ADD CONSTRAINT "accounting.journal_id_company_id_key" UNIQUE (id, setting_company_id),
+DROP CONSTRAINT "accounting.account_id_fkey",
+ADD CONSTRAINT "accounting.account_id_fkey" FOREIGN KEY (accounting_account_id, setting_company_id) REFERENCES "accounting.account" (id, setting_company_id) ON DELETE RESTRICT;
+
+ALTER TABLE "accounting.journal_entry"
+ADD COLUMN IF NOT EXISTS setting_company_id BIGINT NOT NULL DEFAULT 1,
+ADD CONSTRAINT "setting.company_id_fkey" FOREIGN KEY (setting_company_id) REFERENCES "setting.company" (id) ON DELETE RESTRICT,
+DROP CONSTRAINT "accounting.journal_entry_name_key",
+ADD CONSTRAINT "accounting.journal_entry_name_key" UNIQUE (setting_company_id, name),
+DROP CONSTRAINT "accounting.journal_id_fkey",
+ADD CONSTRAINT "accounting.journal_id_fkey" FOREIGN KEY (accounting_journal_id, setting_company_id) REFERENCES "accounting.journal" (id, setting_company_id) ON DELETE RESTRICT;
+
+DROP PROCEDURE IF EXISTS create_sales_order (VARCHAR, TIMESTAMP WITH TIME ZONE, TEXT, BIGINT, BIGINT, BIGINT, TIMESTAMP WITH TIME ZONE, BIGINT, TIMESTAMP WITH TIME ZONE);
+
+CREATE
+OR REPLACE PROCEDURE create_sales_order (
+    name VARCHAR(64),
+    commitment_date TIMESTAMP WITH TIME ZONE,
+    note TEXT,
+    sales_quotation_id BIGINT,
+    accounting_payment_term_id BIGINT,
+    setting_company_id BIGINT,
+    cid BIGINT,
+    ctime TIMESTAMP WITH TIME ZONE,
+    mid BIGINT,
+    mtime TIMESTAMP WITH TIME ZONE
+) LANGUAGE plpgsql AS $$
+DECLARE
+    s_quotation_status sales_quotation_status_typ := 'quotation';
+
+BEGIN
+
+    -- Get sales quotation status, the quotation must belong to the company
+    SELECT
+        "sales.quotation".status INTO s_quotation_status
+    FROM
+        "sales.quotation"
+    WHERE
+        "sales.quotation".id = sales_quotation_id
+        AND "sales.quotation".setting_company_id = create_sales_order.setting_company_id;
+
+    -- Create sales order if sales quotation is in sales order status
+    IF s_quotation_status = 'sales_order' THEN
+        INSERT INTO
+            "sales.order"
+            (name, commitment_date, note, sales_quotation_id, accounting_payment_term_id, setting_company_id, cid, ctime, mid, mtime)
+        VALUES
+            (name, commitment_date, note, sales_quotation_id, accounting_payment_term_id, setting_company_id, cid, ctime, mid, mtime);
+    ELSE
+        RAISE EXCEPTION 'custom_error:sales quotation is not in sales_order status';
+    END IF;
+
+END;
+
+$$;
+
+INSERT INTO
+    "setting.permission" (id, name, cid, ctime, mid, mtime)
+VALUES
+    (48, 'VIEW_SETTING_COMPANIES', 1, NOW(), 1, NOW()),
+    (49, 'CREATE_SETTING_COMPANIES', 1, NOW(), 1, NOW()),
+    (50, 'UPDATE_SETTING_COMPANIES', 1, NOW(), 1, NOW()),
+    (51, 'DELETE_SETTING_COMPANIES', 1, NOW(), 1, NOW());
\ No newline at end of file
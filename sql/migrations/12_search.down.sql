@@ -0,0 +1,39 @@
+DROP INDEX IF EXISTS "setting.customer_search_vector_idx";
+
+DROP INDEX IF EXISTS "setting.customer_search_text_idx";
+
+DROP INDEX IF EXISTS "sales.quotation_search_vector_idx";
+
+DROP INDEX IF EXISTS "sales.quotation_search_text_idx";
+
+DROP INDEX IF EXISTS "sales.order_search_vector_idx";
+
+DROP INDEX IF EXISTS "sales.order_search_text_idx";
+
+DROP INDEX IF EXISTS "accounting.account_search_vector_idx";
+
+DROP INDEX IF EXISTS "accounting.account_search_text_idx";
+
+DROP INDEX IF EXISTS "accounting.journal_entry_search_vector_idx";
+
+DROP INDEX IF EXISTS "accounting.journal_entry_search_text_idx";
+
+ALTER TABLE "setting.customer"
+DROP COLUMN IF EXISTS search_vector,
+DROP COLUMN IF EXISTS search_text;
+
+ALTER TABLE "sales.quotation"
+DROP COLUMN IF EXISTS search_vector,
+DROP COLUMN IF EXISTS search_text;
+
+ALTER TABLE "sales.order"
+DROP COLUMN IF EXISTS search_vector,
+DROP COLUMN IF EXISTS search_text;
+
+ALTER TABLE "accounting.account"
+DROP COLUMN IF EXISTS search_vector,
+DROP COLUMN IF EXISTS search_text;
+
+ALTER TABLE "accounting.journal_entry"
+DROP COLUMN IF EXISTS search_vector,
+DROP COLUMN IF EXISTS search_text;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "setting.saved_view_endpoint_idx";
+
+DROP TABLE IF EXISTS "setting.saved_view";
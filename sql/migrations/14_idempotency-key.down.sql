@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "setting.idempotency_key_expires_at_idx";
+
+DROP TABLE IF EXISTS "setting.idempotency_key";
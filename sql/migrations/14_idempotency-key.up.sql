@@ -0,0 +1,12 @@
+-- The first response of a create request, replayed on the retries of its key
+CREATE TABLE IF NOT EXISTS
+    "setting.idempotency_key" (
+        key CHAR(64) PRIMARY KEY,
+        fingerprint CHAR(64) NOT NULL,
+        -- 0 while the first request is processed
+        status_code INT NOT NULL DEFAULT 0,
+        response BYTEA,
+        expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+    );
+
+CREATE INDEX IF NOT EXISTS "setting.idempotency_key_expires_at_idx" ON "setting.idempotency_key" (expires_at);
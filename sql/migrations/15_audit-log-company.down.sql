@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "setting.audit_log_company_id_idx";
+
+ALTER TABLE "setting.audit_log"
+DROP CONSTRAINT IF EXISTS "setting.company_id_fkey",
+DROP COLUMN IF EXISTS setting_company_id;
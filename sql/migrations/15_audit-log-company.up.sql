@@ -0,0 +1,6 @@
+-- Existing audit logs belong to the default company
+ALTER TABLE "setting.audit_log"
+ADD COLUMN IF NOT EXISTS setting_company_id BIGINT NOT NULL DEFAULT 1,
+ADD CONSTRAINT "setting.company_id_fkey" FOREIGN KEY (setting_company_id) REFERENCES "setting.company" (id) ON DELETE RESTRICT;
+
+CREATE INDEX IF NOT EXISTS "setting.audit_log_company_id_idx" ON "setting.audit_log" (setting_company_id);
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nullism/bqb"
+)
+
+// -- Aggregation of a list, e.g. group=status,creation_date:month&aggregate=count,sum:total_amount
+const (
+	AGGREGATE_GROUP_PARAM = "group"
+	AGGREGATE_PARAM       = "aggregate"
+)
+
+// -- Maximum number of groups returned by an aggregation
+var MAX_AGGREGATE_GROUPS = 1000
+
+var ALLOWED_AGGREGATE_FUNCTIONS = []string{"count", "sum", "avg"}
+
+// -- Truncations of a date group field, see date_trunc
+var ALLOWED_DATE_TRUNCATIONS = []string{"day", "week", "month", "quarter", "year"}
+
+// -- Suffix marking a group field as a date that can be truncated, e.g. creation_date:date
+const AGGREGATE_DATE_FIELD_SUFFIX = ":date"
+
+type GroupValue struct {
+	Field      string
+	Truncation string
+}
+
+type AggregateValue struct {
+	Function string
+	Field    string
+}
+
+// Name is the key of the aggregate in the result, e.g. sum_total_amount.
+func (aggregate AggregateValue) Name() string {
+	if aggregate.Field == "" {
+		return aggregate.Function
+	}
+	return fmt.Sprintf("%s_%s", aggregate.Function, aggregate.Field)
+}
+
+// PrepareAggregate prepares the groups and aggregates of resource, it must be
+// called after PrepareFilters. allowGroupFields are the fields the records
+// can be grouped by, a date field ends with :date and can be truncated.
+// allowAggregateFields are the fields that can be summed and averaged,
+// count is always allowed and is the default aggregate.
+func (qp *QueryParams) PrepareAggregate(c *gin.Context, allowGroupFields []string, allowAggregateFields []string) *QueryParams {
+	qp.knownParams = append(qp.knownParams, AGGREGATE_GROUP_PARAM, AGGREGATE_PARAM)
+	qp.AllowedGroups = allowGroupFields
+	qp.AllowedAggregates = allowAggregateFields
+
+	if groups, ok := c.GetQuery(AGGREGATE_GROUP_PARAM); ok {
+		for _, group := range splitList(groups) {
+			field, truncation, hasTruncation := strings.Cut(group, ":")
+			isDate := ContainsString(allowGroupFields, field+AGGREGATE_DATE_FIELD_SUFFIX)
+			switch {
+			case !isDate && (hasTruncation || !ContainsString(allowGroupFields, field)):
+				qp.addError(AGGREGATE_GROUP_PARAM, fmt.Sprintf("group %s is not allowed", group))
+				continue
+			case hasTruncation && !ContainsString(ALLOWED_DATE_TRUNCATIONS, truncation):
+				qp.addError(AGGREGATE_GROUP_PARAM, fmt.Sprintf("truncation of %s must be one of %s", field, strings.Join(ALLOWED_DATE_TRUNCATIONS, ", ")))
+				continue
+			case slices.ContainsFunc(qp.Groups, func(value GroupValue) bool { return value.Field == field }):
+				qp.addError(AGGREGATE_GROUP_PARAM, fmt.Sprintf("group %s is repeated", field))
+				continue
+			}
+			qp.Groups = append(qp.Groups, GroupValue{Field: field, Truncation: truncation})
+		}
+	}
+
+	aggregates := "count"
+	if value, ok := c.GetQuery(AGGREGATE_PARAM); ok {
+		aggregates = value
+	}
+	for _, aggregate := range splitList(aggregates) {
+		function, field, _ := strings.Cut(aggregate, ":")
+		switch {
+		case !ContainsString(ALLOWED_AGGREGATE_FUNCTIONS, function):
+			qp.addError(AGGREGATE_PARAM, fmt.Sprintf("aggregate function must be one of %s", strings.Join(ALLOWED_AGGREGATE_FUNCTIONS, ", ")))
+			continue
+		case field == "" && function != "count":
+			qp.addError(AGGREGATE_PARAM, fmt.Sprintf("%s requires a field", function))
+			continue
+		case field != "" && !ContainsString(allowAggregateFields, field):
+			qp.addError(AGGREGATE_PARAM, fmt.Sprintf("aggregate %s is not allowed", aggregate))
+			continue
+		}
+		qp.Aggregates = append(qp.Aggregates, AggregateValue{Function: function, Field: field})
+	}
+	if len(qp.Aggregates) == 0 && len(qp.Errors) == 0 {
+		qp.addError(AGGREGATE_PARAM, "at least one aggregate is required")
+	}
+	return qp
+}
+
+// AggregateIntoBqb selects the groups and aggregates of the filtered records
+// of prefix, the columns are the groups followed by the aggregates.
+func (qp *QueryParams) AggregateIntoBqb(prefix string) *bqb.Query {
+	resource := prefixResource(prefix)
+
+	columns := make([]string, 0, len(qp.Groups)+len(qp.Aggregates))
+	positions := make([]string, 0, len(qp.Groups))
+	for index, group := range qp.Groups {
+		expression, _ := FieldExpression(resource, prefix, group.Field)
+		if group.Truncation != "" {
+			expression = fmt.Sprintf("date_trunc('%s', %s)", group.Truncation, expression)
+		}
+		columns = append(columns, fmt.Sprintf(`%s AS "%s"`, expression, group.Field))
+		positions = append(positions, fmt.Sprintf("%d", index+1))
+	}
+	for _, aggregate := range qp.Aggregates {
+		expression := "*"
+		if aggregate.Field != "" {
+			expression, _ = FieldExpression(resource, prefix, aggregate.Field)
+		}
+		columns = append(columns, fmt.Sprintf(`%s(%s) AS "%s"`, strings.ToUpper(aggregate.Function), expression, aggregate.Name()))
+	}
+
+	bqbQuery := bqb.New(fmt.Sprintf("SELECT %s FROM %s", strings.Join(columns, ", "), prefix))
+	qp.FilterIntoBqb(bqbQuery)
+	if len(positions) > 0 {
+		bqbQuery.Space(fmt.Sprintf("GROUP BY %s ORDER BY %s", strings.Join(positions, ", "), strings.Join(positions, ", ")))
+	}
+	bqbQuery.Space("LIMIT ?", MAX_AGGREGATE_GROUPS)
+	return bqbQuery
+}
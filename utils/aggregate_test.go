@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func prepareAggregateQueryParams(target string) *QueryParams {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", target, nil)
+
+	return NewQueryParams().
+		PrepareFilters(c, []string{"status:eq"}, `"sales.quotation"`).
+		PrepareAggregate(c, []string{"status", "creation_date:date", "customer.fullname"}, []string{"total_amount", "discount"})
+}
+
+func TestAggregate(t *testing.T) {
+	t.Run("AggregateIntoBqb", func(t *testing.T) {
+		qp := prepareAggregateQueryParams("/?status:eq=quotation&group=creation_date:month,customer.fullname&aggregate=count,avg:discount")
+		assert.Empty(t, qp.Errors)
+
+		query, params, err := qp.AggregateIntoBqb(`"sales.quotation"`).ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT date_trunc('month', "sales.quotation".creation_date) AS "creation_date", (SELECT "setting.customer".fullname FROM "setting.customer" WHERE "setting.customer".id = "sales.quotation".setting_customer_id) AS "customer.fullname", COUNT(*) AS "count", AVG("sales.quotation".discount) AS "avg_discount" FROM "sales.quotation" WHERE "sales.quotation".status = $1 GROUP BY 1, 2 ORDER BY 1, 2 LIMIT $2`, query)
+		assert.Equal(t, []interface{}{"quotation", MAX_AGGREGATE_GROUPS}, params)
+	})
+
+	t.Run("DefaultAggregate", func(t *testing.T) {
+		qp := prepareAggregateQueryParams("/")
+		assert.Equal(t, []AggregateValue{{Function: "count"}}, qp.Aggregates)
+
+		query, _, err := qp.AggregateIntoBqb(`"sales.quotation"`).ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT COUNT(*) AS "count" FROM "sales.quotation" LIMIT $1`, query)
+	})
+
+	t.Run("InvalidAggregate", func(t *testing.T) {
+		qp := prepareAggregateQueryParams("/?group=status:month,creation_date:hour,name,status,status&aggregate=sum,max:total_amount,sum:name")
+		assert.Equal(t, []QueryParamError{
+			{"group", "group status:month is not allowed"},
+			{"group", "truncation of creation_date must be one of day, week, month, quarter, year"},
+			{"group", "group name is not allowed"},
+			{"group", "group status is repeated"},
+			{"aggregate", "sum requires a field"},
+			{"aggregate", "aggregate function must be one of count, sum, avg"},
+			{"aggregate", "aggregate sum:name is not allowed"},
+		}, qp.Errors)
+	})
+}
@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"system.buon18.com/m/models"
+
+	"github.com/nullism/bqb"
+)
+
+var (
+	ErrUnknownAuditResource = errors.New("unknown audit resource")
+)
+
+const (
+	AUDIT_ACTION_CREATE = "create"
+	AUDIT_ACTION_UPDATE = "update"
+	AUDIT_ACTION_DELETE = "delete"
+	// -- Actions without field changes
+	AUDIT_ACTION_IMPERSONATE = "impersonate"
+
+	AUDIT_MASKED_VALUE = "********"
+)
+
+// -- Fields that change on every mutation, are derived or must never be written to the audit log
+var AUDIT_IGNORED_FIELDS = []string{"cid", "ctime", "mid", "mtime", "search_vector", "search_text"}
+var AUDIT_MASKED_FIELDS = []string{"pwd"}
+
+// Querier is implemented by both *sql.DB and *sql.Tx, so a snapshot can be
+// taken inside the transaction of the mutation.
+type Querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type AuditChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// AuditSnapshot returns the record with its children as a map of field to
+// value, nil is returned when the record doesn't exist.
+func AuditSnapshot(q Querier, resource string, id interface{}) (map[string]interface{}, error) {
+	auditResource, ok := models.AUDIT_RESOURCES[resource]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAuditResource, resource)
+	}
+
+	bqbQuery := bqb.New(`SELECT to_jsonb("record")`)
+	for _, child := range auditResource.Children {
+		value := `to_jsonb("child")`
+		for _, field := range AUDIT_IGNORED_FIELDS {
+			value += fmt.Sprintf(` - '%s'`, field)
+		}
+		orderBy := `"child".id`
+		if child.Column != "" {
+			value = fmt.Sprintf(`"child".%s`, child.Column)
+			orderBy = value
+		}
+
+		bqbQuery.Space(fmt.Sprintf(`|| jsonb_build_object('%s', COALESCE((SELECT jsonb_agg(%s ORDER BY %s) FROM "%s" AS "child" WHERE "child".%s = "record".id), '[]'::jsonb))`, child.Name, value, orderBy, child.Table, child.ForeignKey))
+	}
+	bqbQuery.Space(fmt.Sprintf(`FROM "%s" AS "record" WHERE "record".id = ?`, auditResource.Table), id)
+
+	query, params, err := bqbQuery.ToPgsql()
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot []byte
+	err = q.QueryRow(query, params...).Scan(&snapshot)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	record := map[string]interface{}{}
+	if err := json.Unmarshal(snapshot, &record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// AuditDiff returns the fields that differ between before and after, either
+// may be nil for a created or deleted record.
+func AuditDiff(before map[string]interface{}, after map[string]interface{}) map[string]AuditChange {
+	changes := map[string]AuditChange{}
+	fields := map[string]bool{}
+	for field := range before {
+		fields[field] = true
+	}
+	for field := range after {
+		fields[field] = true
+	}
+
+	for field := range fields {
+		if ContainsString(AUDIT_IGNORED_FIELDS, field) {
+			continue
+		}
+
+		oldValue, newValue := before[field], after[field]
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+
+		if ContainsString(AUDIT_MASKED_FIELDS, field) {
+			if oldValue != nil {
+				oldValue = AUDIT_MASKED_VALUE
+			}
+			if newValue != nil {
+				newValue = AUDIT_MASKED_VALUE
+			}
+		}
+		changes[field] = AuditChange{Old: oldValue, New: newValue}
+	}
+	return changes
+}
+
+// Audit records the mutation of a record, the action is derived from the
+// snapshots. Nothing is recorded when an update didn't change any field.
+func (ctx *CtxW) Audit(q Querier, resource string, id interface{}, before map[string]interface{}, after map[string]interface{}) error {
+	action := AUDIT_ACTION_UPDATE
+	if before == nil {
+		action = AUDIT_ACTION_CREATE
+	} else if after == nil {
+		action = AUDIT_ACTION_DELETE
+	}
+
+	changes := AuditDiff(before, after)
+	if action == AUDIT_ACTION_UPDATE && len(changes) == 0 {
+		return nil
+	}
+
+	changesByte, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	return ctx.insertAuditLog(q, resource, id, action, string(changesByte))
+}
+
+// AuditEvent records an action on a record that doesn't change any field.
+func (ctx *CtxW) AuditEvent(q Querier, resource string, id interface{}, action string) error {
+	if _, ok := models.AUDIT_RESOURCES[resource]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownAuditResource, resource)
+	}
+	return ctx.insertAuditLog(q, resource, id, action, "{}")
+}
+
+func (ctx *CtxW) insertAuditLog(q Querier, resource string, id interface{}, action string, changes string) error {
+	// -- Mutations made while impersonating also record the real actor
+	var actId interface{}
+	if ctx.Actor != nil {
+		actId = ctx.Actor.Id
+	}
+
+	query, params, err := bqb.New(`
+	INSERT INTO "setting.audit_log"
+		(resource, record_id, action, changes, ip, request_id, act_id, setting_company_id, cid, ctime)
+	VALUES
+		(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, resource, id, action, changes, ctx.IP, ctx.RequestId, actId, ctx.Company.Id, ctx.User.Id, time.Now()).ToPgsql()
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(query, params...)
+	return err
+}
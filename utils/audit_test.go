@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"system.buon18.com/m/models/setting"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type auditQuerier struct {
+	params [][]interface{}
+}
+
+func (q *auditQuerier) Exec(query string, args ...interface{}) (sql.Result, error) {
+	q.params = append(q.params, args)
+	return nil, nil
+}
+
+func (q *auditQuerier) QueryRow(query string, args ...interface{}) *sql.Row {
+	return nil
+}
+
+func TestAudit(t *testing.T) {
+	ctx := CtxW{
+		User:      setting.SettingUser{Id: 1001},
+		Company:   setting.SettingCompany{Id: 1000},
+		IP:        "127.0.0.1",
+		RequestId: "request-id",
+	}
+
+	t.Run("AuditDiff", func(t *testing.T) {
+		before := map[string]interface{}{"id": 1.0, "name": "a", "email": "a@buon18.com", "mtime": "t1", "items": []interface{}{1.0}}
+		after := map[string]interface{}{"id": 1.0, "name": "b", "email": "a@buon18.com", "mtime": "t2", "items": []interface{}{1.0, 2.0}}
+
+		changes := AuditDiff(before, after)
+		assert.Equal(t, map[string]AuditChange{
+			"name":  {Old: "a", New: "b"},
+			"items": {Old: []interface{}{1.0}, New: []interface{}{1.0, 2.0}},
+		}, changes)
+	})
+
+	t.Run("MaskedFields", func(t *testing.T) {
+		changes := AuditDiff(map[string]interface{}{"pwd": "hash1"}, map[string]interface{}{"pwd": "hash2"})
+		assert.Equal(t, AuditChange{Old: AUDIT_MASKED_VALUE, New: AUDIT_MASKED_VALUE}, changes["pwd"])
+
+		changes = AuditDiff(nil, map[string]interface{}{"pwd": nil, "name": "a"})
+		assert.NotContains(t, changes, "pwd")
+	})
+
+	t.Run("Actions", func(t *testing.T) {
+		q := &auditQuerier{}
+		record := map[string]interface{}{"id": 1.0, "name": "a"}
+
+		assert.NoError(t, ctx.Audit(q, "setting.customer", 1, nil, record))
+		assert.NoError(t, ctx.Audit(q, "setting.customer", 1, record, map[string]interface{}{"id": 1.0, "name": "b"}))
+		assert.NoError(t, ctx.Audit(q, "setting.customer", 1, record, nil))
+		assert.Len(t, q.params, 3)
+
+		for index, action := range []string{AUDIT_ACTION_CREATE, AUDIT_ACTION_UPDATE, AUDIT_ACTION_DELETE} {
+			params := q.params[index]
+			assert.Equal(t, "setting.customer", params[0])
+			assert.Equal(t, action, params[2])
+			assert.Equal(t, "127.0.0.1", params[4])
+			assert.Equal(t, "request-id", params[5])
+			assert.Nil(t, params[6])
+			assert.Equal(t, uint(1000), params[7])
+			assert.Equal(t, uint(1001), params[8])
+		}
+
+		changes := map[string]AuditChange{}
+		assert.NoError(t, json.Unmarshal([]byte(q.params[1][3].(string)), &changes))
+		assert.Equal(t, map[string]AuditChange{"name": {Old: "a", New: "b"}}, changes)
+	})
+
+	t.Run("UnchangedUpdateIsNotRecorded", func(t *testing.T) {
+		q := &auditQuerier{}
+		record := map[string]interface{}{"id": 1.0, "name": "a", "mtime": "t1"}
+
+		assert.NoError(t, ctx.Audit(q, "setting.customer", 1, record, map[string]interface{}{"id": 1.0, "name": "a", "mtime": "t2"}))
+		assert.Empty(t, q.params)
+	})
+
+	t.Run("Impersonation", func(t *testing.T) {
+		q := &auditQuerier{}
+		impersonatedCtx := ctx
+		impersonatedCtx.Actor = &setting.SettingUser{Id: 1}
+
+		assert.NoError(t, impersonatedCtx.Audit(q, "setting.customer", 1, nil, map[string]interface{}{"id": 1.0}))
+		assert.NoError(t, impersonatedCtx.AuditEvent(q, "setting.user", 1001, AUDIT_ACTION_IMPERSONATE))
+		assert.Len(t, q.params, 2)
+
+		for _, params := range q.params {
+			assert.Equal(t, uint(1), params[6])
+			assert.Equal(t, uint(1000), params[7])
+			assert.Equal(t, uint(1001), params[8])
+		}
+		assert.Equal(t, AUDIT_ACTION_IMPERSONATE, q.params[1][2])
+		assert.Equal(t, "{}", q.params[1][3])
+
+		assert.ErrorIs(t, ctx.AuditEvent(q, "unknown", 1, AUDIT_ACTION_IMPERSONATE), ErrUnknownAuditResource)
+	})
+}
@@ -18,6 +18,10 @@ func PrepareUpdateBqbQuery[T models.CommonUpdateRequest](bqbQuery *bqb.Query, i
 	}
 
 	for index := range val.NumField() {
+		// -- Embedded structs aren't fields of the record, e.g. models.CommonUpdatePrecondition
+		if typ.Field(index).Anonymous {
+			continue
+		}
 		if val.Field(index).IsNil() || val.Field(index).Kind() == reflect.Array || val.Field(index).Kind() == reflect.Slice {
 			continue
 		}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"system.buon18.com/m/models"
+)
+
+// CacheTags returns the tags of the cached responses of resource, the resource
+// itself and every resource its responses embed, directly or not.
+func CacheTags(resource string) []string {
+	tags := []string{resource}
+	for index := 0; index < len(tags); index++ {
+		for _, dependency := range models.CACHE_DEPENDENCIES[tags[index]] {
+			if !ContainsString(tags, dependency) {
+				tags = append(tags, dependency)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// CacheFingerprint identifies what the user is allowed to see of resource and
+// of the resources its responses embed. Users with the same permissions,
+// active company and record rules share the cached responses.
+func (ctx *CtxW) CacheFingerprint(resource string) (string, error) {
+	permissions := make([]string, 0, len(ctx.Permissions))
+	for _, permission := range ctx.Permissions {
+		permissions = append(permissions, permission.Name)
+	}
+	sort.Strings(permissions)
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "company:%d;permissions:%s", ctx.Company.Id, strings.Join(permissions, ","))
+	for _, tag := range CacheTags(resource) {
+		condition := ctx.AccessCondition(tag, fmt.Sprintf(`"%s"`, tag))
+		if condition == nil {
+			continue
+		}
+
+		query, params, err := condition.ToPgsql()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, ";%s:%s:%v", tag, query, params)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"testing"
+
+	"system.buon18.com/m/models/setting"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheTags(t *testing.T) {
+	t.Run("WithoutDependencies", func(t *testing.T) {
+		assert.Equal(t, []string{"setting.customer"}, CacheTags("setting.customer"))
+	})
+
+	t.Run("TransitiveDependencies", func(t *testing.T) {
+		assert.Equal(t, []string{"accounting.payment_term", "sales.order", "sales.quotation", "setting.customer"}, CacheTags("sales.order"))
+		assert.Equal(t, []string{"accounting.account", "accounting.journal", "accounting.journal_entry"}, CacheTags("accounting.journal_entry"))
+	})
+}
+
+func TestCacheFingerprint(t *testing.T) {
+	ctx := func(userId uint, permissions ...string) CtxW {
+		ctx := CtxW{
+			User:    setting.SettingUser{Id: userId},
+			Company: setting.SettingCompany{Id: 1},
+		}
+		for _, permission := range permissions {
+			ctx.Permissions = append(ctx.Permissions, setting.SettingPermission{Name: permission})
+		}
+		return ctx
+	}
+
+	t.Run("SharedBetweenUsers", func(t *testing.T) {
+		user, other := ctx(1001, "VIEW_SALES_QUOTATIONS", "VIEW_SETTING_CUSTOMERS"), ctx(1002, "VIEW_SETTING_CUSTOMERS", "VIEW_SALES_QUOTATIONS")
+
+		fingerprint, err := user.CacheFingerprint("sales.quotation")
+		assert.NoError(t, err)
+		otherFingerprint, err := other.CacheFingerprint("sales.quotation")
+		assert.NoError(t, err)
+		assert.Equal(t, fingerprint, otherFingerprint)
+	})
+
+	t.Run("PermissionsAndCompany", func(t *testing.T) {
+		user := ctx(1001, "VIEW_SALES_QUOTATIONS")
+		fingerprint, err := user.CacheFingerprint("sales.quotation")
+		assert.NoError(t, err)
+
+		other := ctx(1001, "FULL_ACCESS")
+		otherFingerprint, err := other.CacheFingerprint("sales.quotation")
+		assert.NoError(t, err)
+		assert.NotEqual(t, fingerprint, otherFingerprint)
+
+		other = ctx(1001, "VIEW_SALES_QUOTATIONS")
+		other.Company.Id = 1000
+		otherFingerprint, err = other.CacheFingerprint("sales.quotation")
+		assert.NoError(t, err)
+		assert.NotEqual(t, fingerprint, otherFingerprint)
+	})
+
+	t.Run("RecordRulesOfEmbeddedResources", func(t *testing.T) {
+		user, other := ctx(1001), ctx(1002)
+		for _, ctx := range []*CtxW{&user, &other} {
+			ctx.RecordRules = []setting.SettingRecordRule{
+				{Resource: "setting.customer", Field: "cid", Operator: "eq", Value: RECORD_RULE_USER_ID},
+			}
+		}
+
+		fingerprint, err := user.CacheFingerprint("sales.quotation")
+		assert.NoError(t, err)
+		otherFingerprint, err := other.CacheFingerprint("sales.quotation")
+		assert.NoError(t, err)
+		assert.NotEqual(t, fingerprint, otherFingerprint)
+
+		// -- Accounts don't embed customers
+		fingerprint, err = user.CacheFingerprint("accounting.account")
+		assert.NoError(t, err)
+		otherFingerprint, err = other.CacheFingerprint("accounting.account")
+		assert.NoError(t, err)
+		assert.Equal(t, fingerprint, otherFingerprint)
+	})
+}
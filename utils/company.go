@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"system.buon18.com/m/models"
+	"system.buon18.com/m/models/setting"
+
+	"github.com/nullism/bqb"
+)
+
+const COMPANY_HEADER = "X-Company-Id"
+
+var (
+	ErrCompanyNotAllowed = errors.New("company is not allowed")
+)
+
+// ResolveCompany returns the active company among the allowed companies of the
+// user, the first allowed company is used when no company is requested.
+func ResolveCompany(companies []setting.SettingCompany, requested string) (setting.SettingCompany, error) {
+	requested = strings.TrimSpace(requested)
+	if requested == "" {
+		if len(companies) == 0 {
+			return setting.SettingCompany{}, ErrCompanyNotAllowed
+		}
+		return companies[0], nil
+	}
+
+	id, err := strconv.ParseUint(requested, 10, 64)
+	if err != nil {
+		return setting.SettingCompany{}, ErrCompanyNotAllowed
+	}
+	for _, company := range companies {
+		if company.Id == uint(id) {
+			return company, nil
+		}
+	}
+	return setting.SettingCompany{}, ErrCompanyNotAllowed
+}
+
+// AllowsCompanies reports whether the user is allowed to access every company
+// of ids.
+func (ctx *CtxW) AllowsCompanies(ids []uint) bool {
+	for _, id := range ids {
+		if !slices.ContainsFunc(ctx.Companies, func(company setting.SettingCompany) bool { return company.Id == id }) {
+			return false
+		}
+	}
+	return true
+}
+
+// AllowedCompanyCondition restricts the companies themselves to the companies
+// the user is allowed to access.
+func (ctx *CtxW) AllowedCompanyCondition(prefix string) *bqb.Query {
+	if len(ctx.Companies) == 0 {
+		return bqb.New("FALSE")
+	}
+
+	ids := make([]uint, 0, len(ctx.Companies))
+	for _, company := range ctx.Companies {
+		ids = append(ids, company.Id)
+	}
+	return bqb.New(fmt.Sprintf("%s.id IN (?)", prefix), ids)
+}
+
+// CompanyCondition restricts the records of a company resource to the active
+// company, nil is returned for resources that are shared between companies.
+func (ctx *CtxW) CompanyCondition(resource string, prefix string) *bqb.Query {
+	if !ContainsString(models.COMPANY_RESOURCES, resource) {
+		return nil
+	}
+	return bqb.New(fmt.Sprintf("%s.setting_company_id = ?", prefix), ctx.Company.Id)
+}
+
+// AccessCondition builds the condition that a record of resource must match to
+// be accessible, both the active company and the record rules apply.
+func (ctx *CtxW) AccessCondition(resource string, prefix string) *bqb.Query {
+	companyCondition := ctx.CompanyCondition(resource, prefix)
+	recordRuleCondition := ctx.RecordRuleCondition(resource, prefix)
+	switch {
+	case companyCondition == nil:
+		return recordRuleCondition
+	case recordRuleCondition == nil:
+		return companyCondition
+	}
+	return bqb.New("? AND ?", companyCondition, recordRuleCondition)
+}
+
+// AccessIntoBqb restricts a query that already has a WHERE clause.
+func (ctx *CtxW) AccessIntoBqb(bqbQuery *bqb.Query, resource string, prefix string) {
+	if condition := ctx.AccessCondition(resource, prefix); condition != nil {
+		bqbQuery.Space("AND ?", condition)
+	}
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"testing"
+
+	"system.buon18.com/m/models/setting"
+
+	"github.com/nullism/bqb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveCompany(t *testing.T) {
+	companies := []setting.SettingCompany{{Id: 1, Name: "Default"}, {Id: 1000, Name: "Branch"}}
+
+	t.Run("Default", func(t *testing.T) {
+		company, err := ResolveCompany(companies, "")
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), company.Id)
+	})
+
+	t.Run("Requested", func(t *testing.T) {
+		company, err := ResolveCompany(companies, "1000")
+		assert.NoError(t, err)
+		assert.Equal(t, "Branch", company.Name)
+	})
+
+	t.Run("NotAllowed", func(t *testing.T) {
+		_, err := ResolveCompany(companies, "1001")
+		assert.Equal(t, ErrCompanyNotAllowed, err)
+
+		_, err = ResolveCompany(nil, "")
+		assert.Equal(t, ErrCompanyNotAllowed, err)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := ResolveCompany(companies, "abc")
+		assert.Equal(t, ErrCompanyNotAllowed, err)
+	})
+}
+
+func TestAccessCondition(t *testing.T) {
+	ctx := CtxW{
+		User:    setting.SettingUser{Id: 1001},
+		Company: setting.SettingCompany{Id: 1000},
+		RecordRules: []setting.SettingRecordRule{
+			{Resource: "sales.quotation", Field: "cid", Operator: "eq", Value: RECORD_RULE_USER_ID},
+		},
+	}
+
+	t.Run("Company", func(t *testing.T) {
+		bqbQuery := bqb.New(`SELECT * FROM "setting.customer" WHERE id = ?`, "1")
+		ctx.AccessIntoBqb(bqbQuery, "setting.customer", `"setting.customer"`)
+
+		query, params, err := bqbQuery.ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT * FROM "setting.customer" WHERE id = $1 AND "setting.customer".setting_company_id = $2`, query)
+		assert.Equal(t, []interface{}{"1", uint(1000)}, params)
+	})
+
+	t.Run("CompanyAndRecordRule", func(t *testing.T) {
+		query, params, err := ctx.AccessCondition("sales.quotation", `"sales.quotation"`).ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `"sales.quotation".setting_company_id = $1 AND ("sales.quotation".cid = $2)`, query)
+		assert.Equal(t, []interface{}{uint(1000), "1001"}, params)
+	})
+
+	t.Run("AuditLog", func(t *testing.T) {
+		query, params, err := ctx.AccessCondition("setting.audit_log", `"setting.audit_log"`).ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `"setting.audit_log".setting_company_id = $1`, query)
+		assert.Equal(t, []interface{}{uint(1000)}, params)
+	})
+
+	t.Run("Shared", func(t *testing.T) {
+		assert.Nil(t, ctx.AccessCondition("setting.role", `"setting.role"`))
+	})
+}
@@ -1,5 +1,7 @@
 package utils
 
+import "strings"
+
 var (
 	FULL_ACCESS_ID     = 1
 	FULL_AUTH_ID       = 2
@@ -33,6 +35,10 @@ type Permissions struct {
 	SETTING_USERS              CommonPermission
 	SETTING_CUSTOMERS          CommonPermission
 	SETTING_ROLES              CommonPermission
+	SETTING_AUDIT_LOGS         CommonPermission
+	SETTING_SESSIONS           CommonPermission
+	SETTING_LOGIN_EVENTS       CommonPermission
+	SETTING_COMPANIES          CommonPermission
 	SALES_QUOTATIONS           CommonPermission
 	SALES_ORDERS               CommonPermission
 	ACCOUNTING_ACCOUNTS        CommonPermission
@@ -69,6 +75,22 @@ var PREDEFINED_PERMISSIONS = Permissions{
 		UPDATE: "UPDATE_SETTING_ROLES",
 		DELETE: "DELETE_SETTING_ROLES",
 	},
+	SETTING_AUDIT_LOGS: CommonPermission{
+		VIEW: "VIEW_SETTING_AUDIT_LOGS",
+	},
+	SETTING_SESSIONS: CommonPermission{
+		VIEW:   "VIEW_SETTING_SESSIONS",
+		DELETE: "DELETE_SETTING_SESSIONS",
+	},
+	SETTING_LOGIN_EVENTS: CommonPermission{
+		VIEW: "VIEW_SETTING_LOGIN_EVENTS",
+	},
+	SETTING_COMPANIES: CommonPermission{
+		VIEW:   "VIEW_SETTING_COMPANIES",
+		CREATE: "CREATE_SETTING_COMPANIES",
+		UPDATE: "UPDATE_SETTING_COMPANIES",
+		DELETE: "DELETE_SETTING_COMPANIES",
+	},
 	SALES_QUOTATIONS: CommonPermission{
 		VIEW:   "VIEW_SALES_QUOTATIONS",
 		CREATE: "CREATE_SALES_QUOTATIONS",
@@ -106,3 +128,72 @@ var PREDEFINED_PERMISSIONS = Permissions{
 		DELETE: "DELETE_ACCOUNTING_PAYMENT_TERMS",
 	},
 }
+
+// PERMISSION_IMPLICATIONS declares the permissions that are granted by holding
+// another permission, e.g. FULL_SALES grants every SALES_* permission.
+var PERMISSION_IMPLICATIONS = map[string][]string{
+	// -- Companies span every tenant, they aren't part of FULL_SETTING
+	PREDEFINED_PERMISSIONS.FULL_ACCESS: append([]string{
+		PREDEFINED_PERMISSIONS.FULL_AUTH,
+		PREDEFINED_PERMISSIONS.FULL_SETTING,
+		PREDEFINED_PERMISSIONS.FULL_SALES,
+		PREDEFINED_PERMISSIONS.FULL_ACCOUNTING},
+		PREDEFINED_PERMISSIONS.SETTING_COMPANIES.All()...),
+	PREDEFINED_PERMISSIONS.FULL_AUTH: PREDEFINED_PERMISSIONS.AUTH.All(),
+	PREDEFINED_PERMISSIONS.FULL_SETTING: append(append(append(append(append(
+		PREDEFINED_PERMISSIONS.SETTING_USERS.All(),
+		PREDEFINED_PERMISSIONS.SETTING_CUSTOMERS.All()...),
+		PREDEFINED_PERMISSIONS.SETTING_ROLES.All()...),
+		PREDEFINED_PERMISSIONS.SETTING_AUDIT_LOGS.All()...),
+		PREDEFINED_PERMISSIONS.SETTING_SESSIONS.All()...),
+		PREDEFINED_PERMISSIONS.SETTING_LOGIN_EVENTS.All()...),
+	PREDEFINED_PERMISSIONS.FULL_SALES: append(
+		PREDEFINED_PERMISSIONS.SALES_QUOTATIONS.All(),
+		PREDEFINED_PERMISSIONS.SALES_ORDERS.All()...),
+	PREDEFINED_PERMISSIONS.FULL_ACCOUNTING: append(append(append(
+		PREDEFINED_PERMISSIONS.ACCOUNTING_ACCOUNTS.All(),
+		PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNALS.All()...),
+		PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNAL_ENTRIES.All()...),
+		PREDEFINED_PERMISSIONS.ACCOUNTING_PAYMENT_TERMS.All()...),
+}
+
+func (permission CommonPermission) All() []string {
+	permissions := make([]string, 0)
+	for _, name := range []string{permission.VIEW, permission.CREATE, permission.UPDATE, permission.DELETE} {
+		if name != "" {
+			permissions = append(permissions, name)
+		}
+	}
+	return permissions
+}
+
+// EffectivePermissions returns the granted permissions together with every
+// permission they imply, without duplicates and in the order they are found.
+func EffectivePermissions(granted []string) []string {
+	effective := make([]string, 0)
+	queue := append([]string{}, granted...)
+	for len(queue) > 0 {
+		permission := queue[0]
+		queue = queue[1:]
+		if ContainsString(effective, permission) {
+			continue
+		}
+
+		effective = append(effective, permission)
+		queue = append(queue, PERMISSION_IMPLICATIONS[permission]...)
+	}
+	return effective
+}
+
+// HasAnyPermission reports whether one of the permissions is allowed, implied
+// permissions are expected to be expanded already.
+func HasAnyPermission(permissions []string, allowPermissions []string) bool {
+	for _, permission := range permissions {
+		for _, allowPermission := range allowPermissions {
+			if strings.EqualFold(permission, allowPermission) {
+				return true
+			}
+		}
+	}
+	return false
+}
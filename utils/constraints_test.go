@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEffectivePermissions(t *testing.T) {
+	t.Run("Hierarchy", func(t *testing.T) {
+		permissions := EffectivePermissions([]string{PREDEFINED_PERMISSIONS.FULL_SALES})
+		assert.Contains(t, permissions, PREDEFINED_PERMISSIONS.FULL_SALES)
+		assert.Contains(t, permissions, PREDEFINED_PERMISSIONS.SALES_QUOTATIONS.VIEW)
+		assert.Contains(t, permissions, PREDEFINED_PERMISSIONS.SALES_ORDERS.DELETE)
+		assert.NotContains(t, permissions, PREDEFINED_PERMISSIONS.SETTING_USERS.VIEW)
+	})
+
+	t.Run("Transitive", func(t *testing.T) {
+		permissions := EffectivePermissions([]string{PREDEFINED_PERMISSIONS.FULL_ACCESS})
+		assert.Contains(t, permissions, PREDEFINED_PERMISSIONS.FULL_ACCOUNTING)
+		assert.Contains(t, permissions, PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNAL_ENTRIES.UPDATE)
+		assert.Contains(t, permissions, PREDEFINED_PERMISSIONS.AUTH.UPDATE)
+	})
+
+	t.Run("NoDuplicates", func(t *testing.T) {
+		permissions := EffectivePermissions([]string{
+			PREDEFINED_PERMISSIONS.SALES_ORDERS.VIEW,
+			PREDEFINED_PERMISSIONS.FULL_SALES,
+			PREDEFINED_PERMISSIONS.SALES_ORDERS.VIEW,
+		})
+		assert.Equal(t, PREDEFINED_PERMISSIONS.SALES_ORDERS.VIEW, permissions[0])
+		assert.Len(t, permissions, 1+len(PERMISSION_IMPLICATIONS[PREDEFINED_PERMISSIONS.FULL_SALES]))
+	})
+
+	t.Run("HasAnyPermission", func(t *testing.T) {
+		assert.True(t, HasAnyPermission([]string{"view_sales_orders"}, []string{PREDEFINED_PERMISSIONS.SALES_ORDERS.VIEW}))
+		assert.False(t, HasAnyPermission([]string{PREDEFINED_PERMISSIONS.SALES_ORDERS.VIEW}, []string{PREDEFINED_PERMISSIONS.SALES_ORDERS.UPDATE}))
+	})
+}
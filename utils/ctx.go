@@ -10,15 +10,49 @@ import (
 )
 
 var (
-	ErrNoUserCtx        = errors.New("unable to get user context")
-	ErrNoRoleCtx        = errors.New("unable to get role context")
-	ErrNoPermissionsCtx = errors.New("unable to get permissions context")
+	ErrNoUserCtx            = errors.New("unable to get user context")
+	ErrNoRoleCtx            = errors.New("unable to get role context")
+	ErrNoRolesCtx           = errors.New("unable to get roles context")
+	ErrNoPermissionsCtx     = errors.New("unable to get permissions context")
+	ErrNoRolePermissionsCtx = errors.New("unable to get role permissions context")
+	ErrNoRecordRulesCtx     = errors.New("unable to get record rules context")
+	ErrNoCompanyCtx         = errors.New("unable to get company context")
 )
 
 type CtxW struct {
 	User        setting.SettingUser
 	Role        setting.SettingRole
+	Roles       []setting.SettingRole
 	Permissions []setting.SettingPermission
+	// -- Effective permissions of each role, role id -> permission names
+	RolePermissions map[uint][]string
+	RecordRules     []setting.SettingRecordRule
+	// -- Active company and the companies the user is allowed to access
+	Company   setting.SettingCompany
+	Companies []setting.SettingCompany
+	// -- Admin impersonating the user, nil unless impersonating
+	Actor *setting.SettingUser
+	// -- Session of the token, empty for impersonation tokens
+	SessionId string
+	// -- Request metadata
+	IP        string
+	RequestId string
+}
+
+// ClientInfo describes the client of a request, it's available before the
+// user is authenticated.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// Client returns the client of the request, the IP is resolved through the
+// trusted proxies.
+func Client(c *gin.Context) ClientInfo {
+	return ClientInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
 }
 
 func Ctx(c *gin.Context) (CtxW, error) {
@@ -40,6 +74,15 @@ func Ctx(c *gin.Context) (CtxW, error) {
 		ctxRole = cRole.(setting.SettingRole)
 	}
 
+	// -- Get roles, the primary role comes first
+	var ctxRoles []setting.SettingRole
+	if cRoles, err := c.Get("roles"); !err {
+		log.Printf("Error getting roles: %v\n", err)
+		return CtxW{}, ErrNoRolesCtx
+	} else {
+		ctxRoles = cRoles.([]setting.SettingRole)
+	}
+
 	// -- Get permissions
 	var ctxPermissions []setting.SettingPermission
 	if cPermissions, err := c.Get("permissions"); !err {
@@ -49,9 +92,69 @@ func Ctx(c *gin.Context) (CtxW, error) {
 		ctxPermissions = cPermissions.([]setting.SettingPermission)
 	}
 
+	// -- Get permissions of each role
+	var ctxRolePermissions map[uint][]string
+	if cRolePermissions, err := c.Get("role_permissions"); !err {
+		log.Printf("Error getting role permissions: %v\n", err)
+		return CtxW{}, ErrNoRolePermissionsCtx
+	} else {
+		ctxRolePermissions = cRolePermissions.(map[uint][]string)
+	}
+
+	// -- Get record rules
+	var ctxRecordRules []setting.SettingRecordRule
+	if cRecordRules, err := c.Get("record_rules"); !err {
+		log.Printf("Error getting record rules: %v\n", err)
+		return CtxW{}, ErrNoRecordRulesCtx
+	} else {
+		ctxRecordRules = cRecordRules.([]setting.SettingRecordRule)
+	}
+
+	// -- Get companies
+	var ctxCompany setting.SettingCompany
+	var ctxCompanies []setting.SettingCompany
+	if cCompany, err := c.Get("company"); !err {
+		log.Printf("Error getting company: %v\n", err)
+		return CtxW{}, ErrNoCompanyCtx
+	} else {
+		ctxCompany = cCompany.(setting.SettingCompany)
+	}
+	if cCompanies, err := c.Get("companies"); !err {
+		log.Printf("Error getting companies: %v\n", err)
+		return CtxW{}, ErrNoCompanyCtx
+	} else {
+		ctxCompanies = cCompanies.([]setting.SettingCompany)
+	}
+
+	// -- Get actor, only set when impersonating
+	var ctxActor *setting.SettingUser
+	if cActor, ok := c.Get("actor"); ok {
+		actor := cActor.(setting.SettingUser)
+		ctxActor = &actor
+	}
+
 	return CtxW{
-		User:        ctxUser,
-		Role:        ctxRole,
-		Permissions: ctxPermissions,
+		User:            ctxUser,
+		Role:            ctxRole,
+		Roles:           ctxRoles,
+		Permissions:     ctxPermissions,
+		RolePermissions: ctxRolePermissions,
+		RecordRules:     ctxRecordRules,
+		Company:         ctxCompany,
+		Companies:       ctxCompanies,
+		Actor:           ctxActor,
+		SessionId:       c.GetString("session_id"),
+		IP:              c.ClientIP(),
+		RequestId:       c.GetString("request_id"),
 	}, nil
 }
+
+// HasPermission reports whether the effective permissions contain one of names.
+func (ctx *CtxW) HasPermission(names ...string) bool {
+	for _, permission := range ctx.Permissions {
+		if ContainsString(names, permission.Name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,378 @@
+package utils
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"system.buon18.com/m/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nullism/bqb"
+)
+
+// -- Keyset pagination, a cursor points to the first (before) or the last
+// -- (after) record of a page and the next page continues right after it
+const (
+	CURSOR_AFTER_PARAM  = "after"
+	CURSOR_BEFORE_PARAM = "before"
+	TOTAL_PARAM         = "total"
+)
+
+var (
+	ErrInvalidCursor       = errors.New("invalid cursor")
+	ErrCursorSortsMismatch = errors.New("cursor doesn't match the sorts")
+)
+
+type SortValue struct {
+	Field     string
+	Direction string
+	models.SortField
+}
+
+// nullsLast reports whether NULLs are placed after the values.
+func (sort SortValue) nullsLast() bool {
+	switch sort.Nulls {
+	case models.SORT_NULLS_FIRST:
+		return false
+	case models.SORT_NULLS_LAST:
+		return true
+	}
+	return sort.Direction == "ASC"
+}
+
+// flip reverses the order, including the placement of NULLs.
+func (sort SortValue) flip() SortValue {
+	sort.Direction = flipDirection(sort.Direction)
+	switch sort.Nulls {
+	case models.SORT_NULLS_FIRST:
+		sort.Nulls = models.SORT_NULLS_LAST
+	case models.SORT_NULLS_LAST:
+		sort.Nulls = models.SORT_NULLS_FIRST
+	}
+	return sort
+}
+
+// order returns the ORDER BY item of expression, NULLS is only added when the
+// placement differs from the default to keep indexes usable.
+func (sort SortValue) order(expression string) string {
+	if sort.Nulls == "" {
+		return fmt.Sprintf("%s %s", expression, sort.Direction)
+	}
+	return fmt.Sprintf("%s %s NULLS %s", expression, sort.Direction, strings.ToUpper(sort.Nulls))
+}
+
+// Cursor is the decoded position of a record, Values are the values of the
+// sort expressions of the record, Id breaks ties between equal values.
+type Cursor struct {
+	Before bool          `json:"-"`
+	Sorts  string        `json:"s"`
+	Values []interface{} `json:"v"`
+	Id     interface{}   `json:"id"`
+}
+
+func EncodeCursor(cursor Cursor) string {
+	cursorByte, err := json.Marshal(cursor)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(cursorByte)
+}
+
+func DecodeCursor(encoded string) (Cursor, error) {
+	cursorByte, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	decoder := json.NewDecoder(strings.NewReader(string(cursorByte)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&cursor); err != nil || cursor.Id == nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	// -- Numbers are passed as text and casted by the database
+	for index, value := range cursor.Values {
+		if number, ok := value.(json.Number); ok {
+			cursor.Values[index] = number.String()
+		}
+	}
+	if number, ok := cursor.Id.(json.Number); ok {
+		cursor.Id = number.String()
+	}
+	return cursor, nil
+}
+
+// sortExpression resolves the sort field of resource into an expression on
+// prefix, text is sorted case-insensitively and the others as stored.
+func sortExpression(resource string, prefix string, sort SortValue) string {
+	expression, _ := FieldExpression(resource, prefix, sort.Field)
+
+	typ := sort.Typ
+	if typ == "" && !isComputedField(resource, sort.Field) {
+		typ = models.SORT_TYPE_TEXT
+	}
+	if typ == models.SORT_TYPE_TEXT {
+		expression = fmt.Sprintf(`LOWER(%s)`, expression)
+	}
+
+	if sort.Collation != "" {
+		expression = fmt.Sprintf(`%s COLLATE "%s"`, expression, sort.Collation)
+	}
+	return expression
+}
+
+// sortsSignature identifies the sorts a cursor is created for.
+func (qp *QueryParams) sortsSignature() string {
+	sorts := make([]string, len(qp.Sorts))
+	for index, sort := range qp.Sorts {
+		sorts[index] = fmt.Sprintf("%s %s", sort.Field, sort.Direction)
+	}
+	return strings.Join(sorts, ",")
+}
+
+func (qp *QueryParams) prepareCursor(c *gin.Context) {
+	qp.knownParams = append(qp.knownParams, CURSOR_AFTER_PARAM, CURSOR_BEFORE_PARAM, TOTAL_PARAM)
+
+	after, hasAfter := c.GetQuery(CURSOR_AFTER_PARAM)
+	before, hasBefore := c.GetQuery(CURSOR_BEFORE_PARAM)
+	switch {
+	case hasAfter && hasBefore:
+		qp.addError(CURSOR_BEFORE_PARAM, "before can't be combined with after")
+	case hasAfter || hasBefore:
+		param, encoded := CURSOR_AFTER_PARAM, after
+		if hasBefore {
+			param, encoded = CURSOR_BEFORE_PARAM, before
+		}
+
+		cursor, err := DecodeCursor(encoded)
+		switch {
+		case err != nil:
+			qp.addError(param, err.Error())
+		case cursor.Sorts != qp.sortsSignature() || len(cursor.Values) != len(qp.Sorts):
+			qp.addError(param, ErrCursorSortsMismatch.Error())
+		case c.Query("offset") != "":
+			qp.addError("offset", fmt.Sprintf("offset can't be combined with %s", param))
+		default:
+			cursor.Before = hasBefore
+			qp.Cursor = &cursor
+			// -- Counting is what keyset pagination avoids, opt in with total=true
+			qp.WithTotal = false
+		}
+	}
+
+	if total, ok := c.GetQuery(TOTAL_PARAM); ok {
+		switch total {
+		case "true":
+			qp.WithTotal = true
+		case "false":
+			qp.WithTotal = false
+		default:
+			qp.addError(TOTAL_PARAM, "total must be true or false")
+		}
+	}
+}
+
+// afterCondition matches the records placed after value when ordered by
+// expression and sort.
+func afterCondition(expression string, sort SortValue, value interface{}) *bqb.Query {
+	operator := ">"
+	if sort.Direction == "DESC" {
+		operator = "<"
+	}
+
+	switch {
+	case sort.nullsLast() && value != nil:
+		return bqb.New(fmt.Sprintf("(%s %s ? OR %s IS NULL)", expression, operator, expression), value)
+	case sort.nullsLast():
+		return bqb.New("FALSE")
+	case value != nil:
+		return bqb.New(fmt.Sprintf("%s %s ?", expression, operator), value)
+	}
+	return bqb.New(fmt.Sprintf("%s IS NOT NULL", expression))
+}
+
+func equalCondition(expression string, value interface{}) *bqb.Query {
+	if value == nil {
+		return bqb.New(fmt.Sprintf("%s IS NULL", expression))
+	}
+	return bqb.New(fmt.Sprintf("%s = ?", expression), value)
+}
+
+func flipDirection(direction string) string {
+	if direction == "ASC" {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+// pageOrder returns the expressions and sorts the page is selected by, the
+// order is reversed to select the page before a cursor.
+func (qp *QueryParams) pageOrder(prefix string, keyDirection string) ([]string, []SortValue) {
+	expressions := make([]string, 0, len(qp.Sorts)+1)
+	sorts := make([]SortValue, 0, len(qp.Sorts)+1)
+	for _, sort := range qp.Sorts {
+		expressions = append(expressions, sortExpression(prefixResource(prefix), prefix, sort))
+		sorts = append(sorts, sort)
+	}
+	expressions = append(expressions, fmt.Sprintf("%s.id", prefix))
+	sorts = append(sorts, SortValue{Field: "id", Direction: keyDirection})
+
+	if qp.IsBefore() {
+		for index := range sorts {
+			sorts[index] = sorts[index].flip()
+		}
+	}
+	return expressions, sorts
+}
+
+func (qp *QueryParams) cursorCondition(expressions []string, sorts []SortValue) *bqb.Query {
+	values := append(append([]interface{}{}, qp.Cursor.Values...), qp.Cursor.Id)
+
+	// -- (e1 after v1) OR (e1 = v1 AND e2 after v2) OR ...
+	placeholders := make([]string, len(expressions))
+	conditions := make([]interface{}, len(expressions))
+	for index := range expressions {
+		parts := make([]string, 0, index+1)
+		partConditions := make([]interface{}, 0, index+1)
+		for previous := 0; previous < index; previous++ {
+			parts = append(parts, "?")
+			partConditions = append(partConditions, equalCondition(expressions[previous], values[previous]))
+		}
+		parts = append(parts, "?")
+		if index == len(expressions)-1 {
+			// -- The key is never NULL
+			operator := ">"
+			if sorts[index].Direction == "DESC" {
+				operator = "<"
+			}
+			partConditions = append(partConditions, bqb.New(fmt.Sprintf("%s %s ?", expressions[index], operator), values[index]))
+		} else {
+			partConditions = append(partConditions, afterCondition(expressions[index], sorts[index], values[index]))
+		}
+
+		placeholders[index] = "?"
+		conditions[index] = bqb.New(fmt.Sprintf("(%s)", strings.Join(parts, " AND ")), partConditions...)
+	}
+	return bqb.New(fmt.Sprintf("(%s)", strings.Join(placeholders, " OR ")), conditions...)
+}
+
+// PageIntoBqb selects a page of records of prefix, it adds the filters, the
+// cursor condition, the order and the pagination. keyDirection is the
+// direction of prefix.id that breaks ties between equal sort values.
+func (qp *QueryParams) PageIntoBqb(bqbQuery *bqb.Query, prefix string, keyDirection string) {
+	expressions, sorts := qp.pageOrder(prefix, keyDirection)
+
+	conditions := qp.Conditions
+	if qp.Cursor != nil {
+		qp.Conditions = append(append([]*bqb.Query{}, conditions...), qp.cursorCondition(expressions, sorts))
+	}
+	qp.FilterIntoBqb(bqbQuery)
+	qp.Conditions = conditions
+
+	orders := make([]string, len(expressions))
+	for index := range expressions {
+		orders[index] = sorts[index].order(expressions[index])
+	}
+	bqbQuery.Space(fmt.Sprintf("ORDER BY %s", strings.Join(orders, ", ")))
+
+	if qp.Cursor != nil {
+		bqbQuery.Space(`LIMIT ?`, qp.Pagination.Limit)
+	} else {
+		qp.PaginationIntoBqb(bqbQuery)
+	}
+}
+
+// IsBefore reports whether the page is selected in reverse order, services
+// selecting the page without a wrapping query reverse the records.
+func (qp *QueryParams) IsBefore() bool {
+	return qp.Cursor != nil && qp.Cursor.Before
+}
+
+// PrepareCursors creates the cursors of the page ranging from firstId to
+// lastId in table, count is the number of records of the page.
+func (qp *QueryParams) PrepareCursors(db *sql.DB, table string, firstId interface{}, lastId interface{}, count int) error {
+	if count == 0 {
+		return nil
+	}
+
+	hasMore := count >= qp.Pagination.Limit
+	hasNext, hasPrev := hasMore, qp.Cursor != nil || qp.Pagination.Offset > 0
+	if qp.IsBefore() {
+		hasNext, hasPrev = true, hasMore
+	}
+
+	if hasNext {
+		cursor, err := qp.cursorOf(db, table, lastId)
+		if err != nil {
+			return err
+		}
+		qp.NextCursor = EncodeCursor(cursor)
+	}
+	if hasPrev {
+		cursor, err := qp.cursorOf(db, table, firstId)
+		if err != nil {
+			return err
+		}
+		qp.PrevCursor = EncodeCursor(cursor)
+	}
+	return nil
+}
+
+func (qp *QueryParams) cursorOf(db *sql.DB, table string, id interface{}) (Cursor, error) {
+	cursor := Cursor{Sorts: qp.sortsSignature(), Values: []interface{}{}, Id: id}
+	if len(qp.Sorts) == 0 {
+		return cursor, nil
+	}
+
+	expressions := make([]string, len(qp.Sorts))
+	for index, sort := range qp.Sorts {
+		expressions[index] = sortExpression(prefixResource(table), table, sort)
+	}
+
+	query, params, err := bqb.New(fmt.Sprintf(`SELECT %s FROM %s WHERE %s.id = ?`, strings.Join(expressions, ", "), table, table), id).ToPgsql()
+	if err != nil {
+		return Cursor{}, err
+	}
+
+	values := make([]interface{}, len(expressions))
+	valuePtrs := make([]interface{}, len(expressions))
+	for index := range values {
+		valuePtrs[index] = &values[index]
+	}
+	if err := db.QueryRow(query, params...).Scan(valuePtrs...); err != nil {
+		return Cursor{}, err
+	}
+
+	for index, value := range values {
+		switch value := value.(type) {
+		case []byte:
+			values[index] = string(value)
+		case time.Time:
+			values[index] = value.Format(time.RFC3339Nano)
+		}
+	}
+	cursor.Values = values
+	return cursor, nil
+}
+
+// PaginationHeaders sets the total and the cursors of the page.
+func (qp *QueryParams) PaginationHeaders(c *gin.Context, total int) {
+	if qp.WithTotal {
+		c.Header("X-Total-Count", fmt.Sprintf("%d", total))
+		c.Set("total", total)
+	}
+	if qp.NextCursor != "" {
+		c.Header("X-Next-Cursor", qp.NextCursor)
+		c.Set("next_cursor", qp.NextCursor)
+	}
+	if qp.PrevCursor != "" {
+		c.Header("X-Prev-Cursor", qp.PrevCursor)
+		c.Set("prev_cursor", qp.PrevCursor)
+	}
+}
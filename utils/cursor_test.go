@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"system.buon18.com/m/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nullism/bqb"
+	"github.com/stretchr/testify/assert"
+)
+
+func prepareCursorQueryParams(target string) *QueryParams {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", target, nil)
+
+	return NewQueryParams().
+		PrepareFilters(c, []string{"name:like"}, `"foo.bar"`).
+		PrepareSorts(c, []string{"name"}, `"limited_bars"`).
+		PreparePagination(c)
+}
+
+func TestCursor(t *testing.T) {
+	t.Run("EncodeAndDecode", func(t *testing.T) {
+		encoded := EncodeCursor(Cursor{Sorts: "name ASC", Values: []interface{}{"foo", nil, 1.5}, Id: uint(10)})
+
+		cursor, err := DecodeCursor(encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, Cursor{Sorts: "name ASC", Values: []interface{}{"foo", nil, "1.5"}, Id: "10"}, cursor)
+
+		_, err = DecodeCursor("not a cursor")
+		assert.Equal(t, ErrInvalidCursor, err)
+	})
+
+	t.Run("PageIntoBqbWithOffset", func(t *testing.T) {
+		qp := prepareCursorQueryParams("/?name:like=a&sort:name=desc&offset=10&limit=5")
+		assert.True(t, qp.WithTotal)
+
+		bqbQuery := bqb.New(`SELECT * FROM "foo.bar"`)
+		qp.PageIntoBqb(bqbQuery, `"foo.bar"`, "ASC")
+
+		query, params, err := bqbQuery.ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT * FROM "foo.bar" WHERE "foo.bar".name LIKE $1 ORDER BY LOWER("foo.bar".name) DESC, "foo.bar".id ASC OFFSET $2 LIMIT $3`, query)
+		assert.Equal(t, []interface{}{"%a%", 10, 5}, params)
+	})
+
+	t.Run("PageIntoBqbAfter", func(t *testing.T) {
+		after := EncodeCursor(Cursor{Sorts: "name ASC", Values: []interface{}{"foo"}, Id: 7})
+		qp := prepareCursorQueryParams("/?sort:name=asc&limit=5&after=" + after)
+		assert.Empty(t, qp.Errors)
+		assert.False(t, qp.WithTotal)
+		assert.False(t, qp.IsBefore())
+
+		bqbQuery := bqb.New(`SELECT * FROM "foo.bar"`)
+		qp.PageIntoBqb(bqbQuery, `"foo.bar"`, "ASC")
+
+		query, params, err := bqbQuery.ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT * FROM "foo.bar" WHERE (((LOWER("foo.bar".name) > $1 OR LOWER("foo.bar".name) IS NULL)) OR (LOWER("foo.bar".name) = $2 AND "foo.bar".id > $3)) ORDER BY LOWER("foo.bar".name) ASC, "foo.bar".id ASC LIMIT $4`, query)
+		assert.Equal(t, []interface{}{"foo", "foo", "7", 5}, params)
+	})
+
+	t.Run("PageIntoBqbBefore", func(t *testing.T) {
+		before := EncodeCursor(Cursor{Sorts: "name ASC", Values: []interface{}{nil}, Id: 7})
+		qp := prepareCursorQueryParams("/?sort:name=asc&before=" + before + "&total=true")
+		assert.Empty(t, qp.Errors)
+		assert.True(t, qp.WithTotal)
+		assert.True(t, qp.IsBefore())
+
+		bqbQuery := bqb.New(`SELECT * FROM "foo.bar"`)
+		qp.PageIntoBqb(bqbQuery, `"foo.bar"`, "ASC")
+
+		query, params, err := bqbQuery.ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT * FROM "foo.bar" WHERE ((LOWER("foo.bar".name) IS NOT NULL) OR (LOWER("foo.bar".name) IS NULL AND "foo.bar".id < $1)) ORDER BY LOWER("foo.bar".name) DESC, "foo.bar".id DESC LIMIT $2`, query)
+		assert.Equal(t, []interface{}{"7", 10}, params)
+	})
+
+	t.Run("InvalidCursor", func(t *testing.T) {
+		after := EncodeCursor(Cursor{Sorts: "name ASC", Values: []interface{}{"foo"}, Id: 7})
+
+		qp := prepareCursorQueryParams("/?sort:name=desc&after=" + after)
+		assert.Equal(t, []QueryParamError{{"after", ErrCursorSortsMismatch.Error()}}, qp.Errors)
+
+		qp = prepareCursorQueryParams("/?sort:name=asc&offset=5&after=" + after)
+		assert.Equal(t, []QueryParamError{{"offset", "offset can't be combined with after"}}, qp.Errors)
+
+		qp = prepareCursorQueryParams("/?after=abc&total=maybe")
+		assert.Equal(t, []QueryParamError{{"after", ErrInvalidCursor.Error()}, {"total", "total must be true or false"}}, qp.Errors)
+
+		qp = prepareCursorQueryParams("/?after=" + after + "&before=" + after)
+		assert.Equal(t, []QueryParamError{{"before", "before can't be combined with after"}}, qp.Errors)
+	})
+
+	t.Run("PaginationHeaders", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		qp := NewQueryParams()
+		qp.WithTotal = false
+		qp.NextCursor = "next"
+		qp.PaginationHeaders(c, 5)
+
+		assert.Equal(t, "", w.Header().Get("X-Total-Count"))
+		assert.Equal(t, "next", w.Header().Get("X-Next-Cursor"))
+		assert.Equal(t, "", w.Header().Get("X-Prev-Cursor"))
+	})
+
+	t.Run("PageIntoBqbBeforeWithNullsLast", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		before := EncodeCursor(Cursor{Sorts: "ctime DESC", Values: []interface{}{"2024-01-01T00:00:00Z"}, Id: 7})
+		c.Request = httptest.NewRequest("GET", "/?sort:ctime=desc&before="+before, nil)
+
+		qp := NewQueryParams().
+			PrepareSorts(c, []string{"ctime"}, `"foo.bar"`, models.SortFields{"ctime": {Typ: models.SORT_TYPE_DATE, Nulls: models.SORT_NULLS_LAST}}).
+			PreparePagination(c)
+		assert.Empty(t, qp.Errors)
+
+		bqbQuery := bqb.New(`SELECT * FROM "foo.bar"`)
+		qp.PageIntoBqb(bqbQuery, `"foo.bar"`, "ASC")
+
+		query, params, err := bqbQuery.ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT * FROM "foo.bar" WHERE (("foo.bar".ctime > $1) OR ("foo.bar".ctime = $2 AND "foo.bar".id < $3)) ORDER BY "foo.bar".ctime ASC NULLS FIRST, "foo.bar".id DESC LIMIT $4`, query)
+		assert.Equal(t, []interface{}{"2024-01-01T00:00:00Z", "2024-01-01T00:00:00Z", "7", 10}, params)
+	})
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// ETag is the validator of a response, a hash of its content along with the
+// total count of a list, e.g. `"9f86d081884c7d65"`.
+func ETag(response []byte, total string) string {
+	hash := sha256.New()
+	hash.Write(response)
+	hash.Write([]byte{0})
+	hash.Write([]byte(total))
+	return `"` + hex.EncodeToString(hash.Sum(nil))[:32] + `"`
+}
+
+// ETagMatches reports whether etag is one of the entity tags of an
+// If-None-Match or If-Match header, weak tags are compared as strong ones.
+func ETagMatches(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestETag(t *testing.T) {
+	t.Run("Content", func(t *testing.T) {
+		etag := ETag([]byte(`{"id":1}`), "")
+		assert.Len(t, etag, 34)
+		assert.Equal(t, etag, ETag([]byte(`{"id":1}`), ""))
+		assert.NotEqual(t, etag, ETag([]byte(`{"id":2}`), ""))
+		assert.NotEqual(t, etag, ETag([]byte(`{"id":1}`), "1"))
+	})
+
+	t.Run("Matches", func(t *testing.T) {
+		etag := ETag([]byte(`{"id":1}`), "")
+		assert.True(t, ETagMatches(etag, etag))
+		assert.True(t, ETagMatches(`"other", W/`+etag, etag))
+		assert.True(t, ETagMatches("*", etag))
+		assert.False(t, ETagMatches(`"other"`, etag))
+		assert.False(t, ETagMatches("", etag))
+	})
+}
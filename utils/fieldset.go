@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// -- Sparse fieldsets, fields=name,note selects the attributes and
+// -- include=quotation,payment_term.lines selects the related records returned
+const (
+	FIELDS_PARAM  = "fields"
+	INCLUDE_PARAM = "include"
+)
+
+func splitList(list string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// PrepareFieldset prepares the attributes and relations returned, allowFields
+// are attributes of the response and allowIncludes are relation paths, e.g.
+// quotation.customer. Without fields every attribute is returned, without
+// include every relation, the id is always returned.
+func (qp *QueryParams) PrepareFieldset(c *gin.Context, allowFields []string, allowIncludes []string) *QueryParams {
+	qp.knownParams = append(qp.knownParams, FIELDS_PARAM, INCLUDE_PARAM)
+	qp.DefaultFieldset(allowFields, allowIncludes)
+
+	if fields, ok := c.GetQuery(FIELDS_PARAM); ok {
+		qp.Fields = []string{}
+		for _, field := range splitList(fields) {
+			if !ContainsString(allowFields, field) {
+				qp.addError(FIELDS_PARAM, fmt.Sprintf("field %s is not allowed", field))
+				continue
+			}
+			qp.Fields = append(qp.Fields, field)
+		}
+	}
+
+	if includes, ok := c.GetQuery(INCLUDE_PARAM); ok {
+		qp.Includes = []string{}
+		for _, include := range splitList(includes) {
+			if !ContainsString(allowIncludes, include) {
+				qp.addError(INCLUDE_PARAM, fmt.Sprintf("relation %s is not allowed", include))
+				continue
+			}
+
+			// -- A nested relation includes its parents, e.g. quotation.customer includes quotation
+			parts := strings.Split(include, ".")
+			for index := range parts {
+				if path := strings.Join(parts[:index+1], "."); !ContainsString(qp.Includes, path) {
+					qp.Includes = append(qp.Includes, path)
+				}
+			}
+		}
+	}
+	return qp
+}
+
+// DefaultFieldset prepares every attribute and relation, the representation
+// of a record regardless of the fields and include of the request.
+func (qp *QueryParams) DefaultFieldset(allowFields []string, allowIncludes []string) *QueryParams {
+	qp.AllowedFields = allowFields
+	qp.AllowedIncludes = allowIncludes
+	qp.Includes = append([]string{}, allowIncludes...)
+	return qp
+}
+
+// IsIncluded reports whether the relation path is returned, services skip the
+// joins of relations that aren't.
+func (qp *QueryParams) IsIncluded(path string) bool {
+	return ContainsString(qp.Includes, path)
+}
+
+// SparseFieldset keeps the prepared attributes and relations of value, a
+// record or a list of records.
+func (qp *QueryParams) SparseFieldset(value interface{}) (interface{}, error) {
+	if qp.Fields == nil && len(qp.Includes) == len(qp.AllowedIncludes) {
+		return value, nil
+	}
+
+	valueByte, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded interface{}
+	decoder := json.NewDecoder(bytes.NewReader(valueByte))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	return qp.sparse(decoded, ""), nil
+}
+
+func (qp *QueryParams) sparse(value interface{}, path string) interface{} {
+	switch value := value.(type) {
+	case []interface{}:
+		for index := range value {
+			value[index] = qp.sparse(value[index], path)
+		}
+	case map[string]interface{}:
+		for key, child := range value {
+			keyPath := key
+			if path != "" {
+				keyPath = fmt.Sprintf("%s.%s", path, key)
+			}
+
+			switch {
+			case ContainsString(qp.AllowedIncludes, keyPath) && qp.IsIncluded(keyPath):
+				value[key] = qp.sparse(child, keyPath)
+			case ContainsString(qp.AllowedIncludes, keyPath):
+				delete(value, key)
+			case path == "" && qp.Fields != nil && key != "id" && !ContainsString(qp.Fields, key):
+				delete(value, key)
+			}
+		}
+	}
+	return value
+}
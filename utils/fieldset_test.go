@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func prepareFieldsetQueryParams(target string) *QueryParams {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", target, nil)
+
+	return NewQueryParams().
+		PrepareFieldset(c, []string{"name", "note"}, []string{"quotation", "quotation.customer", "payment_term"})
+}
+
+func TestFieldset(t *testing.T) {
+	order := map[string]interface{}{
+		"id":   1,
+		"name": "Order 1",
+		"note": "note",
+		"quotation": map[string]interface{}{
+			"id":       2,
+			"customer": map[string]interface{}{"id": 3},
+		},
+		"payment_term": map[string]interface{}{"id": 4},
+	}
+
+	t.Run("WithoutFieldset", func(t *testing.T) {
+		qp := prepareFieldsetQueryParams("/")
+		assert.True(t, qp.IsIncluded("quotation.customer"))
+
+		sparse, err := qp.SparseFieldset(order)
+		assert.NoError(t, err)
+		assert.Equal(t, order, sparse)
+	})
+
+	t.Run("SparseFieldset", func(t *testing.T) {
+		qp := prepareFieldsetQueryParams("/?fields=name&include=quotation")
+		assert.Empty(t, qp.Errors)
+		assert.True(t, qp.IsIncluded("quotation"))
+		assert.False(t, qp.IsIncluded("quotation.customer"))
+		assert.False(t, qp.IsIncluded("payment_term"))
+
+		sparse, err := qp.SparseFieldset([]interface{}{order})
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{map[string]interface{}{
+			"id":        json.Number("1"),
+			"name":      "Order 1",
+			"quotation": map[string]interface{}{"id": json.Number("2")},
+		}}, sparse)
+	})
+
+	t.Run("DefaultFieldset", func(t *testing.T) {
+		qp := NewQueryParams().
+			DefaultFieldset([]string{"name", "note"}, []string{"quotation", "quotation.customer", "payment_term"})
+		assert.Equal(t, prepareFieldsetQueryParams("/").Includes, qp.Includes)
+
+		sparse, err := qp.SparseFieldset(order)
+		assert.NoError(t, err)
+		assert.Equal(t, order, sparse)
+	})
+
+	t.Run("NestedInclude", func(t *testing.T) {
+		qp := prepareFieldsetQueryParams("/?include=quotation.customer")
+		assert.Equal(t, []string{"quotation", "quotation.customer"}, qp.Includes)
+	})
+
+	t.Run("InvalidFieldset", func(t *testing.T) {
+		qp := prepareFieldsetQueryParams("/?fields=name,password&include=customer")
+		assert.Equal(t, []QueryParamError{{"fields", "field password is not allowed"}, {"include", "relation customer is not allowed"}}, qp.Errors)
+	})
+}
@@ -0,0 +1,232 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nullism/bqb"
+)
+
+// -- Query parameter holding a filter expression, e.g.
+// -- filter=(status:eq=quotation|status:eq=quotation_sent),!name:like=test
+// --
+// --   ,   AND, binds tighter than OR
+// --   |   OR
+// --   !   NOT, applies to the following filter or group
+// --   ()  group
+// --   \   escapes the next character, e.g. status:in=quotation\,quotation_sent
+const FILTER_EXPRESSION_PARAM = "filter"
+
+// -- Maximum nesting of groups and negations in a filter expression
+const MAX_FILTER_EXPRESSION_DEPTH = 8
+
+const (
+	FILTER_NODE_AND = "and"
+	FILTER_NODE_OR  = "or"
+	FILTER_NODE_NOT = "not"
+	// -- The child is checked on a related record, see relationFilter
+	FILTER_NODE_EXISTS = "exists"
+)
+
+var (
+	ErrInvalidFilterExpression = errors.New("invalid filter expression")
+	ErrFilterNotAllowed        = errors.New("filter is not allowed")
+)
+
+// FilterNode is a node of a parsed filter expression, a node is either a
+// single filter or a group combining its children with Operator.
+type FilterNode struct {
+	Operator string
+	Filter   FilterValue
+	Children []FilterNode
+	// -- Subquery of an exists node, ? is the condition of the child
+	Subquery string
+}
+
+type filterExpressionParser struct {
+	input                   []rune
+	pos                     int
+	depth                   int
+	allowFilterFieldsAndOps []string
+	prefix                  string
+}
+
+// ParseFilterExpression parses a filter expression, every filter must be one of
+// allowFilterFieldsAndOps and its field is prefixed with prefix.
+func ParseFilterExpression(expression string, allowFilterFieldsAndOps []string, prefix string) (FilterNode, error) {
+	parser := filterExpressionParser{
+		input:                   []rune(expression),
+		allowFilterFieldsAndOps: allowFilterFieldsAndOps,
+		prefix:                  prefix,
+	}
+
+	node, err := parser.parseOr()
+	if err != nil {
+		return FilterNode{}, err
+	}
+	if parser.pos != len(parser.input) {
+		return FilterNode{}, ErrInvalidFilterExpression
+	}
+	return node, nil
+}
+
+func (parser *filterExpressionParser) peek() rune {
+	if parser.pos >= len(parser.input) {
+		return 0
+	}
+	return parser.input[parser.pos]
+}
+
+func (parser *filterExpressionParser) parseOr() (FilterNode, error) {
+	return parser.parseList('|', FILTER_NODE_OR, parser.parseAnd)
+}
+
+func (parser *filterExpressionParser) parseAnd() (FilterNode, error) {
+	return parser.parseList(',', FILTER_NODE_AND, parser.parseUnary)
+}
+
+func (parser *filterExpressionParser) parseList(separator rune, operator string, parseChild func() (FilterNode, error)) (FilterNode, error) {
+	child, err := parseChild()
+	if err != nil {
+		return FilterNode{}, err
+	}
+
+	children := []FilterNode{child}
+	for parser.peek() == separator {
+		parser.pos++
+		child, err := parseChild()
+		if err != nil {
+			return FilterNode{}, err
+		}
+		children = append(children, child)
+	}
+
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return FilterNode{Operator: operator, Children: children}, nil
+}
+
+func (parser *filterExpressionParser) parseUnary() (FilterNode, error) {
+	switch parser.peek() {
+	case '!', '(':
+		parser.depth++
+		if parser.depth > MAX_FILTER_EXPRESSION_DEPTH {
+			return FilterNode{}, ErrInvalidFilterExpression
+		}
+		defer func() { parser.depth-- }()
+	}
+
+	switch parser.peek() {
+	case '!':
+		parser.pos++
+		child, err := parser.parseUnary()
+		if err != nil {
+			return FilterNode{}, err
+		}
+		return FilterNode{Operator: FILTER_NODE_NOT, Children: []FilterNode{child}}, nil
+	case '(':
+		parser.pos++
+		node, err := parser.parseOr()
+		if err != nil {
+			return FilterNode{}, err
+		}
+		if parser.peek() != ')' {
+			return FilterNode{}, ErrInvalidFilterExpression
+		}
+		parser.pos++
+		return node, nil
+	}
+
+	return parser.parseFilter()
+}
+
+// parseFilter parses "field:operator=value" up to the next unescaped , | ( or ).
+func (parser *filterExpressionParser) parseFilter() (FilterNode, error) {
+	var builder strings.Builder
+loop:
+	for parser.pos < len(parser.input) {
+		char := parser.input[parser.pos]
+		switch char {
+		case ',', '|', '(', ')':
+			break loop
+		case '\\':
+			parser.pos++
+			if parser.pos >= len(parser.input) {
+				return FilterNode{}, ErrInvalidFilterExpression
+			}
+			char = parser.input[parser.pos]
+		}
+		builder.WriteRune(char)
+		parser.pos++
+	}
+
+	filter := builder.String()
+	fieldEnd := strings.Index(filter, ":")
+	if fieldEnd == -1 {
+		return FilterNode{}, ErrInvalidFilterExpression
+	}
+	operatorEnd := strings.Index(filter[fieldEnd:], "=")
+	if operatorEnd == -1 {
+		return FilterNode{}, ErrInvalidFilterExpression
+	}
+	operatorEnd += fieldEnd
+
+	field := filter[:fieldEnd]
+	operator := filter[fieldEnd+1 : operatorEnd]
+	value := filter[operatorEnd+1:]
+
+	// -- validate field and operator
+	if !ContainsString(parser.allowFilterFieldsAndOps, fmt.Sprintf("%s:%s", field, operator)) || !ContainsString(ALLOWED_FILTER_OPERATORS, operator) {
+		return FilterNode{}, ErrFilterNotAllowed
+	}
+
+	node, ok := relationFilter(prefixResource(parser.prefix), parser.prefix, field, operator, value)
+	if !ok {
+		return FilterNode{}, ErrFilterNotAllowed
+	}
+	return node, nil
+}
+
+// Condition compiles the node into a condition.
+func (node FilterNode) Condition() *bqb.Query {
+	switch node.Operator {
+	case FILTER_NODE_NOT:
+		return bqb.New("NOT (?)", node.Children[0].Condition())
+	case FILTER_NODE_EXISTS:
+		return bqb.New(node.Subquery, node.Children[0].Condition())
+	case FILTER_NODE_AND, FILTER_NODE_OR:
+		separator := " AND "
+		if node.Operator == FILTER_NODE_OR {
+			separator = " OR "
+		}
+
+		placeholders := make([]string, len(node.Children))
+		conditions := make([]interface{}, len(node.Children))
+		for index, child := range node.Children {
+			placeholders[index] = "?"
+			conditions[index] = child.Condition()
+		}
+		return bqb.New(fmt.Sprintf("(%s)", strings.Join(placeholders, separator)), conditions...)
+	}
+
+	return filterCondition(node.Filter)
+}
+
+func filterCondition(filter FilterValue) *bqb.Query {
+	if filter.Operator == "in" || filter.Operator == "nin" {
+		values := strings.Split(filter.Value, ",")
+		condition := bqb.New(fmt.Sprintf("%s %s (", filter.Field, MAPPED_FILTER_OPERATORS_TO_SQL[filter.Operator]))
+		for i, value := range values {
+			condition.Space("?", value)
+			if i < len(values)-1 {
+				condition.Space(",")
+			}
+		}
+		condition.Space(")")
+		return condition
+	}
+
+	return bqb.New(fmt.Sprintf("%s %s ?", filter.Field, MAPPED_FILTER_OPERATORS_TO_SQL[filter.Operator]), filter.Value)
+}
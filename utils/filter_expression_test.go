@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nullism/bqb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterExpression(t *testing.T) {
+	allow := []string{"status:eq", "status:in", "name:like", "customer_id:eq"}
+
+	t.Run("OrGroup", func(t *testing.T) {
+		node, err := ParseFilterExpression("(status:eq=quotation|status:eq=quotation_sent),customer_id:eq=5", allow, `"sales.quotation"`)
+		assert.NoError(t, err)
+
+		query, params, err := node.Condition().ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `(("sales.quotation".status = $1 OR "sales.quotation".status = $2) AND "sales.quotation".customer_id = $3)`, query)
+		assert.Equal(t, []interface{}{"quotation", "quotation_sent", "5"}, params)
+	})
+
+	t.Run("Precedence", func(t *testing.T) {
+		node, err := ParseFilterExpression("status:eq=a|status:eq=b,customer_id:eq=5", allow, "q")
+		assert.NoError(t, err)
+
+		query, _, err := node.Condition().ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `(q.status = $1 OR (q.status = $2 AND q.customer_id = $3))`, query)
+	})
+
+	t.Run("Negation", func(t *testing.T) {
+		node, err := ParseFilterExpression("!name:like=test,!(status:eq=a|status:eq=b)", allow, "q")
+		assert.NoError(t, err)
+
+		query, params, err := node.Condition().ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `(NOT (q.name LIKE $1) AND NOT ((q.status = $2 OR q.status = $3)))`, query)
+		assert.Equal(t, []interface{}{"%test%", "a", "b"}, params)
+	})
+
+	t.Run("Escape", func(t *testing.T) {
+		node, err := ParseFilterExpression(`status:in=a\,b,name:like=x\|y=z`, allow, "q")
+		assert.NoError(t, err)
+
+		query, params, err := node.Condition().ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `(q.status IN ( $1 , $2 ) AND q.name LIKE $3)`, query)
+		assert.Equal(t, []interface{}{"a", "b", "%x|y=z%"}, params)
+	})
+
+	t.Run("NotAllowed", func(t *testing.T) {
+		_, err := ParseFilterExpression("status:eq=a|password:eq=b", allow, "q")
+		assert.Equal(t, ErrFilterNotAllowed, err)
+
+		_, err = ParseFilterExpression("status:ne=a", allow, "q")
+		assert.Equal(t, ErrFilterNotAllowed, err)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, expression := range []string{"", "(status:eq=a", "status:eq=a)", "status:eq=a,", "status=a", "status:eq", `status:eq=a\`, "!!!!!!!!!status:eq=a"} {
+			_, err := ParseFilterExpression(expression, allow, "q")
+			assert.Error(t, err, expression)
+		}
+	})
+
+	t.Run("PrepareFilters", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		c.Request = httptest.NewRequest("GET", "/?status:eq=a&filter="+url.QueryEscape("(status:eq=b|!customer_id:eq=5)")+"&filter="+url.QueryEscape("password:eq=x"), nil)
+
+		bqbQuery := bqb.New("SELECT * FROM q")
+		qp := NewQueryParams().
+			PrepareFilters(c, allow, "q")
+		qp.FilterIntoBqb(bqbQuery)
+
+		assert.Equal(t, []QueryParamError{{"filter", ErrFilterNotAllowed.Error()}}, qp.Errors)
+
+		query, params, err := bqbQuery.ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT * FROM q WHERE q.status = $1 AND (q.status = $2 OR NOT (q.customer_id = $3))`, query)
+		assert.Equal(t, []interface{}{"a", "b", "5"}, params)
+	})
+}
@@ -13,11 +13,18 @@ type WebTokenClaims struct {
 	Email       string   `json:"email"`
 	Role        string   `json:"role"`
 	Permissions []string `json:"permissions"`
+	// -- Email of the admin acting as the user, empty unless impersonating
+	Act string `json:"act"`
+	// -- Session the token belongs to, empty for impersonation tokens
+	Sid      string `json:"sid"`
+	IssuedAt time.Time
 	jwt.Claims
 }
 
 type RefreshTokenClaims struct {
-	Email string `json:"email"`
+	Email    string `json:"email"`
+	Sid      string `json:"sid"`
+	IssuedAt time.Time
 	jwt.Claims
 }
 
@@ -37,18 +44,31 @@ func GenerateWebToken(c WebTokenClaims) (string, error) {
 	config := config.GetConfigInstance()
 
 	// Create the Claims
-	claims := &jwt.MapClaims{
+	claims := jwt.MapClaims{
 		"email":       c.Email,
 		"role":        c.Role,
 		"permissions": c.Permissions,
+		"iat":         time.Now().Unix(),
 		"exp":         time.Now().Add(time.Second * time.Duration(config.TOKEN_DURATION_SEC)).Unix(),
 	}
 
-	// Generate token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if c.Sid != "" {
+		claims["sid"] = c.Sid
+	}
 
-	// Sign token
-	tokenString, err := token.SignedString([]byte(config.TOKEN_KEY))
+	// Impersonation tokens carry the real user as actor (RFC 8693) and are short-lived
+	if c.Act != "" {
+		claims["act"] = map[string]string{"sub": c.Act}
+		claims["exp"] = time.Now().Add(time.Second * time.Duration(config.IMPERSONATION_TOKEN_SEC)).Unix()
+	}
+
+	keySet, err := GetWebTokenKeySet()
+	if err != nil {
+		return "", err
+	}
+
+	// Sign token with the active key
+	tokenString, err := keySet.Sign(claims)
 	if err != nil {
 		return "", err
 	}
@@ -62,8 +82,12 @@ func GenerateRefreshToken(c RefreshTokenClaims) (string, error) {
 	// Create the Claims
 	claims := &jwt.MapClaims{
 		"email": c.Email,
+		"iat":   time.Now().Unix(),
 		"exp":   time.Now().Add(time.Duration(config.REFRESH_TOKEN_SEC) * time.Second).Unix(),
 	}
+	if c.Sid != "" {
+		(*claims)["sid"] = c.Sid
+	}
 
 	// Generate token
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -78,11 +102,12 @@ func GenerateRefreshToken(c RefreshTokenClaims) (string, error) {
 }
 
 func ValidateWebToken(tokenString string) (WebTokenClaims, error) {
-	config := config.GetConfigInstance()
+	keySet, err := GetWebTokenKeySet()
+	if err != nil {
+		return WebTokenClaims{}, err
+	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(config.TOKEN_KEY), nil
-	})
+	token, err := keySet.Parse(tokenString)
 	if err != nil {
 		return WebTokenClaims{}, err
 	}
@@ -98,10 +123,20 @@ func ValidateWebToken(tokenString string) (WebTokenClaims, error) {
 			permissionsArr = append(permissionsArr, permission.(string))
 		}
 
+		act := ""
+		if actClaim, ok := claims["act"].(map[string]interface{}); ok {
+			if act, ok = actClaim["sub"].(string); !ok || act == "" {
+				return WebTokenClaims{}, fmt.Errorf("invalid act claim")
+			}
+		}
+
 		return WebTokenClaims{
 			Email:       claims["email"].(string),
 			Role:        claims["role"].(string),
 			Permissions: permissionsArr,
+			Act:         act,
+			Sid:         stringClaim(claims, "sid"),
+			IssuedAt:    issuedAt(claims),
 		}, nil
 	}
 
@@ -113,7 +148,7 @@ func ValidateRefreshToken(tokenString string) (RefreshTokenClaims, error) {
 
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		return []byte(config.REFRESH_TOKEN_KEY), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil {
 		return RefreshTokenClaims{}, err
@@ -121,9 +156,26 @@ func ValidateRefreshToken(tokenString string) (RefreshTokenClaims, error) {
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 		return RefreshTokenClaims{
-			Email: claims["email"].(string),
+			Email:    claims["email"].(string),
+			Sid:      stringClaim(claims, "sid"),
+			IssuedAt: issuedAt(claims),
 		}, nil
 	}
 
 	return RefreshTokenClaims{}, fmt.Errorf("invalid token")
 }
+
+// issuedAt returns the iat claim, tokens issued before the claim existed are
+// treated as issued at the epoch so that any revocation applies to them.
+func issuedAt(claims jwt.MapClaims) time.Time {
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		return iat.Time
+	}
+	return time.Unix(0, 0)
+}
+
+// stringClaim returns the claim when it's a string, otherwise empty.
+func stringClaim(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
@@ -0,0 +1,313 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+
+	"system.buon18.com/m/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrInvalidPEM         = errors.New("invalid pem block")
+	ErrUnsupportedAlg     = errors.New("unsupported signing algorithm")
+	ErrKeyDoesNotMatchAlg = errors.New("key does not match signing algorithm")
+	ErrMissingSigningKey  = errors.New("signing key file is required for asymmetric signing")
+)
+
+// WebTokenKeySet signs web tokens with a single active key and verifies them
+// against every key that is still accepted, which allows keys to be rotated
+// without invalidating tokens that are already issued.
+type WebTokenKeySet struct {
+	Method     jwt.SigningMethod
+	KeyId      string
+	signingKey interface{}
+	// -- kid -> public key (or shared secret for HS256)
+	verificationKeys map[string]interface{}
+	// -- Shared secret of kid-less HS256 tokens issued before asymmetric signing
+	legacySecret []byte
+	jwks         JWKS
+}
+
+func NewHMACKeySet(secret []byte) *WebTokenKeySet {
+	return &WebTokenKeySet{
+		Method:           jwt.SigningMethodHS256,
+		signingKey:       secret,
+		verificationKeys: map[string]interface{}{"": secret},
+		jwks:             JWKS{Keys: []JWK{}},
+	}
+}
+
+func NewAsymmetricKeySet(alg string, keyId string, privateKey crypto.Signer, previousKeys map[string]crypto.PublicKey) (*WebTokenKeySet, error) {
+	var method jwt.SigningMethod
+	switch alg {
+	case "RS256":
+		if _, ok := privateKey.(*rsa.PrivateKey); !ok {
+			return nil, fmt.Errorf("%w: %s", ErrKeyDoesNotMatchAlg, alg)
+		}
+		method = jwt.SigningMethodRS256
+	case "EdDSA":
+		if _, ok := privateKey.(ed25519.PrivateKey); !ok {
+			return nil, fmt.Errorf("%w: %s", ErrKeyDoesNotMatchAlg, alg)
+		}
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlg, alg)
+	}
+
+	if keyId == "" {
+		keyId = KeyThumbprint(privateKey.Public())
+	}
+
+	keySet := &WebTokenKeySet{
+		Method:           method,
+		KeyId:            keyId,
+		signingKey:       privateKey,
+		verificationKeys: map[string]interface{}{keyId: privateKey.Public()},
+		jwks:             JWKS{Keys: []JWK{}},
+	}
+	for kid, publicKey := range previousKeys {
+		if kid == keyId {
+			continue
+		}
+		keySet.verificationKeys[kid] = publicKey
+	}
+
+	// -- The active key comes first and the previous keys are sorted, so that
+	// -- the key set is the same across processes and can be cached
+	kids := make([]string, 0, len(keySet.verificationKeys))
+	for kid := range keySet.verificationKeys {
+		if kid != keyId {
+			kids = append(kids, kid)
+		}
+	}
+	sort.Strings(kids)
+
+	for _, kid := range append([]string{keyId}, kids...) {
+		jwk, err := NewJWK(kid, keySet.verificationKeys[kid])
+		if err != nil {
+			return nil, err
+		}
+		keySet.jwks.Keys = append(keySet.jwks.Keys, jwk)
+	}
+
+	return keySet, nil
+}
+
+// AcceptLegacyHMAC keeps verifying the kid-less HS256 tokens signed with secret
+// while moving from HS256 to an asymmetric key, nothing is signed with it and
+// it is never published.
+func (keySet *WebTokenKeySet) AcceptLegacyHMAC(secret []byte) *WebTokenKeySet {
+	if keySet.KeyId != "" && len(secret) > 0 {
+		keySet.legacySecret = secret
+	}
+	return keySet
+}
+
+func (keySet *WebTokenKeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(keySet.Method, claims)
+	if keySet.KeyId != "" {
+		token.Header["kid"] = keySet.KeyId
+	}
+	return token.SignedString(keySet.signingKey)
+}
+
+func (keySet *WebTokenKeySet) Parse(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if keySet.KeyId == "" {
+			return keySet.verificationKeys[""], nil
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+			if kid == "" && keySet.legacySecret != nil {
+				return keySet.legacySecret, nil
+			}
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlg, token.Method.Alg())
+		}
+		if key, ok := keySet.verificationKeys[kid]; ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKeyId, kid)
+	}, jwt.WithValidMethods(keySet.validMethods()))
+}
+
+func (keySet *WebTokenKeySet) validMethods() []string {
+	if keySet.KeyId == "" {
+		return []string{jwt.SigningMethodHS256.Alg()}
+	}
+
+	// -- Previous keys may use another algorithm than the active one
+	methods := []string{}
+	for _, key := range keySet.verificationKeys {
+		switch key.(type) {
+		case *rsa.PublicKey:
+			if !ContainsString(methods, jwt.SigningMethodRS256.Alg()) {
+				methods = append(methods, jwt.SigningMethodRS256.Alg())
+			}
+		case ed25519.PublicKey:
+			if !ContainsString(methods, jwt.SigningMethodEdDSA.Alg()) {
+				methods = append(methods, jwt.SigningMethodEdDSA.Alg())
+			}
+		}
+	}
+	if keySet.legacySecret != nil {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	return methods
+}
+
+// JWKS returns the public verification keys, the shared secret of HS256 is
+// never published.
+func (keySet *WebTokenKeySet) JWKS() JWKS {
+	return keySet.jwks
+}
+
+// KeyThumbprint derives a stable key id from the public key.
+func KeyThumbprint(publicKey crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:])[:16]
+}
+
+func NewJWK(kid string, publicKey crypto.PublicKey) (JWK, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			Alg: jwt.SigningMethodRS256.Alg(),
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Kid: kid,
+			Use: "sig",
+			Alg: jwt.SigningMethodEdDSA.Alg(),
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key),
+		}, nil
+	}
+	return JWK{}, ErrUnsupportedKeyType
+}
+
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidPEM
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedKeyType, block.Type)
+}
+
+// ParsePublicKeyPEM accepts either a public key or the private key it belongs to.
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidPEM
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+
+	signer, err := ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	return signer.Public(), nil
+}
+
+var (
+	webTokenKeySet     *WebTokenKeySet
+	webTokenKeySetErr  error
+	webTokenKeySetOnce sync.Once
+)
+
+// GetWebTokenKeySet loads the key set from the config on first use.
+func GetWebTokenKeySet() (*WebTokenKeySet, error) {
+	webTokenKeySetOnce.Do(func() {
+		config := config.GetConfigInstance()
+
+		if config.JWT_SIGNING_ALG == "HS256" {
+			webTokenKeySet = NewHMACKeySet([]byte(config.TOKEN_KEY))
+			return
+		}
+
+		if config.JWT_SIGNING_KEY_FILE == "" {
+			webTokenKeySetErr = ErrMissingSigningKey
+			return
+		}
+
+		data, err := os.ReadFile(config.JWT_SIGNING_KEY_FILE)
+		if err != nil {
+			webTokenKeySetErr = err
+			return
+		}
+		privateKey, err := ParsePrivateKeyPEM(data)
+		if err != nil {
+			webTokenKeySetErr = err
+			return
+		}
+
+		previousKeys := map[string]crypto.PublicKey{}
+		for kid, path := range config.JWT_VERIFICATION_KEY_FILES {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				webTokenKeySetErr = err
+				return
+			}
+			publicKey, err := ParsePublicKeyPEM(data)
+			if err != nil {
+				webTokenKeySetErr = fmt.Errorf("verification key %q: %w", kid, err)
+				return
+			}
+			previousKeys[kid] = publicKey
+		}
+
+		webTokenKeySet, webTokenKeySetErr = NewAsymmetricKeySet(config.JWT_SIGNING_ALG, config.JWT_SIGNING_KEY_ID, privateKey, previousKeys)
+		if webTokenKeySetErr != nil {
+			return
+		}
+
+		// -- Tokens issued with TOKEN_KEY stay valid until they expire, TOKEN_KEY
+		// -- is removed once TOKEN_DURATION_SEC has passed since the switch
+		webTokenKeySet.AcceptLegacyHMAC([]byte(config.TOKEN_KEY))
+	})
+
+	return webTokenKeySet, webTokenKeySetErr
+}
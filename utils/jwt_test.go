@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebTokenKeySet(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	claims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"email": "admin@buon18.com",
+			"exp":   time.Now().Add(time.Minute).Unix(),
+		}
+	}
+
+	t.Run("RS256", func(t *testing.T) {
+		keySet, err := NewAsymmetricKeySet("RS256", "rsa-1", rsaKey, nil)
+		assert.NoError(t, err)
+
+		tokenString, err := keySet.Sign(claims())
+		assert.NoError(t, err)
+
+		token, err := keySet.Parse(tokenString)
+		assert.NoError(t, err)
+		assert.True(t, token.Valid)
+		assert.Equal(t, "rsa-1", token.Header["kid"])
+		assert.Equal(t, "RS256", token.Header["alg"])
+	})
+
+	t.Run("EdDSA", func(t *testing.T) {
+		keySet, err := NewAsymmetricKeySet("EdDSA", "", edKey, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, KeyThumbprint(edKey.Public()), keySet.KeyId)
+
+		tokenString, err := keySet.Sign(claims())
+		assert.NoError(t, err)
+
+		token, err := keySet.Parse(tokenString)
+		assert.NoError(t, err)
+		assert.True(t, token.Valid)
+	})
+
+	t.Run("KeyDoesNotMatchAlg", func(t *testing.T) {
+		_, err := NewAsymmetricKeySet("RS256", "", edKey, nil)
+		assert.ErrorIs(t, err, ErrKeyDoesNotMatchAlg)
+
+		_, err = NewAsymmetricKeySet("HS256", "", rsaKey, nil)
+		assert.ErrorIs(t, err, ErrUnsupportedAlg)
+	})
+
+	t.Run("Rotation", func(t *testing.T) {
+		oldKeySet, err := NewAsymmetricKeySet("RS256", "rsa-1", rsaKey, nil)
+		assert.NoError(t, err)
+		oldTokenString, err := oldKeySet.Sign(claims())
+		assert.NoError(t, err)
+
+		// -- The new key signs while the previous key still verifies
+		newKeySet, err := NewAsymmetricKeySet("EdDSA", "ed-2", edKey, map[string]crypto.PublicKey{"rsa-1": rsaKey.Public()})
+		assert.NoError(t, err)
+
+		_, err = newKeySet.Parse(oldTokenString)
+		assert.NoError(t, err)
+
+		newTokenString, err := newKeySet.Sign(claims())
+		assert.NoError(t, err)
+		_, err = newKeySet.Parse(newTokenString)
+		assert.NoError(t, err)
+
+		// -- Once the previous key is retired its tokens are rejected
+		retiredKeySet, err := NewAsymmetricKeySet("EdDSA", "ed-2", edKey, nil)
+		assert.NoError(t, err)
+		_, err = retiredKeySet.Parse(oldTokenString)
+		assert.Error(t, err)
+
+		jwks := newKeySet.JWKS()
+		assert.Len(t, jwks.Keys, 2)
+		assert.Equal(t, "ed-2", jwks.Keys[0].Kid)
+		for _, jwk := range jwks.Keys {
+			publicKey, err := jwk.PublicKey()
+			assert.NoError(t, err)
+			if jwk.Kid == "rsa-1" {
+				assert.Equal(t, rsaKey.Public(), publicKey)
+			} else {
+				assert.Equal(t, "ed-2", jwk.Kid)
+				assert.Equal(t, edKey.Public(), publicKey)
+			}
+		}
+	})
+
+	t.Run("HS256Transition", func(t *testing.T) {
+		legacyTokenString, err := NewHMACKeySet([]byte("secret")).Sign(claims())
+		assert.NoError(t, err)
+
+		// -- Tokens signed with TOKEN_KEY still verify after switching to RS256
+		keySet, err := NewAsymmetricKeySet("RS256", "rsa-1", rsaKey, nil)
+		assert.NoError(t, err)
+		keySet.AcceptLegacyHMAC([]byte("secret"))
+
+		_, err = keySet.Parse(legacyTokenString)
+		assert.NoError(t, err)
+		assert.Len(t, keySet.JWKS().Keys, 1)
+
+		newTokenString, err := keySet.Sign(claims())
+		assert.NoError(t, err)
+		token, err := keySet.Parse(newTokenString)
+		assert.NoError(t, err)
+		assert.Equal(t, "RS256", token.Header["alg"])
+
+		// -- The secret only verifies kid-less tokens
+		token = jwt.NewWithClaims(jwt.SigningMethodHS256, claims())
+		token.Header["kid"] = "rsa-1"
+		tokenString, err := token.SignedString([]byte("secret"))
+		assert.NoError(t, err)
+		_, err = keySet.Parse(tokenString)
+		assert.Error(t, err)
+
+		forgedTokenString, err := NewHMACKeySet([]byte("other")).Sign(claims())
+		assert.NoError(t, err)
+		_, err = keySet.Parse(forgedTokenString)
+		assert.Error(t, err)
+
+		// -- Once TOKEN_KEY is removed its tokens are rejected
+		cutoverKeySet, err := NewAsymmetricKeySet("RS256", "rsa-1", rsaKey, nil)
+		assert.NoError(t, err)
+		cutoverKeySet.AcceptLegacyHMAC(nil)
+		_, err = cutoverKeySet.Parse(legacyTokenString)
+		assert.Error(t, err)
+	})
+
+	t.Run("StableJWKSOrder", func(t *testing.T) {
+		previousKeys := map[string]crypto.PublicKey{"rsa-c": rsaKey.Public(), "rsa-a": rsaKey.Public(), "rsa-b": rsaKey.Public()}
+		for i := 0; i < 5; i++ {
+			keySet, err := NewAsymmetricKeySet("EdDSA", "ed-2", edKey, previousKeys)
+			assert.NoError(t, err)
+
+			kids := []string{}
+			for _, jwk := range keySet.JWKS().Keys {
+				kids = append(kids, jwk.Kid)
+			}
+			assert.Equal(t, []string{"ed-2", "rsa-a", "rsa-b", "rsa-c"}, kids)
+		}
+	})
+
+	t.Run("UnknownKeyId", func(t *testing.T) {
+		keySet, err := NewAsymmetricKeySet("RS256", "rsa-1", rsaKey, nil)
+		assert.NoError(t, err)
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims())
+		token.Header["kid"] = "rsa-unknown"
+		tokenString, err := token.SignedString(rsaKey)
+		assert.NoError(t, err)
+
+		_, err = keySet.Parse(tokenString)
+		assert.ErrorIs(t, err, ErrUnknownKeyId)
+	})
+
+	t.Run("RejectHMACWithPublicKey", func(t *testing.T) {
+		keySet, err := NewAsymmetricKeySet("RS256", "rsa-1", rsaKey, nil)
+		assert.NoError(t, err)
+
+		der, err := x509.MarshalPKIXPublicKey(rsaKey.Public())
+		assert.NoError(t, err)
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims())
+		token.Header["kid"] = "rsa-1"
+		tokenString, err := token.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+		assert.NoError(t, err)
+
+		_, err = keySet.Parse(tokenString)
+		assert.Error(t, err)
+	})
+
+	t.Run("HS256", func(t *testing.T) {
+		keySet := NewHMACKeySet([]byte("secret"))
+
+		tokenString, err := keySet.Sign(claims())
+		assert.NoError(t, err)
+
+		_, err = keySet.Parse(tokenString)
+		assert.NoError(t, err)
+		assert.Empty(t, keySet.JWKS().Keys)
+	})
+
+	t.Run("ParsePEM", func(t *testing.T) {
+		der, err := x509.MarshalPKCS8PrivateKey(edKey)
+		assert.NoError(t, err)
+		privateKey, err := ParsePrivateKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+		assert.NoError(t, err)
+		assert.Equal(t, edKey.Public(), privateKey.Public())
+
+		pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+		publicKey, err := ParsePublicKeyPEM(pkcs1)
+		assert.NoError(t, err)
+		assert.Equal(t, rsaKey.Public(), publicKey)
+
+		_, err = ParsePrivateKeyPEM([]byte("not a pem"))
+		assert.ErrorIs(t, err, ErrInvalidPEM)
+	})
+}
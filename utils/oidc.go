@@ -0,0 +1,282 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrOIDCNotConfigured  = errors.New("openid connect is not configured")
+	ErrOIDCDiscovery      = errors.New("unable to discover openid connect provider")
+	ErrOIDCExchange       = errors.New("unable to exchange authorization code")
+	ErrOIDCInvalidIdToken = errors.New("invalid id token")
+	ErrOIDCInvalidNonce   = errors.New("invalid id token nonce")
+)
+
+type OIDCConfig struct {
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+type OIDCTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IdToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+type OIDCClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Nonce         string
+	Raw           map[string]interface{}
+}
+
+type OIDCProvider struct {
+	Config     OIDCConfig
+	HTTPClient *http.Client
+
+	lock      sync.Mutex
+	discovery *oidcDiscovery
+	jwks      *JWKS
+}
+
+func NewOIDCProvider(config OIDCConfig) *OIDCProvider {
+	return &OIDCProvider{
+		Config:     config,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// RandomString returns a url-safe random string of n bytes of entropy.
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (provider *OIDCProvider) discover() (*oidcDiscovery, error) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
+
+	if provider.discovery != nil {
+		return provider.discovery, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := provider.getJSON(provider.Config.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCDiscovery, err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != provider.Config.Issuer {
+		return nil, fmt.Errorf("%w: issuer mismatch %q", ErrOIDCDiscovery, discovery.Issuer)
+	}
+
+	provider.discovery = &discovery
+	return provider.discovery, nil
+}
+
+func (provider *OIDCProvider) keys(refresh bool) (*JWKS, error) {
+	discovery, err := provider.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
+
+	if provider.jwks != nil && !refresh {
+		return provider.jwks, nil
+	}
+
+	var jwks JWKS
+	if err := provider.getJSON(discovery.JwksURI, &jwks); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCDiscovery, err)
+	}
+
+	provider.jwks = &jwks
+	return provider.jwks, nil
+}
+
+func (provider *OIDCProvider) getJSON(url string, v interface{}) error {
+	resp, err := provider.HTTPClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, url)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (provider *OIDCProvider) AuthCodeURL(state, nonce string) (string, error) {
+	discovery, err := provider.discover()
+	if err != nil {
+		return "", err
+	}
+
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", provider.Config.ClientId)
+	values.Set("redirect_uri", provider.Config.RedirectURL)
+	values.Set("scope", strings.Join(provider.Config.Scopes, " "))
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return discovery.AuthorizationEndpoint + separator + values.Encode(), nil
+}
+
+func (provider *OIDCProvider) Exchange(code string) (OIDCTokenResponse, error) {
+	discovery, err := provider.discover()
+	if err != nil {
+		return OIDCTokenResponse{}, err
+	}
+
+	values := url.Values{}
+	values.Set("grant_type", "authorization_code")
+	values.Set("code", code)
+	values.Set("redirect_uri", provider.Config.RedirectURL)
+
+	req, err := http.NewRequest("POST", discovery.TokenEndpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return OIDCTokenResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(provider.Config.ClientId), url.QueryEscape(provider.Config.ClientSecret))
+
+	resp, err := provider.HTTPClient.Do(req)
+	if err != nil {
+		return OIDCTokenResponse{}, fmt.Errorf("%w: %v", ErrOIDCExchange, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return OIDCTokenResponse{}, fmt.Errorf("%w: status code %d", ErrOIDCExchange, resp.StatusCode)
+	}
+
+	var tokenResponse OIDCTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		return OIDCTokenResponse{}, fmt.Errorf("%w: %v", ErrOIDCExchange, err)
+	}
+	if tokenResponse.IdToken == "" {
+		return OIDCTokenResponse{}, fmt.Errorf("%w: missing id_token", ErrOIDCExchange)
+	}
+
+	return tokenResponse, nil
+}
+
+func (provider *OIDCProvider) VerifyIdToken(rawIdToken, nonce string) (OIDCClaims, error) {
+	jwks, err := provider.keys(false)
+	if err != nil {
+		return OIDCClaims{}, err
+	}
+
+	parse := func(jwks *JWKS) (*jwt.Token, error) {
+		return jwt.Parse(
+			rawIdToken,
+			jwks.Keyfunc,
+			jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "EdDSA"}),
+			jwt.WithIssuer(provider.Config.Issuer),
+			jwt.WithAudience(provider.Config.ClientId),
+			jwt.WithExpirationRequired(),
+		)
+	}
+
+	token, err := parse(jwks)
+	if errors.Is(err, ErrUnknownKeyId) {
+		// -- The provider may have rotated its keys
+		if jwks, err = provider.keys(true); err != nil {
+			return OIDCClaims{}, err
+		}
+		token, err = parse(jwks)
+	}
+	if err != nil {
+		return OIDCClaims{}, fmt.Errorf("%w: %v", ErrOIDCInvalidIdToken, err)
+	}
+
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return OIDCClaims{}, ErrOIDCInvalidIdToken
+	}
+
+	claims := OIDCClaims{Raw: mapClaims}
+	claims.Subject, _ = mapClaims["sub"].(string)
+	claims.Email, _ = mapClaims["email"].(string)
+	// -- Some providers send the boolean claims as strings
+	switch emailVerified := mapClaims["email_verified"].(type) {
+	case bool:
+		claims.EmailVerified = emailVerified
+	case string:
+		claims.EmailVerified = emailVerified == "true"
+	}
+	claims.Name, _ = mapClaims["name"].(string)
+	claims.Nonce, _ = mapClaims["nonce"].(string)
+
+	if claims.Nonce != nonce {
+		return OIDCClaims{}, ErrOIDCInvalidNonce
+	}
+	if claims.Subject == "" {
+		return OIDCClaims{}, fmt.Errorf("%w: missing sub claim", ErrOIDCInvalidIdToken)
+	}
+
+	return claims, nil
+}
+
+// ClaimValues returns the values of a string or string array claim.
+func (claims OIDCClaims) ClaimValues(name string) []string {
+	values := make([]string, 0)
+	switch value := claims.Raw[name].(type) {
+	case string:
+		values = append(values, value)
+	case []interface{}:
+		for _, v := range value {
+			if str, ok := v.(string); ok {
+				values = append(values, str)
+			}
+		}
+	}
+	return values
+}
+
+// MapClaimToRoleId returns the first role mapped from the claim values, or the
+// default role id when none of the values is mapped.
+func (claims OIDCClaims) MapClaimToRoleId(claimName string, mapping map[string]uint, defaultRoleId uint) uint {
+	for _, value := range claims.ClaimValues(claimName) {
+		if roleId, ok := mapping[value]; ok {
+			return roleId
+		}
+	}
+	return defaultRoleId
+}
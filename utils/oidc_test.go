@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockIdentityProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims
+}
+
+func newMockIdentityProvider(t *testing.T) *mockIdentityProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	idp := &mockIdentityProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(JWKS{Keys: []JWK{{
+			Kty: "RSA",
+			Kid: "mock-key",
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientId, clientSecret, ok := r.BasicAuth()
+		if !ok || clientId != "buon18" || clientSecret != "secret" || r.PostFormValue("code") != "valid-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, idp.claims)
+		token.Header["kid"] = "mock-key"
+		idToken, err := token.SignedString(key)
+		assert.NoError(t, err)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"id_token":     idToken,
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	})
+	idp.server = httptest.NewServer(mux)
+
+	return idp
+}
+
+func TestOIDCProvider(t *testing.T) {
+	idp := newMockIdentityProvider(t)
+	defer idp.server.Close()
+
+	provider := NewOIDCProvider(OIDCConfig{
+		Issuer:       idp.server.URL,
+		ClientId:     "buon18",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/api/auth/oidc/callback",
+		Scopes:       []string{"openid", "email", "profile"},
+	})
+
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":    idp.server.URL,
+			"aud":    "buon18",
+			"sub":    "user-1",
+			"email":  "oidc@buon18.com",
+			"name":   "OIDC User",
+			"nonce":  "nonce",
+			"groups": []string{"everyone", "sales"},
+			"exp":    time.Now().Add(time.Minute).Unix(),
+		}
+	}
+
+	t.Run("AuthCodeURL", func(t *testing.T) {
+		authURL, err := provider.AuthCodeURL("state", "nonce")
+		assert.NoError(t, err)
+
+		parsedURL, err := url.Parse(authURL)
+		assert.NoError(t, err)
+		assert.Equal(t, "/authorize", parsedURL.Path)
+		assert.Equal(t, "code", parsedURL.Query().Get("response_type"))
+		assert.Equal(t, "buon18", parsedURL.Query().Get("client_id"))
+		assert.Equal(t, "openid email profile", parsedURL.Query().Get("scope"))
+		assert.Equal(t, "state", parsedURL.Query().Get("state"))
+		assert.Equal(t, "nonce", parsedURL.Query().Get("nonce"))
+	})
+
+	t.Run("ExchangeAndVerify", func(t *testing.T) {
+		idp.claims = validClaims()
+
+		tokenResponse, err := provider.Exchange("valid-code")
+		assert.NoError(t, err)
+
+		claims, err := provider.VerifyIdToken(tokenResponse.IdToken, "nonce")
+		assert.NoError(t, err)
+		assert.Equal(t, "user-1", claims.Subject)
+		assert.Equal(t, "oidc@buon18.com", claims.Email)
+		assert.False(t, claims.EmailVerified)
+		assert.Equal(t, "OIDC User", claims.Name)
+		assert.Equal(t, uint(4), claims.MapClaimToRoleId("groups", map[string]uint{"sales": 4}, 2))
+		assert.Equal(t, uint(2), claims.MapClaimToRoleId("groups", map[string]uint{"admins": 1}, 2))
+	})
+
+	t.Run("EmailVerified", func(t *testing.T) {
+		for _, emailVerified := range []interface{}{true, "true"} {
+			idp.claims = validClaims()
+			idp.claims["email_verified"] = emailVerified
+
+			tokenResponse, err := provider.Exchange("valid-code")
+			assert.NoError(t, err)
+
+			claims, err := provider.VerifyIdToken(tokenResponse.IdToken, "nonce")
+			assert.NoError(t, err)
+			assert.True(t, claims.EmailVerified)
+		}
+	})
+
+	t.Run("InvalidCode", func(t *testing.T) {
+		_, err := provider.Exchange("invalid-code")
+		assert.ErrorIs(t, err, ErrOIDCExchange)
+	})
+
+	t.Run("InvalidNonce", func(t *testing.T) {
+		idp.claims = validClaims()
+
+		tokenResponse, err := provider.Exchange("valid-code")
+		assert.NoError(t, err)
+
+		_, err = provider.VerifyIdToken(tokenResponse.IdToken, "another-nonce")
+		assert.ErrorIs(t, err, ErrOIDCInvalidNonce)
+	})
+
+	t.Run("InvalidAudience", func(t *testing.T) {
+		idp.claims = validClaims()
+		idp.claims["aud"] = "another-client"
+
+		tokenResponse, err := provider.Exchange("valid-code")
+		assert.NoError(t, err)
+
+		_, err = provider.VerifyIdToken(tokenResponse.IdToken, "nonce")
+		assert.ErrorIs(t, err, ErrOIDCInvalidIdToken)
+	})
+
+	t.Run("ExpiredIdToken", func(t *testing.T) {
+		idp.claims = validClaims()
+		idp.claims["exp"] = time.Now().Add(-time.Minute).Unix()
+
+		tokenResponse, err := provider.Exchange("valid-code")
+		assert.NoError(t, err)
+
+		_, err = provider.VerifyIdToken(tokenResponse.IdToken, "nonce")
+		assert.ErrorIs(t, err, ErrOIDCInvalidIdToken)
+	})
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"system.buon18.com/m/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nullism/bqb"
+)
+
+var ErrUpdateConflict = errors.New("record was modified since it was read")
+
+// RecordMTime returns the mtime of the record of table.
+func RecordMTime(DB *sql.DB, table string, id string) (time.Time, error) {
+	query, params, err := bqb.New(fmt.Sprintf(`SELECT mtime FROM "%s" WHERE id = ?`, table), id).ToPgsql()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var mtime time.Time
+	err = DB.QueryRow(query, params...).Scan(&mtime)
+	return mtime, err
+}
+
+// -- Representation of a record as returned by its detail route without query
+// -- parameters, e.g. the ETag of GET /api/sales/quotations/:id without fields
+type CurrentFunc func() (any, int, error)
+
+// IfMatch resolves the If-Match header of an update into the mtime of the
+// record, so that the update is only applied if the record wasn't modified
+// since. The mtime is read before the representation, a modification in
+// between is rejected by the update itself.
+func IfMatch(c *gin.Context, DB *sql.DB, table string, id string, precondition *models.CommonUpdatePrecondition, current CurrentFunc) (int, error) {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		return 0, nil
+	}
+
+	mtime, err := RecordMTime(DB, table, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// -- The update reports the record as not found
+			return 0, nil
+		}
+		log.Printf("%v", err)
+		return 500, ErrInternalServer
+	}
+
+	representation, statusCode, err := current()
+	if err != nil {
+		return statusCode, err
+	}
+	representationByte, err := json.Marshal(representation)
+	if err != nil {
+		log.Printf("%v", err)
+		return 500, ErrInternalServer
+	}
+	if !ETagMatches(ifMatch, ETag(representationByte, "")) {
+		return 409, ErrUpdateConflict
+	}
+
+	precondition.MatchMTime(mtime)
+	return 0, nil
+}
+
+// UpdateErrorResponse responds with the error of an update, a conflict is
+// answered with the current representation of the record under fieldName.
+func UpdateErrorResponse(c *gin.Context, fieldName string, statusCode int, err error, current CurrentFunc) {
+	if !errors.Is(err, ErrUpdateConflict) {
+		c.JSON(statusCode, NewErrorResponse(statusCode, err.Error()))
+		return
+	}
+
+	// -- The record may have been deleted in the meantime
+	representation, currentStatusCode, currentErr := current()
+	if currentErr != nil {
+		c.JSON(currentStatusCode, NewErrorResponse(currentStatusCode, currentErr.Error()))
+		return
+	}
+
+	if representationByte, err := json.Marshal(representation); err == nil {
+		c.Header("ETag", ETag(representationByte, ""))
+	}
+	c.JSON(409, NewResponse(409, err.Error(), gin.H{
+		fieldName: representation,
+	}))
+}
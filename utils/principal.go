@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"database/sql"
+	"log"
+
+	"system.buon18.com/m/models/setting"
+
+	"github.com/nullism/bqb"
+)
+
+// PrincipalQuerier is implemented by both *sql.DB and *sql.Tx, so a principal
+// can be loaded inside the transaction that provisioned the user.
+type PrincipalQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queryEach calls scan on every row of query, the rows are always closed and
+// an iteration that fails midway is an error rather than a partial result.
+func queryEach(q PrincipalQuerier, query string, params []interface{}, scan func(rows *sql.Rows) error) error {
+	rows, err := q.Query(query, params...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// LoadPrincipal loads the user of email with every role, the effective
+// permissions, the record rules of the roles and the allowed companies. The
+// authentication and the issued tokens are both built from it.
+func LoadPrincipal(q PrincipalQuerier, email string) (Principal, error) {
+	// -- Prepare sql query
+	query, params, err := bqb.New(`
+	SELECT 
+		"setting.user".id, 
+		"setting.user".name, 
+		"setting.user".email, 
+		"setting.user".typ, 
+		"setting.user".state, 
+		"setting.user".sessions_revoked_at, 
+		COALESCE("setting.role".id, 0), 
+		COALESCE("setting.role".name, ''), 
+		COALESCE("setting.role".description, '')
+	FROM 
+		"setting.user"
+	LEFT JOIN "setting.role" ON "setting.user".setting_role_id = "setting.role".id 
+		OR "setting.role".id IN (SELECT setting_role_id FROM "setting.user_role" WHERE setting_user_id = "setting.user".id)
+	WHERE "setting.user".email = ?
+	ORDER BY "setting.role".id = "setting.user".setting_role_id DESC, "setting.role".id`, email).ToPgsql()
+	if err != nil {
+		return Principal{}, err
+	}
+
+	// -- Validate user
+	var user setting.SettingUser
+	roles := make([]setting.SettingRole, 0)
+	roleIds := make([]uint, 0)
+	err = queryEach(q, query, params, func(rows *sql.Rows) error {
+		var role setting.SettingRole
+		if err := rows.Scan(&user.Id, &user.Name, &user.Email, &user.Typ, &user.State, &user.SessionsRevokedAt, &role.Id, &role.Name, &role.Description); err != nil {
+			return err
+		}
+
+		if role.Id != 0 {
+			roles = append(roles, role)
+			roleIds = append(roleIds, role.Id)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error querying user: %v\n", err)
+		return Principal{}, err
+	}
+
+	// -- The primary role comes first
+	var role setting.SettingRole
+	if len(roles) > 0 {
+		role = roles[0]
+	}
+
+	// -- Get permissions granted by the roles, including the implied ones
+	permissions := make([]setting.SettingPermission, 0)
+	rolePermissions := map[uint][]string{}
+	if len(roleIds) > 0 {
+		query, params, err = bqb.New(`
+		SELECT
+			"setting.role_permission".setting_role_id,
+			"setting.permission".name
+		FROM
+			"setting.role_permission"
+		LEFT JOIN "setting.permission" ON "setting.role_permission".setting_permission_id = "setting.permission".id
+		WHERE "setting.role_permission".setting_role_id IN (?)`, roleIds).ToPgsql()
+		if err != nil {
+			return Principal{}, err
+		}
+
+		grantedPermissions := make([]string, 0)
+		err = queryEach(q, query, params, func(rows *sql.Rows) error {
+			var roleId uint
+			var name string
+			if err := rows.Scan(&roleId, &name); err != nil {
+				return err
+			}
+			rolePermissions[roleId] = append(rolePermissions[roleId], name)
+			grantedPermissions = append(grantedPermissions, name)
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error querying permissions: %v\n", err)
+			return Principal{}, err
+		}
+
+		for roleId, granted := range rolePermissions {
+			rolePermissions[roleId] = EffectivePermissions(granted)
+		}
+
+		if effectivePermissions := EffectivePermissions(grantedPermissions); len(effectivePermissions) > 0 {
+			query, params, err = bqb.New(`
+			SELECT
+				id,
+				name
+			FROM
+				"setting.permission"
+			WHERE name IN (?)
+			ORDER BY id`, effectivePermissions).ToPgsql()
+			if err != nil {
+				return Principal{}, err
+			}
+
+			err = queryEach(q, query, params, func(rows *sql.Rows) error {
+				var permission setting.SettingPermission
+				if err := rows.Scan(&permission.Id, &permission.Name); err != nil {
+					return err
+				}
+				permissions = append(permissions, permission)
+				return nil
+			})
+			if err != nil {
+				log.Printf("Error querying permissions: %v\n", err)
+				return Principal{}, err
+			}
+		}
+	}
+
+	// -- Get record rules of the roles
+	query, params, err = bqb.New(`
+	SELECT
+		id,
+		setting_role_id,
+		resource,
+		field,
+		operator,
+		value
+	FROM
+		"setting.record_rule"
+	WHERE setting_role_id IN (
+		SELECT setting_role_id FROM "setting.user" WHERE id = ?
+		UNION
+		SELECT setting_role_id FROM "setting.user_role" WHERE setting_user_id = ?
+	)
+	ORDER BY id`, user.Id, user.Id).ToPgsql()
+	if err != nil {
+		return Principal{}, err
+	}
+
+	recordRules := make([]setting.SettingRecordRule, 0)
+	err = queryEach(q, query, params, func(rows *sql.Rows) error {
+		var recordRule setting.SettingRecordRule
+		if err := rows.Scan(&recordRule.Id, &recordRule.SettingRoleId, &recordRule.Resource, &recordRule.Field, &recordRule.Operator, &recordRule.Value); err != nil {
+			return err
+		}
+		recordRules = append(recordRules, recordRule)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error querying record rules: %v\n", err)
+		return Principal{}, err
+	}
+
+	// -- Get allowed companies
+	query, params, err = bqb.New(`
+	SELECT
+		"setting.company".id,
+		"setting.company".name
+	FROM
+		"setting.user_company"
+	LEFT JOIN "setting.company" ON "setting.user_company".setting_company_id = "setting.company".id
+	WHERE "setting.user_company".setting_user_id = ?
+	ORDER BY "setting.company".id`, user.Id).ToPgsql()
+	if err != nil {
+		return Principal{}, err
+	}
+
+	companies := make([]setting.SettingCompany, 0)
+	err = queryEach(q, query, params, func(rows *sql.Rows) error {
+		var company setting.SettingCompany
+		if err := rows.Scan(&company.Id, &company.Name); err != nil {
+			return err
+		}
+		companies = append(companies, company)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error querying companies: %v\n", err)
+		return Principal{}, err
+	}
+
+	// -- Get sessions revoked before their expiry
+	query, params, err = bqb.New(`
+	SELECT
+		sid
+	FROM
+		"setting.session"
+	WHERE setting_user_id = ? AND revoked_at IS NOT NULL AND expires_at > NOW()`, user.Id).ToPgsql()
+	if err != nil {
+		return Principal{}, err
+	}
+
+	revokedSessionIds := make([]string, 0)
+	err = queryEach(q, query, params, func(rows *sql.Rows) error {
+		var sid string
+		if err := rows.Scan(&sid); err != nil {
+			return err
+		}
+		revokedSessionIds = append(revokedSessionIds, sid)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error querying sessions: %v\n", err)
+		return Principal{}, err
+	}
+
+	return Principal{
+		User:              user,
+		Role:              role,
+		Roles:             roles,
+		Permissions:       permissions,
+		RolePermissions:   rolePermissions,
+		RecordRules:       recordRules,
+		Companies:         companies,
+		RevokedSessionIds: revokedSessionIds,
+	}, nil
+}
@@ -0,0 +1,230 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"system.buon18.com/m/config"
+	"system.buon18.com/m/models/setting"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+type Principal struct {
+	User        setting.SettingUser
+	Role        setting.SettingRole
+	Roles       []setting.SettingRole
+	Permissions []setting.SettingPermission
+	// -- Effective permissions of each role, role id -> permission names
+	RolePermissions map[uint][]string
+	RecordRules     []setting.SettingRecordRule
+	// -- Companies the user is allowed to access
+	Companies []setting.SettingCompany
+	// -- Sessions revoked before their expiry
+	RevokedSessionIds []string
+}
+
+type principalCacheEntry struct {
+	principal     Principal
+	sharedVersion uint64
+	expiresAt     time.Time
+}
+
+// PrincipalCacheVersionStore shares the version of the principal cache between
+// the instances of the API, an invalidation on one instance then applies to
+// all of them.
+type PrincipalCacheVersionStore interface {
+	Version() (uint64, error)
+	Bump() error
+}
+
+// -- Version of the principal cache shared through Valkey
+const PRINCIPAL_CACHE_VERSION_KEY = "principal_cache_version"
+
+type valkeyPrincipalCacheVersionStore struct {
+	valkeyClient valkey.Client
+}
+
+func NewValkeyPrincipalCacheVersionStore(valkeyClient valkey.Client) PrincipalCacheVersionStore {
+	return valkeyPrincipalCacheVersionStore{valkeyClient: valkeyClient}
+}
+
+func (store valkeyPrincipalCacheVersionStore) Version() (uint64, error) {
+	version, err := store.valkeyClient.Do(context.Background(), store.valkeyClient.B().Get().Key(PRINCIPAL_CACHE_VERSION_KEY).Build()).AsUint64()
+	if valkey.IsValkeyNil(err) {
+		return 0, nil
+	}
+	return version, err
+}
+
+func (store valkeyPrincipalCacheVersionStore) Bump() error {
+	return store.valkeyClient.Do(context.Background(), store.valkeyClient.B().Incr().Key(PRINCIPAL_CACHE_VERSION_KEY).Build()).Error()
+}
+
+// -- Versions read before loading a principal, see Set
+type PrincipalCacheVersion struct {
+	local  uint64
+	shared uint64
+	// -- The shared version couldn't be read, the principal isn't cached
+	unavailable bool
+}
+
+// PrincipalCache keeps the authenticated user with its role and permissions in
+// memory, so that authentication doesn't hit the database on every request.
+//
+// Every invalidation bumps the version, a principal loaded before the bump is
+// not stored because it may already be stale. With a shared version store the
+// version is also bumped for the other instances, whose entries loaded before
+// the bump are no longer served. Without it the invalidations only reach the
+// local instance, the others see them once their entries expire.
+type PrincipalCache struct {
+	ttl    time.Duration
+	shared PrincipalCacheVersionStore
+
+	lock    sync.RWMutex
+	version uint64
+	// -- email -> principal
+	entries map[string]principalCacheEntry
+}
+
+func NewPrincipalCache(ttl time.Duration) *PrincipalCache {
+	return &PrincipalCache{
+		ttl:     ttl,
+		entries: map[string]principalCacheEntry{},
+	}
+}
+
+// UseSharedVersion shares the invalidations with the other instances through store.
+func (cache *PrincipalCache) UseSharedVersion(store PrincipalCacheVersionStore) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	cache.shared = store
+	cache.version++
+	cache.entries = map[string]principalCacheEntry{}
+}
+
+func (cache *PrincipalCache) Version() PrincipalCacheVersion {
+	cache.lock.RLock()
+	version := PrincipalCacheVersion{local: cache.version}
+	shared := cache.shared
+	cache.lock.RUnlock()
+
+	if shared != nil {
+		sharedVersion, err := shared.Version()
+		if err != nil {
+			log.Printf("PrincipalCache: %v\n", err)
+			version.unavailable = true
+		}
+		version.shared = sharedVersion
+	}
+	return version
+}
+
+func (cache *PrincipalCache) Get(email string) (Principal, bool) {
+	cache.lock.RLock()
+	entry, ok := cache.entries[email]
+	shared := cache.shared
+	cache.lock.RUnlock()
+
+	if !ok || time.Now().After(entry.expiresAt) {
+		return Principal{}, false
+	}
+
+	// -- The entry may have been invalidated by another instance
+	if shared != nil {
+		sharedVersion, err := shared.Version()
+		if err != nil {
+			log.Printf("PrincipalCache: %v\n", err)
+			return Principal{}, false
+		}
+		if sharedVersion != entry.sharedVersion {
+			return Principal{}, false
+		}
+	}
+	return entry.principal, true
+}
+
+// Set stores the principal unless the cache was invalidated after version was read.
+func (cache *PrincipalCache) Set(email string, principal Principal, version PrincipalCacheVersion) {
+	if cache.ttl <= 0 || principal.User.Id == 0 || version.unavailable {
+		return
+	}
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	if version.local != cache.version {
+		return
+	}
+
+	// -- Drop expired entries from time to time instead of running a janitor
+	if len(cache.entries)%256 == 255 {
+		now := time.Now()
+		for key, entry := range cache.entries {
+			if now.After(entry.expiresAt) {
+				delete(cache.entries, key)
+			}
+		}
+	}
+
+	cache.entries[email] = principalCacheEntry{
+		principal:     principal,
+		sharedVersion: version.shared,
+		expiresAt:     time.Now().Add(cache.ttl),
+	}
+}
+
+// InvalidateUser drops the principal of the user, the other instances drop
+// every principal loaded before as they can't tell which one is the user's.
+func (cache *PrincipalCache) InvalidateUser(userId uint) {
+	cache.lock.Lock()
+	cache.version++
+	for key, entry := range cache.entries {
+		if entry.principal.User.Id == userId {
+			delete(cache.entries, key)
+		}
+	}
+	cache.lock.Unlock()
+
+	cache.bumpShared()
+}
+
+// InvalidateAll is used when a change may affect many users, e.g. role permissions.
+func (cache *PrincipalCache) InvalidateAll() {
+	cache.lock.Lock()
+	cache.version++
+	cache.entries = map[string]principalCacheEntry{}
+	cache.lock.Unlock()
+
+	cache.bumpShared()
+}
+
+func (cache *PrincipalCache) bumpShared() {
+	cache.lock.RLock()
+	shared := cache.shared
+	cache.lock.RUnlock()
+
+	if shared == nil {
+		return
+	}
+	if err := shared.Bump(); err != nil {
+		log.Printf("PrincipalCache: %v\n", err)
+	}
+}
+
+var (
+	principalCache     *PrincipalCache
+	principalCacheOnce sync.Once
+)
+
+func GetPrincipalCache() *PrincipalCache {
+	principalCacheOnce.Do(func() {
+		config := config.GetConfigInstance()
+		principalCache = NewPrincipalCache(time.Duration(config.PRINCIPAL_CACHE_SEC) * time.Second)
+	})
+
+	return principalCache
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"system.buon18.com/m/models/setting"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrincipalCache(t *testing.T) {
+	admin := Principal{
+		User:        setting.SettingUser{Id: 1, Email: "admin@buon18.com"},
+		Role:        setting.SettingRole{Id: 1, Name: "Admin"},
+		Permissions: []setting.SettingPermission{{Id: 1, Name: "FULL_ACCESS"}},
+	}
+	sales := Principal{
+		User: setting.SettingUser{Id: 2, Email: "sales@buon18.com"},
+		Role: setting.SettingRole{Id: 2, Name: "Sales"},
+	}
+
+	t.Run("GetAndSet", func(t *testing.T) {
+		cache := NewPrincipalCache(time.Minute)
+
+		_, ok := cache.Get(admin.User.Email)
+		assert.False(t, ok)
+
+		cache.Set(admin.User.Email, admin, cache.Version())
+		principal, ok := cache.Get(admin.User.Email)
+		assert.True(t, ok)
+		assert.Equal(t, admin, principal)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		cache := NewPrincipalCache(time.Millisecond)
+
+		cache.Set(admin.User.Email, admin, cache.Version())
+		time.Sleep(5 * time.Millisecond)
+		_, ok := cache.Get(admin.User.Email)
+		assert.False(t, ok)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		cache := NewPrincipalCache(0)
+
+		cache.Set(admin.User.Email, admin, cache.Version())
+		_, ok := cache.Get(admin.User.Email)
+		assert.False(t, ok)
+	})
+
+	t.Run("UnknownUserIsNotCached", func(t *testing.T) {
+		cache := NewPrincipalCache(time.Minute)
+
+		cache.Set("unknown@buon18.com", Principal{}, cache.Version())
+		_, ok := cache.Get("unknown@buon18.com")
+		assert.False(t, ok)
+	})
+
+	t.Run("InvalidateUser", func(t *testing.T) {
+		cache := NewPrincipalCache(time.Minute)
+		cache.Set(admin.User.Email, admin, cache.Version())
+		cache.Set(sales.User.Email, sales, cache.Version())
+
+		cache.InvalidateUser(sales.User.Id)
+		_, ok := cache.Get(sales.User.Email)
+		assert.False(t, ok)
+		_, ok = cache.Get(admin.User.Email)
+		assert.True(t, ok)
+	})
+
+	t.Run("InvalidateAll", func(t *testing.T) {
+		cache := NewPrincipalCache(time.Minute)
+		cache.Set(admin.User.Email, admin, cache.Version())
+		cache.Set(sales.User.Email, sales, cache.Version())
+
+		cache.InvalidateAll()
+		_, ok := cache.Get(admin.User.Email)
+		assert.False(t, ok)
+		_, ok = cache.Get(sales.User.Email)
+		assert.False(t, ok)
+	})
+
+	t.Run("StaleSetAfterInvalidation", func(t *testing.T) {
+		cache := NewPrincipalCache(time.Minute)
+
+		// -- The principal was loaded before the role changed
+		version := cache.Version()
+		cache.InvalidateAll()
+		cache.Set(sales.User.Email, sales, version)
+
+		_, ok := cache.Get(sales.User.Email)
+		assert.False(t, ok)
+	})
+
+	t.Run("SharedInvalidation", func(t *testing.T) {
+		store := &memoryPrincipalCacheVersionStore{}
+		instanceA := NewPrincipalCache(time.Minute)
+		instanceA.UseSharedVersion(store)
+		instanceB := NewPrincipalCache(time.Minute)
+		instanceB.UseSharedVersion(store)
+
+		instanceB.Set(admin.User.Email, admin, instanceB.Version())
+		_, ok := instanceB.Get(admin.User.Email)
+		assert.True(t, ok)
+
+		// -- The session of the admin is revoked through instance A
+		instanceA.InvalidateUser(admin.User.Id)
+		_, ok = instanceB.Get(admin.User.Email)
+		assert.False(t, ok)
+
+		// -- A principal loaded on B before the invalidation on A isn't stored
+		version := instanceB.Version()
+		instanceA.InvalidateAll()
+		instanceB.Set(admin.User.Email, admin, version)
+		_, ok = instanceB.Get(admin.User.Email)
+		assert.False(t, ok)
+	})
+
+	t.Run("SharedVersionUnavailable", func(t *testing.T) {
+		cache := NewPrincipalCache(time.Minute)
+		cache.UseSharedVersion(&memoryPrincipalCacheVersionStore{err: errors.New("connection refused")})
+
+		cache.Set(admin.User.Email, admin, cache.Version())
+		_, ok := cache.Get(admin.User.Email)
+		assert.False(t, ok)
+	})
+}
+
+type memoryPrincipalCacheVersionStore struct {
+	version uint64
+	err     error
+}
+
+func (store *memoryPrincipalCacheVersionStore) Version() (uint64, error) {
+	return store.version, store.err
+}
+
+func (store *memoryPrincipalCacheVersionStore) Bump() error {
+	store.version++
+	return store.err
+}
@@ -1,9 +1,14 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
+	"system.buon18.com/m/models"
+
 	"github.com/gin-gonic/gin"
 	"github.com/nullism/bqb"
 )
@@ -22,6 +27,29 @@ var MAPPED_FILTER_OPERATORS_TO_SQL = map[string]string{
 	"nin":  "NOT IN",
 }
 
+// -- Maximum number of records a list request can ask for with limit
+var MAX_PAGE_SIZE = 100
+
+var ErrInvalidQueryParams = errors.New("invalid query parameters")
+
+// QueryParamError describes an offending query parameter of a list request.
+type QueryParamError struct {
+	Param   string `json:"param"`
+	Message string `json:"message"`
+}
+
+// QueryParamsErrorData is returned with 400 along with the filters, sorts,
+// fields, relations, groups and aggregates that the endpoint accepts.
+type QueryParamsErrorData struct {
+	Errors            []QueryParamError `json:"errors"`
+	AllowedFilters    []string          `json:"allowed_filters"`
+	AllowedSorts      []string          `json:"allowed_sorts"`
+	AllowedFields     []string          `json:"allowed_fields,omitempty"`
+	AllowedIncludes   []string          `json:"allowed_includes,omitempty"`
+	AllowedGroups     []string          `json:"allowed_groups,omitempty"`
+	AllowedAggregates []string          `json:"allowed_aggregates,omitempty"`
+}
+
 type FilterValue struct {
 	Field    string
 	Operator string
@@ -34,28 +62,73 @@ type PaginationValue struct {
 }
 
 type QueryParams struct {
-	Fitlers    []FilterValue
-	Pagination PaginationValue
-	OrderBy    []string
+	Fitlers      []FilterValue
+	FilterGroups []FilterNode
+	Conditions   []*bqb.Query
+	Pagination   PaginationValue
+	OrderBy      []string
+	Sorts        []SortValue
+	// -- Resource filters and sorts are resolved against, see FieldExpression
+	Resource string
+
+	// -- Keyset pagination, see PageIntoBqb
+	Cursor     *Cursor
+	NextCursor string
+	PrevCursor string
+	WithTotal  bool
+
+	// -- Sparse fieldsets, see PrepareFieldset
+	Fields   []string
+	Includes []string
+
+	// -- Aggregation, see PrepareAggregate
+	Groups     []GroupValue
+	Aggregates []AggregateValue
+
+	Errors            []QueryParamError
+	AllowedFilters    []string
+	AllowedSorts      []string
+	AllowedFields     []string
+	AllowedIncludes   []string
+	AllowedGroups     []string
+	AllowedAggregates []string
+	knownParams       []string
 }
 
 func NewQueryParams() *QueryParams {
 	return &QueryParams{
-		Fitlers:    []FilterValue{},
-		Pagination: PaginationValue{0, 10},
-		OrderBy:    []string{},
+		Fitlers:      []FilterValue{},
+		FilterGroups: []FilterNode{},
+		Conditions:   []*bqb.Query{},
+		Pagination:   PaginationValue{0, 10},
+		OrderBy:      []string{},
+		Sorts:        []SortValue{},
+		WithTotal:    true,
+
+		Errors:         []QueryParamError{},
+		AllowedFilters: []string{},
+		AllowedSorts:   []string{},
+		knownParams:    []string{},
 	}
 }
 
+func (qp *QueryParams) addError(param string, message string) {
+	qp.Errors = append(qp.Errors, QueryParamError{param, message})
+}
+
+// AddFilter adds a filter of the form "field:operator=value", a malformed
+// filter is reported as an error of the query parameters.
 func (qp *QueryParams) AddFilter(filter string) *QueryParams {
-	// filter = "field:operator=value"
-	filterArr := strings.Split(filter, ":")
+	// -- The value may contain ":" and "="
+	filterArr := strings.SplitN(filter, ":", 2)
 	field := filterArr[0]
 	if len(filterArr) != 2 {
+		qp.addError(filter, "filter must be field:operator=value")
 		return qp
 	}
-	filterArr = strings.Split(filterArr[1], "=")
+	filterArr = strings.SplitN(filterArr[1], "=", 2)
 	if len(filterArr) != 2 {
+		qp.addError(filter, "filter must be field:operator=value")
 		return qp
 	}
 	operator := filterArr[0]
@@ -63,6 +136,7 @@ func (qp *QueryParams) AddFilter(filter string) *QueryParams {
 
 	// -- validate operator
 	if !ContainsString(ALLOWED_FILTER_OPERATORS, operator) {
+		qp.addError(filter, fmt.Sprintf("filter operator must be one of %s", strings.Join(ALLOWED_FILTER_OPERATORS, ", ")))
 		return qp
 	}
 
@@ -74,6 +148,22 @@ func (qp *QueryParams) AddFilter(filter string) *QueryParams {
 	return qp
 }
 
+// AddFilterGroup adds a parsed filter expression, see ParseFilterExpression.
+func (qp *QueryParams) AddFilterGroup(node FilterNode) *QueryParams {
+	qp.FilterGroups = append(qp.FilterGroups, node)
+	return qp
+}
+
+// AddCondition adds a condition that isn't coming from the request, e.g. record rules.
+func (qp *QueryParams) AddCondition(condition *bqb.Query) *QueryParams {
+	if condition == nil {
+		return qp
+	}
+
+	qp.Conditions = append(qp.Conditions, condition)
+	return qp
+}
+
 func (qp *QueryParams) AddOffset(offset int) *QueryParams {
 	if offset < 0 {
 		return qp
@@ -92,41 +182,51 @@ func (qp *QueryParams) AddLimit(limit int) *QueryParams {
 	return qp
 }
 
+// AddOrderBy adds an order of the form "field ASC" or "field DESC", a
+// malformed order is reported as an error of the query parameters.
 func (qp *QueryParams) AddOrderBy(orderBy string) *QueryParams {
-	orderByArr := strings.Split(orderBy, " ")
-	if len(orderByArr) != 2 {
+	// -- The placement of NULLs is kept as is, e.g. "field ASC NULLS LAST"
+	nulls := ""
+	for _, placement := range []string{" NULLS FIRST", " NULLS LAST"} {
+		if strings.HasSuffix(strings.ToUpper(orderBy), placement) {
+			nulls = placement
+			orderBy = orderBy[:len(orderBy)-len(placement)]
+		}
+	}
+
+	// -- The field may be an expression containing spaces, e.g. a subquery
+	separator := strings.LastIndex(orderBy, " ")
+	if separator == -1 {
+		qp.addError(orderBy, "sort must be field asc or desc")
 		return qp
 	}
 
-	field := orderByArr[0]
-	sort := orderByArr[1]
+	field := orderBy[:separator]
+	sort := orderBy[separator+1:]
 
 	if !(strings.EqualFold(sort, "asc") || strings.EqualFold(sort, "desc")) {
+		qp.addError(orderBy, "sort direction must be asc or desc")
 		return qp
 	}
 
-	qp.OrderBy = append(qp.OrderBy, fmt.Sprintf("%s %s", field, strings.ToUpper(sort)))
+	qp.OrderBy = append(qp.OrderBy, fmt.Sprintf("%s %s%s", field, strings.ToUpper(sort), nulls))
 	return qp
 }
 
 func (qp *QueryParams) FilterIntoBqb(bqbQuery *bqb.Query) {
-	if len(qp.Fitlers) > 0 {
+	conditions := append([]*bqb.Query{}, qp.Conditions...)
+	for _, filter := range qp.Fitlers {
+		conditions = append(conditions, filterCondition(filter))
+	}
+	for _, group := range qp.FilterGroups {
+		conditions = append(conditions, group.Condition())
+	}
+
+	if len(conditions) > 0 {
 		bqbQuery.Space("WHERE")
-		for index, filter := range qp.Fitlers {
-			if filter.Operator == "in" || filter.Operator == "nin" {
-				values := strings.Split(filter.Value, ",")
-				bqbQuery.Space(fmt.Sprintf("%s %s (", filter.Field, MAPPED_FILTER_OPERATORS_TO_SQL[filter.Operator]))
-				for i, value := range values {
-					bqbQuery.Space("?", value)
-					if i < len(values)-1 {
-						bqbQuery.Space(",")
-					}
-				}
-				bqbQuery.Space(")")
-			} else {
-				bqbQuery.Space(fmt.Sprintf("%s %s ?", filter.Field, MAPPED_FILTER_OPERATORS_TO_SQL[filter.Operator]), filter.Value)
-			}
-			if index < len(qp.Fitlers)-1 {
+		for index, condition := range conditions {
+			bqbQuery.Space("?", condition)
+			if index < len(conditions)-1 {
 				bqbQuery.Space("AND")
 			}
 		}
@@ -155,18 +255,72 @@ func (qp *QueryParams) PaginationIntoBqb(bqbQuery *bqb.Query) {
 }
 
 func (qp *QueryParams) PrepareFilters(c *gin.Context, allowFilterFieldsAndOps []string, prefix string) *QueryParams {
+	qp.AllowedFilters = append(qp.AllowedFilters, allowFilterFieldsAndOps...)
+	qp.knownParams = append(qp.knownParams, allowFilterFieldsAndOps...)
+	qp.knownParams = append(qp.knownParams, FILTER_EXPRESSION_PARAM)
+
+	qp.Resource = prefixResource(prefix)
+
 	for _, filter := range allowFilterFieldsAndOps {
 		if validFilter, ok := c.GetQuery(filter); ok {
-			qp.AddFilter(fmt.Sprintf(`%s.%s=%s`, prefix, filter, validFilter))
+			field, operator, _ := strings.Cut(filter, ":")
+			if !ContainsString(ALLOWED_FILTER_OPERATORS, operator) {
+				continue
+			}
+
+			// -- Filters on relations and computed fields aren't plain columns
+			node, ok := relationFilter(qp.Resource, prefix, field, operator, validFilter)
+			switch {
+			case !ok:
+				qp.addError(filter, ErrFilterNotAllowed.Error())
+			case node.Operator == "":
+				qp.Fitlers = append(qp.Fitlers, node.Filter)
+			default:
+				qp.AddFilterGroup(node)
+			}
+		}
+	}
+
+	// -- Filter expressions
+	for _, expression := range c.QueryArray(FILTER_EXPRESSION_PARAM) {
+		node, err := ParseFilterExpression(expression, allowFilterFieldsAndOps, prefix)
+		if err != nil {
+			qp.addError(FILTER_EXPRESSION_PARAM, err.Error())
+			continue
 		}
+		qp.AddFilterGroup(node)
 	}
 	return qp
 }
 
-func (qp *QueryParams) PrepareSorts(c *gin.Context, allowSortFields []string, prefix string) *QueryParams {
+// PrepareSorts prepares the sorts on prefix, prefix may be an alias of the
+// resource prepared by PrepareFilters, e.g. "limited_quotations". sortFields
+// describes how the fields are ordered, see models.SortField.
+func (qp *QueryParams) PrepareSorts(c *gin.Context, allowSortFields []string, prefix string, sortFields ...models.SortFields) *QueryParams {
+	qp.AllowedSorts = append(qp.AllowedSorts, allowSortFields...)
+
+	resource := qp.Resource
+	if resource == "" {
+		resource = prefixResource(prefix)
+	}
+
 	for _, sort := range allowSortFields {
-		if validSort, ok := c.GetQuery(fmt.Sprintf("sort:%s", sort)); ok {
-			qp.AddOrderBy(fmt.Sprintf(`LOWER(%s.%s) %s`, prefix, sort, validSort))
+		param := fmt.Sprintf("sort:%s", sort)
+		qp.knownParams = append(qp.knownParams, param)
+
+		if validSort, ok := c.GetQuery(param); ok {
+			if !(strings.EqualFold(validSort, "asc") || strings.EqualFold(validSort, "desc")) {
+				qp.addError(param, "sort direction must be asc or desc")
+				continue
+			}
+			sortValue := SortValue{Field: sort, Direction: strings.ToUpper(validSort)}
+			for _, fields := range sortFields {
+				if sortField, ok := fields[sort]; ok {
+					sortValue.SortField = sortField
+				}
+			}
+			qp.AddOrderBy(sortValue.order(sortExpression(resource, prefix, sortValue)))
+			qp.Sorts = append(qp.Sorts, sortValue)
 		}
 	}
 	return qp
@@ -174,13 +328,69 @@ func (qp *QueryParams) PrepareSorts(c *gin.Context, allowSortFields []string, pr
 
 func (qp *QueryParams) PreparePagination(c *gin.Context) *QueryParams {
 	for _, pagination := range []string{"offset", "limit"} {
+		qp.knownParams = append(qp.knownParams, pagination)
+
 		if validPagination, ok := c.GetQuery(pagination); ok {
+			value, err := strconv.Atoi(validPagination)
+			if err != nil || value < 0 {
+				qp.addError(pagination, fmt.Sprintf("%s must be a non-negative integer", pagination))
+				continue
+			}
+
 			if pagination == "offset" {
-				qp.AddOffset(StrToInt(validPagination, 0))
+				qp.AddOffset(value)
 			} else {
-				qp.AddLimit(StrToInt(validPagination, 10))
+				if value > MAX_PAGE_SIZE {
+					qp.addError(pagination, fmt.Sprintf("limit must not exceed %d", MAX_PAGE_SIZE))
+					continue
+				}
+				qp.AddLimit(value)
 			}
 		}
 	}
+
+	qp.prepareCursor(c)
 	return qp
 }
+
+// Validate reports the offending query parameters collected while preparing
+// along with the parameters the endpoint doesn't accept, it must be called
+// after every Prepare*.
+func (qp *QueryParams) Validate(c *gin.Context) (*Response, bool) {
+	paramErrors := append([]QueryParamError{}, qp.Errors...)
+
+	params := make([]string, 0)
+	for param := range c.Request.URL.Query() {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+
+	for _, param := range params {
+		if ContainsString(qp.knownParams, param) {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(param, "sort:"):
+			paramErrors = append(paramErrors, QueryParamError{param, "sort is not allowed"})
+		case strings.Contains(param, ":"):
+			paramErrors = append(paramErrors, QueryParamError{param, ErrFilterNotAllowed.Error()})
+		default:
+			paramErrors = append(paramErrors, QueryParamError{param, "unknown query parameter"})
+		}
+	}
+
+	if len(paramErrors) == 0 {
+		return nil, true
+	}
+
+	return NewResponse(400, ErrInvalidQueryParams.Error(), QueryParamsErrorData{
+		Errors:            paramErrors,
+		AllowedFilters:    qp.AllowedFilters,
+		AllowedSorts:      qp.AllowedSorts,
+		AllowedFields:     qp.AllowedFields,
+		AllowedIncludes:   qp.AllowedIncludes,
+		AllowedGroups:     qp.AllowedGroups,
+		AllowedAggregates: qp.AllowedAggregates,
+	}), false
+}
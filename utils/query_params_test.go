@@ -4,6 +4,8 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"system.buon18.com/m/models"
+
 	"github.com/gin-gonic/gin"
 	"github.com/nullism/bqb"
 	"github.com/stretchr/testify/assert"
@@ -66,6 +68,21 @@ func TestQueryParams(t *testing.T) {
 		if len(qp.Fitlers) != 0 {
 			t.Errorf("Expected 0, got %d", len(qp.Fitlers))
 		}
+		if len(qp.Errors) != 1 || qp.Errors[0].Param != "field:invalid=value" {
+			t.Errorf("Expected an error for field:invalid=value, got %v", qp.Errors)
+		}
+	})
+
+	t.Run("AddFilterMalformed", func(t *testing.T) {
+		qp := NewQueryParams()
+		qp.AddFilter("field")
+		qp.AddFilter("field:eq")
+		if len(qp.Fitlers) != 0 {
+			t.Errorf("Expected 0, got %d", len(qp.Fitlers))
+		}
+		if len(qp.Errors) != 2 {
+			t.Errorf("Expected 2, got %d", len(qp.Errors))
+		}
 	})
 
 	t.Run("Pagination", func(t *testing.T) {
@@ -115,6 +132,18 @@ func TestQueryParams(t *testing.T) {
 		}
 	})
 
+	t.Run("AddOrderByMalformed", func(t *testing.T) {
+		qp := NewQueryParams()
+		qp.AddOrderBy("field1")
+		qp.AddOrderBy("field2 sideways")
+		if len(qp.OrderBy) != 0 {
+			t.Errorf("Expected 0, got %d", len(qp.OrderBy))
+		}
+		if len(qp.Errors) != 2 {
+			t.Errorf("Expected 2, got %d", len(qp.Errors))
+		}
+	})
+
 	t.Run("FilterIntoBqb", func(t *testing.T) {
 		bqbQuery := bqb.New("SELECT * FROM table")
 		qp := NewQueryParams()
@@ -138,6 +167,32 @@ func TestQueryParams(t *testing.T) {
 		}
 	})
 
+	t.Run("FilterIntoBqbWithConditions", func(t *testing.T) {
+		bqbQuery := bqb.New("SELECT * FROM table")
+		qp := NewQueryParams()
+
+		qp.AddCondition(bqb.New("(cid = ?)", "1"))
+		qp.AddCondition(nil)
+		qp.AddFilter("field1:eq=value1")
+
+		qp.FilterIntoBqb(bqbQuery)
+
+		query, params, err := bqbQuery.ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT * FROM table WHERE (cid = $1) AND field1 = $2`, query)
+		assert.Equal(t, []interface{}{"1", "value1"}, params)
+
+		bqbQuery = bqb.New("SELECT * FROM table")
+		qp = NewQueryParams()
+		qp.AddCondition(bqb.New("(cid = ?)", "1"))
+
+		qp.FilterIntoBqb(bqbQuery)
+
+		query, _, err = bqbQuery.ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT * FROM table WHERE (cid = $1)`, query)
+	})
+
 	t.Run("PaginationIntoBqb", func(t *testing.T) {
 		bqbQuery := bqb.New("SELECT * FROM table")
 		qp := NewQueryParams()
@@ -239,4 +294,77 @@ func TestQueryParams(t *testing.T) {
 		}
 	})
 
+	t.Run("AddFilterWithColonInValue", func(t *testing.T) {
+		qp := NewQueryParams()
+		qp.AddFilter("ctime:gte=2024-01-01T10:00:00")
+		assert.Equal(t, []FilterValue{{"ctime", "gte", "2024-01-01T10:00:00"}}, qp.Fitlers)
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		c.Request = httptest.NewRequest("GET", "/?field1:eq=foo&sort:email=asc&offset=5&limit=20", nil)
+
+		qp := NewQueryParams().
+			PrepareFilters(c, []string{"field1:eq"}, `"foo.bar"`).
+			PrepareSorts(c, []string{"email"}, `"foo.bar"`).
+			PreparePagination(c)
+
+		response, ok := qp.Validate(c)
+		assert.True(t, ok)
+		assert.Nil(t, response)
+		assert.Equal(t, PaginationValue{5, 20}, qp.Pagination)
+	})
+
+	t.Run("ValidateInvalid", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		c.Request = httptest.NewRequest("GET", "/?field1:like=foo&field1=foo&sort:email=up&sort:name=asc&offset=abc&limit=101&filter=(field1:eq=foo", nil)
+
+		qp := NewQueryParams().
+			PrepareFilters(c, []string{"field1:eq"}, `"foo.bar"`).
+			PrepareSorts(c, []string{"email"}, `"foo.bar"`).
+			PreparePagination(c)
+
+		response, ok := qp.Validate(c)
+		assert.False(t, ok)
+		assert.Equal(t, 400, response.Code)
+		assert.Equal(t, ErrInvalidQueryParams.Error(), response.Message)
+		assert.Equal(t, QueryParamsErrorData{
+			Errors: []QueryParamError{
+				{"filter", ErrInvalidFilterExpression.Error()},
+				{"sort:email", "sort direction must be asc or desc"},
+				{"offset", "offset must be a non-negative integer"},
+				{"limit", "limit must not exceed 100"},
+				{"field1", "unknown query parameter"},
+				{"field1:like", "filter is not allowed"},
+				{"sort:name", "sort is not allowed"},
+			},
+			AllowedFilters: []string{"field1:eq"},
+			AllowedSorts:   []string{"email"},
+		}, response.Data)
+	})
+
+	t.Run("TypedSorts", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/?sort:status=asc&sort:ctime=desc&sort:name=asc", nil)
+
+		qp := NewQueryParams().
+			PrepareSorts(c, []string{"status", "ctime", "name"}, `"foo.bar"`, models.SortFields{
+				"status": {Typ: models.SORT_TYPE_ENUM},
+				"ctime":  {Typ: models.SORT_TYPE_DATE, Nulls: models.SORT_NULLS_LAST},
+				"name":   {Collation: "C"},
+			})
+
+		assert.Equal(t, []string{`"foo.bar".status ASC`, `"foo.bar".ctime DESC NULLS LAST`, `LOWER("foo.bar".name) COLLATE "C" ASC`}, qp.OrderBy)
+	})
 }
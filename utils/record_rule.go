@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"system.buon18.com/m/models"
+	"system.buon18.com/m/models/setting"
+
+	"github.com/nullism/bqb"
+)
+
+const (
+	RECORD_RULE_USER_ID = "{user.id}"
+	RECORD_RULE_ROLE_ID = "{role.id}"
+)
+
+// -- Permission a role must grant for its record rules of a resource to apply
+var RECORD_RULE_PERMISSIONS = map[string]string{
+	"setting.customer":         PREDEFINED_PERMISSIONS.SETTING_CUSTOMERS.VIEW,
+	"sales.quotation":          PREDEFINED_PERMISSIONS.SALES_QUOTATIONS.VIEW,
+	"sales.order":              PREDEFINED_PERMISSIONS.SALES_ORDERS.VIEW,
+	"accounting.journal_entry": PREDEFINED_PERMISSIONS.ACCOUNTING_JOURNAL_ENTRIES.VIEW,
+}
+
+// RecordRuleCondition builds the condition that a record of resource must match
+// to be accessible. Rules of the same resource are OR'ed, a resource without any
+// rule is not restricted and nil is returned. Only the roles that grant the
+// permission of resource count, a granting role without any rule for resource
+// lifts the restriction and the rules of the other roles don't widen access.
+// Without a granting role, e.g. for a resource embedded in another, every rule
+// of resource applies.
+func (ctx *CtxW) RecordRuleCondition(resource string, prefix string) *bqb.Query {
+	grantingRoleIds := make([]uint, 0)
+	for _, role := range ctx.Roles {
+		if ctx.roleGrants(role.Id, resource) {
+			grantingRoleIds = append(grantingRoleIds, role.Id)
+		}
+	}
+	for _, roleId := range grantingRoleIds {
+		if !ctx.hasRecordRule(roleId, resource) {
+			return nil
+		}
+	}
+
+	condition := bqb.Q()
+	for _, recordRule := range ctx.RecordRules {
+		if recordRule.Resource != resource {
+			continue
+		}
+		if len(grantingRoleIds) > 0 && !slices.Contains(grantingRoleIds, recordRule.SettingRoleId) {
+			continue
+		}
+		condition.Or("?", ctx.recordRuleIntoBqb(recordRule, prefix))
+	}
+
+	if condition.Empty() {
+		return nil
+	}
+	return bqb.New("(?)", condition)
+}
+
+// RecordRuleIntoBqb restricts a query that already has a WHERE clause.
+func (ctx *CtxW) RecordRuleIntoBqb(bqbQuery *bqb.Query, resource string, prefix string) {
+	if condition := ctx.RecordRuleCondition(resource, prefix); condition != nil {
+		bqbQuery.Space("AND ?", condition)
+	}
+}
+
+// roleGrants reports whether the effective permissions of the role contain the
+// permission of resource, every role grants a resource without permission.
+func (ctx *CtxW) roleGrants(roleId uint, resource string) bool {
+	permission, ok := RECORD_RULE_PERMISSIONS[resource]
+	if !ok {
+		return true
+	}
+	return ContainsString(ctx.RolePermissions[roleId], permission)
+}
+
+func (ctx *CtxW) hasRecordRule(roleId uint, resource string) bool {
+	for _, recordRule := range ctx.RecordRules {
+		if recordRule.SettingRoleId == roleId && recordRule.Resource == resource {
+			return true
+		}
+	}
+	return false
+}
+
+func (ctx *CtxW) recordRuleIntoBqb(recordRule setting.SettingRecordRule, prefix string) *bqb.Query {
+	// -- Rules are validated on creation, a rule that is not valid anymore denies access
+	operator, ok := MAPPED_FILTER_OPERATORS_TO_SQL[recordRule.Operator]
+	if !ok || !ContainsString(models.VALID_RECORD_RULE_OPERATORS, recordRule.Operator) || !ContainsString(models.VALID_RECORD_RULE_RESOURCE_FIELDS[recordRule.Resource], recordRule.Field) {
+		return bqb.New("FALSE")
+	}
+
+	field := fmt.Sprintf("%s.%s", prefix, recordRule.Field)
+	if recordRule.Operator == "in" || recordRule.Operator == "nin" {
+		values := make([]interface{}, 0)
+		for _, value := range strings.Split(recordRule.Value, ",") {
+			values = append(values, ctx.recordRuleValue(recordRule, value))
+		}
+		return bqb.New(fmt.Sprintf("%s %s (?)", field, operator), values)
+	}
+
+	return bqb.New(fmt.Sprintf("%s %s ?", field, operator), ctx.recordRuleValue(recordRule, recordRule.Value))
+}
+
+func (ctx *CtxW) recordRuleValue(recordRule setting.SettingRecordRule, value string) string {
+	// -- {role.id} refers to the role the rule belongs to
+	roleId := recordRule.SettingRoleId
+	if roleId == 0 {
+		roleId = ctx.Role.Id
+	}
+
+	value = strings.TrimSpace(value)
+	value = strings.ReplaceAll(value, RECORD_RULE_USER_ID, IntToStr(int(ctx.User.Id)))
+	value = strings.ReplaceAll(value, RECORD_RULE_ROLE_ID, IntToStr(int(roleId)))
+	return value
+}
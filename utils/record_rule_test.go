@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"testing"
+
+	"system.buon18.com/m/models/setting"
+
+	"github.com/nullism/bqb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordRule(t *testing.T) {
+	ctx := CtxW{
+		User: setting.SettingUser{Id: 1001},
+		Role: setting.SettingRole{Id: 1002},
+		RecordRules: []setting.SettingRecordRule{
+			{Resource: "sales.quotation", Field: "cid", Operator: "eq", Value: RECORD_RULE_USER_ID},
+			{Resource: "sales.quotation", Field: "status", Operator: "in", Value: "quotation, quotation_sent"},
+			{Resource: "setting.customer", Field: "mid", Operator: "ne", Value: "1"},
+		},
+	}
+
+	t.Run("Unrestricted", func(t *testing.T) {
+		assert.Nil(t, ctx.RecordRuleCondition("sales.order", `"sales.order"`))
+
+		bqbQuery := bqb.New(`SELECT * FROM "sales.order" WHERE id = ?`, "1")
+		ctx.RecordRuleIntoBqb(bqbQuery, "sales.order", `"sales.order"`)
+
+		query, _, err := bqbQuery.ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT * FROM "sales.order" WHERE id = $1`, query)
+	})
+
+	t.Run("RecordRuleIntoBqb", func(t *testing.T) {
+		bqbQuery := bqb.New(`SELECT * FROM "sales.quotation" WHERE id = ?`, "1")
+		ctx.RecordRuleIntoBqb(bqbQuery, "sales.quotation", `"sales.quotation"`)
+
+		query, params, err := bqbQuery.ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT * FROM "sales.quotation" WHERE id = $1 AND ("sales.quotation".cid = $2 OR "sales.quotation".status IN ($3,$4))`, query)
+		assert.Equal(t, []interface{}{"1", "1001", "quotation", "quotation_sent"}, params)
+	})
+
+	t.Run("InvalidRuleDeniesAccess", func(t *testing.T) {
+		ctx := CtxW{
+			RecordRules: []setting.SettingRecordRule{
+				{Resource: "sales.quotation", Field: "name; DROP TABLE", Operator: "eq", Value: "1"},
+				{Resource: "sales.quotation", Field: "cid", Operator: "like", Value: "1"},
+			},
+		}
+
+		query, _, err := ctx.RecordRuleCondition("sales.quotation", `"sales.quotation"`).ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `(FALSE OR FALSE)`, query)
+	})
+
+	t.Run("Placeholders", func(t *testing.T) {
+		ctx := CtxW{
+			User: setting.SettingUser{Id: 1001},
+			Role: setting.SettingRole{Id: 1002},
+			RecordRules: []setting.SettingRecordRule{
+				{Resource: "sales.order", Field: "cid", Operator: "nin", Value: "{user.id},{role.id}"},
+			},
+		}
+
+		query, params, err := ctx.RecordRuleCondition("sales.order", `"limited_orders"`).ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `("limited_orders".cid NOT IN ($1,$2))`, query)
+		assert.Equal(t, []interface{}{"1001", "1002"}, params)
+	})
+
+	t.Run("MultipleRoles", func(t *testing.T) {
+		ctx := CtxW{
+			User:  setting.SettingUser{Id: 1001},
+			Role:  setting.SettingRole{Id: 1002},
+			Roles: []setting.SettingRole{{Id: 1002}, {Id: 1003}, {Id: 1004}},
+			RolePermissions: map[uint][]string{
+				1002: {PREDEFINED_PERMISSIONS.SALES_ORDERS.VIEW, PREDEFINED_PERMISSIONS.SALES_QUOTATIONS.VIEW},
+				1003: {PREDEFINED_PERMISSIONS.SALES_ORDERS.VIEW},
+				1004: {PREDEFINED_PERMISSIONS.ACCOUNTING_ACCOUNTS.VIEW},
+			},
+			RecordRules: []setting.SettingRecordRule{
+				{SettingRoleId: 1002, Resource: "sales.order", Field: "cid", Operator: "eq", Value: "{user.id}"},
+				{SettingRoleId: 1003, Resource: "sales.order", Field: "mid", Operator: "eq", Value: "{role.id}"},
+				{SettingRoleId: 1002, Resource: "sales.quotation", Field: "cid", Operator: "eq", Value: "{user.id}"},
+				{SettingRoleId: 1004, Resource: "sales.quotation", Field: "status", Operator: "eq", Value: "quotation"},
+			},
+		}
+
+		query, params, err := ctx.RecordRuleCondition("sales.order", `"sales.order"`).ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `("sales.order".cid = $1 OR "sales.order".mid = $2)`, query)
+		assert.Equal(t, []interface{}{"1001", "1003"}, params)
+
+		// -- Roles 1003 and 1004 don't grant quotations, they neither lift nor widen the rule of 1002
+		query, params, err = ctx.RecordRuleCondition("sales.quotation", `"sales.quotation"`).ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `("sales.quotation".cid = $1)`, query)
+		assert.Equal(t, []interface{}{"1001"}, params)
+
+		// -- A granting role without any rule lifts the restriction
+		ctx.RolePermissions[1003] = append(ctx.RolePermissions[1003], PREDEFINED_PERMISSIONS.SALES_QUOTATIONS.VIEW)
+		assert.Nil(t, ctx.RecordRuleCondition("sales.quotation", `"sales.quotation"`))
+	})
+}
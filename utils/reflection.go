@@ -7,6 +7,9 @@ import (
 func IsAllFieldsNil(v interface{}) bool {
 	val := reflect.ValueOf(v).Elem()
 	for i := 0; i < val.NumField(); i++ {
+		if val.Type().Field(i).Anonymous {
+			continue
+		}
 		if !val.Field(i).IsNil() {
 			return false
 		}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"system.buon18.com/m/models"
+)
+
+// prefixResource returns the resource of a table prefix, e.g. `"sales.quotation"`.
+func prefixResource(prefix string) string {
+	return strings.Trim(prefix, `"`)
+}
+
+// FieldExpression resolves field of resource into an expression on prefix, the
+// field is either a column, a computed field or a relation path like
+// customer.email that is resolved with a subquery on the related record.
+func FieldExpression(resource string, prefix string, field string) (string, bool) {
+	relationName, relationField, isPath := strings.Cut(field, ".")
+	if !isPath {
+		if computed, ok := models.COMPUTED_FIELDS[resource][field]; ok {
+			return fmt.Sprintf(computed, prefix), true
+		}
+		return fmt.Sprintf("%s.%s", prefix, field), true
+	}
+
+	relation, ok := models.RELATIONS[resource][relationName]
+	if !ok {
+		return "", false
+	}
+
+	table := fmt.Sprintf(`"%s"`, relation.Resource)
+	expression, ok := FieldExpression(relation.Resource, table, relationField)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf(`(SELECT %s FROM %s WHERE %s.id = %s.%s)`, expression, table, table, prefix, relation.Column), true
+}
+
+// isComputedField reports whether field of resource, or the field its relation
+// path leads to, is a computed field.
+func isComputedField(resource string, field string) bool {
+	relationName, relationField, isPath := strings.Cut(field, ".")
+	if !isPath {
+		_, ok := models.COMPUTED_FIELDS[resource][field]
+		return ok
+	}
+
+	relation, ok := models.RELATIONS[resource][relationName]
+	return ok && isComputedField(relation.Resource, relationField)
+}
+
+// relationFilter builds the filter on field of resource, a filter on a relation
+// path is checked with EXISTS on the related record.
+func relationFilter(resource string, prefix string, field string, operator string, value string) (FilterNode, bool) {
+	relationName, relationField, isPath := strings.Cut(field, ".")
+	if !isPath {
+		expression, ok := FieldExpression(resource, prefix, field)
+		if !ok {
+			return FilterNode{}, false
+		}
+
+		if operator == "like" {
+			value = "%" + value + "%"
+		}
+		return FilterNode{Filter: FilterValue{expression, operator, value}}, true
+	}
+
+	relation, ok := models.RELATIONS[resource][relationName]
+	if !ok {
+		return FilterNode{}, false
+	}
+
+	table := fmt.Sprintf(`"%s"`, relation.Resource)
+	child, ok := relationFilter(relation.Resource, table, relationField, operator, value)
+	if !ok {
+		return FilterNode{}, false
+	}
+	return FilterNode{
+		Operator: FILTER_NODE_EXISTS,
+		Subquery: fmt.Sprintf(`EXISTS (SELECT 1 FROM %s WHERE %s.id = %s.%s AND ?)`, table, table, prefix, relation.Column),
+		Children: []FilterNode{child},
+	}, true
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nullism/bqb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelation(t *testing.T) {
+	t.Run("FieldExpression", func(t *testing.T) {
+		expression, ok := FieldExpression("sales.quotation", `"limited_quotations"`, "customer.email")
+		assert.True(t, ok)
+		assert.Equal(t, `(SELECT "setting.customer".email FROM "setting.customer" WHERE "setting.customer".id = "limited_quotations".setting_customer_id)`, expression)
+
+		expression, ok = FieldExpression("sales.order", `"sales.order"`, "quotation.customer.fullname")
+		assert.True(t, ok)
+		assert.Equal(t, `(SELECT (SELECT "setting.customer".fullname FROM "setting.customer" WHERE "setting.customer".id = "sales.quotation".setting_customer_id) FROM "sales.quotation" WHERE "sales.quotation".id = "sales.order".sales_quotation_id)`, expression)
+
+		_, ok = FieldExpression("sales.quotation", `"sales.quotation"`, "order.name")
+		assert.False(t, ok)
+	})
+
+	t.Run("SortExpression", func(t *testing.T) {
+		assert.Equal(t, `LOWER("sales.quotation".name)`, sortExpression("sales.quotation", `"sales.quotation"`, SortValue{Field: "name"}))
+		assert.Equal(t, `(COALESCE((SELECT SUM("sales.order_item".price - "sales.order_item".discount) FROM "sales.order_item" WHERE "sales.order_item".sales_quotation_id = "limited_quotations".id), 0) + "limited_quotations".amount_delivery - "limited_quotations".discount)`, sortExpression("sales.quotation", `"limited_quotations"`, SortValue{Field: "total_amount"}))
+		assert.True(t, isComputedField("sales.order", "quotation.total_amount"))
+		assert.False(t, isComputedField("sales.order", "quotation.name"))
+	})
+
+	t.Run("PrepareFilters", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		c.Request = httptest.NewRequest("GET", "/?name:like=Q&customer.email:like=jane&sort:customer.fullname=asc", nil)
+
+		qp := NewQueryParams().
+			PrepareFilters(c, []string{"name:like", "customer.email:like"}, `"sales.quotation"`).
+			PrepareSorts(c, []string{"customer.fullname"}, `"limited_quotations"`).
+			PreparePagination(c)
+
+		bqbQuery := bqb.New(`SELECT * FROM "sales.quotation"`)
+		qp.FilterIntoBqb(bqbQuery)
+
+		query, params, err := bqbQuery.ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT * FROM "sales.quotation" WHERE "sales.quotation".name LIKE $1 AND EXISTS (SELECT 1 FROM "setting.customer" WHERE "setting.customer".id = "sales.quotation".setting_customer_id AND "setting.customer".email LIKE $2)`, query)
+		assert.Equal(t, []interface{}{"%Q%", "%jane%"}, params)
+
+		assert.Equal(t, []string{`LOWER((SELECT "setting.customer".fullname FROM "setting.customer" WHERE "setting.customer".id = "limited_quotations".setting_customer_id)) ASC`}, qp.OrderBy)
+	})
+
+	t.Run("FilterExpression", func(t *testing.T) {
+		node, err := ParseFilterExpression("quotation.status:eq=sales_order|!quotation.customer.email:like=jane", []string{"quotation.status:eq", "quotation.customer.email:like"}, `"sales.order"`)
+		assert.NoError(t, err)
+
+		query, params, err := node.Condition().ToPgsql()
+		assert.NoError(t, err)
+		assert.Equal(t, `(EXISTS (SELECT 1 FROM "sales.quotation" WHERE "sales.quotation".id = "sales.order".sales_quotation_id AND "sales.quotation".status = $1) OR NOT (EXISTS (SELECT 1 FROM "sales.quotation" WHERE "sales.quotation".id = "sales.order".sales_quotation_id AND EXISTS (SELECT 1 FROM "setting.customer" WHERE "setting.customer".id = "sales.quotation".setting_customer_id AND "setting.customer".email LIKE $2))))`, query)
+		assert.Equal(t, []interface{}{"sales_order", "%jane%"}, params)
+	})
+}
@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"system.buon18.com/m/config"
+)
+
+// ResponseCacheEntry is a cached response along with its pagination headers.
+type ResponseCacheEntry struct {
+	Response   json.RawMessage `json:"response"`
+	Total      *int            `json:"total,omitempty"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	PrevCursor string          `json:"prev_cursor,omitempty"`
+	// -- The entry is stale afterwards, it's still served while it's refreshed
+	FreshUntil time.Time `json:"fresh_until"`
+}
+
+func (entry ResponseCacheEntry) IsFresh() bool {
+	return time.Now().Before(entry.FreshUntil)
+}
+
+type ResponseCacheStats struct {
+	Hits      int64 `json:"hits"`
+	StaleHits int64 `json:"stale_hits"`
+	Misses    int64 `json:"misses"`
+	Coalesced int64 `json:"coalesced"`
+	Entries   int   `json:"entries"`
+}
+
+type responseCacheItem struct {
+	key   string
+	entry ResponseCacheEntry
+}
+
+// ResponseFlight is a response being computed, the concurrent requests of the
+// same key wait for it instead of hitting the database.
+type ResponseFlight struct {
+	done  chan struct{}
+	entry *ResponseCacheEntry
+}
+
+// Wait returns the entry computed by the flight, false when the response
+// couldn't be cached or the request is canceled.
+func (flight *ResponseFlight) Wait(ctx context.Context) (ResponseCacheEntry, bool) {
+	select {
+	case <-flight.done:
+		if flight.entry == nil {
+			return ResponseCacheEntry{}, false
+		}
+		return *flight.entry, true
+	case <-ctx.Done():
+		return ResponseCacheEntry{}, false
+	}
+}
+
+// ResponseCache is the in-process tier of the response cache, a bounded LRU
+// used in front of Valkey or alone when Valkey is unavailable. It also keeps
+// the versions of the cache tags when they can't be shared through Valkey and
+// coalesces the concurrent requests of a key, see Acquire.
+type ResponseCache struct {
+	ttl      time.Duration
+	staleTtl time.Duration
+	capacity int
+
+	lock sync.Mutex
+	// -- The front is the most recently used entry
+	lru     *list.List
+	entries map[string]*list.Element
+	tags    map[string]uint64
+	flights map[string]*ResponseFlight
+
+	hits      atomic.Int64
+	staleHits atomic.Int64
+	misses    atomic.Int64
+	coalesced atomic.Int64
+}
+
+func NewResponseCache(ttl time.Duration, staleTtl time.Duration, capacity int) *ResponseCache {
+	return &ResponseCache{
+		ttl:      ttl,
+		staleTtl: staleTtl,
+		capacity: capacity,
+		lru:      list.New(),
+		entries:  map[string]*list.Element{},
+		tags:     map[string]uint64{},
+		flights:  map[string]*ResponseFlight{},
+	}
+}
+
+// Enabled reports whether responses are cached at all, in process or in Valkey.
+func (cache *ResponseCache) Enabled() bool {
+	return cache.ttl > 0
+}
+
+// NewEntry returns an entry that is fresh for the duration of the cache.
+func (cache *ResponseCache) NewEntry() ResponseCacheEntry {
+	return ResponseCacheEntry{FreshUntil: time.Now().Add(cache.ttl)}
+}
+
+// ExpiresAt is when entry can't be served anymore, even stale.
+func (cache *ResponseCache) ExpiresAt(entry ResponseCacheEntry) time.Time {
+	return entry.FreshUntil.Add(cache.staleTtl)
+}
+
+func (cache *ResponseCache) Get(key string) (ResponseCacheEntry, bool) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	element, ok := cache.entries[key]
+	if !ok {
+		return ResponseCacheEntry{}, false
+	}
+
+	item := element.Value.(*responseCacheItem)
+	if time.Now().After(cache.ExpiresAt(item.entry)) {
+		cache.lru.Remove(element)
+		delete(cache.entries, key)
+		return ResponseCacheEntry{}, false
+	}
+
+	cache.lru.MoveToFront(element)
+	return item.entry, true
+}
+
+// Set stores the entry, the least recently used entries are evicted beyond
+// the capacity.
+func (cache *ResponseCache) Set(key string, entry ResponseCacheEntry) {
+	if cache.capacity <= 0 {
+		return
+	}
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	if element, ok := cache.entries[key]; ok {
+		element.Value.(*responseCacheItem).entry = entry
+		cache.lru.MoveToFront(element)
+		return
+	}
+
+	cache.entries[key] = cache.lru.PushFront(&responseCacheItem{key: key, entry: entry})
+	for cache.lru.Len() > cache.capacity {
+		element := cache.lru.Back()
+		cache.lru.Remove(element)
+		delete(cache.entries, element.Value.(*responseCacheItem).key)
+	}
+}
+
+// TagsVersion returns the local versions of tags, e.g. "3.0.1".
+func (cache *ResponseCache) TagsVersion(tags []string) string {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	versions := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		versions = append(versions, fmt.Sprintf("%d", cache.tags[tag]))
+	}
+	return strings.Join(versions, ".")
+}
+
+// Purge bumps the local versions of tags, the entries keyed by the previous
+// versions are never read again and get evicted.
+func (cache *ResponseCache) Purge(tags ...string) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	for _, tag := range tags {
+		cache.tags[tag]++
+	}
+}
+
+// Acquire returns the flight of key, the first request of a key leads the
+// flight and must Release it once its response is computed.
+func (cache *ResponseCache) Acquire(key string) (*ResponseFlight, bool) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	if flight, ok := cache.flights[key]; ok {
+		return flight, false
+	}
+
+	flight := &ResponseFlight{done: make(chan struct{})}
+	cache.flights[key] = flight
+	return flight, true
+}
+
+// Release ends the flight of key with the computed entry, nil when the
+// response couldn't be cached.
+func (cache *ResponseCache) Release(key string, flight *ResponseFlight, entry *ResponseCacheEntry) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	flight.entry = entry
+	close(flight.done)
+	delete(cache.flights, key)
+}
+
+func (cache *ResponseCache) Hit(stale bool) {
+	if stale {
+		cache.staleHits.Add(1)
+		return
+	}
+	cache.hits.Add(1)
+}
+
+func (cache *ResponseCache) Miss() {
+	cache.misses.Add(1)
+}
+
+func (cache *ResponseCache) Coalesce() {
+	cache.coalesced.Add(1)
+}
+
+func (cache *ResponseCache) Stats() ResponseCacheStats {
+	cache.lock.Lock()
+	entries := cache.lru.Len()
+	cache.lock.Unlock()
+
+	return ResponseCacheStats{
+		Hits:      cache.hits.Load(),
+		StaleHits: cache.staleHits.Load(),
+		Misses:    cache.misses.Load(),
+		Coalesced: cache.coalesced.Load(),
+		Entries:   entries,
+	}
+}
+
+var (
+	responseCache     *ResponseCache
+	responseCacheOnce sync.Once
+)
+
+func GetResponseCache() *ResponseCache {
+	responseCacheOnce.Do(func() {
+		config := config.GetConfigInstance()
+		responseCache = NewResponseCache(
+			time.Duration(config.CACHE_DURATION_SEC)*time.Second,
+			time.Duration(config.CACHE_STALE_SEC)*time.Second,
+			config.CACHE_LOCAL_ENTRIES,
+		)
+	})
+
+	return responseCache
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseCache(t *testing.T) {
+	t.Run("EvictLeastRecentlyUsed", func(t *testing.T) {
+		cache := NewResponseCache(time.Minute, time.Minute, 2)
+		cache.Set("a", cache.NewEntry())
+		cache.Set("b", cache.NewEntry())
+		_, ok := cache.Get("a")
+		assert.True(t, ok)
+		cache.Set("c", cache.NewEntry())
+
+		_, ok = cache.Get("b")
+		assert.False(t, ok)
+		_, ok = cache.Get("a")
+		assert.True(t, ok)
+		_, ok = cache.Get("c")
+		assert.True(t, ok)
+		assert.Equal(t, 2, cache.Stats().Entries)
+	})
+
+	t.Run("StaleEntry", func(t *testing.T) {
+		cache := NewResponseCache(time.Minute, time.Minute, 10)
+		cache.Set("stale", ResponseCacheEntry{FreshUntil: time.Now().Add(-30 * time.Second)})
+		cache.Set("expired", ResponseCacheEntry{FreshUntil: time.Now().Add(-2 * time.Minute)})
+
+		entry, ok := cache.Get("stale")
+		assert.True(t, ok)
+		assert.False(t, entry.IsFresh())
+		_, ok = cache.Get("expired")
+		assert.False(t, ok)
+	})
+
+	t.Run("TagsVersion", func(t *testing.T) {
+		cache := NewResponseCache(time.Minute, 0, 0)
+		assert.Equal(t, "0.0", cache.TagsVersion([]string{"sales.quotation", "setting.customer"}))
+		cache.Purge("setting.customer")
+		cache.Purge("setting.customer")
+		assert.Equal(t, "0.2", cache.TagsVersion([]string{"sales.quotation", "setting.customer"}))
+	})
+
+	t.Run("CoalesceFlight", func(t *testing.T) {
+		cache := NewResponseCache(time.Minute, 0, 0)
+		flight, leader := cache.Acquire("key")
+		assert.True(t, leader)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			follower, leader := cache.Acquire("key")
+			assert.False(t, leader)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				entry, ok := follower.Wait(context.Background())
+				assert.True(t, ok)
+				assert.Equal(t, `{"id":1}`, string(entry.Response))
+			}()
+		}
+
+		entry := cache.NewEntry()
+		entry.Response = []byte(`{"id":1}`)
+		cache.Release("key", flight, &entry)
+		wg.Wait()
+
+		_, leader = cache.Acquire("key")
+		assert.True(t, leader)
+	})
+
+	t.Run("UncacheableFlight", func(t *testing.T) {
+		cache := NewResponseCache(time.Minute, 0, 0)
+		flight, _ := cache.Acquire("key")
+		follower, _ := cache.Acquire("key")
+		cache.Release("key", flight, nil)
+
+		_, ok := follower.Wait(context.Background())
+		assert.False(t, ok)
+	})
+
+	t.Run("CanceledWait", func(t *testing.T) {
+		cache := NewResponseCache(time.Minute, 0, 0)
+		cache.Acquire("key")
+		follower, _ := cache.Acquire("key")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, ok := follower.Wait(ctx)
+		assert.False(t, ok)
+	})
+}
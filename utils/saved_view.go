@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// -- Saved view applied to a list request, see middlewares.SavedView
+const VIEW_ID_PARAM = "view_id"
+
+// ApplyView adds the filters, sorts and columns of a saved view to the query
+// of a list request. A filter of the request takes precedence over the same
+// filter of the view, any sort of the request replaces the sorts of the view
+// and the columns are the fields of the request unless it has fields.
+func ApplyView(query url.Values, filters map[string]string, sorts map[string]string, columns []string) url.Values {
+	query.Del(VIEW_ID_PARAM)
+
+	if len(columns) > 0 && !query.Has(FIELDS_PARAM) {
+		query.Set(FIELDS_PARAM, strings.Join(columns, ","))
+	}
+
+	for param, value := range filters {
+		if !query.Has(param) {
+			query.Set(param, value)
+		}
+	}
+
+	for param := range query {
+		if strings.HasPrefix(param, "sort:") {
+			return query
+		}
+	}
+	for field, direction := range sorts {
+		query.Set(fmt.Sprintf("sort:%s", field), direction)
+	}
+	return query
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyView(t *testing.T) {
+	filters := map[string]string{"status:eq": "draft", "date:gte": "2024-10-01"}
+	sorts := map[string]string{"date": "desc"}
+	columns := []string{"name", "status"}
+
+	t.Run("ViewOnly", func(t *testing.T) {
+		query, _ := url.ParseQuery("view_id=1000&limit=5")
+		assert.Equal(t, url.Values{
+			"limit":     {"5"},
+			"status:eq": {"draft"},
+			"date:gte":  {"2024-10-01"},
+			"sort:date": {"desc"},
+		}, ApplyView(query, filters, sorts, nil))
+	})
+
+	t.Run("Columns", func(t *testing.T) {
+		query, _ := url.ParseQuery("view_id=1000")
+		assert.Equal(t, "name,status", ApplyView(query, nil, nil, columns).Get(FIELDS_PARAM))
+
+		query, _ = url.ParseQuery("view_id=1000&fields=name")
+		assert.Equal(t, "name", ApplyView(query, nil, nil, columns).Get(FIELDS_PARAM))
+	})
+
+	t.Run("RequestTakesPrecedence", func(t *testing.T) {
+		query, _ := url.ParseQuery("view_id=1000&status:eq=posted&sort:name=asc&fields=name")
+		assert.Equal(t, url.Values{
+			"status:eq": {"posted"},
+			"date:gte":  {"2024-10-01"},
+			"sort:name": {"asc"},
+			"fields":    {"name"},
+		}, ApplyView(query, filters, sorts, []string{"name"}))
+	})
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// PrefixTsQuery turns the words of text into a tsquery matching records that
+// contain every word, the last word of an omnibox is usually incomplete so
+// every word matches as a prefix. An empty string is returned without words.
+func PrefixTsQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, len(words))
+	for index, word := range words {
+		terms[index] = fmt.Sprintf("%s:*", word)
+	}
+	return strings.Join(terms, " & ")
+}
@@ -0,0 +1,14 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixTsQuery(t *testing.T) {
+	assert.Equal(t, "jane:* & doe:*", PrefixTsQuery("Jane Doe"))
+	assert.Equal(t, "jd:* & dummy:* & data:* & com:*", PrefixTsQuery("jd@dummy-data.com"))
+	assert.Equal(t, "q:* & 1:*", PrefixTsQuery("'q' | !1 <->"))
+	assert.Equal(t, "", PrefixTsQuery(" & ! "))
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 
 	"system.buon18.com/m/models"
@@ -36,6 +37,10 @@ func ValidateStruct(v interface{}) (validationErrors []string, ok bool) {
 		return false
 	})
 
+	validate.RegisterValidation("setting_user_state", func(fl validator.FieldLevel) bool {
+		return ContainsString(models.VALID_SETTING_USER_STATES, fl.Field().String())
+	})
+
 	validate.RegisterValidation("sales_quotation_status", func(fl validator.FieldLevel) bool {
 		status := fl.Field().String()
 		for _, validStatus := range models.VALID_SALES_QUOTATION_STATUS {
@@ -76,6 +81,31 @@ func ValidateStruct(v interface{}) (validationErrors []string, ok bool) {
 		return false
 	})
 
+	validate.RegisterValidation("record_rule_resource", func(fl validator.FieldLevel) bool {
+		_, ok := models.VALID_RECORD_RULE_RESOURCE_FIELDS[fl.Field().String()]
+		return ok
+	})
+
+	validate.RegisterValidation("record_rule_field", func(fl validator.FieldLevel) bool {
+		resource := fl.Parent().FieldByName("Resource")
+		if !resource.IsValid() {
+			return false
+		}
+		return ContainsString(models.VALID_RECORD_RULE_RESOURCE_FIELDS[resource.String()], fl.Field().String())
+	})
+
+	validate.RegisterValidation("record_rule_operator", func(fl validator.FieldLevel) bool {
+		return ContainsString(models.VALID_RECORD_RULE_OPERATORS, fl.Field().String())
+	})
+
+	validate.RegisterValidation("saved_view_endpoint", func(fl validator.FieldLevel) bool {
+		return ContainsString(models.VALID_SAVED_VIEW_ENDPOINTS, fl.Field().String())
+	})
+
+	validate.RegisterValidation("sort_direction", func(fl validator.FieldLevel) bool {
+		return strings.EqualFold(fl.Field().String(), "asc") || strings.EqualFold(fl.Field().String(), "desc")
+	})
+
 	validate.RegisterValidation("phone", func(fl validator.FieldLevel) bool {
 		// Define a regex pattern for phone numbers
 		phoneRegex := `^\+?[0-9]{10,15}$` // Example pattern: allows international numbers starting with + and 10-15 digits
@@ -105,6 +135,8 @@ func ValidateStruct(v interface{}) (validationErrors []string, ok bool) {
 				validationErrors = append(validationErrors, fmt.Sprintf("%s must be greater than or equal to %s", jsonFieldName(e.Namespace()), e.Param()))
 			case "gender":
 				validationErrors = append(validationErrors, fmt.Sprintf("%s must be one of %s", jsonFieldName(e.Namespace()), models.VALID_GENDER_TYPES))
+			case "setting_user_state":
+				validationErrors = append(validationErrors, fmt.Sprintf("%s must be one of %s", jsonFieldName(e.Namespace()), models.VALID_SETTING_USER_STATES))
 			case "sales_quotation_status":
 				validationErrors = append(validationErrors, fmt.Sprintf("%s must be one of %s", jsonFieldName(e.Namespace()), models.VALID_SALES_QUOTATION_STATUS))
 			case "accounting_account_typ":
@@ -113,6 +145,21 @@ func ValidateStruct(v interface{}) (validationErrors []string, ok bool) {
 				validationErrors = append(validationErrors, fmt.Sprintf("%s must be one of %s", jsonFieldName(e.Namespace()), models.VALID_ACCOUNTING_JOURNAL_TYPES))
 			case "accounting_journal_entry_typ":
 				validationErrors = append(validationErrors, fmt.Sprintf("%s must be one of %s", jsonFieldName(e.Namespace()), models.VALID_ACCOUNTING_JOURNAL_ENTRY_TYPES))
+			case "record_rule_resource":
+				resources := make([]string, 0)
+				for resource := range models.VALID_RECORD_RULE_RESOURCE_FIELDS {
+					resources = append(resources, resource)
+				}
+				sort.Strings(resources)
+				validationErrors = append(validationErrors, fmt.Sprintf("%s must be one of %s", jsonFieldName(e.Namespace()), resources))
+			case "record_rule_field":
+				validationErrors = append(validationErrors, fmt.Sprintf("%s is not allowed for the resource", jsonFieldName(e.Namespace())))
+			case "record_rule_operator":
+				validationErrors = append(validationErrors, fmt.Sprintf("%s must be one of %s", jsonFieldName(e.Namespace()), models.VALID_RECORD_RULE_OPERATORS))
+			case "saved_view_endpoint":
+				validationErrors = append(validationErrors, fmt.Sprintf("%s must be one of %s", jsonFieldName(e.Namespace()), models.VALID_SAVED_VIEW_ENDPOINTS))
+			case "sort_direction":
+				validationErrors = append(validationErrors, fmt.Sprintf("%s must be asc or desc", jsonFieldName(e.Namespace())))
 			case "phone":
 				validationErrors = append(validationErrors, fmt.Sprintf("%s is not a valid phone number", jsonFieldName(e.Namespace())))
 			case "json":